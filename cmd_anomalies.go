@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func anomaliesCmd(args []string) int {
+	policy := vectorclocks.DefaultAnomalyPolicy
+	fs := flag.NewFlagSet("anomalies", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runID := fs.String("run", "", "run to check (defaults to the latest)")
+	method := fs.String("method", string(policy.Method), "spread measure: mad or stddev")
+	k := fs.Float64("k", policy.K, "number of MADs or standard deviations flagged as anomalous")
+	window := fs.Int("window", policy.Window, "number of earlier runs forming the history")
+	minRuns := fs.Int("min-runs", policy.MinRuns, "earlier runs a step needs before it is judged")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *runID == "" {
+		if *runID, err = a.LatestRunID(); err != nil {
+			return fail(err)
+		}
+	}
+	anomalies, err := a.Anomalies(*runID, vectorclocks.AnomalyPolicy{
+		Method:  vectorclocks.AnomalyMethod(*method),
+		K:       *k,
+		Window:  *window,
+		MinRuns: *minRuns,
+	})
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteAnomalies(os.Stdout, anomalies); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func archiveCmd(args []string) int {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to compact")
+	before := fs.String("before", "", "archive the runs started before this date (YYYY-MM-DD)")
+	to := fs.String("to", "", "directory, s3://bucket/prefix or gs://bucket/prefix to write the archived runs to")
+	fs.Parse(args)
+
+	if *before == "" || *to == "" {
+		fmt.Fprintln(os.Stderr, "usage: archive --before YYYY-MM-DD --to s3://bucket/prefix")
+		return 2
+	}
+	cutoff, err := time.Parse("2006-01-02", *before)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid --before: %v\n", err)
+		return 2
+	}
+	store, err := vectorclocks.OpenObjectStore(*to)
+	if err != nil {
+		return fail(err)
+	}
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	archived, err := a.ArchiveBefore(context.Background(), cutoff, store)
+	for _, r := range archived {
+		fmt.Printf("%s: %d steps -> %s\n", r.RunID, r.Steps, r.Location)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Printf("archived %d runs\n", len(archived))
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func backgroundCmd(args []string) int {
+	fs := flag.NewFlagSet("background", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runID := fs.String("run", "", "run to analyse (defaults to the latest)")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *runID == "" {
+		if *runID, err = a.LatestRunID(); err != nil {
+			return fail(err)
+		}
+	}
+	r, err := a.BackgroundOverhead(*runID)
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteBackgroundOverhead(os.Stdout, r); err != nil {
+		return fail(err)
+	}
+	return 0
+}
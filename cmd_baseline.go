@@ -0,0 +1,27 @@
+package main
+
+import "flag"
+
+func baselineCmd(args []string) int {
+	fs := flag.NewFlagSet("baseline", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	out := fs.String("out", "baseline.json", "baseline file to write")
+	runs := fs.Int("runs", 20, "number of recent runs the bands are derived from")
+	tolerance := fs.Float64("tolerance", cfg.Threshold, "percentage added to the p95 to form the upper bound of each band")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	f, err := a.BuildBaselineFile(*runs, *tolerance)
+	if err != nil {
+		return fail(err)
+	}
+	if err := writeFile(*out, f.Write); err != nil {
+		return fail(err)
+	}
+	return 0
+}
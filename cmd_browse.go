@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func browseCmd(args []string) int {
+	fs := flag.NewFlagSet("browse", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runs := fs.Int("runs", 20, "number of recent runs to list")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	b := &browser{agent: a, in: bufio.NewScanner(os.Stdin), out: os.Stdout, byDuration: true}
+	if err := b.runs(*runs); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// browser is an interactive terminal view of the database: recent runs,
+// the scenarios of a run and the steps of a scenario. Each screen is a
+// numbered list; entering a number drills in.
+type browser struct {
+	agent      *vectorclocks.VectorClockAgent
+	in         *bufio.Scanner
+	out        io.Writer
+	byDuration bool
+}
+
+// errQuit unwinds all screens when the user quits.
+var errQuit = errors.New("quit")
+
+// entry is one row of a screen.
+type entry struct {
+	name     string
+	duration time.Duration
+	detail   string
+}
+
+func (b *browser) runs(n int) error {
+	runIDs, err := b.agent.RecentRuns(n)
+	if err != nil {
+		return err
+	}
+	timings := make(map[string]vectorclocks.RunTimings, len(runIDs))
+	entries := make([]entry, 0, len(runIDs))
+	for _, runID := range runIDs {
+		rt, err := b.agent.RunTimings(runID)
+		if err != nil {
+			return err
+		}
+		timings[runID] = rt
+		entries = append(entries, entry{name: runID, duration: rt.Total, detail: fmt.Sprintf("%d scenarios", len(rt.Scenarios))})
+	}
+
+	err = b.screen("Runs", entries, false, func(e entry) error {
+		return b.scenarios(timings[e.name])
+	})
+	if err == errQuit {
+		return nil
+	}
+	return err
+}
+
+func (b *browser) scenarios(rt vectorclocks.RunTimings) error {
+	steps := make(map[string]int)
+	for key := range rt.Steps {
+		steps[key.Scenario]++
+	}
+	entries := make([]entry, 0, len(rt.Scenarios))
+	for name, d := range rt.Scenarios {
+		entries = append(entries, entry{name: name, duration: d, detail: fmt.Sprintf("%d steps", steps[name])})
+	}
+	return b.screen("Run "+rt.RunID, entries, true, func(e entry) error {
+		return b.steps(rt, e.name)
+	})
+}
+
+func (b *browser) steps(rt vectorclocks.RunTimings, scenario string) error {
+	var entries []entry
+	for key, d := range rt.Steps {
+		if key.Scenario == scenario {
+			entries = append(entries, entry{name: key.Step, duration: d})
+		}
+	}
+	return b.screen(rt.RunID+" / "+scenario, entries, true, nil)
+}
+
+// screen shows entries until the user goes back. open is called with the
+// chosen entry; a nil open makes the entries leaves. Sortable screens
+// toggle between duration and name order.
+func (b *browser) screen(title string, entries []entry, sortable bool, open func(entry) error) error {
+	for {
+		if sortable {
+			b.sort(entries)
+		}
+		fmt.Fprintf(b.out, "\n=== %s ===\n", title)
+		for i, e := range entries {
+			fmt.Fprintf(b.out, "%3d  %10s  %-12s %s\n", i+1, e.duration.Round(time.Millisecond), e.detail, e.name)
+		}
+		if len(entries) == 0 {
+			fmt.Fprintln(b.out, "  (nothing recorded)")
+		}
+
+		help := "b back, q quit"
+		if sortable {
+			help = "s sort, " + help
+		}
+		if open != nil {
+			help = "number to open, " + help
+		}
+		fmt.Fprintf(b.out, "%s > ", help)
+		if !b.in.Scan() {
+			return errQuit
+		}
+
+		switch input := strings.TrimSpace(b.in.Text()); input {
+		case "q":
+			return errQuit
+		case "b":
+			return nil
+		case "s":
+			b.byDuration = !b.byDuration
+		default:
+			i, err := strconv.Atoi(input)
+			if open == nil || err != nil || i < 1 || i > len(entries) {
+				fmt.Fprintf(b.out, "unknown choice %q\n", input)
+				continue
+			}
+			if err := open(entries[i-1]); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (b *browser) sort(entries []entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if b.byDuration && entries[i].duration != entries[j].duration {
+			return entries[i].duration > entries[j].duration
+		}
+		return entries[i].name < entries[j].name
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func collectCmd(args []string) int {
+	fs := flag.NewFlagSet("collect", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to store collected steps in")
+	addr := fs.String("addr", "localhost:9090", "address to listen on")
+	token := fs.String("token", cfg.CollectorToken, "bearer token agents must send")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+
+	srv := &http.Server{Addr: *addr, Handler: a.CollectorHandler(*token)}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("collecting into %s on http://%s\n", *dbPath, *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		a.Close()
+		return fail(err)
+	}
+	// Close writes the steps still queued.
+	if err := a.Close(); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func compareCmd(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database holding both runs")
+	baseDB := fs.String("base-db", "", "database holding the base run: a path, http(s) URL, s3:// or gs:// object, or postgres:// DSN (default: --db)")
+	headDB := fs.String("head-db", "", "database holding the head run (default: --db)")
+	baseRun := fs.String("base", "", "base run ID (default: latest run in the base database, or the one before the head run when both share a database)")
+	headRun := fs.String("head", "", "head run ID (default: latest run in the head database)")
+	baseLabel := fs.String("base-label", "", "without --base, take the latest base run labelled key or key=value, e.g. release=1.3")
+	headLabel := fs.String("head-label", "", "without --head, take the latest head run labelled key or key=value, e.g. release=1.4")
+	suite := fs.String("suite", "", "without --base and --head, compare the latest runs of this suite (run --suite)")
+	bySuite := fs.Bool("by-suite", false, "compare the latest run of every suite with the one before, suite by suite")
+	threshold := fs.Float64("threshold", cfg.Threshold, "percentage slowdown flagged as a regression")
+	format := fs.String("format", cfg.ReportFormat, "output format: text, markdown, github or teamcity annotations of the regressions, or html for a side-by-side waterfall")
+	out := fs.String("out", "waterfall.html", "HTML file to write with --format html")
+	flagName := fs.String("flag", "", "compare recent runs by this feature flag instead of two runs")
+	flagBase := fs.String("flag-base", "", "flag value of the base runs (default: runs without the flag)")
+	flagHead := fs.String("flag-head", "on", "flag value of the head runs")
+	runs := fs.Int("runs", 30, "number of recent runs searched with --flag, or per environment with --envs")
+	envs := fs.String("envs", "", "compare these comma-separated environments, such as dev,staging,prod-mirror, instead of two runs")
+	commit := fs.String("commit", "", "commit whose runs --envs compares (default: the latest commit run in every environment)")
+	spread := fs.Float64("spread", vectorclocks.DefaultEnvironmentSpread, "flag steps whose share of the run differs by this factor between environments")
+	envLabel := fs.String("env-label", "", "name --envs by this run label, such as env for runs labelled env=staging, instead of run --env")
+	fs.Parse(args)
+
+	if *flagName != "" {
+		return compareFlag(*dbPath, *flagName, *flagBase, *flagHead, *runs, *threshold, *format)
+	}
+	if *envs != "" {
+		return compareEnvironments(*dbPath, *envLabel, strings.Split(*envs, ","), *commit, *runs, *spread, *format)
+	}
+	if *bySuite {
+		return compareSuites(*dbPath, *threshold, *format)
+	}
+
+	if *baseDB == "" {
+		*baseDB = *dbPath
+	}
+	if *headDB == "" {
+		*headDB = *dbPath
+	}
+
+	skip := 0
+	if *baseDB == *headDB && *baseRun == "" {
+		skip = 1
+	}
+	basePath, cleanup, err := vectorclocks.FetchDatabase(context.Background(), *baseDB,
+		vectorclocks.WithTablePrefix(cfg.TablePrefix), vectorclocks.WithConnectionOptions(cfg.Connection))
+	if err != nil {
+		return fail(err)
+	}
+	defer cleanup()
+	*baseDB = basePath
+	if *baseLabel != "" && *baseRun == "" {
+		if *baseRun, err = latestLabeled(*baseDB, *baseLabel); err != nil {
+			return fail(err)
+		}
+	}
+	if *headLabel != "" && *headRun == "" {
+		if *headRun, err = latestLabeled(*headDB, *headLabel); err != nil {
+			return fail(err)
+		}
+	}
+	if *suite != "" && *baseRun == "" {
+		if *baseRun, err = suiteRun(*baseDB, *suite, skip); err != nil {
+			return fail(err)
+		}
+	}
+	if *suite != "" && *headRun == "" {
+		if *headRun, err = suiteRun(*headDB, *suite, 0); err != nil {
+			return fail(err)
+		}
+	}
+	if *format == "html" {
+		return compareWaterfalls(*baseDB, *baseRun, *headDB, *headRun, skip, *threshold, *out)
+	}
+
+	head, err := loadRun(*headDB, *headRun, 0)
+	if err != nil {
+		return fail(err)
+	}
+	base, err := loadRun(*baseDB, *baseRun, skip)
+	if err != nil {
+		return fail(err)
+	}
+
+	return writeComparison(vectorclocks.Compare(base, head, *threshold), *format)
+}
+
+// writeComparison prints c as text or markdown.
+func writeComparison(c vectorclocks.Comparison, format string) int {
+	write := vectorclocks.WriteComparison
+	switch format {
+	case "text":
+	case "markdown":
+		write = vectorclocks.WriteComparisonMarkdown
+	case vectorclocks.AnnotateGitHub, vectorclocks.AnnotateTeamCity:
+		write = func(w io.Writer, c vectorclocks.Comparison) error {
+			return vectorclocks.WriteCIAnnotations(w, format, vectorclocks.DeltaAnnotations(c.Regressions()))
+		}
+	default:
+		return fail(fmt.Errorf("unknown format %q (want text, markdown, github, teamcity or html)", format))
+	}
+	if err := write(os.Stdout, c); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// compareSuites compares the latest two runs of every suite in dbPath.
+func compareSuites(dbPath string, threshold float64, format string) int {
+	a, err := openAgent(dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	suites, err := a.Suites()
+	if err != nil {
+		return fail(err)
+	}
+	for _, s := range suites {
+		runs, err := a.SuiteRuns(s.Suite, 2)
+		if err != nil {
+			return fail(err)
+		}
+		if len(runs) < 2 {
+			continue
+		}
+		base, err := a.RunTimings(runs[1])
+		if err != nil {
+			return fail(err)
+		}
+		head, err := a.RunTimings(runs[0])
+		if err != nil {
+			return fail(err)
+		}
+		name := s.Suite
+		if name == "" {
+			name = "(none)"
+		}
+		if format == "markdown" {
+			fmt.Printf("### Suite %s\n\n", name)
+		} else if format == "text" {
+			fmt.Printf("=== Suite %s ===\n", name)
+		}
+		if code := writeComparison(vectorclocks.Compare(base, head, threshold), format); code != 0 {
+			return code
+		}
+	}
+	return 0
+}
+
+// suiteRun returns the latest run of suite in dbPath after skipping the
+// skip most recent ones.
+func suiteRun(dbPath, suite string, skip int) (string, error) {
+	a, err := openAgent(dbPath)
+	if err != nil {
+		return "", err
+	}
+	defer a.Close()
+
+	runs, err := a.SuiteRuns(suite, skip+1)
+	if err != nil {
+		return "", err
+	}
+	if len(runs) <= skip {
+		return "", fmt.Errorf("%s holds fewer than %d runs of suite %s", dbPath, skip+1, suite)
+	}
+	return runs[skip], nil
+}
+
+// compareFlag compares the recent runs of dbPath by a feature flag.
+func compareFlag(dbPath, flag, baseValue, headValue string, runs int, threshold float64, format string) int {
+	a, err := openAgent(dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	c, err := a.CompareFlag(flag, baseValue, headValue, runs, threshold)
+	if err != nil {
+		return fail(err)
+	}
+	return writeComparison(c, format)
+}
+
+// compareEnvironments compares the runs of a commit across environments,
+// named by the run label envLabel when set.
+func compareEnvironments(dbPath, envLabel string, envs []string, commit string, runs int, spread float64, format string) int {
+	write := vectorclocks.WriteEnvironmentComparison
+	switch format {
+	case "text":
+	case "markdown":
+		write = vectorclocks.WriteEnvironmentComparisonMarkdown
+	default:
+		return fail(fmt.Errorf("unknown format %q (want text or markdown with --envs)", format))
+	}
+
+	a, err := openAgent(dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	for i := range envs {
+		envs[i] = strings.TrimSpace(envs[i])
+	}
+	c, err := a.CompareEnvironmentsBy(envLabel, envs, commit, runs, spread)
+	if err != nil {
+		return fail(err)
+	}
+	if err := write(os.Stdout, c); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// compareWaterfalls writes the side-by-side step timelines of two runs.
+func compareWaterfalls(baseDB, baseRun, headDB, headRun string, skip int, threshold float64, out string) int {
+	head, err := loadWaterfall(headDB, headRun, 0)
+	if err != nil {
+		return fail(err)
+	}
+	base, err := loadWaterfall(baseDB, baseRun, skip)
+	if err != nil {
+		return fail(err)
+	}
+	if err := writeFile(out, vectorclocks.CompareWaterfalls(base, head, threshold).WriteHTML); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// loadRun reads the timings of runID from dbPath. Without a run ID it
+// takes the latest run, skipping the skip most recent ones.
+func loadRun(dbPath, runID string, skip int) (vectorclocks.RunTimings, error) {
+	a, err := openAgent(dbPath)
+	if err != nil {
+		return vectorclocks.RunTimings{}, err
+	}
+	defer a.Close()
+
+	if runID, err = resolveRun(a, dbPath, runID, skip); err != nil {
+		return vectorclocks.RunTimings{}, err
+	}
+	return a.RunTimings(runID)
+}
+
+// loadWaterfall is loadRun for step timelines.
+func loadWaterfall(dbPath, runID string, skip int) (vectorclocks.Waterfall, error) {
+	a, err := openAgent(dbPath, vectorclocks.WithAssetDir(cfg.Assets))
+	if err != nil {
+		return vectorclocks.Waterfall{}, err
+	}
+	defer a.Close()
+
+	if runID, err = resolveRun(a, dbPath, runID, skip); err != nil {
+		return vectorclocks.Waterfall{}, err
+	}
+	return a.Waterfall(runID)
+}
+
+// latestLabeled returns the latest run in dbPath labelled label.
+func latestLabeled(dbPath, label string) (string, error) {
+	a, err := openAgent(dbPath)
+	if err != nil {
+		return "", err
+	}
+	defer a.Close()
+
+	runs, err := a.RunsLabeled(label, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(runs) == 0 {
+		return "", fmt.Errorf("%s holds no run labelled %s", dbPath, label)
+	}
+	return runs[0], nil
+}
+
+// resolveRun returns runID, or without one the latest run after skipping
+// the skip most recent ones.
+func resolveRun(a *vectorclocks.VectorClockAgent, dbPath, runID string, skip int) (string, error) {
+	if runID != "" {
+		return runID, nil
+	}
+	runs, err := a.RecentRuns(skip + 1)
+	if err != nil {
+		return "", err
+	}
+	if len(runs) <= skip {
+		return "", fmt.Errorf("%s holds fewer than %d runs", dbPath, skip+1)
+	}
+	return runs[skip], nil
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// completion is registered here because its script lists commands.
+func init() {
+	commands["completion"] = command{"print a bash, zsh or fish completion script", completionCmd}
+}
+
+func completionCmd(args []string) int {
+	fs := flag.NewFlagSet("completion", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s completion bash|zsh|fish\n\nprints a completion script, e.g.\n  source <(%s completion bash)\n", os.Args[0], os.Args[0])
+	}
+	fs.Parse(args)
+
+	script, ok := completionScripts[fs.Arg(0)]
+	if !ok {
+		fs.Usage()
+		return 2
+	}
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf(script, strings.Join(names, " "))
+	return 0
+}
+
+// completionScripts complete subcommand names from the list they are
+// formatted with and flags by parsing the "-h" output of the subcommand,
+// so new flags never need a script change.
+var completionScripts = map[string]string{
+	"bash": `_vectorcolcks() {
+	local cur="${COMP_WORDS[COMP_CWORD]}"
+	if [ "$COMP_CWORD" -eq 1 ]; then
+		COMPREPLY=($(compgen -W "%[1]s" -- "$cur"))
+	elif [[ "$cur" == -* ]]; then
+		local flags=$("${COMP_WORDS[0]}" "${COMP_WORDS[1]}" -h 2>&1 | sed -n 's/^  -\([a-z0-9-]*\).*/--\1/p')
+		COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+	else
+		COMPREPLY=($(compgen -f -- "$cur"))
+	fi
+}
+complete -F _vectorcolcks vectorColcks
+`,
+	"zsh": `#compdef vectorColcks
+_vectorcolcks() {
+	if (( CURRENT == 2 )); then
+		compadd %[1]s
+	elif [[ "$PREFIX" == -* ]]; then
+		compadd -- $("$words[1]" "$words[2]" -h 2>&1 | sed -n 's/^  -\([a-z0-9-]*\).*/--\1/p')
+	else
+		_files
+	fi
+}
+compdef _vectorcolcks vectorColcks
+`,
+	"fish": `complete -c vectorColcks -f -n "__fish_use_subcommand" -a "%[1]s"
+complete -c vectorColcks -n "not __fish_use_subcommand" -a "(vectorColcks (commandline -opc)[2] -h 2>&1 | sed -n 's/^  -\([a-z0-9-]*\).*/--\1/p')"
+`,
+}
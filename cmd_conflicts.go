@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func conflictsCmd(args []string) int {
+	fs := flag.NewFlagSet("conflicts", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runID := fs.String("run", "", "run to analyse (default: the latest run)")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *runID == "" {
+		if *runID, err = a.LatestRunID(); err != nil {
+			return fail(err)
+		}
+	}
+	conflicts, err := a.Conflicts(*runID)
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteConflicts(os.Stdout, conflicts); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func coverageCmd(args []string) int {
+	fs := flag.NewFlagSet("coverage", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runID := fs.String("run", "", "run to check (default: the latest runs that recorded step definitions)")
+	runs := fs.Int("runs", 1, "number of recent runs to check; a definition is unused if none of them used it")
+	unused := fs.Bool("unused", false, "list only the unused step definitions, one per line")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	runIDs := []string{*runID}
+	if *runID == "" {
+		if runIDs, err = a.DefinitionRuns(*runs); err != nil {
+			return fail(err)
+		}
+		if len(runIDs) == 0 {
+			return fail(fmt.Errorf("no run recorded its step definitions; register them with agent.Step"))
+		}
+	}
+	coverage, err := a.DefinitionCoverage(runIDs...)
+	if err != nil {
+		return fail(err)
+	}
+	if *unused {
+		for _, u := range coverage {
+			if u.Unused() {
+				fmt.Println(u.Pattern)
+			}
+		}
+		return 0
+	}
+	if err := vectorclocks.WriteDefinitionCoverage(os.Stdout, coverage); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func etaCmd(args []string) int {
+	fs := flag.NewFlagSet("eta", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	concurrency := fs.Int("concurrency", cfg.Concurrency, "number of scenarios godog runs in parallel")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"features"}
+	}
+	p, err := a.PredictSuite(paths, *concurrency)
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WritePrediction(os.Stdout, p); err != nil {
+		return fail(err)
+	}
+	return 0
+}
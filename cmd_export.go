@@ -0,0 +1,121 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func exportCmd(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	format := fs.String("format", vectorclocks.FormatJSON, "output format: json, csv, ndjson, knapsack, junit, openmetrics, chrome-trace, allure for a directory of Allure results, sqlite for a single-run database file, or stats for a suite's aggregated step statistics")
+	out := fs.String("out", "", "file to write (default stdout), or directory with --format allure")
+	runID := fs.String("run", "", "only steps of this run (default with --format openmetrics or chrome-trace: the latest run)")
+	scenario := fs.String("scenario", "", "only steps of the scenario with this exact name")
+	feature := fs.String("feature", "", "only steps of this feature file, as stored in feature_uri")
+	tag := fs.String("tag", "", "only steps of scenarios with this tag, e.g. @smoke")
+	label := fs.String("label", "", "only steps of runs labelled with key or key=value")
+	suite := fs.String("suite", "", "only steps of runs of this suite (run --suite)")
+	runs := fs.Int("runs", max(cfg.Baselines.Runs, 20), "number of recent green runs aggregated with --format stats")
+	branch := fs.String("branch", cfg.Baselines.Branch, "only aggregate runs of this branch with --format stats")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *format == "sqlite" {
+		return archiveRun(a, *runID, *out)
+	}
+	if *format == vectorclocks.FormatStats {
+		return exportStats(a, *suite, vectorclocks.BaselineWindow{Runs: *runs, Branch: *branch}, *out)
+	}
+	if (*format == vectorclocks.FormatOpenMetrics || *format == vectorclocks.FormatChromeTrace) && *runID == "" {
+		if *runID, err = latestSuiteRun(a, *suite); err != nil {
+			return fail(err)
+		}
+	}
+
+	timings, err := a.Timings(vectorclocks.TimingFilter{
+		RunID:      *runID,
+		Scenario:   *scenario,
+		FeatureURI: *feature,
+		Tag:        *tag,
+		Label:      *label,
+		Suite:      *suite,
+	})
+	if err != nil {
+		return fail(err)
+	}
+	if *format == vectorclocks.FormatAllure {
+		if *out == "" {
+			return fail(fmt.Errorf("--format allure needs --out"))
+		}
+		if err := vectorclocks.WriteAllureResults(*out, timings); err != nil {
+			return fail(err)
+		}
+		return 0
+	}
+	render := func(w io.Writer) error {
+		return vectorclocks.WriteExport(w, *format, timings)
+	}
+	if *format == vectorclocks.FormatChromeTrace {
+		executions, err := a.Executions(*runID)
+		if err != nil {
+			return fail(err)
+		}
+		render = func(w io.Writer) error {
+			return vectorclocks.WriteChromeTrace(w, timings, executions)
+		}
+	}
+	if *out == "" {
+		err = render(os.Stdout)
+	} else {
+		err = writeFile(*out, render)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// exportStats writes the aggregated step statistics of suite as a stats
+// bundle.
+func exportStats(a *vectorclocks.VectorClockAgent, suite string, window vectorclocks.BaselineWindow, out string) int {
+	b, err := a.ExportStats(suite, window)
+	if err != nil {
+		return fail(err)
+	}
+	if out == "" {
+		err = b.Write(os.Stdout)
+	} else {
+		err = writeFile(out, b.Write)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// archiveRun writes one run as a standalone SQLite file.
+func archiveRun(a *vectorclocks.VectorClockAgent, runID, out string) int {
+	if out == "" {
+		return fail(fmt.Errorf("--format sqlite needs --out"))
+	}
+	if runID == "" {
+		var err error
+		if runID, err = a.LatestRunID(); err != nil {
+			return fail(err)
+		}
+	}
+	if err := a.WriteRunArchive(runID, out); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func failfastCmd(args []string) int {
+	fs := flag.NewFlagSet("failfast", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	limit := fs.Int("limit", 20, "number of recent runs to chart")
+	width := fs.Int("width", 40, "width of the longest bar")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	history, err := a.FailureHistory(*limit)
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteFailureChart(os.Stdout, history, *width); err != nil {
+		return fail(err)
+	}
+	return 0
+}
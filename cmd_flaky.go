@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func flakyCmd(args []string) int {
+	fs := flag.NewFlagSet("flaky", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runs := fs.Int("runs", 20, "number of recent runs to examine")
+	threshold := fs.Float64("threshold", cfg.FlakyThreshold, "minimum instability score (0-1) to report")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	flaky, err := a.FlakySteps(*runs, *threshold)
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteFlaky(os.Stdout, flaky); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func ganttCmd(args []string) int {
+	fs := flag.NewFlagSet("gantt", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runID := fs.String("run", "", "run to draw (defaults to the latest)")
+	out := fs.String("out", "gantt.html", "HTML file to write")
+	assetDir := fs.String("assets", cfg.Assets, "directory whose templates override the embedded ones")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath, vectorclocks.WithAssetDir(*assetDir))
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *runID == "" {
+		if *runID, err = a.LatestRunID(); err != nil {
+			return fail(err)
+		}
+	}
+	g, err := a.Gantt(*runID)
+	if err != nil {
+		return fail(err)
+	}
+	if err := writeFile(*out, g.WriteHTML); err != nil {
+		return fail(err)
+	}
+	return 0
+}
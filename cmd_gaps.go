@@ -0,0 +1,36 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func gapsCmd(args []string) int {
+	fs := flag.NewFlagSet("gaps", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runID := fs.String("run", "", "run to analyse (defaults to the latest)")
+	limit := fs.Int("limit", 20, "number of scenarios to list, the most untimed first (0 for all)")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *runID == "" {
+		if *runID, err = a.LatestRunID(); err != nil {
+			return fail(err)
+		}
+	}
+	r, err := a.Gaps(*runID)
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteGaps(os.Stdout, r, *limit); err != nil {
+		return fail(err)
+	}
+	return 0
+}
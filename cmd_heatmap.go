@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func heatmapCmd(args []string) int {
+	fs := flag.NewFlagSet("heatmap", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	format := fs.String("format", "html", "output format: html, json or csv")
+	out := fs.String("out", "", "file to write (default stdout)")
+	runID := fs.String("run", "", "only steps of this run (default: all runs)")
+	feature := fs.String("feature", "", "only steps of this feature file, as stored in feature_uri")
+	tag := fs.String("tag", "", "only steps of scenarios with this tag, e.g. @smoke")
+	label := fs.String("label", "", "only steps of runs labelled with key or key=value")
+	assetDir := fs.String("assets", cfg.Assets, "directory whose templates override the embedded ones")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath, vectorclocks.WithAssetDir(*assetDir))
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	h, err := a.Heatmap(vectorclocks.TimingFilter{
+		RunID:      *runID,
+		FeatureURI: *feature,
+		Tag:        *tag,
+		Label:      *label,
+	})
+	if err != nil {
+		return fail(err)
+	}
+
+	var render func(io.Writer) error
+	switch *format {
+	case "html":
+		render = h.WriteHTML
+	case vectorclocks.FormatJSON:
+		render = h.WriteJSON
+	case vectorclocks.FormatCSV:
+		render = h.WriteCSV
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q (want html, json or csv)\n", *format)
+		return 2
+	}
+	if *out == "" {
+		err = render(os.Stdout)
+	} else {
+		err = writeFile(*out, render)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func leaksCmd(args []string) int {
+	fs := flag.NewFlagSet("leaks", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runID := fs.String("run", "", "run to analyse (defaults to the latest)")
+	goroutines := fs.Int("goroutines", cfg.Leaks.Goroutines, "goroutines a scenario may leave behind")
+	fds := fs.Int("fds", cfg.Leaks.FDs, "open file descriptors a scenario may leave behind")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *runID == "" {
+		if *runID, err = a.LatestRunID(); err != nil {
+			return fail(err)
+		}
+	}
+	leaks, err := a.Leaks(*runID, vectorclocks.LeakPolicy{Goroutines: *goroutines, FDs: *fds})
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteLeaks(os.Stdout, leaks); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+func mergeCmd(args []string) int {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to merge into")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "usage: merge [--db merged.db] shard1.db shard2.db ...")
+		return 2
+	}
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	merged, err := a.Merge(fs.Args()...)
+	for _, m := range merged {
+		fmt.Println(m)
+	}
+	if err != nil {
+		return fail(err)
+	}
+	return 0
+}
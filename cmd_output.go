@@ -0,0 +1,39 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func outputCmd(args []string) int {
+	fs := flag.NewFlagSet("output", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runID := fs.String("run", "", "run ID (default: latest run)")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *runID == "" {
+		if *runID, err = a.LatestRunID(); err != nil {
+			return fail(err)
+		}
+	}
+	output, _, err := a.RunOutput(*runID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fail(fmt.Errorf("no output captured for run %s (run with --capture-output)", *runID))
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if _, err := os.Stdout.Write(output); err != nil {
+		return fail(err)
+	}
+	return 0
+}
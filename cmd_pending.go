@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func pendingCmd(args []string) int {
+	fs := flag.NewFlagSet("pending", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	steps, err := a.PendingSteps()
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WritePendingSteps(os.Stdout, steps, time.Now()); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func rebaselineCmd(args []string) int {
+	fs := flag.NewFlagSet("rebaseline", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to update")
+	suite := fs.String("suite", cfg.Suite, "suite whose baselines to recompute (run --suite)")
+	runs := fs.Int("runs", max(cfg.Baselines.Runs, 20), "number of recent green runs forming the baseline")
+	branch := fs.String("branch", cfg.Baselines.Branch, "only use runs of this branch, e.g. main")
+	show := fs.Bool("show", false, "print the stored baselines without recomputing them")
+	imports := fs.String("import", "", "import these comma-separated stats bundles (export --format stats), combined with the local baselines when read, instead of recomputing them")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	var b vectorclocks.StoredBaseline
+	switch {
+	case *imports != "":
+		var bundles []vectorclocks.StatsBundle
+		for _, path := range strings.Split(*imports, ",") {
+			bundle, err := vectorclocks.LoadStatsBundle(strings.TrimSpace(path))
+			if err != nil {
+				return fail(err)
+			}
+			bundles = append(bundles, bundle)
+		}
+		b, err = a.ImportStats(*suite, bundles...)
+	case *show:
+		var ok bool
+		if b, ok, err = a.StoredBaselineOf(*suite); err == nil && !ok {
+			err = fmt.Errorf("no baseline stored for suite %q; run rebaseline without --show", *suite)
+		}
+	default:
+		b, err = a.RecomputeBaselines(*suite, vectorclocks.BaselineWindow{Runs: *runs, Branch: *branch})
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteStoredBaseline(os.Stdout, b); err != nil {
+		return fail(err)
+	}
+	return 0
+}
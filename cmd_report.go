@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+	"github.com/infiniteCrank/vectorColcks/vectorclocks/report"
+)
+
+func reportCmd(args []string) int {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	scenario := fs.String("scenario", "", "only steps of the scenario with this exact name")
+	feature := fs.String("feature", "", "only steps of this feature file, as stored in feature_uri")
+	stepHash := fs.String("step-hash", "", "only executions of the step with this step_hash, across runs")
+	stepContains := fs.String("step-contains", "", "only steps whose text contains this string")
+	annotation := fs.String("annotation", "", "only steps annotated with key or key=value")
+	label := fs.String("label", "", "only steps of runs labelled with key or key=value")
+	suite := fs.String("suite", "", "only steps of runs of this suite (run --suite)")
+	minDuration := fs.Duration("min-duration", 0, "only steps that took at least this long")
+	sortBy := fs.String("sort", vectorclocks.SortByTime, "order rows by time, duration, or causal to follow the vector clocks of merged runners")
+	limit := fs.Int("limit", 0, "print at most N rows (0 for all)")
+	unit := fs.String("unit", string(cfg.Unit), "print durations in auto, ns, us, ms or s (default whole milliseconds)")
+	watch := fs.Bool("watch", false, "reprint the report whenever new steps are recorded, until interrupted")
+	interval := fs.Duration("interval", 2*time.Second, "how often --watch checks for new steps")
+	format := fs.String("format", "", "instead of step rows, print a run report as "+strings.Join(report.Formats(), ", "))
+	runID := fs.String("run", "", "run reported with --format (default: the latest run, compared with the one before)")
+	baseID := fs.String("base", "", "run the --format report compares against")
+	threshold := fs.Float64("threshold", cfg.Threshold, "percentage slowdown the --format report flags as a regression")
+	out := fs.String("out", "", "file to write the --format report to (default stdout)")
+	fs.Parse(args)
+
+	if *format != "" {
+		if _, ok := report.Lookup(*format); !ok {
+			fmt.Fprintf(os.Stderr, "unknown format %q (want %s)\n", *format, strings.Join(report.Formats(), ", "))
+			return 2
+		}
+	}
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *format != "" {
+		snap, err := report.Take(a, *runID, *baseID, *threshold)
+		if err != nil {
+			return fail(err)
+		}
+		render := func(w io.Writer) error { return report.Render(*format, snap, w) }
+		if *out == "" {
+			err = render(os.Stdout)
+		} else {
+			err = writeFile(*out, render)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		return 0
+	}
+
+	var displayUnit vectorclocks.DisplayUnit
+	if *unit != "" {
+		if displayUnit, err = vectorclocks.ParseDisplayUnit(*unit); err != nil {
+			return fail(err)
+		}
+	}
+	filter := vectorclocks.TimingFilter{
+		Scenario:     *scenario,
+		FeatureURI:   *feature,
+		StepHash:     *stepHash,
+		StepContains: *stepContains,
+		Annotation:   *annotation,
+		Label:        *label,
+		Suite:        *suite,
+		MinDuration:  *minDuration,
+		SortBy:       *sortBy,
+		Limit:        *limit,
+	}
+	render := func() error {
+		timings, err := a.Timings(filter)
+		if err != nil {
+			return err
+		}
+		return vectorclocks.WriteTimingsIn(os.Stdout, timings, displayUnit)
+	}
+
+	if !*watch {
+		if err := render(); err != nil {
+			return fail(err)
+		}
+		return 0
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err = a.Watch(ctx, *interval, func() error {
+		// Clear the screen and move the cursor home before each refresh.
+		fmt.Print("\033[H\033[2J")
+		fmt.Printf("%s  (watching %s, Ctrl-C to stop)\n", time.Now().Format(time.TimeOnly), *dbPath)
+		return render()
+	})
+	if err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/cucumber/godog"
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func runCmd(args []string) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to record into")
+	concurrency := fs.Int("concurrency", cfg.Concurrency, "number of scenarios godog runs in parallel")
+	verbosity := fs.String("verbosity", cfg.Verbosity.String(), "agent output: silent, summary, report or debug")
+	assetDir := fs.String("assets", cfg.Assets, "directory whose templates override the embedded ones")
+	roundTo := fs.Duration("round", cfg.Rounding.Round, "round persisted durations to this precision (0 keeps them exact)")
+	exactAbove := fs.Duration("round-exact-above", cfg.Rounding.ExactAbove, "keep durations at or above this value exact when rounding")
+	keepRuns := fs.Int("keep-runs", cfg.KeepRuns, "prune all but the most recent N runs on startup")
+	keepDays := fs.Int("keep-days", cfg.KeepDays, "prune runs older than N days on startup")
+	rawDays := fs.Int("raw-days", cfg.RawDays, "roll step rows older than N days into daily aggregates (0 keeps them raw)")
+	shardIndex := fs.Int("shard-index", 0, "0-based index of the shard this process runs")
+	shardTotal := fs.Int("shard-total", 1, "number of shards the suite is split into")
+	paths := fs.String("paths", "features", "comma-separated feature files and directories to run, e.g. from the shard command; none, as for an empty shard, runs nothing and succeeds")
+	tags := fs.String("tags", "", "godog tag expression selecting the scenarios to run, e.g. @slow")
+	retries := fs.Int("retries", cfg.Retries, "re-run failed scenarios up to N times, recorded separately")
+	gatePercent := fs.Float64("gate-percent", cfg.Gate.Percent, "fail when a scenario or step is this many percent slower than the baseline median")
+	gateAbsolute := fs.Duration("gate-absolute", cfg.Gate.Absolute, "fail when a scenario or step is this much slower than the baseline median")
+	gateWindow := fs.Int("gate-window", cfg.Gate.Window, "number of earlier runs forming the gate baseline")
+	gateMinRuns := fs.Int("gate-min-runs", cfg.Gate.MinRuns, "baseline runs needed before the gate is enforced")
+	gateBaseline := fs.String("gate-baseline", cfg.Gate.Baseline, "take the gate baseline from this database: a path, http(s) URL, s3:// or gs:// object, or postgres:// DSN (default: --db)")
+	gateStored := fs.Bool("gate-stored", cfg.Gate.Stored, "gate against the baseline stored by rebaseline or --store-baselines instead of loading --gate-window runs")
+	storeBaselines := fs.Int("store-baselines", cfg.Baselines.Runs, "recompute the suite's stored baselines over the last N green runs after the run (0 to disable)")
+	baselineBranch := fs.String("baseline-branch", cfg.Baselines.Branch, "only use runs of this branch for --store-baselines")
+	baselineFile := fs.String("baseline-file", cfg.BaselineFile, "fail when a scenario or step exceeds its band in this baseline file")
+	captureOutput := fs.Bool("capture-output", cfg.CaptureOutput, "store a compressed copy of the godog output with the run")
+	rewriteMissing := fs.Bool("rewrite-missing", cfg.RewriteMissing, "write steps missing from the database again when the run ends")
+	approxSamples := fs.Int("approx-samples", cfg.ApproxSamples, "keep N sampled durations per step and estimate statistics from them (0 scans every row)")
+	budgets := fs.String("budget", "", "comma-separated tag budgets such as @smoke=60s, reported after the run")
+	budgetFail := fs.Bool("budget-fail", cfg.Budgets.Fail, "fail the run when a tag budget is exceeded")
+	benchmarkRuns := fs.Int("benchmark-runs", cfg.Benchmark.Runs, "run @benchmark scenarios N times in total and report their confidence intervals")
+	resourceLimits := fs.String("resource-limits", "", "comma-separated resource=tokens limits such as db:users=1, delaying scenarios that would exceed them")
+	webhook := fs.String("webhook", cfg.Webhook, "post a Slack-compatible run summary to this URL when the suite finishes")
+	collector := fs.String("collector", cfg.Collector, "ship steps to the collector at this URL instead of writing them to --db")
+	collectorToken := fs.String("collector-token", cfg.CollectorToken, "bearer token sent to the collector")
+	upload := fs.String("upload", cfg.Upload.URL, "upload the results to this directory, s3://bucket/prefix or gs://bucket/prefix when the suite finishes")
+	uploadPath := fs.String("upload-path", cfg.Upload.Path, "uploaded object name; {run_id}, {date} and {ext} are replaced (default "+vectorclocks.DefaultUploadPath+")")
+	featureFlags := fs.String("flags", "", "comma-separated feature flags of the system under test such as new_checkout=on, recorded with the run")
+	runLabels := fs.String("labels", "", "comma-separated labels attached to the run such as release=1.4,ticket=QA-123")
+	suiteName := fs.String("suite", cfg.Suite, "name of the suite, such as api or ui, when several suites share the database")
+	processID := fs.String("process-id", cfg.ProcessID, "this runner's component of the vector clocks stamped on steps (default host name and process ID)")
+	environment := fs.String("env", cfg.Environment, "environment the suite runs against, such as staging (default $"+vectorclocks.EnvironmentEnv+")")
+	sampleRate := fs.Int("sample-rate", cfg.Sampling.Rate, "record only one in N step executions (failed and --sample-slow steps are always recorded)")
+	sampleSlow := fs.Duration("sample-slow", cfg.Sampling.Slow, "always record steps taking at least this long when sampling")
+	recordTags := fs.String("record-tags", "", "comma-separated tags; record only scenarios carrying one of them, such as @perf")
+	skipTags := fs.String("skip-tags", "", "comma-separated tags whose scenarios are not recorded")
+	recordSteps := fs.String("record-steps", "", "record only steps whose text matches this regular expression")
+	skipSteps := fs.String("skip-steps", "", "do not record steps whose text matches this regular expression, such as ^I am on")
+	trackMemory := fs.Bool("track-memory", cfg.TrackMemory, "record the heap bytes, objects and GC cycles each step allocated")
+	profileDir := fs.String("profile-dir", cfg.Profiles.Dir, "write a CPU profile per slow step to this directory, named after the step ID")
+	profileSlow := fs.Duration("profile-slow", cfg.Profiles.Threshold, "profile the steps that took at least this long in the previous run")
+	profileWatchdog := fs.Bool("profile-watchdog", cfg.Profiles.Watchdog, "profile any step still running after --profile-slow instead")
+	detectLeaks := fs.Bool("detect-leaks", cfg.DetectLeaks, "warn about scenarios that leave goroutines or open files behind and store the counts")
+	leakGoroutines := fs.Int("leak-goroutines", cfg.Leaks.Goroutines, "goroutines a scenario may leave behind with --detect-leaks")
+	leakFDs := fs.Int("leak-fds", cfg.Leaks.FDs, "open file descriptors a scenario may leave behind with --detect-leaks")
+	warnSlow := fs.Duration("warn-slow", cfg.WarnSlow, "warn as soon as a step has run this long, with its historical mean")
+	annotate := fs.String("annotate", cfg.Annotations, "print regressions, exceeded bands and slow steps as CI annotations: github, teamcity or auto")
+	onConflict := fs.String("on-conflict", string(cfg.Conflicts), "what to do with a step whose ID another run already stored: append (default), error or upsert")
+	stepTimeouts := fs.String("step-timeout", "", "fail steps that run longer than this, e.g. 30s,@slow=2m,/^I upload/=5m")
+	eta := fs.Bool("eta", cfg.ETA, "predict the suite runtime from recent runs and log the time left as scenarios finish")
+	faults := fs.String("faults", "", "comma-separated faults injected into the instrumented helpers, such as @payments:http=500ms or db=error@0.1")
+	logFormat := fs.String("log-format", cfg.LogFormat, "write the agent's diagnostics to stderr as text or json slog records instead of plain lines on stdout")
+	events := fs.String("events", cfg.Events, "write an NDJSON event per finished step to this file (- for stdout)")
+	allureDir := fs.String("allure-dir", cfg.AllureDir, "write the run's scenarios and steps as Allure results to this directory")
+	metricsFile := fs.String("metrics-file", cfg.MetricsFile, "write an OpenMetrics snapshot of the run to this file, e.g. for node_exporter's textfile collector")
+	uploadFormat := fs.String("upload-format", cfg.Upload.Format, "sqlite for the whole database, or an export format such as ndjson for the run's steps")
+	useFormatter := fs.Bool("formatter", false, "record through the vectorclocks godog formatter, stacked with pretty, instead of scenario hooks")
+	rollout := fs.Bool("rollout", false, "pick the concurrency from the progressive rollout instead of --concurrency")
+	rolloutMax := fs.Int("rollout-max", vectorclocks.DefaultRolloutPolicy.Max, "highest concurrency the rollout tries")
+	fs.Parse(args)
+
+	level, err := vectorclocks.ParseVerbosity(*verbosity)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	featurePaths := splitPaths(*paths)
+	if len(featurePaths) == 0 {
+		fmt.Fprintln(os.Stderr, "no feature paths to run")
+		if level >= vectorclocks.VerbositySummary {
+			fmt.Println(vectorclocks.EmptySummary())
+		}
+		return 0
+	}
+
+	budgetPolicy := vectorclocks.BudgetPolicy{Budgets: cfg.Budgets.Budgets, Fail: *budgetFail}
+	if *profileDir != "" && *profileSlow <= 0 {
+		fmt.Fprintln(os.Stderr, "--profile-dir needs --profile-slow")
+		return 2
+	}
+	if *budgets != "" {
+		if budgetPolicy.Budgets, err = vectorclocks.ParseBudgets(*budgets); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	limits := cfg.ResourceLimits
+	if *resourceLimits != "" {
+		if limits, err = vectorclocks.ParseResourceLimits(*resourceLimits); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	flags := cfg.FeatureFlags
+	if *featureFlags != "" {
+		if flags, err = vectorclocks.ParseFeatureFlags(*featureFlags); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	if _, err = vectorclocks.ParseAnnotationFormat(*annotate); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	labels := cfg.Labels
+	if *runLabels != "" {
+		if labels, err = vectorclocks.ParseLabels(*runLabels); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	conflicts := cfg.Conflicts
+	if *onConflict != "" {
+		if conflicts, err = vectorclocks.ParseConflictPolicy(*onConflict); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	timeouts := cfg.StepTimeouts
+	if *stepTimeouts != "" {
+		if timeouts, err = vectorclocks.ParseStepTimeouts(*stepTimeouts); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	faultList := cfg.Faults
+	if *faults != "" {
+		if faultList, err = vectorclocks.ParseFaults(*faults); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+
+	filter := cfg.Record
+	if *recordTags != "" {
+		filter.IncludeTags = vectorclocks.ParseTags(*recordTags)
+	}
+	if *skipTags != "" {
+		filter.ExcludeTags = vectorclocks.ParseTags(*skipTags)
+	}
+	for _, f := range []struct {
+		flag, expr string
+		re         **regexp.Regexp
+	}{
+		{"--record-steps", *recordSteps, &filter.IncludeSteps},
+		{"--skip-steps", *skipSteps, &filter.ExcludeSteps},
+	} {
+		if f.expr == "" {
+			continue
+		}
+		if *f.re, err = regexp.Compile(f.expr); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", f.flag, err)
+			return 2
+		}
+	}
+
+	if _, err = vectorclocks.ParseLogFormat(*logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	// The flags default to the config, so applying them to a copy of it
+	// gives the settings of this run.
+	c := cfg
+	c.DB = *dbPath
+	c.Concurrency = *concurrency
+	c.Verbosity = level
+	c.LogFormat = *logFormat
+	c.Assets = *assetDir
+	c.Rounding = vectorclocks.RoundingPolicy{Round: *roundTo, ExactAbove: *exactAbove}
+	c.KeepRuns, c.KeepDays, c.RawDays = *keepRuns, *keepDays, *rawDays
+	c.Retries = *retries
+	c.Gate = vectorclocks.GatePolicy{
+		Percent:  *gatePercent,
+		Absolute: *gateAbsolute,
+		Window:   *gateWindow,
+		MinRuns:  *gateMinRuns,
+		Baseline: *gateBaseline,
+		Stored:   *gateStored,
+	}
+	c.Baselines = vectorclocks.BaselineWindow{Runs: *storeBaselines, Branch: *baselineBranch}
+	c.BaselineFile = *baselineFile
+	c.Budgets = budgetPolicy
+	c.Benchmark.Runs = *benchmarkRuns
+	c.ResourceLimits = limits
+	c.Webhook = *webhook
+	c.Collector, c.CollectorToken = *collector, *collectorToken
+	c.Upload = vectorclocks.UploadPolicy{URL: *upload, Path: *uploadPath, Format: *uploadFormat}
+	c.FeatureFlags = flags
+	c.Labels = labels
+	c.Suite = *suiteName
+	c.ProcessID = *processID
+	c.Faults = faultList
+	c.Environment = *environment
+	c.Sampling = vectorclocks.SamplingPolicy{Rate: *sampleRate, Slow: *sampleSlow}
+	c.Record = filter
+	c.TrackMemory = *trackMemory
+	c.Profiles = vectorclocks.ProfilePolicy{Dir: *profileDir, Threshold: *profileSlow, Watchdog: *profileWatchdog}
+	c.DetectLeaks = *detectLeaks
+	c.Leaks.Goroutines, c.Leaks.FDs = *leakGoroutines, *leakFDs
+	c.WarnSlow = *warnSlow
+	c.Annotations = *annotate
+	c.ETA = *eta
+	c.MetricsFile = *metricsFile
+	c.AllureDir = *allureDir
+	c.CaptureOutput = *captureOutput
+	c.RewriteMissing = *rewriteMissing
+	c.ApproxSamples = *approxSamples
+	c.Conflicts = conflicts
+	c.StepTimeouts = timeouts
+
+	agentOpts := append(c.Options(),
+		vectorclocks.WithShard(*shardIndex, *shardTotal),
+		vectorclocks.WithSignalHandling(),
+	)
+
+	switch *events {
+	case "":
+	case "-":
+		agentOpts = append(agentOpts, vectorclocks.WithEventStream(os.Stdout))
+	default:
+		f, err := os.OpenFile(*events, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fail(err)
+		}
+		defer f.Close()
+		agentOpts = append(agentOpts, vectorclocks.WithEventStream(f))
+	}
+
+	agent, err = vectorclocks.NewVectorClockAgent(c.DB, agentOpts...)
+	if err != nil {
+		return fail(err)
+	}
+
+	if *rollout {
+		policy := vectorclocks.DefaultRolloutPolicy
+		policy.Max = *rolloutMax
+		if *concurrency, err = agent.NextConcurrency(policy); err != nil {
+			agent.Close()
+			return fail(err)
+		}
+	}
+
+	opts := godog.Options{
+		Format:      "pretty",
+		Paths:       featurePaths,
+		Concurrency: *concurrency,
+		Tags:        *tags,
+	}
+	if *useFormatter {
+		opts.Format += "," + agent.RegisterFormatter()
+		viaFormatter = true
+	}
+
+	suite := godog.TestSuite{
+		Name:                "godogsuite",
+		ScenarioInitializer: InitializeScenario,
+		Options:             &opts,
+	}
+
+	status := agent.RunSuite(suite)
+
+	if err := agent.Report(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if conflicts, err := agent.Conflicts(agent.RunID()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else if len(conflicts) > 0 && level >= vectorclocks.VerbositySummary {
+		fmt.Println("=== Concurrent Resource Usage ===")
+		vectorclocks.WriteConflicts(os.Stdout, conflicts)
+	}
+	if err := agent.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	agent.PrintSummary()
+
+	return status
+}
+
+// splitPaths splits a comma-separated --paths value, dropping empty
+// entries such as the empty line the shard command prints for a shard
+// that got no feature files.
+func splitPaths(s string) []string {
+	var paths []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
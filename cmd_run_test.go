@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitPaths(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"features", []string{"features"}},
+		{"a.feature, b.feature:12", []string{"a.feature", "b.feature:12"}},
+		{"a.feature,,b.feature,", []string{"a.feature", "b.feature"}},
+		{"", nil},
+		{" , ", nil},
+	}
+	for _, tt := range tests {
+		if got := splitPaths(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitPaths(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRunEmptyShard(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	status := runCmd([]string{"--paths", "", "--verbosity", "summary", "--db", t.TempDir() + "/timings.db"})
+	os.Stdout = stdout
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != 0 {
+		t.Errorf("run --paths \"\" exited %d, want 0", status)
+	}
+	if !strings.Contains(string(out), "VC_SUMMARY total=0.000s scenarios=0 failed=0 regressions=0 run_id=") {
+		t.Errorf("run --paths \"\" printed %q, want an empty VC_SUMMARY line", out)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func safetyCmd(args []string) int {
+	fs := flag.NewFlagSet("safety", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read and store verdicts in")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	verdicts, err := a.ParallelSafety()
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteSafety(os.Stdout, verdicts); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func sampleCmd(args []string) int {
+	fs := flag.NewFlagSet("sample", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	budget := fs.Duration("budget", 2*time.Minute, "maximum expected runtime of the sampled scenarios")
+	coverage := fs.Float64("coverage", 0.9, "share of known step texts the profile should cover")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	profile, err := a.SampleProfile(*budget, *coverage)
+	if err != nil {
+		return fail(err)
+	}
+	fmt.Printf("=== Sampling Profile: %d of %d scenarios, ~%s, %.0f%% of step texts ===\n",
+		len(profile.Scenarios), profile.TotalScenarios, profile.Expected.Round(time.Millisecond), profile.Coverage*100)
+	for _, s := range profile.Scenarios {
+		fmt.Printf("Scenario: %s (%s), Expected: %s, Steps: %d\n", s.Name, s.Path(), s.Expected.Round(time.Millisecond), s.Steps)
+	}
+	fmt.Printf("Paths: %s\n", strings.Join(profile.Paths(), ","))
+	return 0
+}
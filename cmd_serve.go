@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func serveCmd(args []string) int {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	addr := fs.String("addr", "localhost:8080", "address to listen on")
+	runs := fs.Int("runs", vectorclocks.DefaultServeRuns, "number of recent runs the pages cover")
+	assetDir := fs.String("assets", cfg.Assets, "directory whose templates override the embedded ones")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath, vectorclocks.WithAssetDir(*assetDir))
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	srv := &http.Server{Addr: *addr, Handler: a.Handler(*runs)}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+
+	fmt.Printf("serving %s on http://%s\n", *dbPath, *addr)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fail(err)
+	}
+	return 0
+}
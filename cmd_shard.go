@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func shardCmd(args []string) int {
+	fs := flag.NewFlagSet("shard", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	n := fs.Int("n", 2, "number of shards")
+	by := fs.String("by", vectorclocks.ShardByFeature, "split by feature file or by scenario")
+	index := fs.Int("shard-index", -1, "print only the comma-separated paths of this 0-based shard, for run --paths or --godog.paths")
+	asJSON := fs.Bool("json", false, "print the plan as JSON")
+	fs.Parse(args)
+
+	if *n < 1 || *index >= *n {
+		fmt.Fprintln(os.Stderr, "need -n of at least 1 and a --shard-index below it")
+		return 2
+	}
+	if *by != vectorclocks.ShardByFeature && *by != vectorclocks.ShardByScenario {
+		fmt.Fprintf(os.Stderr, "unknown --by %q (want feature or scenario)\n", *by)
+		return 2
+	}
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	paths := fs.Args()
+	if len(paths) == 0 {
+		paths = []string{"features"}
+	}
+	plan, err := a.PlanShards(paths, *n, *by)
+	if err != nil {
+		return fail(err)
+	}
+	switch {
+	case *index >= 0:
+		fmt.Println(strings.Join(plan.Shards[*index].Paths, ","))
+	case *asJSON:
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			return fail(err)
+		}
+	default:
+		if err := vectorclocks.WriteShardPlan(os.Stdout, plan); err != nil {
+			return fail(err)
+		}
+	}
+	return 0
+}
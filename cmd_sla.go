@@ -0,0 +1,32 @@
+package main
+
+import (
+	"flag"
+	"time"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func slaCmd(args []string) int {
+	fs := flag.NewFlagSet("sla", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	out := fs.String("out", "sla.html", "HTML file to write")
+	period := fs.Duration("period", 7*24*time.Hour, "period covered by the report")
+	assetDir := fs.String("assets", cfg.Assets, "directory whose templates override the embedded ones")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath, vectorclocks.WithAssetDir(*assetDir))
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	report, err := a.SLAReport(*period)
+	if err != nil {
+		return fail(err)
+	}
+	if err := writeFile(*out, report.WriteHTML); err != nil {
+		return fail(err)
+	}
+	return 0
+}
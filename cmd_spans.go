@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func spansCmd(args []string) int {
+	fs := flag.NewFlagSet("spans", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runID := fs.String("run", "", "run to show (defaults to the latest)")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *runID == "" {
+		if *runID, err = a.LatestRunID(); err != nil {
+			return fail(err)
+		}
+	}
+	spans, err := a.Spans(*runID)
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteSpans(os.Stdout, spans); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func stabilityCmd(args []string) int {
+	fs := flag.NewFlagSet("stability", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runs := fs.Int("runs", 0, "recompute the scores over the N most recent runs (0 prints the stored scores)")
+	sortBy := fs.String("sort", vectorclocks.StabilityByScore, "order: score, pass-rate, variance, retries or name")
+	limit := fs.Int("limit", 0, "print at most N scenarios (0 prints all)")
+	asJSON := fs.Bool("json", false, "print the scores as JSON")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	var scores []vectorclocks.ScenarioStability
+	if *runs > 0 {
+		scores, err = a.UpdateStability(*runs)
+	} else {
+		scores, err = a.Stability()
+	}
+	if err != nil {
+		return fail(err)
+	}
+	if !vectorclocks.SortStability(scores, *sortBy) {
+		fmt.Fprintf(os.Stderr, "unknown sort %q (want score, pass-rate, variance, retries or name)\n", *sortBy)
+		return 2
+	}
+	if *limit > 0 && len(scores) > *limit {
+		scores = scores[:*limit]
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(scores); err != nil {
+			return fail(err)
+		}
+		return 0
+	}
+	if err := vectorclocks.WriteStability(os.Stdout, scores); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func suitesCmd(args []string) int {
+	fs := flag.NewFlagSet("suites", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	suites, err := a.Suites()
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteSuites(os.Stdout, suites); err != nil {
+		return fail(err)
+	}
+	return 0
+}
+
+// latestSuiteRun returns the latest run of suite, or the latest run of any
+// suite when suite is empty.
+func latestSuiteRun(a *vectorclocks.VectorClockAgent, suite string) (string, error) {
+	if suite == "" {
+		return a.LatestRunID()
+	}
+	runs, err := a.SuiteRuns(suite, 1)
+	if err != nil {
+		return "", err
+	}
+	if len(runs) == 0 {
+		return "", fmt.Errorf("no runs of suite %s recorded", suite)
+	}
+	return runs[0], nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func topCmd(args []string) int {
+	fs := flag.NewFlagSet("top", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	n := fs.Int("n", 10, "number of steps and scenarios to list")
+	examples := fs.Bool("examples", false, "list the slowest rows of scenario outlines instead")
+	memory := fs.Bool("memory", false, "list the steps allocating the most memory, recorded with run --track-memory, instead")
+	share := fs.String("share", "", "list each feature, scenario or step's share of the total step time instead, largest first")
+	pareto := fs.Float64("pareto", 0, "with --share, list the items making up this percentage of the time, e.g. 80, instead of -n")
+	runID := fs.String("run", "", "with --share, the run to break down (default: the latest run)")
+	all := fs.Bool("all", false, "with --share, break down all recorded runs instead of one")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	if *examples {
+		hotspots, err := a.SlowestExamples(*n)
+		if err != nil {
+			return fail(err)
+		}
+		if err := vectorclocks.WriteHotspots(os.Stdout, "Slowest outline examples", hotspots); err != nil {
+			return fail(err)
+		}
+		return 0
+	}
+	if *share != "" {
+		filter := vectorclocks.TimingFilter{RunID: *runID}
+		title := "Time by " + *share
+		if *all {
+			filter.RunID = ""
+			title += " across all runs"
+		} else {
+			if filter.RunID == "" {
+				if filter.RunID, err = a.LatestRunID(); err != nil {
+					return fail(err)
+				}
+			}
+			title += " in run " + filter.RunID
+		}
+		shares, err := a.Attribution(*share, filter)
+		if err != nil {
+			return fail(err)
+		}
+		shown := shares
+		if *pareto > 0 {
+			shown = vectorclocks.ParetoCut(shares, *pareto)
+		} else if len(shown) > *n {
+			shown = shown[:*n]
+		}
+		if err := vectorclocks.WriteShares(os.Stdout, title, shown, shares); err != nil {
+			return fail(err)
+		}
+		return 0
+	}
+	if *memory {
+		hotspots, err := a.MemoryHotspots(*n)
+		if err != nil {
+			return fail(err)
+		}
+		if err := vectorclocks.WriteMemoryHotspots(os.Stdout, "Most allocating steps", hotspots); err != nil {
+			return fail(err)
+		}
+		return 0
+	}
+	steps, scenarios, err := a.Top(*n)
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteHotspots(os.Stdout, "Slowest scenarios", scenarios); err != nil {
+		return fail(err)
+	}
+	fmt.Println()
+	if err := vectorclocks.WriteHotspots(os.Stdout, "Slowest steps", steps); err != nil {
+		return fail(err)
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func trendCmd(args []string) int {
+	fs := flag.NewFlagSet("trend", flag.ExitOnError)
+	dbPath := fs.String("db", cfg.DB, "SQLite database to read")
+	runs := fs.Int("runs", 20, "number of recent runs to fit")
+	threshold := fs.Float64("threshold", cfg.Threshold, "percentage growth across the window flagged as a rising trend")
+	fs.Parse(args)
+
+	a, err := openAgent(*dbPath)
+	if err != nil {
+		return fail(err)
+	}
+	defer a.Close()
+
+	scenarios, steps, err := a.Trends(*runs, *threshold)
+	if err != nil {
+		return fail(err)
+	}
+	if err := vectorclocks.WriteTrends(os.Stdout, "--- Scenarios ---", scenarios); err != nil {
+		return fail(err)
+	}
+	fmt.Println()
+	if err := vectorclocks.WriteTrends(os.Stdout, "--- Steps ---", steps); err != nil {
+		return fail(err)
+	}
+
+	archived, err := a.ArchivedRuns()
+	if err != nil {
+		return fail(err)
+	}
+	if len(archived) > 0 {
+		last := archived[len(archived)-1]
+		fmt.Printf("\n%d runs up to %s are archived and not covered\n", len(archived), last.StartedAt.Format("2006-01-02"))
+	}
+	return 0
+}
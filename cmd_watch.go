@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+func watchCmd(args []string) int {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	dbPath := flags.String("db", cfg.DB, "SQLite database to record into")
+	paths := flags.String("paths", "features", "comma-separated feature files and directories to run")
+	tags := flags.String("tags", "", "godog tag expression selecting the scenarios to run, e.g. @slow")
+	watched := flags.String("watch", "features,.", "comma-separated directories whose .feature and .go files trigger a re-run")
+	interval := flags.Duration("interval", 500*time.Millisecond, "how often to check the watched files for changes")
+	threshold := flags.Float64("threshold", cfg.Threshold, "percentage slowdown flagged as a regression in the diff")
+	command := flags.String("cmd", "", "command running one iteration, given --db, --paths and --tags (default: this binary's run command; use \"go run . run\" to rebuild on .go changes)")
+	flags.Parse(args)
+
+	var run []string
+	if *command != "" {
+		run = strings.Fields(*command)
+	} else {
+		self, err := os.Executable()
+		if err != nil {
+			return fail(err)
+		}
+		run = []string{self, "run", "--verbosity", "summary"}
+	}
+	run = append(run, "--db", *dbPath, "--paths", *paths)
+	if *tags != "" {
+		run = append(run, "--tags", *tags)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	previous, _ := latestRun(*dbPath)
+	dirs := strings.Split(*watched, ",")
+	for {
+		state := watchState(dirs)
+
+		cmd := exec.CommandContext(ctx, run[0], run[1:]...)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil && ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "iteration failed: %v\n", err)
+		}
+		if ctx.Err() != nil {
+			return 0
+		}
+
+		latest, err := latestRun(*dbPath)
+		if err != nil {
+			return fail(err)
+		}
+		if previous != "" && latest != previous {
+			if err := printIterationDiff(*dbPath, previous, latest, *threshold); err != nil {
+				return fail(err)
+			}
+		}
+		previous = latest
+
+		fmt.Printf("%s  (watching %s, Ctrl-C to stop)\n", time.Now().Format(time.TimeOnly), *watched)
+		if !waitForChange(ctx, dirs, state, *interval) {
+			return 0
+		}
+	}
+}
+
+// latestRun returns the latest run recorded in dbPath, or "" for none.
+func latestRun(dbPath string) (string, error) {
+	a, err := openAgent(dbPath)
+	if err != nil {
+		return "", err
+	}
+	defer a.Close()
+
+	runs, err := a.RecentRuns(1)
+	if err != nil || len(runs) == 0 {
+		return "", err
+	}
+	return runs[0], nil
+}
+
+// printIterationDiff prints the step durations of head against those of
+// the iteration before.
+func printIterationDiff(dbPath, base, head string, threshold float64) error {
+	a, err := openAgent(dbPath)
+	if err != nil {
+		return err
+	}
+	defer a.Close()
+
+	b, err := a.RunTimings(base)
+	if err != nil {
+		return err
+	}
+	h, err := a.RunTimings(head)
+	if err != nil {
+		return err
+	}
+	fmt.Println("=== Against the previous iteration ===")
+	return vectorclocks.WriteComparison(os.Stdout, vectorclocks.Compare(b, h, threshold))
+}
+
+// watchState maps the .feature and .go files below dirs to their
+// modification times.
+func watchState(dirs []string) map[string]time.Time {
+	state := make(map[string]time.Time)
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if d.IsDir() {
+				if path != dir && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if ext := filepath.Ext(path); ext != ".feature" && ext != ".go" {
+				return nil
+			}
+			if info, err := d.Info(); err == nil {
+				state[path] = info.ModTime()
+			}
+			return nil
+		})
+	}
+	return state
+}
+
+// waitForChange polls dirs until their files differ from state. It returns
+// false when ctx is done first.
+func waitForChange(ctx context.Context, dirs []string, state map[string]time.Time, interval time.Duration) bool {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+		current := watchState(dirs)
+		if len(current) != len(state) {
+			return true
+		}
+		for path, modTime := range current {
+			if !state[path].Equal(modTime) {
+				return true
+			}
+		}
+	}
+}
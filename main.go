@@ -2,160 +2,179 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
+	"io"
 	"os"
-	"sync"
-	"sync/atomic"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/cucumber/godog"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
 )
 
-// VectorClockAgent collects timings for steps and persists them to SQLite.
-type VectorClockAgent struct {
-	startTimes sync.Map
-	durations  sync.Map
-	counter    uint64
-	db         *sql.DB
-}
+var agent *vectorclocks.VectorClockAgent
 
-func NewVectorClockAgent(dbPath string) *VectorClockAgent {
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		panic(fmt.Sprintf("failed to open SQLite database: %v", err))
-	}
+// viaFormatter is set when the agent records through its godog formatter
+// rather than the scenario hooks.
+var viaFormatter bool
 
-	_, err = db.Exec(`
-		CREATE TABLE IF NOT EXISTS step_timings (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			step_id TEXT UNIQUE,
-			scenario_name TEXT,
-			step_text TEXT,
-			duration_ms INTEGER,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		panic(fmt.Sprintf("failed to create table: %v", err))
-	}
+// cfg supplies the flag defaults of every subcommand.
+var cfg vectorclocks.Config
 
-	return &VectorClockAgent{
-		db: db,
+func InitializeScenario(ctx *godog.ScenarioContext) {
+	if !viaFormatter {
+		agent.InitializeScenario(ctx)
 	}
-}
 
-func (v *VectorClockAgent) generateStepID(scenarioName, stepText string) string {
-	count := atomic.AddUint64(&v.counter, 1)
-	return fmt.Sprintf("%s-%s-%d", scenarioName, stepText, count)
+	agent.Step(ctx, `^I perform an action$`, iPerformAction)
 }
 
-func (v *VectorClockAgent) Start(scenarioName, stepText string) string {
-	stepID := v.generateStepID(scenarioName, stepText)
-	v.startTimes.Store(stepID, time.Now())
-	return stepID
+// iPerformAction stands in for an asynchronous action that completes after
+// 150ms, and waits for it the way real glue code would.
+func iPerformAction(ctx context.Context) error {
+	done := time.Now().Add(150 * time.Millisecond)
+	return vectorclocks.WaitFor(ctx, func() (bool, error) {
+		return time.Now().After(done), nil
+	})
 }
 
-func (v *VectorClockAgent) End(stepID, scenarioName, stepText string) {
-	val, ok := v.startTimes.Load(stepID)
-	if !ok {
-		fmt.Printf("No start time recorded for step '%s'\n", stepID)
-		return
-	}
-	startTime, _ := val.(time.Time)
-	duration := time.Since(startTime)
-	v.durations.Store(stepID, duration)
-
-	_, err := v.db.Exec(`
-		INSERT OR IGNORE INTO step_timings (step_id, scenario_name, step_text, duration_ms)
-		VALUES (?, ?, ?, ?)
-	`, stepID, scenarioName, stepText, duration.Milliseconds())
+// command is one vectorColcks subcommand. run receives the arguments after
+// the command name and returns the process exit code.
+type command struct {
+	summary string
+	run     func(args []string) int
+}
 
-	if err != nil {
-		fmt.Printf("Failed to save step '%s' to DB: %v\n", stepID, err)
-	}
+var commands = map[string]command{
+	"run":        {"run the godog suite and record step timings (default)", runCmd},
+	"anomalies":  {"list steps of a run that deviate far from their history", anomaliesCmd},
+	"output":     {"print the godog output captured for a run", outputCmd},
+	"rebaseline": {"recompute the stored per-step baselines the regression gate reads", rebaselineCmd},
+	"report":     {"print recorded step timings with filtering and sorting", reportCmd},
+	"coverage":   {"list the step definitions the steps of recent runs used, unused ones first", coverageCmd},
+	"critical":   {"show the scenario chain that bounded a parallel run's wall time", criticalCmd},
+	"gaps":       {"show the scenario time spent outside steps, in hooks and the framework", gapsCmd},
+	"gantt":      {"draw which scenarios ran on which worker and when as an HTML timeline", ganttCmd},
+	"suites":     {"list the suites recorded in the database with their latest run", suitesCmd},
+	"conflicts":  {"list scenario pairs that used a resource concurrently", conflictsCmd},
+	"archive":    {"move old runs to cold storage, leaving stubs behind", archiveCmd},
+	"browse":     {"browse runs, scenarios and steps interactively in the terminal", browseCmd},
+	"baseline":   {"write a baseline file of expected duration bands for CI", baselineCmd},
+	"collect":    {"store steps shipped by remote agents over HTTP", collectCmd},
+	"compare":    {"show per-scenario and per-step deltas between two runs", compareCmd},
+	"export":     {"dump recorded step timings as JSON, CSV or NDJSON", exportCmd},
+	"flaky":      {"score steps by duration variance and outcome flip-flopping", flakyCmd},
+	"stability":  {"score scenarios by pass rate, duration variance and retries", stabilityCmd},
+	"shard":      {"split the feature files into shards of equal predicted duration", shardCmd},
+	"eta":        {"predict the runtime of the feature files from recent runs", etaCmd},
+	"background": {"show the time a run spent in Background steps per feature", backgroundCmd},
+	"heatmap":    {"write a scenario × step matrix of step time as HTML, JSON or CSV", heatmapCmd},
+	"leaks":      {"list scenarios that left goroutines or open files behind", leaksCmd},
+	"failfast":   {"chart time-to-first-failure over recent runs", failfastCmd},
+	"merge":      {"combine the runs of several databases, e.g. those of CI shards", mergeCmd},
+	"pending":    {"show how long pending steps took to get implemented", pendingCmd},
+	"safety":     {"classify scenarios as parallel-safe, unsafe or unknown", safetyCmd},
+	"sample":     {"print a fast scenario subset covering most step texts", sampleCmd},
+	"serve":      {"serve an HTML dashboard of runs, scenarios and step trends", serveCmd},
+	"spans":      {"show the timed phases inside the steps of a run", spansCmd},
+	"sla":        {"write an HTML SLA report grouped by tag", slaCmd},
+	"trend":      {"flag steps and scenarios whose duration creeps up over recent runs", trendCmd},
+	"top":        {"list the slowest steps and scenarios across runs", topCmd},
+	"watch":      {"re-run the suite on file changes and diff step durations against the previous iteration", watchCmd},
 }
 
-func (v *VectorClockAgent) Report() {
-	fmt.Println("=== Step Duration Report (SQLite) ===")
-	rows, err := v.db.Query(`SELECT step_id, scenario_name, step_text, duration_ms, created_at FROM step_timings`)
-	if err != nil {
-		fmt.Printf("Failed to fetch report: %v\n", err)
-		return
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s [--read-only] <command> [flags]\n\ncommands:\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
 	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var stepID, scenarioName, stepText, createdAt string
-		var durationMs int64
-		if err := rows.Scan(&stepID, &scenarioName, &stepText, &durationMs, &createdAt); err != nil {
-			fmt.Printf("Failed to scan row: %v\n", err)
-			continue
-		}
-		fmt.Printf("StepID: %s, Scenario: %s, Step: %s, Duration: %d ms, Timestamp: %s\n", stepID, scenarioName, stepText, durationMs, createdAt)
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
 	}
 }
 
-func (v *VectorClockAgent) Close() error {
-	return v.db.Close()
-}
-
-var agent *VectorClockAgent
+// writingCommands change the database and are refused in read-only mode.
+var writingCommands = map[string]bool{"run": true, "archive": true, "collect": true, "merge": true, "watch": true, "rebaseline": true}
 
-func InitializeScenario(ctx *godog.ScenarioContext) {
-	var scenarioName string
+func main() {
+	name, args := "run", os.Args[1:]
+	readOnly := false
+	if len(args) > 0 && (args[0] == "--read-only" || args[0] == "-read-only") {
+		readOnly, args = true, args[1:]
+	}
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
 
-	ctx.Before(func(ctx context.Context, s *godog.Scenario) (context.Context, error) {
-		scenarioName = s.Name
-		return ctx, nil
-	})
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		usage()
+		os.Exit(2)
+	}
 
-	stepIDs := make(map[*godog.Step]string)
-	stepCtx := ctx.StepContext()
+	var err error
+	if cfg, err = vectorclocks.LoadConfig(configPath()); err != nil {
+		os.Exit(fail(err))
+	}
+	cfg.ReadOnly = cfg.ReadOnly || readOnly
+	if cfg.ReadOnly && writingCommands[name] {
+		fmt.Fprintf(os.Stderr, "%s writes to the database and cannot run in read-only mode\n", name)
+		os.Exit(2)
+	}
+	os.Exit(cmd.run(args))
+}
 
-	stepCtx.Before(func(ctx context.Context, step *godog.Step) (context.Context, error) {
-		stepID := agent.Start(scenarioName, step.Text)
-		stepIDs[step] = stepID
-		return ctx, nil
-	})
+// configFiles are looked up in the working directory when VECTORCLOCKS_CONFIG
+// is not set.
+var configFiles = []string{"vectorclocks.toml", "vectorclocks.yaml", "vectorclocks.yml"}
 
-	stepCtx.After(func(ctx context.Context, step *godog.Step, status godog.StepResultStatus, err error) (context.Context, error) {
-		if stepID, ok := stepIDs[step]; ok {
-			agent.End(stepID, scenarioName, step.Text)
-			delete(stepIDs, step)
+// configPath returns the config file to load, or "" when there is none.
+func configPath() string {
+	if path := os.Getenv("VECTORCLOCKS_CONFIG"); path != "" {
+		return path
+	}
+	for _, path := range configFiles {
+		if _, err := os.Stat(path); err == nil {
+			return path
 		}
-		return ctx, nil
-	})
-
-	ctx.Step(`^I perform an action$`, iPerformAction)
+	}
+	return ""
 }
 
-func iPerformAction() error {
-	time.Sleep(150 * time.Millisecond)
-	return nil
+// openAgent opens dbPath for a subcommand that reads or maintains the
+// database rather than recording a run.
+func openAgent(dbPath string, opts ...vectorclocks.Option) (*vectorclocks.VectorClockAgent, error) {
+	opts = append([]vectorclocks.Option{
+		vectorclocks.WithVerbosity(vectorclocks.VerbositySummary),
+		vectorclocks.WithTablePrefix(cfg.TablePrefix),
+		vectorclocks.WithSchema(cfg.Schema, cfg.SchemaFile),
+		vectorclocks.WithConnectionOptions(cfg.Connection),
+	}, opts...)
+	if cfg.ReadOnly {
+		opts = append(opts, vectorclocks.WithReadOnly())
+	}
+	return vectorclocks.NewVectorClockAgent(dbPath, opts...)
 }
 
-func main() {
-	agent = NewVectorClockAgent("step_timings.db")
-
-	opts := godog.Options{
-		Format: "pretty",
-		Paths:  []string{"features"},
-	}
+// fail prints err and returns the exit code for a failed command.
+func fail(err error) int {
+	fmt.Fprintln(os.Stderr, err)
+	return 1
+}
 
-	suite := godog.TestSuite{
-		Name:                "godogsuite",
-		ScenarioInitializer: InitializeScenario,
-		Options:             &opts,
+// writeFile creates path and fills it with render.
+func writeFile(path string, render func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
 	}
-
-	status := suite.Run()
-
-	agent.Report()
-	agent.Close()
-
-	if status != 0 {
-		os.Exit(status)
+	if err := render(f); err != nil {
+		f.Close()
+		return err
 	}
+	return f.Close()
 }
@@ -0,0 +1,549 @@
+package vectorclocks
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cucumber/godog"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ErrClosed is returned when steps are recorded after Close.
+	ErrClosed = errors.New("vectorclocks: agent is closed")
+	// ErrUnknownStep is returned by End for a step ID that Start never
+	// returned or that has already ended.
+	ErrUnknownStep = errors.New("vectorclocks: no start time recorded for step")
+)
+
+// VectorClockAgent collects timings for steps and persists them to SQLite.
+type VectorClockAgent struct {
+	startTimes sync.Map
+	// durations holds every stepRecord handed to the writer, keyed by step
+	// ID, so Close can reconcile it with the database.
+	durations  sync.Map
+	counter    uint64
+	namespace  string
+	db         *namedDB
+	naming     naming
+	insertStmt *sql.Stmt
+	// resourceStmt records resources declared with Uses.
+	resourceStmt *sql.Stmt
+
+	writes     chan []stepRecord
+	flushes    chan chan error
+	writerDone chan struct{}
+	writeErr   error // set by the writer before writerDone is closed
+	closeMu    sync.RWMutex
+	closed     bool
+
+	verbosity Verbosity
+	assets    fs.FS
+	onError   func(error)
+	rounding  RoundingPolicy
+	buckets   []time.Duration
+	unit      DisplayUnit
+	retention retention
+	shard     shardInfo
+	providers []MetadataProvider
+	metadata  RunMetadata
+	// environment overrides the detected RunMetadata.Environment.
+	environment string
+
+	rewriteMissing bool
+	reservoirSize  int
+	anomaly        AnomalyPolicy
+	gate           *GatePolicy
+	budgets        *BudgetPolicy
+	baselineFile   string
+	allureDir      string
+	annotate       string
+	metricsFile    string
+	notifiers      []Notifier
+	collector      *collectorClient
+	upload         *UploadPolicy
+	flags          map[string]string
+	labelsMu       sync.Mutex
+	labels         map[string]string
+	suite          string
+	process        string
+	clockMu        sync.Mutex
+	clock          VectorClock
+	timeSource     Clock
+	newID          IDGenerator
+	definitionsMu  sync.Mutex
+	definitions    map[string]bool
+	baselineWindow *BaselineWindow
+	events         *eventStream
+	faults         []Fault
+	logger         *slog.Logger
+	testLog        bool
+	sampling       *SamplingPolicy
+	unsampled      atomic.Uint64
+	recordFilter   *RecordFilter
+	trackMemory    bool
+	profiles       *ProfilePolicy
+	slowSteps      map[StepKey]bool
+	leaks          *LeakPolicy
+	leaking        atomic.Uint64
+	warnSlow       time.Duration
+	eta            *etaTracker
+	pickles        pickleIndex
+	conflicts      ConflictPolicy
+	running        sync.Map // *scenarioBatch -> scenarioInfo
+	timeouts       []StepTimeout
+	connection     ConnectionOptions
+	sharedDB       *sql.DB
+	readOnly       bool
+	output         *capturedOutput
+
+	shutdownSignals []os.Signal
+	signals         chan os.Signal
+	closeDone       chan struct{}
+	interruptMu     sync.Mutex
+	interrupted     string
+
+	compositionMu sync.Mutex
+	composition   map[string]bool
+
+	firstFailureMu sync.Mutex
+	firstFailure   *firstFailure
+
+	histories sync.Map // normalized step text -> StepHistory
+
+	workers      workerPool
+	reservations *reservations
+	executionsMu sync.Mutex
+	executions   []executionRecord
+
+	concurrency int
+	// strict is godog's Strict option of the suite RunSuite runs, under
+	// which undefined and pending steps fail their scenario.
+	strict bool
+
+	benchmark    *BenchmarkPolicy
+	benchmarking atomic.Bool
+
+	retries    int
+	retrying   atomic.Bool
+	attempt    atomic.Int32
+	failuresMu sync.Mutex
+	failures   map[failedScenario]bool
+	retried    map[failedScenario]bool
+
+	runID     string
+	startedAt time.Time
+	scenarios uint64
+	failed    uint64
+	regressed uint64
+	// summaryOnce guards PrintSummary.
+	summaryOnce sync.Once
+}
+
+// Verbosity controls how much the agent prints while and after the suite runs.
+type Verbosity int
+
+const (
+	// VerbositySilent suppresses all agent output.
+	VerbositySilent Verbosity = iota
+	// VerbositySummary prints errors and the final VC_SUMMARY line.
+	VerbositySummary
+	// VerbosityReport additionally prints the full step duration report.
+	VerbosityReport
+	// VerbosityDebug additionally traces every hook invocation.
+	VerbosityDebug
+)
+
+var verbosityNames = map[string]Verbosity{
+	"silent":  VerbositySilent,
+	"summary": VerbositySummary,
+	"report":  VerbosityReport,
+	"debug":   VerbosityDebug,
+}
+
+// String returns the level name accepted by ParseVerbosity.
+func (l Verbosity) String() string {
+	for name, level := range verbosityNames {
+		if level == l {
+			return name
+		}
+	}
+	return strconv.Itoa(int(l))
+}
+
+// ParseVerbosity maps a level name (silent, summary, report, debug) to its
+// Verbosity.
+func ParseVerbosity(name string) (Verbosity, error) {
+	if v, ok := verbosityNames[name]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("unknown verbosity %q (want silent, summary, report or debug)", name)
+}
+
+// Option configures a VectorClockAgent.
+type Option func(*VectorClockAgent)
+
+// WithErrorHandler sets the function that receives errors the agent cannot
+// return to a caller, such as hook failures or write errors noticed while
+// preparing a report. Flush and Close return write errors directly. By
+// default errors are printed unless the agent is silent.
+func WithErrorHandler(fn func(error)) Option {
+	return func(v *VectorClockAgent) {
+		v.onError = fn
+	}
+}
+
+// WithVerbosity sets the agent output level. The default is VerbosityReport.
+func WithVerbosity(level Verbosity) Option {
+	return func(v *VectorClockAgent) {
+		v.verbosity = level
+	}
+}
+
+// WithNamespace scopes the agent to one of several test suites running in
+// the same process. Each suite gets its own agent with a distinct
+// namespace, which prefixes its step IDs and suffixes its run ID, so the
+// suites' step counters and runs never interleave.
+func WithNamespace(name string) Option {
+	return func(v *VectorClockAgent) {
+		v.namespace = name
+	}
+}
+
+// newRunID names a run by the second it started, the process and a random
+// suffix, so that the runs of several agents in one process, or of
+// containers whose processes are all PID 1, never share an ID.
+func newRunID(startedAt time.Time) string {
+	var b [4]byte
+	rand.Read(b[:])
+	return fmt.Sprintf("%s-%d-%x", startedAt.UTC().Format("20060102T150405Z"), os.Getpid(), b)
+}
+
+// NewVectorClockAgent opens (or creates) the SQLite database at dbPath,
+// brings its schema up to date and starts the background writer.
+func NewVectorClockAgent(dbPath string, opts ...Option) (*VectorClockAgent, error) {
+	v := &VectorClockAgent{
+		assets:     embeddedAssets,
+		verbosity:  VerbosityReport,
+		timeSource: systemClock{},
+		closeDone:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(v)
+	}
+	v.startedAt = v.now()
+	v.runID = newRunID(v.startedAt)
+
+	db, err := v.openDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if v.readOnly {
+		err = checkSchema(db)
+	} else if err = migrate(db); err != nil {
+		err = fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	insertStmt, err := db.Prepare(insertStepSQL(v.conflicts))
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare insert statement: %w", err)
+	}
+	resourceStmt, err := db.Prepare(`
+		INSERT INTO step_resources (run_id, step_id, scenario_name, resource, started_at, ended_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		insertStmt.Close()
+		db.Close()
+		return nil, fmt.Errorf("failed to prepare resource statement: %w", err)
+	}
+	v.db, v.insertStmt, v.resourceStmt = db, insertStmt, resourceStmt
+
+	if v.process == "" {
+		v.process = defaultProcessID()
+	}
+	if v.namespace != "" {
+		v.runID += "-" + v.namespace
+		v.process += "-" + v.namespace
+		if v.suite == "" {
+			v.suite = v.namespace
+		}
+	}
+	if v.logger == nil {
+		v.logger = v.defaultLogger()
+	}
+	if v.onError == nil {
+		v.onError = func(err error) {
+			v.logger.Error(err.Error())
+		}
+	}
+	v.detectMetadata()
+	v.detectFlags()
+	v.startWriter()
+	if v.readOnly {
+		return v, nil
+	}
+	if len(v.shutdownSignals) > 0 {
+		v.handleSignals()
+	}
+
+	if v.retention.onStart {
+		if _, err := v.Prune(); err != nil {
+			v.Close()
+			return nil, err
+		}
+	}
+	if err := v.backfillDigests(); err != nil {
+		v.Close()
+		return nil, err
+	}
+	if err := v.backfillApproximateStats(); err != nil {
+		v.Close()
+		return nil, err
+	}
+	if _, err := v.Downsample(); err != nil {
+		v.Close()
+		return nil, err
+	}
+	if err := v.loadSlowSteps(); err != nil {
+		v.Close()
+		return nil, err
+	}
+	return v, nil
+}
+
+// handleError passes err to the configured error handler.
+func (v *VectorClockAgent) handleError(err error) {
+	v.onError(err)
+}
+
+// RunID identifies the current run in the step_timings table.
+func (v *VectorClockAgent) RunID() string {
+	return v.runID
+}
+
+func (v *VectorClockAgent) generateStepID(scenarioName, stepText string) string {
+	if v.newID != nil {
+		return v.newID(scenarioName, stepText)
+	}
+	count := atomic.AddUint64(&v.counter, 1)
+	if v.namespace != "" {
+		return fmt.Sprintf("%s/%s-%s-%d", v.namespace, scenarioName, stepText, count)
+	}
+	return fmt.Sprintf("%s-%s-%d", scenarioName, stepText, count)
+}
+
+// Start records the start time of a step and returns the step ID to pass to
+// End.
+func (v *VectorClockAgent) Start(scenarioName, stepText string) string {
+	stepID := v.generateStepID(scenarioName, stepText)
+	v.startTimes.Store(stepID, v.now())
+	v.logger.Debug("start step", "step_id", stepID)
+	return stepID
+}
+
+// End records the duration of a step started with Start together with its
+// godog result status and the tags of its scenario. The row is written in
+// the background; write failures go to the error handler.
+func (v *VectorClockAgent) End(stepID, scenarioName, stepText string, status godog.StepResultStatus, tags []string) error {
+	rec, err := v.finish(stepID, scenarioName, stepText, status, tags)
+	if err != nil {
+		return err
+	}
+	v.emit(rec)
+	return v.enqueue([]stepRecord{rec})
+}
+
+// finish measures a started step and builds its record without persisting
+// it.
+func (v *VectorClockAgent) finish(stepID, scenarioName, stepText string, status godog.StepResultStatus, tags []string) (stepRecord, error) {
+	val, ok := v.startTimes.LoadAndDelete(stepID)
+	if !ok {
+		return stepRecord{}, fmt.Errorf("%w '%s'", ErrUnknownStep, stepID)
+	}
+	// The duration uses the monotonic clock; the persisted end time is
+	// derived from it so that wall-clock jumps cannot distort the timeline.
+	startTime, _ := val.(time.Time)
+	duration := v.since(startTime)
+	v.logger.Debug("end step", "step_id", stepID, "duration", duration)
+
+	return stepRecord{
+		stepID:       stepID,
+		scenarioName: scenarioName,
+		stepText:     stepText,
+		duration:     duration,
+		runID:        v.runID,
+		status:       status.String(),
+		tags:         strings.Join(tags, ","),
+		phase:        v.phase(),
+		attempt:      v.attemptNumber(),
+		startedAt:    startTime,
+		endedAt:      startTime.Add(duration),
+		process:      v.process,
+		clock:        v.Tick().String(),
+	}, nil
+}
+
+// Report prints every persisted step timing followed by the duration
+// distribution of each step text, a histogram of all step durations and the
+// steps of this run that were anomalously slow or fast (see Anomalies).
+// With WithRetries the retried steps follow, apart from their first
+// attempts. It prints nothing below VerbosityReport.
+func (v *VectorClockAgent) Report() error {
+	if v.verbosity < VerbosityReport {
+		return nil
+	}
+	timings, err := v.Timings(TimingFilter{})
+	if err != nil {
+		return err
+	}
+	fmt.Println("=== Step Duration Report (SQLite) ===")
+	if err := WriteTimingsIn(os.Stdout, timings, v.unit); err != nil {
+		return err
+	}
+
+	stats, err := v.StepStats()
+	if err != nil {
+		return err
+	}
+	fmt.Println("=== Step Duration Statistics ===")
+	if err := WriteStepStatsIn(os.Stdout, stats, v.unit); err != nil {
+		return err
+	}
+
+	buckets, err := v.Histogram("")
+	if err != nil {
+		return err
+	}
+	fmt.Println("=== Step Duration Histogram ===")
+	if err := WriteHistogram(os.Stdout, buckets, 40); err != nil {
+		return err
+	}
+
+	anomalies, err := v.Anomalies(v.runID, v.anomaly)
+	if err != nil {
+		return err
+	}
+	fmt.Println("=== Anomalies ===")
+	if err := WriteAnomalies(os.Stdout, anomalies); err != nil {
+		return err
+	}
+
+	if v.retries == 0 {
+		return nil
+	}
+	retried, err := v.RetriedSteps(v.runID)
+	if err != nil {
+		return err
+	}
+	fmt.Println("=== Retried Steps ===")
+	return WriteRetriedSteps(os.Stdout, retried)
+}
+
+// ScenarioFinished counts a completed scenario for the run summary.
+func (v *VectorClockAgent) ScenarioFinished(failed bool) {
+	atomic.AddUint64(&v.scenarios, 1)
+	if failed {
+		atomic.AddUint64(&v.failed, 1)
+	}
+}
+
+// Summary returns a single machine-parsable line describing the run, meant
+// to be the last line the agent prints so log scrapers can pick it up.
+func (v *VectorClockAgent) Summary() string {
+	return formatSummary(v.since(v.startedAt),
+		atomic.LoadUint64(&v.scenarios),
+		atomic.LoadUint64(&v.failed),
+		atomic.LoadUint64(&v.regressed),
+		v.runID,
+	)
+}
+
+// EmptySummary is the Summary line of a run without scenarios, for a
+// process that has nothing to run, such as a CI shard that got no feature
+// files, and so opens no agent.
+func EmptySummary() string {
+	return formatSummary(0, 0, 0, 0, newRunID(time.Now()))
+}
+
+func formatSummary(total time.Duration, scenarios, failed, regressed uint64, runID string) string {
+	return fmt.Sprintf("VC_SUMMARY total=%ss scenarios=%d failed=%d regressions=%d run_id=%s",
+		strconv.FormatFloat(total.Seconds(), 'f', 3, 64), scenarios, failed, regressed, runID)
+}
+
+// PrintSummary prints the Summary line unless the agent is silent. The
+// line is printed once, even when a signal of WithSignalHandling races the
+// caller's own call.
+func (v *VectorClockAgent) PrintSummary() {
+	if v.verbosity >= VerbositySummary {
+		v.summaryOnce.Do(func() { fmt.Println(v.Summary()) })
+	}
+}
+
+// Close writes all pending steps, reconciles the steps recorded by this run
+// with the database, merges them into the step digests, refreshes the scenario
+// stability scores, checkpoints the WAL into the main database file and
+// closes it. The returned error includes every write that failed since the
+// last Flush; steps that are still missing afterwards are reported.
+// Scenarios still running, as when godog stopped early, are ended first
+// with their open steps recorded as StatusAborted, or StatusInterrupted
+// after Interrupt.
+func (v *VectorClockAgent) Close() error {
+	v.abortRunning()
+	v.closeMu.Lock()
+	if v.closed {
+		v.closeMu.Unlock()
+		return ErrClosed
+	}
+	v.closed = true
+	close(v.writes)
+	v.closeMu.Unlock()
+	defer close(v.closeDone)
+	v.stopSignals()
+
+	<-v.writerDone
+	if v.readOnly {
+		return errors.Join(v.insertStmt.Close(), v.resourceStmt.Close(), v.db.Close())
+	}
+	errs := []error{v.writeErr, v.recordRun(), v.storeExecutions(), v.storeOutput()}
+	if v.collector != nil {
+		// Shipped steps are not in the local database to reconcile with.
+	} else if r, err := v.reconcile(v.rewriteMissing); err != nil {
+		errs = append(errs, err)
+	} else if !r.OK() {
+		v.logger.Warn("reconciliation found missing steps", "result", r)
+	}
+	if n := v.unsampled.Load(); n > 0 {
+		v.logger.Info("step sampling left steps unrecorded", "steps", n, "rate", v.sampling.Rate)
+	}
+	v.leakSummary()
+	errs = append(errs, v.writeAllureRun(), v.writeMetricsFile(), v.updateDigests(), v.updateLifecycle(), v.updateStability(), v.updateBaselines())
+	// Only main is opened in WAL mode; checkpointing a schema attached by
+	// WithSchema fails with "database table is locked".
+	if _, err := v.db.Exec(`PRAGMA main.wal_checkpoint(TRUNCATE)`); err != nil {
+		errs = append(errs, fmt.Errorf("failed to checkpoint WAL: %w", err))
+	}
+	if v.upload != nil {
+		if err := v.uploadRun(); err != nil {
+			v.handleError(fmt.Errorf("upload: %w", err))
+		}
+	}
+	errs = append(errs, v.insertStmt.Close(), v.resourceStmt.Close(), v.db.Close())
+	return errors.Join(errs...)
+}
@@ -0,0 +1,262 @@
+package vectorclocks
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FormatAllure is a directory of Allure result files, one
+// <uuid>-result.json per scenario execution, that Allure's report
+// generator and dashboards read.
+const FormatAllure = "allure"
+
+// WithAllureResults makes Close write the steps of the run to dir as
+// Allure results (see WriteAllureResults), so suites reporting to Allure
+// get the timings without another tool. Steps shipped WithCollector are
+// not in the local database and are not written.
+func WithAllureResults(dir string) Option {
+	return func(v *VectorClockAgent) {
+		v.allureDir = dir
+	}
+}
+
+type allureResult struct {
+	UUID          string            `json:"uuid"`
+	HistoryID     string            `json:"historyId"`
+	TestCaseID    string            `json:"testCaseId"`
+	Name          string            `json:"name"`
+	FullName      string            `json:"fullName"`
+	Status        string            `json:"status"`
+	StatusDetails *allureDetails    `json:"statusDetails,omitempty"`
+	Stage         string            `json:"stage"`
+	Start         int64             `json:"start"`
+	Stop          int64             `json:"stop"`
+	Labels        []allureLabel     `json:"labels"`
+	Parameters    []allureParameter `json:"parameters,omitempty"`
+	Steps         []allureStep      `json:"steps"`
+}
+
+type allureStep struct {
+	Name          string             `json:"name"`
+	Status        string             `json:"status"`
+	StatusDetails *allureDetails     `json:"statusDetails,omitempty"`
+	Stage         string             `json:"stage"`
+	Start         int64              `json:"start"`
+	Stop          int64              `json:"stop"`
+	Attachments   []allureAttachment `json:"attachments,omitempty"`
+}
+
+type allureDetails struct {
+	Message string `json:"message"`
+}
+
+type allureLabel struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type allureParameter struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type allureAttachment struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+}
+
+// allureStatus maps a step status to Allure's passed, failed, broken,
+// skipped or unknown. Steps that never ran to a verdict are broken.
+func allureStatus(status string) string {
+	switch status {
+	case "passed", "failed", "skipped":
+		return status
+	case StatusTimedOut:
+		return "failed"
+	case "pending":
+		return "skipped"
+	case StatusAborted, StatusInterrupted, "undefined", "ambiguous":
+		return "broken"
+	default:
+		return "unknown"
+	}
+}
+
+// allureRank orders statuses by how much they say about a scenario: the
+// scenario takes the status of its worst step.
+var allureRank = map[string]int{"unknown": 0, "passed": 1, "skipped": 2, "broken": 3, "failed": 4}
+
+// WriteAllureResults writes timings to dir as Allure results. Each
+// execution of a scenario, told apart by run, Examples row and attempt,
+// becomes a result whose steps carry their durations and statuses, and
+// whose tags become tag labels. Step annotations are written as text
+// attachments. dir is created when missing; existing results are kept, as
+// Allure merges a directory of results from many sources.
+func WriteAllureResults(dir string, timings []StepTiming) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+
+	type execution struct {
+		runID, feature, scenario string
+		exampleLine, attempt     int
+	}
+	var order []execution
+	groups := make(map[execution][]StepTiming)
+	for _, t := range timings {
+		if t.Phase != "" && t.Phase != phasePrimary {
+			continue
+		}
+		e := execution{t.RunID, t.FeatureURI, t.ScenarioName, t.ExampleLine, t.Attempt}
+		if _, ok := groups[e]; !ok {
+			order = append(order, e)
+		}
+		groups[e] = append(groups[e], t)
+	}
+
+	for _, e := range order {
+		steps := groups[e]
+		sort.SliceStable(steps, func(i, j int) bool { return stepStart(steps[i]).Before(stepStart(steps[j])) })
+		r, err := newAllureResult(dir, steps)
+		if err != nil {
+			return err
+		}
+		if err := writeAllureFile(filepath.Join(dir, r.UUID+"-result.json"), r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newAllureResult(dir string, steps []StepTiming) (allureResult, error) {
+	first := steps[0]
+	r := allureResult{
+		UUID:       newAllureUUID(),
+		HistoryID:  allureID(first.FeatureURI, first.ScenarioName, first.Example),
+		TestCaseID: allureID(first.FeatureURI, first.ScenarioName),
+		Name:       first.ScenarioName,
+		FullName:   first.ScenarioName,
+		Status:     "unknown",
+		Stage:      "finished",
+		Start:      stepStart(first).UnixMilli(),
+		Labels: []allureLabel{
+			{Name: "framework", Value: "godog"},
+			{Name: "language", Value: "go"},
+		},
+		Steps: []allureStep{},
+	}
+	if first.FeatureURI != "" {
+		r.FullName = first.FeatureURI + ": " + first.ScenarioName
+		r.Labels = append(r.Labels, allureLabel{Name: "feature", Value: first.FeatureURI}, allureLabel{Name: "suite", Value: first.FeatureURI})
+	}
+	for _, tag := range first.Tags {
+		r.Labels = append(r.Labels, allureLabel{Name: "tag", Value: strings.TrimPrefix(tag, "@")})
+	}
+	if first.Example != "" {
+		r.Parameters = append(r.Parameters, allureParameter{Name: "example", Value: first.Example})
+	}
+	if first.Attempt > 1 {
+		r.Parameters = append(r.Parameters, allureParameter{Name: "attempt", Value: fmt.Sprint(first.Attempt)})
+	}
+
+	for _, t := range steps {
+		start := stepStart(t)
+		s := allureStep{
+			Name:   t.StepText,
+			Status: allureStatus(t.Status),
+			Stage:  "finished",
+			Start:  start.UnixMilli(),
+			Stop:   start.Add(t.Duration).UnixMilli(),
+		}
+		if t.Status == StatusTimedOut || t.Status == StatusAborted || t.Status == StatusInterrupted {
+			s.StatusDetails = &allureDetails{Message: "step " + strings.ReplaceAll(t.Status, "_", " ")}
+		}
+		if len(t.Annotations) > 0 {
+			a, err := writeAllureAttachment(dir, "annotations", formatAnnotationLines(t.Annotations))
+			if err != nil {
+				return r, err
+			}
+			s.Attachments = append(s.Attachments, a)
+		}
+		if allureRank[s.Status] > allureRank[r.Status] {
+			r.Status, r.StatusDetails = s.Status, s.StatusDetails
+		}
+		if s.Stop > r.Stop {
+			r.Stop = s.Stop
+		}
+		r.Steps = append(r.Steps, s)
+	}
+	return r, nil
+}
+
+// allureID hashes parts into the stable IDs Allure uses to track a test
+// across runs.
+func allureID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// newAllureUUID returns a random version 4 UUID.
+func newAllureUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func writeAllureAttachment(dir, name, content string) (allureAttachment, error) {
+	source := newAllureUUID() + "-attachment.txt"
+	if err := os.WriteFile(filepath.Join(dir, source), []byte(content), 0o644); err != nil {
+		return allureAttachment{}, fmt.Errorf("failed to write Allure attachment: %w", err)
+	}
+	return allureAttachment{Name: name, Source: source, Type: "text/plain"}, nil
+}
+
+func writeAllureFile(path string, r allureResult) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("failed to encode Allure result: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write Allure result: %w", err)
+	}
+	return nil
+}
+
+// formatAnnotationLines writes annotations as key=value lines in key order.
+func formatAnnotationLines(annotations map[string]string) string {
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + "=" + annotations[k] + "\n")
+	}
+	return b.String()
+}
+
+// writeAllureRun writes the current run as configured by WithAllureResults.
+func (v *VectorClockAgent) writeAllureRun() error {
+	if v.allureDir == "" || v.collector != nil {
+		return nil
+	}
+	timings, err := v.Timings(TimingFilter{RunID: v.runID})
+	if err != nil {
+		return err
+	}
+	if len(timings) == 0 {
+		return nil
+	}
+	return WriteAllureResults(v.allureDir, timings)
+}
@@ -0,0 +1,86 @@
+package vectorclocks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Annotate attaches a key and value to the step running in ctx, such as the
+// request ID it sent, the number of records it checked or the environment
+// it targeted. Annotations are stored with the step's timing row and shown
+// by reports and exports; values are stored as their fmt.Sprint text. A key
+// annotated twice keeps the last value. Annotate is a no-op outside a step
+// recorded by the agent and after the step has ended.
+func (v *VectorClockAgent) Annotate(ctx context.Context, key string, value interface{}) {
+	info, ok := ctx.Value(stepKey).(*stepInfo)
+	if !ok {
+		return
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	if info.ended {
+		return
+	}
+	if info.annotations == nil {
+		info.annotations = make(map[string]string)
+	}
+	info.annotations[key] = fmt.Sprint(value)
+}
+
+// encodeAnnotations stores annotations as a JSON object, or NULL when there
+// are none.
+func encodeAnnotations(annotations map[string]string) (interface{}, error) {
+	if len(annotations) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(annotations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode annotations: %w", err)
+	}
+	return string(data), nil
+}
+
+func decodeAnnotations(s string) (map[string]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	var annotations map[string]string
+	if err := json.Unmarshal([]byte(s), &annotations); err != nil {
+		return nil, fmt.Errorf("failed to decode annotations: %w", err)
+	}
+	return annotations, nil
+}
+
+// jsonMatch returns a condition on the JSON object in column that holds
+// when it has the key of spec ("key"), or the key with the value of spec
+// ("key=value").
+func jsonMatch(column, spec string) (string, []interface{}) {
+	key, value, hasValue := strings.Cut(spec, "=")
+	path := jsonPath(key)
+	if hasValue {
+		return "json_extract(" + column + ", ?) = ?", []interface{}{path, value}
+	}
+	return "json_type(" + column + ", ?) IS NOT NULL", []interface{}{path}
+}
+
+// jsonPath returns the SQLite JSON path of key in an object.
+func jsonPath(key string) string {
+	return `$."` + strings.ReplaceAll(key, `"`, `\"`) + `"`
+}
+
+// formatAnnotations writes annotations as key=value pairs in key order.
+func formatAnnotations(annotations map[string]string) string {
+	keys := make([]string, 0, len(annotations))
+	for k := range annotations {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + annotations[k]
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,216 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// AnomalyMethod selects how the spread of a step's history is measured.
+type AnomalyMethod string
+
+const (
+	// AnomalyMAD measures distance from the median in median absolute
+	// deviations, which a few outliers in the history cannot inflate.
+	AnomalyMAD AnomalyMethod = "mad"
+	// AnomalyStdDev measures distance from the mean in standard deviations.
+	AnomalyStdDev AnomalyMethod = "stddev"
+)
+
+// AnomalyPolicy decides when a step duration in a run is anomalous.
+type AnomalyPolicy struct {
+	Method AnomalyMethod
+	// K is the number of MADs or standard deviations a duration may be
+	// away from the history before it is flagged.
+	K float64
+	// Window is the number of earlier runs forming the history.
+	Window int
+	// MinRuns is the number of earlier runs a step needs before it is
+	// judged.
+	MinRuns int
+}
+
+// DefaultAnomalyPolicy flags durations more than 3.5 scaled MADs from the
+// median of the last 30 runs.
+var DefaultAnomalyPolicy = AnomalyPolicy{Method: AnomalyMAD, K: 3.5, Window: 30, MinRuns: 5}
+
+// WithAnomalyPolicy sets the policy for the anomalies section of Report.
+// Zero fields fall back to DefaultAnomalyPolicy.
+func WithAnomalyPolicy(policy AnomalyPolicy) Option {
+	return func(v *VectorClockAgent) {
+		v.anomaly = policy.withDefaults()
+	}
+}
+
+func (p AnomalyPolicy) withDefaults() AnomalyPolicy {
+	if p.Method == "" {
+		p.Method = DefaultAnomalyPolicy.Method
+	}
+	if p.K <= 0 {
+		p.K = DefaultAnomalyPolicy.K
+	}
+	if p.Window <= 0 {
+		p.Window = DefaultAnomalyPolicy.Window
+	}
+	if p.MinRuns <= 0 {
+		p.MinRuns = DefaultAnomalyPolicy.MinRuns
+	}
+	return p
+}
+
+// Anomaly is a step whose duration in one run lies far outside its history.
+type Anomaly struct {
+	Key      StepKey
+	Duration time.Duration
+	// Center is the median or mean of the history, Spread the scaled MAD
+	// or standard deviation.
+	Center time.Duration
+	Spread time.Duration
+	// Score is the signed distance from Center in units of Spread.
+	Score float64
+	Runs  int
+}
+
+// madScale turns a median absolute deviation into an estimate of the
+// standard deviation of normally distributed data.
+const madScale = 1.4826
+
+// Anomalies returns the steps of runID whose duration deviates more than
+// policy.K MADs or standard deviations from the same step in up to
+// policy.Window earlier runs, largest deviation first. Steps whose history
+// never varied are not judged.
+func (v *VectorClockAgent) Anomalies(runID string, policy AnomalyPolicy) ([]Anomaly, error) {
+	policy = policy.withDefaults()
+	runIDs, err := v.runsBefore(runID, policy.Window)
+	if err != nil {
+		return nil, err
+	}
+	if len(runIDs) < policy.MinRuns {
+		return nil, nil
+	}
+
+	history := make(map[StepKey][]time.Duration)
+	for _, id := range runIDs {
+		rt, err := v.RunTimings(id)
+		if err != nil {
+			return nil, err
+		}
+		for key, d := range rt.Steps {
+			history[key] = append(history[key], d)
+		}
+	}
+	head, err := v.RunTimings(runID)
+	if err != nil {
+		return nil, err
+	}
+
+	var anomalies []Anomaly
+	for key, d := range head.Steps {
+		past := history[key]
+		if len(past) < policy.MinRuns {
+			continue
+		}
+		center, spread := policy.spread(past)
+		if spread == 0 {
+			continue
+		}
+		score := float64(d-center) / spread
+		if math.Abs(score) <= policy.K {
+			continue
+		}
+		anomalies = append(anomalies, Anomaly{
+			Key:      key,
+			Duration: d,
+			Center:   center,
+			Spread:   time.Duration(spread),
+			Score:    score,
+			Runs:     len(past),
+		})
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		if a, b := math.Abs(anomalies[i].Score), math.Abs(anomalies[j].Score); a != b {
+			return a > b
+		}
+		return anomalies[i].Key.String() < anomalies[j].Key.String()
+	})
+	return anomalies, nil
+}
+
+// spread returns the center and spread of d under the policy's method.
+func (p AnomalyPolicy) spread(d []time.Duration) (time.Duration, float64) {
+	if p.Method == AnomalyStdDev {
+		var sum float64
+		for _, x := range d {
+			sum += float64(x)
+		}
+		mean := sum / float64(len(d))
+		var sq float64
+		for _, x := range d {
+			sq += (float64(x) - mean) * (float64(x) - mean)
+		}
+		return time.Duration(mean), math.Sqrt(sq / float64(len(d)))
+	}
+
+	sorted := append([]time.Duration(nil), d...)
+	sortDurations(sorted)
+	median := percentile(sorted, 50)
+	deviations := make([]time.Duration, len(sorted))
+	for i, x := range sorted {
+		deviations[i] = x - median
+		if deviations[i] < 0 {
+			deviations[i] = -deviations[i]
+		}
+	}
+	sortDurations(deviations)
+	return median, madScale * float64(percentile(deviations, 50))
+}
+
+// runsBefore returns up to n runs that started before runID, newest first.
+// For the current run those are its comparable baseline runs.
+func (v *VectorClockAgent) runsBefore(runID string, n int) ([]string, error) {
+	if runID == v.runID {
+		return v.BaselineRuns(n)
+	}
+	rows, err := v.db.Query(`
+		SELECT run_id FROM runs
+		WHERE started_at < (SELECT started_at FROM runs WHERE run_id = ?)
+		ORDER BY started_at DESC, rowid DESC
+		LIMIT ?
+	`, runID, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs before %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, id)
+	}
+	return runIDs, rows.Err()
+}
+
+// WriteAnomalies prints anomalies as a table, one step per row.
+func WriteAnomalies(w io.Writer, anomalies []Anomaly) error {
+	if len(anomalies) == 0 {
+		_, err := fmt.Fprintln(w, "no anomalies")
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%9s %9s %9s %7s %5s  %s\n", "duration", "usual", "spread", "score", "runs", "step"); err != nil {
+		return err
+	}
+	ms := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	for _, a := range anomalies {
+		_, err := fmt.Fprintf(w, "%9s %9s %9s %+7.1f %5d  %s\n",
+			ms(a.Duration), ms(a.Center), ms(a.Spread), a.Score, a.Runs, a.Key)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// DefaultReservoirSize is the number of samples kept per step by
+// WithApproximateStats when no size is given.
+const DefaultReservoirSize = 1024
+
+// WithApproximateStats keeps a running summary and a uniform random sample
+// of at most size durations per step text, updated as steps are written.
+// StepStats then reads those instead of scanning every raw row: count,
+// mean, min and max stay exact, percentiles come from the step's t-digest
+// (see StepDigest) or, before the first run closed, the sample. The first
+// agent opened with the option samples the rows already recorded. The
+// summaries cover all history; Prune and Downsample do not shrink them.
+func WithApproximateStats(size int) Option {
+	return func(v *VectorClockAgent) {
+		if size <= 0 {
+			size = DefaultReservoirSize
+		}
+		v.reservoirSize = size
+	}
+}
+
+// sampleStep records one primary-phase duration in tx using reservoir
+// sampling (Algorithm R), so every duration seen so far is equally likely to
+// be in the sample.
+func (v *VectorClockAgent) sampleStep(tx *namedTx, stepText string, d time.Duration) error {
+	stepText = normalizeStepText(stepText)
+	ms, ns := d.Milliseconds(), d.Nanoseconds()
+	var seen int64
+	err := tx.QueryRow(`
+		INSERT INTO step_summary (step_text, count, total_ms, min_ms, max_ms, total_ns, min_ns, max_ns) VALUES (?, 1, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (step_text) DO UPDATE SET
+			count = count + 1,
+			total_ms = total_ms + excluded.total_ms,
+			min_ms = MIN(min_ms, excluded.min_ms),
+			max_ms = MAX(max_ms, excluded.max_ms),
+			total_ns = total_ns + excluded.total_ns,
+			min_ns = MIN(min_ns, excluded.min_ns),
+			max_ns = MAX(max_ns, excluded.max_ns)
+		RETURNING count
+	`, stepText, ms, ms, ms, ns, ns, ns).Scan(&seen)
+	if err != nil {
+		return fmt.Errorf("failed to update summary of step %q: %w", stepText, err)
+	}
+
+	slot := seen - 1
+	if seen > int64(v.reservoirSize) {
+		if slot = rand.Int63n(seen); slot >= int64(v.reservoirSize) {
+			return nil
+		}
+	}
+	_, err = tx.Exec(`INSERT OR REPLACE INTO step_reservoir (step_text, slot, duration_ms, duration_ns) VALUES (?, ?, ?, ?)`,
+		stepText, slot, ms, ns)
+	if err != nil {
+		return fmt.Errorf("failed to sample step %q: %w", stepText, err)
+	}
+	return nil
+}
+
+// RebuildApproximateStats recomputes the step summaries and reservoirs from
+// the raw rows, e.g. after pruning to let old durations drop out.
+func (v *VectorClockAgent) RebuildApproximateStats() error {
+	if v.reservoirSize == 0 {
+		return fmt.Errorf("approximate stats are not enabled")
+	}
+	v.sync()
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM step_summary`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM step_reservoir`); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT step_text, ` + durationNs + ` FROM step_timings WHERE ` + primaryPhase + ` ORDER BY id`)
+	if err != nil {
+		return fmt.Errorf("failed to load step durations: %w", err)
+	}
+	type sample struct {
+		text string
+		ns   int64
+	}
+	var all []sample
+	for rows.Next() {
+		var s sample
+		if err := rows.Scan(&s.text, &s.ns); err != nil {
+			rows.Close()
+			return err
+		}
+		all = append(all, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, s := range all {
+		if err := v.sampleStep(tx, s.text, time.Duration(s.ns)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// backfillApproximateStats samples the existing rows the first time
+// WithApproximateStats is used on a database.
+func (v *VectorClockAgent) backfillApproximateStats() error {
+	if v.reservoirSize == 0 {
+		return nil
+	}
+	var summarized, recorded bool
+	if err := v.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM step_summary)`).Scan(&summarized); err != nil {
+		return fmt.Errorf("failed to check step summaries: %w", err)
+	}
+	if summarized {
+		return nil
+	}
+	if err := v.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM step_timings)`).Scan(&recorded); err != nil {
+		return fmt.Errorf("failed to check step timings: %w", err)
+	}
+	if !recorded {
+		return nil
+	}
+	return v.RebuildApproximateStats()
+}
+
+// approximateStepStats is StepStats read from the summaries and reservoirs.
+func (v *VectorClockAgent) approximateStepStats() ([]StepStats, error) {
+	v.sync()
+
+	samples := make(map[string][]time.Duration)
+	rows, err := v.db.Query(`SELECT step_text, duration_ns FROM step_reservoir`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load step samples: %w", err)
+	}
+	for rows.Next() {
+		var text string
+		var ns int64
+		if err := rows.Scan(&text, &ns); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		samples[text] = append(samples[text], time.Duration(ns))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	digests, err := v.StepDigests()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = v.db.Query(`SELECT step_text, count, total_ns, min_ns, max_ns FROM step_summary`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load step summaries: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []StepStats
+	for rows.Next() {
+		var text string
+		var count, totalNs, minNs, maxNs int64
+		if err := rows.Scan(&text, &count, &totalNs, &minNs, &maxNs); err != nil {
+			return nil, err
+		}
+		s := StepStats{
+			Step:  text,
+			Count: int(count),
+			Mean:  time.Duration(totalNs / count),
+			Min:   time.Duration(minNs),
+			Max:   time.Duration(maxNs),
+		}
+		if t := digests[text]; t != nil {
+			s.P50, s.P90, s.P95, s.P99 = digestQuantile(t, 0.5), digestQuantile(t, 0.9), digestQuantile(t, 0.95), digestQuantile(t, 0.99)
+		} else {
+			d := samples[text]
+			sortDurations(d)
+			s.P50, s.P90, s.P95, s.P99 = percentile(d, 50), percentile(d, 90), percentile(d, 95), percentile(d, 99)
+		}
+		stats = append(stats, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortStepStats(stats)
+	return stats, nil
+}
@@ -0,0 +1,133 @@
+package vectorclocks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runTables are the tables holding rows of a single run, keyed by run_id.
+var runTables = []string{"runs", "run_flags", "step_timings", "step_resources", "step_spans", "scenario_executions", "run_output", "step_definitions"}
+
+// ArchiveRun writes the current run to a new SQLite file at path holding
+// only that run's rows and the agent schema, small enough to upload as a CI
+// artifact; the file can be opened like any other database. Call it after
+// RunSuite: it stores what the agent otherwise stores on Close.
+func (v *VectorClockAgent) ArchiveRun(path string) error {
+	v.sync()
+	if err := errors.Join(v.recordRun(), v.storeExecutions(), v.storeOutput()); err != nil {
+		return err
+	}
+	return v.WriteRunArchive(v.runID, path)
+}
+
+// WriteRunArchive writes runID to a new SQLite file at path as ArchiveRun
+// does. It fails when path already exists.
+func (v *VectorClockAgent) WriteRunArchive(runID, path string) error {
+	v.sync()
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("archive %s already exists", path)
+	}
+	if err := createArchive(path, v.connection.Key); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	conn, err := v.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS vc_archive`, path); err != nil {
+		return fmt.Errorf("failed to attach archive %s: %w", path, err)
+	}
+	defer conn.ExecContext(ctx, `DETACH DATABASE vc_archive`)
+
+	for _, table := range runTables {
+		columns, err := archiveColumns(ctx, conn, v.naming, table)
+		if err != nil {
+			return err
+		}
+		list := strings.Join(columns, ", ")
+		_, err = conn.ExecContext(ctx, fmt.Sprintf(`INSERT INTO vc_archive.%s (%s) SELECT %s FROM %q.%q WHERE run_id = ?`,
+			table, list, list, v.naming.schemaName(), v.naming.table(table)), runID)
+		if err != nil {
+			return fmt.Errorf("failed to archive %s of run %s: %w", table, runID, err)
+		}
+	}
+	return nil
+}
+
+// createArchive creates an empty database at path with the agent schema,
+// encrypted with key when it is set, as the agent's database is.
+func createArchive(path, key string) error {
+	db, err := sql.Open(driverName(key, naming{}), path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive %s: %w", path, err)
+	}
+	defer db.Close()
+	if err := migrate(&namedDB{DB: db}); err != nil {
+		return fmt.Errorf("failed to create schema of archive %s: %w", path, err)
+	}
+	return nil
+}
+
+// archiveColumns returns the columns table has both in the archive and in
+// the agent's database, which may predate some of them.
+func archiveColumns(ctx context.Context, conn *sql.Conn, n naming, table string) ([]string, error) {
+	archived, err := tableColumns(ctx, conn, "vc_archive", table)
+	if err != nil {
+		return nil, err
+	}
+	source, err := tableColumns(ctx, conn, n.schemaName(), n.table(table))
+	if err != nil {
+		return nil, err
+	}
+	return sharedColumns(archived, source), nil
+}
+
+// queryer is a connection or transaction.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// tableColumns returns the columns of schema.table in declaration order;
+// none when the table does not exist.
+func tableColumns(ctx context.Context, q queryer, schema, table string) ([]string, error) {
+	rows, err := q.QueryContext(ctx, fmt.Sprintf(`PRAGMA %q.table_info(%q)`, schema, table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+	var columns []string
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, typ string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// sharedColumns returns the columns of into that from has as well.
+func sharedColumns(into, from []string) []string {
+	have := make(map[string]bool, len(from))
+	for _, c := range from {
+		have[c] = true
+	}
+	var columns []string
+	for _, c := range into {
+		if have[c] {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
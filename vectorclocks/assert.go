@@ -0,0 +1,38 @@
+package vectorclocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrTooSlow fails a step that ran past the limit it asserted with
+// AssertUnder.
+var ErrTooSlow = errors.New("vectorclocks: step slower than asserted")
+
+// AssertUnder checks that the step running in ctx has taken less than
+// limit so far, measured from the start time the agent recorded for it,
+// and returns an error wrapping ErrTooSlow otherwise. Return it from the
+// end of a step definition to turn a timing expectation into a failure:
+//
+//	return agent.AssertUnder(ctx, 500*time.Millisecond)
+//
+// The limit is annotated on the step as "assert_under". AssertUnder needs
+// the step context of InitializeScenario's hooks and fails without it, so
+// that an assertion never passes unchecked.
+func (v *VectorClockAgent) AssertUnder(ctx context.Context, limit time.Duration) error {
+	info, ok := ctx.Value(stepKey).(*stepInfo)
+	if !ok {
+		return errors.New("vectorclocks: AssertUnder called outside a step recorded by InitializeScenario")
+	}
+	val, ok := v.startTimes.Load(info.id)
+	if !ok {
+		return fmt.Errorf("%w '%s'", ErrUnknownStep, info.id)
+	}
+	v.Annotate(ctx, "assert_under", limit)
+	if elapsed := v.since(val.(time.Time)); elapsed >= limit {
+		return fmt.Errorf("%w: '%s' took %s, limit %s", ErrTooSlow, info.text, elapsed.Round(time.Millisecond), limit)
+	}
+	return nil
+}
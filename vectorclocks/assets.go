@@ -0,0 +1,58 @@
+package vectorclocks
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// embeddedAssets holds the schema migrations and report templates so the
+// binary works without any files next to it.
+//
+//go:embed migrations/*.sql templates/*
+var embeddedAssets embed.FS
+
+// overlayFS serves a file from dir when it exists there and falls back to
+// the embedded copy otherwise, so users can override single templates.
+type overlayFS struct {
+	dir  string
+	base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := os.DirFS(o.dir).Open(name)
+	if err == nil {
+		return f, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return o.base.Open(name)
+}
+
+// WithAssetDir lets files below dir (for example templates/sla.html.tmpl)
+// replace the embedded report templates and static assets. Migrations are
+// always taken from the binary.
+func WithAssetDir(dir string) Option {
+	return func(v *VectorClockAgent) {
+		if dir != "" {
+			v.assets = overlayFS{dir: dir, base: embeddedAssets}
+		}
+	}
+}
+
+func loadTemplate(fsys fs.FS, name string, funcs template.FuncMap) (*template.Template, error) {
+	src, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", name, err)
+	}
+	tmpl, err := template.New(path.Base(name)).Funcs(funcs).Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %w", name, err)
+	}
+	return tmpl, nil
+}
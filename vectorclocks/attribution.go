@@ -0,0 +1,123 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Levels accepted by Attribution.
+const (
+	// ShareByFeature attributes time to feature files.
+	ShareByFeature = "feature"
+	// ShareByScenario attributes time to scenarios.
+	ShareByScenario = "scenario"
+	// ShareByStep attributes time to step texts, whitespace-normalized and
+	// summed across the scenarios sharing them, as a step definition is
+	// optimized once for all of them.
+	ShareByStep = "step"
+)
+
+// Share is the part of the total step time spent in one feature, scenario
+// or step. Percent is the share of the total and Cumulative the share of
+// it and every larger item, both from 0 to 100.
+type Share struct {
+	Name       string
+	Duration   time.Duration
+	Percent    float64
+	Cumulative float64
+}
+
+// Attribution returns the share of the step time of the steps matching
+// filter spent in every feature, scenario or step (see ShareByFeature and
+// the other levels), largest first, so the few items costing most of the
+// suite's runtime stand out.
+func (v *VectorClockAgent) Attribution(level string, filter TimingFilter) ([]Share, error) {
+	timings, err := v.Timings(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attribution: %w", err)
+	}
+	return Attribute(timings, level)
+}
+
+// Attribute computes the shares of timings at level. Only primary-phase
+// steps are counted.
+func Attribute(timings []StepTiming, level string) ([]Share, error) {
+	var key func(StepTiming) string
+	switch level {
+	case ShareByFeature:
+		key = func(t StepTiming) string { return t.FeatureURI }
+	case ShareByScenario:
+		key = func(t StepTiming) string { return t.ScenarioName }
+	case ShareByStep:
+		key = func(t StepTiming) string { return normalizeStepText(t.StepText) }
+	default:
+		return nil, fmt.Errorf("unknown attribution level %q (want %s, %s or %s)", level, ShareByFeature, ShareByScenario, ShareByStep)
+	}
+
+	var total time.Duration
+	durations := make(map[string]time.Duration)
+	for _, t := range timings {
+		if t.Phase != "" && t.Phase != phasePrimary {
+			continue
+		}
+		durations[key(t)] += t.Duration
+		total += t.Duration
+	}
+	shares := make([]Share, 0, len(durations))
+	for name, d := range durations {
+		shares = append(shares, Share{Name: name, Duration: d})
+	}
+	sort.Slice(shares, func(i, j int) bool {
+		if shares[i].Duration != shares[j].Duration {
+			return shares[i].Duration > shares[j].Duration
+		}
+		return shares[i].Name < shares[j].Name
+	})
+	var cumulative time.Duration
+	for i := range shares {
+		cumulative += shares[i].Duration
+		if total > 0 {
+			shares[i].Percent = 100 * float64(shares[i].Duration) / float64(total)
+			shares[i].Cumulative = 100 * float64(cumulative) / float64(total)
+		}
+	}
+	return shares, nil
+}
+
+// ParetoCut returns the leading shares that together reach percent of the
+// total time.
+func ParetoCut(shares []Share, percent float64) []Share {
+	for i, s := range shares {
+		if s.Cumulative >= percent {
+			return shares[:i+1]
+		}
+	}
+	return shares
+}
+
+// WriteShares prints shares under title, largest first, with their
+// cumulative percentage. When shown is shorter than all, a closing line
+// tells how many of all items the rows cover.
+func WriteShares(w io.Writer, title string, shown, all []Share) error {
+	if _, err := fmt.Fprintf(w, "%s\n%10s %7s %11s  %s\n", title, "time", "share", "cumulative", "name"); err != nil {
+		return err
+	}
+	for _, s := range shown {
+		name := s.Name
+		if name == "" {
+			name = "(unknown)"
+		}
+		_, err := fmt.Fprintf(w, "%10s %6.1f%% %10.1f%%  %s\n", s.Duration.Round(time.Millisecond), s.Percent, s.Cumulative, name)
+		if err != nil {
+			return err
+		}
+	}
+	if len(shown) == 0 || len(shown) == len(all) {
+		return nil
+	}
+	_, err := fmt.Fprintf(w, "%d of %d (%.1f%%) account for %.1f%% of the time\n",
+		len(shown), len(all), 100*float64(len(shown))/float64(len(all)), shown[len(shown)-1].Cumulative)
+	return err
+}
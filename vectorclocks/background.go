@@ -0,0 +1,97 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Step origins stored with each step whose feature file could be read.
+const (
+	OriginBackground = "background"
+	OriginScenario   = "scenario"
+)
+
+// BackgroundCost is what the Background steps of one feature cost in a
+// run.
+type BackgroundCost struct {
+	FeatureURI string
+	// Scenarios is the number of scenario executions that ran the
+	// Background.
+	Scenarios  int
+	Background time.Duration
+	// Total is the duration of all steps of the feature, Background
+	// included.
+	Total time.Duration
+}
+
+// BackgroundReport is the Background overhead of a run.
+type BackgroundReport struct {
+	RunID      string
+	Features   []BackgroundCost
+	Background time.Duration
+	Total      time.Duration
+}
+
+// BackgroundOverhead returns the time runID spent in Background steps, per
+// feature with the costliest first. Background steps run before every
+// scenario of their feature, so a slow one is paid many times over; steps
+// recorded before origins were tracked, or whose feature file could not be
+// read, are left out.
+func (v *VectorClockAgent) BackgroundOverhead(runID string) (BackgroundReport, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT COALESCE(feature_uri, ''),
+			COUNT(DISTINCT CASE WHEN origin = 'background' THEN scenario_name || ':' || COALESCE(example_line, 0) END),
+			SUM(CASE WHEN origin = 'background' THEN `+durationNs+` * COALESCE(sample_rate, 1) ELSE 0 END),
+			SUM(`+durationNs+` * COALESCE(sample_rate, 1))
+		FROM step_timings
+		WHERE run_id = ? AND `+primaryPhase+` AND origin IS NOT NULL
+		GROUP BY COALESCE(feature_uri, '')
+		ORDER BY 3 DESC
+	`, runID)
+	if err != nil {
+		return BackgroundReport{}, fmt.Errorf("failed to load background overhead: %w", err)
+	}
+	defer rows.Close()
+
+	r := BackgroundReport{RunID: runID}
+	for rows.Next() {
+		var c BackgroundCost
+		var backgroundNs, totalNs int64
+		if err := rows.Scan(&c.FeatureURI, &c.Scenarios, &backgroundNs, &totalNs); err != nil {
+			return BackgroundReport{}, err
+		}
+		c.Background, c.Total = time.Duration(backgroundNs), time.Duration(totalNs)
+		r.Background += c.Background
+		r.Total += c.Total
+		if c.Background > 0 {
+			r.Features = append(r.Features, c)
+		}
+	}
+	return r, rows.Err()
+}
+
+// WriteBackgroundOverhead prints r with each feature's share of its own
+// runtime spent in Background steps.
+func WriteBackgroundOverhead(w io.Writer, r BackgroundReport) error {
+	fmt.Fprintf(w, "=== Background overhead of run %s: %s of %s (%.1f%%) ===\n",
+		r.RunID, r.Background.Round(time.Millisecond), r.Total.Round(time.Millisecond), percentOf(r.Background, r.Total))
+	for _, c := range r.Features {
+		_, err := fmt.Fprintf(w, "%s: %s over %d scenarios, %s each (%.1f%% of the feature)\n",
+			c.FeatureURI, c.Background.Round(time.Millisecond), c.Scenarios,
+			(c.Background / time.Duration(max(c.Scenarios, 1))).Round(time.Millisecond), percentOf(c.Background, c.Total))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func percentOf(part, whole time.Duration) float64 {
+	if whole <= 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(whole)
+}
@@ -0,0 +1,214 @@
+package vectorclocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// baselineFileVersion is bumped when the baseline file format changes
+// incompatibly.
+const baselineFileVersion = 1
+
+// BaselineFile holds expected duration bands for scenarios and steps. It is
+// meant to be committed next to the features so CI can gate a run without
+// access to the historical database.
+type BaselineFile struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	Runs      int            `json:"runs"`
+	Tolerance float64        `json:"tolerance_percent"`
+	Scenarios []BaselineBand `json:"scenarios"`
+	Steps     []BaselineBand `json:"steps"`
+}
+
+// BaselineBand is the expected duration range of one scenario or step. Step
+// bands carry both Scenario and Step; scenario bands only Scenario.
+type BaselineBand struct {
+	Scenario string `json:"scenario"`
+	Step     string `json:"step,omitempty"`
+	MedianMs int64  `json:"median_ms"`
+	UpperMs  int64  `json:"upper_ms"`
+}
+
+func (b BaselineBand) name() string {
+	if b.Step == "" {
+		return b.Scenario
+	}
+	return StepKey{Scenario: b.Scenario, Step: b.Step}.String()
+}
+
+// BuildBaselineFile derives bands from up to n comparable runs (see
+// BaselineRuns, which excludes the current run). The upper bound of a band
+// is the 95th percentile raised by tolerance percent.
+func (v *VectorClockAgent) BuildBaselineFile(n int, tolerance float64) (BaselineFile, error) {
+	runIDs, err := v.BaselineRuns(n)
+	if err != nil {
+		return BaselineFile{}, err
+	}
+	if len(runIDs) == 0 {
+		return BaselineFile{}, fmt.Errorf("no runs recorded to build a baseline from")
+	}
+
+	scenarios := make(map[string][]time.Duration)
+	steps := make(map[StepKey][]time.Duration)
+	for _, runID := range runIDs {
+		rt, err := v.RunTimings(runID)
+		if err != nil {
+			return BaselineFile{}, err
+		}
+		for name, d := range rt.Scenarios {
+			scenarios[name] = append(scenarios[name], d)
+		}
+		for key, d := range rt.Steps {
+			steps[key] = append(steps[key], d)
+		}
+	}
+
+	band := func(key StepKey, d []time.Duration) BaselineBand {
+		sortDurations(d)
+		upper := float64(percentile(d, 95)) * (1 + tolerance/100)
+		return BaselineBand{
+			Scenario: key.Scenario,
+			Step:     key.Step,
+			MedianMs: percentile(d, 50).Milliseconds(),
+			UpperMs:  time.Duration(upper).Milliseconds(),
+		}
+	}
+	f := BaselineFile{
+		Version:   baselineFileVersion,
+		CreatedAt: time.Now().UTC().Truncate(time.Second),
+		Runs:      len(runIDs),
+		Tolerance: tolerance,
+	}
+	for name, d := range scenarios {
+		f.Scenarios = append(f.Scenarios, band(StepKey{Scenario: name}, d))
+	}
+	for key, d := range steps {
+		f.Steps = append(f.Steps, band(key, d))
+	}
+	sortBands(f.Scenarios)
+	sortBands(f.Steps)
+	return f, nil
+}
+
+func sortBands(bands []BaselineBand) {
+	sort.Slice(bands, func(i, j int) bool { return bands[i].name() < bands[j].name() })
+}
+
+// Write encodes f as indented JSON, so diffs of a committed file stay
+// readable.
+func (f BaselineFile) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(f)
+}
+
+// LoadBaselineFile reads a baseline file written by BaselineFile.Write.
+func LoadBaselineFile(path string) (BaselineFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return BaselineFile{}, fmt.Errorf("failed to read baseline file: %w", err)
+	}
+	var f BaselineFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return BaselineFile{}, fmt.Errorf("failed to parse baseline file %s: %w", path, err)
+	}
+	if f.Version != baselineFileVersion {
+		return BaselineFile{}, fmt.Errorf("baseline file %s has version %d, want %d", path, f.Version, baselineFileVersion)
+	}
+	return f, nil
+}
+
+// BandViolation is a scenario or step that ran longer than its band allows.
+type BandViolation struct {
+	Name     string
+	Duration time.Duration
+	Median   time.Duration
+	Upper    time.Duration
+}
+
+// Check returns the scenarios and steps of rt that exceed their band.
+// Items missing from the file are ignored.
+func (f BaselineFile) Check(rt RunTimings) []BandViolation {
+	var violations []BandViolation
+	check := func(b BaselineBand, d time.Duration, ok bool) {
+		upper := time.Duration(b.UpperMs) * time.Millisecond
+		if ok && d > upper {
+			violations = append(violations, BandViolation{
+				Name:     b.name(),
+				Duration: d,
+				Median:   time.Duration(b.MedianMs) * time.Millisecond,
+				Upper:    upper,
+			})
+		}
+	}
+	for _, b := range f.Scenarios {
+		d, ok := rt.Scenarios[b.Scenario]
+		check(b, d, ok)
+	}
+	for _, b := range f.Steps {
+		d, ok := rt.Steps[StepKey{Scenario: b.Scenario, Step: b.Step}]
+		check(b, d, ok)
+	}
+	return violations
+}
+
+// WriteViolations prints violations one per line.
+func WriteViolations(w io.Writer, violations []BandViolation) error {
+	for _, b := range violations {
+		_, err := fmt.Fprintf(w, "!! %s: %s exceeds band (median %s, upper %s)\n", b.Name,
+			b.Duration.Round(time.Millisecond), b.Median.Round(time.Millisecond), b.Upper.Round(time.Millisecond))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithBaselineFile makes RunSuite check the finished run against the bands
+// in the baseline file at path and fail with RegressionExitCode when any
+// scenario or step exceeds its band. It needs no earlier runs in the
+// database.
+func WithBaselineFile(path string) Option {
+	return func(v *VectorClockAgent) {
+		v.baselineFile = path
+	}
+}
+
+// applyBaselineFile checks the run against the baseline file and returns
+// the final status.
+func (v *VectorClockAgent) applyBaselineFile(status int) int {
+	f, err := LoadBaselineFile(v.baselineFile)
+	if err != nil {
+		v.handleError(err)
+		return status
+	}
+	rt, err := v.RunTimings(v.runID)
+	if err != nil {
+		v.handleError(fmt.Errorf("baseline file check: %w", err))
+		return status
+	}
+
+	violations := f.Check(rt)
+	if len(violations) == 0 {
+		return status
+	}
+	v.addRegressions(len(violations))
+	if v.verbosity >= VerbositySummary {
+		fmt.Println("=== Baseline Band Violations ===")
+		WriteViolations(os.Stdout, violations)
+	}
+	names := make([]string, len(violations))
+	for i, b := range violations {
+		names[i] = b.Name
+	}
+	v.printAnnotations(names, ViolationAnnotations(violations))
+	if status == 0 {
+		status = RegressionExitCode
+	}
+	return status
+}
@@ -0,0 +1,92 @@
+package vectorclocks
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBaselineFileGate(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "timings.db")
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 3; i++ {
+		recordRun(t, dbPath, start.Add(time.Duration(i)*time.Minute), time.Millisecond)
+	}
+
+	a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := a.BuildBaselineFile(10, 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if f.Runs != 3 || len(f.Scenarios) != 1 || len(f.Steps) != 2 {
+		t.Fatalf("BuildBaselineFile = %+v, want 3 runs, 1 scenario and 2 step bands", f)
+	}
+
+	path := filepath.Join(dir, "baseline.json")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Write(out); err != nil {
+		t.Fatal(err)
+	}
+	out.Close()
+	if loaded, err := LoadBaselineFile(path); err != nil || loaded.Runs != f.Runs || len(loaded.Steps) != len(f.Steps) {
+		t.Fatalf("LoadBaselineFile = %+v, %v; want the written file", loaded, err)
+	}
+
+	// The database is only needed to record the gated run: a fresh one
+	// without history gates just the same.
+	fresh := filepath.Join(dir, "fresh.db")
+	if status := recordRun(t, fresh, start.Add(10*time.Minute), time.Millisecond, WithBaselineFile(path)); status != 0 {
+		t.Errorf("run within its bands: status = %d, want 0", status)
+	}
+	if status := recordRun(t, fresh, start.Add(11*time.Minute), 10*time.Millisecond, WithBaselineFile(path)); status != RegressionExitCode {
+		t.Errorf("run exceeding its bands: status = %d, want %d", status, RegressionExitCode)
+	}
+}
+
+func TestBaselineFileCheck(t *testing.T) {
+	f := BaselineFile{
+		Scenarios: []BaselineBand{{Scenario: "checkout", MedianMs: 10, UpperMs: 20}},
+		Steps: []BaselineBand{
+			{Scenario: "checkout", Step: "I pay", MedianMs: 5, UpperMs: 8},
+			{Scenario: "refund", Step: "I refund", MedianMs: 5, UpperMs: 8},
+		},
+	}
+	rt := RunTimings{
+		Scenarios: map[string]time.Duration{"checkout": 20 * time.Millisecond},
+		Steps:     map[StepKey]time.Duration{{Scenario: "checkout", Step: "I pay"}: 9 * time.Millisecond},
+	}
+	violations := f.Check(rt)
+	if len(violations) != 1 || violations[0].Name != (StepKey{Scenario: "checkout", Step: "I pay"}).String() {
+		t.Errorf("Check = %+v, want only the step over its upper bound", violations)
+	}
+}
+
+func TestLoadBaselineFileVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := os.WriteFile(path, []byte(`{"version": 2}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBaselineFile(path); err == nil || !strings.Contains(err.Error(), "version 2") {
+		t.Errorf("LoadBaselineFile = %v, want a version error", err)
+	}
+
+	// An unreadable baseline file is reported but leaves the status alone.
+	var reported error
+	status := recordRun(t, filepath.Join(t.TempDir(), "timings.db"), time.Now(), time.Millisecond,
+		WithBaselineFile(path), WithErrorHandler(func(err error) { reported = err }))
+	if status != 0 || reported == nil {
+		t.Errorf("status = %d, reported %v; want 0 and the version error", status, reported)
+	}
+}
@@ -0,0 +1,248 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// BaselineWindow selects the runs stored baselines are computed from: the
+// most recent uninterrupted, unsharded runs of a suite without failed
+// steps.
+type BaselineWindow struct {
+	// Runs is the number of runs in the window; 20 when zero.
+	Runs int
+	// Branch limits the window to runs recorded on the branch, e.g.
+	// "main"; empty takes runs of every branch.
+	Branch string
+}
+
+func (w BaselineWindow) size() int {
+	if w.Runs <= 0 {
+		return 20
+	}
+	return w.Runs
+}
+
+// WithStoredBaselines recomputes the stored baselines of the agent's suite
+// over window whenever a run that belongs in the window closes, so that
+// the regression gate reads them instead of loading every baseline run
+// (see RecomputeBaselines).
+func WithStoredBaselines(window BaselineWindow) Option {
+	return func(v *VectorClockAgent) {
+		v.baselineWindow = &window
+	}
+}
+
+// StoredBaseline is the baseline RecomputeBaselines stored for a suite.
+type StoredBaseline struct {
+	Suite string
+	// Timings holds the median total, scenario and step durations.
+	Timings RunTimings
+	// Runs is the number of runs the medians were taken over.
+	Runs       int
+	ComputedAt time.Time
+}
+
+// baselineWindowRuns returns the IDs of the runs of suite in window,
+// newest first.
+func (v *VectorClockAgent) baselineWindowRuns(suite string, window BaselineWindow) ([]string, error) {
+	rows, err := v.db.Query(`
+		SELECT run_id FROM runs r
+		WHERE COALESCE(suite, '') = ? AND interrupted IS NULL AND shard_total IS NULL
+			AND (? = '' OR branch = ?)
+			AND NOT EXISTS (
+				SELECT 1 FROM step_timings s
+				WHERE s.run_id = r.run_id AND `+primaryPhase+`
+					AND COALESCE(s.status, 'passed') NOT IN ('passed', 'skipped', 'pending')
+			)
+		ORDER BY started_at DESC, rowid DESC
+		LIMIT ?
+	`, suite, window.Branch, window.Branch, window.size())
+	if err != nil {
+		return nil, fmt.Errorf("failed to select baseline runs of suite %q: %w", suite, err)
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	return runIDs, rows.Err()
+}
+
+// RecomputeBaselines takes the median total, scenario and step durations
+// of the runs of suite in window and stores them in the baselines table,
+// replacing the suite's previously computed baselines; imported ones are
+// kept. Run it from a scheduled maintenance job, or let
+// WithStoredBaselines run it after each run.
+func (v *VectorClockAgent) RecomputeBaselines(suite string, window BaselineWindow) (StoredBaseline, error) {
+	if v.readOnly {
+		return StoredBaseline{}, ErrReadOnly
+	}
+	v.sync()
+
+	runIDs, err := v.baselineWindowRuns(suite, window)
+	if err != nil {
+		return StoredBaseline{}, err
+	}
+	runs := make([]RunTimings, 0, len(runIDs))
+	scenarioRuns := make(map[string]int)
+	stepRuns := make(map[StepKey]int)
+	for _, runID := range runIDs {
+		rt, err := v.RunTimings(runID)
+		if err != nil {
+			return StoredBaseline{}, err
+		}
+		for name := range rt.Scenarios {
+			scenarioRuns[name]++
+		}
+		for key := range rt.Steps {
+			stepRuns[key]++
+		}
+		runs = append(runs, rt)
+	}
+	b := StoredBaseline{Suite: suite, Runs: len(runs), ComputedAt: v.now().UTC()}
+	if len(runs) > 0 {
+		b.Timings = medianTimings(runs)
+	}
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return b, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM baselines WHERE suite = ?`, suite); err != nil {
+		return b, fmt.Errorf("failed to clear baselines of suite %q: %w", suite, err)
+	}
+	if len(runs) > 0 {
+		computedAt := formatPrecise(b.ComputedAt)
+		insert := func(kind, scenario, step string, d time.Duration, n int) error {
+			_, err := tx.Exec(`
+				INSERT INTO baselines (suite, kind, scenario_name, step_text, median_ns, run_count, computed_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?)
+			`, suite, kind, scenario, step, d.Nanoseconds(), n, computedAt)
+			if err != nil {
+				return fmt.Errorf("failed to store baselines of suite %q: %w", suite, err)
+			}
+			return nil
+		}
+		if err := insert("total", "", "", b.Timings.Total, len(runs)); err != nil {
+			return b, err
+		}
+		for name, d := range b.Timings.Scenarios {
+			if err := insert("scenario", name, "", d, scenarioRuns[name]); err != nil {
+				return b, err
+			}
+		}
+		for key, d := range b.Timings.Steps {
+			if err := insert("step", key.Scenario, key.Step, d, stepRuns[key]); err != nil {
+				return b, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return b, fmt.Errorf("failed to commit baselines of suite %q: %w", suite, err)
+	}
+	return b, nil
+}
+
+// StoredBaselineOf returns the baseline stored for suite; ok is false
+// when none is stored. Baselines imported with ImportStats are combined
+// with those of the local runs: each total, scenario and step takes the
+// pooled median of every set of runs it was stored for.
+func (v *VectorClockAgent) StoredBaselineOf(suite string) (b StoredBaseline, ok bool, err error) {
+	rows, err := v.db.Query(`
+		SELECT kind, scenario_name, step_text, median_ns, run_count, computed_at
+		FROM baselines
+		WHERE suite = ?
+		UNION ALL
+		SELECT kind, scenario_name, step_text, median_ns, run_count, imported_at
+		FROM imported_baselines
+		WHERE suite = ?
+	`, suite, suite)
+	if err != nil {
+		return b, false, fmt.Errorf("failed to load baselines of suite %q: %w", suite, err)
+	}
+	defer rows.Close()
+
+	var totals []baselineMedian
+	scenarios := make(map[string][]baselineMedian)
+	steps := make(map[StepKey][]baselineMedian)
+	b = StoredBaseline{Suite: suite}
+	for rows.Next() {
+		var kind, scenario, step string
+		var ns int64
+		var computedAt timestamp
+		var m baselineMedian
+		if err := rows.Scan(&kind, &scenario, &step, &ns, &m.runs, &computedAt); err != nil {
+			return b, false, err
+		}
+		m.median = time.Duration(ns)
+		switch kind {
+		case "total":
+			totals = append(totals, m)
+			b.Runs += m.runs
+			if computedAt.After(b.ComputedAt) {
+				b.ComputedAt = computedAt.Time
+			}
+		case "scenario":
+			scenarios[scenario] = append(scenarios[scenario], m)
+		case "step":
+			key := StepKey{Scenario: scenario, Step: step}
+			steps[key] = append(steps[key], m)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return b, false, err
+	}
+
+	b.Timings = RunTimings{
+		RunID:     fmt.Sprintf("stored median of %d runs", b.Runs),
+		Total:     pooledMedian(totals),
+		Scenarios: make(map[string]time.Duration, len(scenarios)),
+		Steps:     make(map[StepKey]time.Duration, len(steps)),
+	}
+	for name, medians := range scenarios {
+		b.Timings.Scenarios[name] = pooledMedian(medians)
+	}
+	for key, medians := range steps {
+		b.Timings.Steps[key] = pooledMedian(medians)
+	}
+	return b, len(totals) > 0, nil
+}
+
+// updateBaselines recomputes the suite's stored baselines on Close when
+// WithStoredBaselines is set and the closing run belongs in the window.
+func (v *VectorClockAgent) updateBaselines() error {
+	if v.baselineWindow == nil || v.sharded() || v.collector != nil {
+		return nil
+	}
+	runIDs, err := v.baselineWindowRuns(v.suite, *v.baselineWindow)
+	if err != nil {
+		return err
+	}
+	for _, runID := range runIDs {
+		if runID == v.runID {
+			_, err := v.RecomputeBaselines(v.suite, *v.baselineWindow)
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteStoredBaseline prints a summary of b.
+func WriteStoredBaseline(w io.Writer, b StoredBaseline) error {
+	suite := b.Suite
+	if suite == "" {
+		suite = "(none)"
+	}
+	_, err := fmt.Fprintf(w, "suite %s: median of %d runs, total %s, %d scenarios, %d steps, computed %s\n",
+		suite, b.Runs, b.Timings.Total.Round(time.Millisecond), len(b.Timings.Scenarios), len(b.Timings.Steps),
+		b.ComputedAt.Local().Format(time.DateTime))
+	return err
+}
@@ -0,0 +1,256 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// BenchmarkTag marks the scenarios RunSuite repeats as micro-benchmarks.
+const BenchmarkTag = "@benchmark"
+
+const phaseBenchmark = "benchmark"
+
+// DefaultBenchmarkPrecision is the relative confidence interval half-width
+// the required sample size is computed for: ±5% of the mean.
+const DefaultBenchmarkPrecision = 0.05
+
+// BenchmarkPolicy configures the repetition of @benchmark scenarios.
+type BenchmarkPolicy struct {
+	// Runs is the number of times each @benchmark scenario runs in total,
+	// including the regular run of the suite.
+	Runs int
+	// Precision is the wanted 95% confidence interval half-width relative
+	// to the mean; zero means DefaultBenchmarkPrecision.
+	Precision float64
+}
+
+// WithBenchmarks makes RunSuite run the scenarios tagged @benchmark
+// policy.Runs times in total and report their mean, confidence interval and
+// the number of samples needed for the wanted precision. Repetitions are
+// stored in the "benchmark" phase, numbered by their attempt, and excluded
+// from the regular statistics.
+func WithBenchmarks(policy BenchmarkPolicy) Option {
+	return func(v *VectorClockAgent) {
+		if policy.Runs > 1 {
+			v.benchmark = &policy
+		}
+	}
+}
+
+func (p BenchmarkPolicy) precision() float64 {
+	if p.Precision <= 0 {
+		return DefaultBenchmarkPrecision
+	}
+	return p.Precision
+}
+
+// runBenchmarks repeats the @benchmark scenarios of suite. Failures in the
+// repetitions are reported but do not change the suite status.
+func (v *VectorClockAgent) runBenchmarks(suite godog.TestSuite) {
+	var opts godog.Options
+	if suite.Options != nil {
+		opts = *suite.Options
+	}
+	if opts.Tags != "" {
+		opts.Tags = "(" + opts.Tags + ") && " + BenchmarkTag
+	} else {
+		opts.Tags = BenchmarkTag
+	}
+	bench := suite
+	bench.Options = &opts
+
+	v.retrying.Store(false)
+	v.benchmarking.Store(true)
+	defer v.benchmarking.Store(false)
+	for run := 2; run <= v.benchmark.Runs; run++ {
+		v.attempt.Store(int32(run))
+		v.logger.Info("benchmark repetition", "run", run, "of", v.benchmark.Runs)
+		if status := bench.Run(); status != 0 {
+			v.logger.Warn("benchmark repetition failed", "run", run, "status", status)
+		}
+	}
+}
+
+// Benchmark summarizes the samples of one @benchmark scenario or step.
+type Benchmark struct {
+	// Key names the step; Key.Step is empty for the scenario as a whole,
+	// whose samples are the summed step durations of each repetition.
+	Key     StepKey
+	Samples int
+	Mean    time.Duration
+	StdDev  time.Duration
+	// CI is the half-width of the 95% confidence interval of the mean.
+	CI time.Duration
+	// Required is the number of samples needed for a CI of the policy's
+	// precision; it is 0 when there are too few samples to tell.
+	Required int
+}
+
+// Benchmarks returns the statistics of the @benchmark scenarios of runID and
+// their steps, using the first run of the suite and all its benchmark
+// repetitions as samples. Every execution of a scenario, told apart by its
+// feature file, line and Examples row, gives one sample of the scenario and
+// one of each of its steps, summing the step's occurrences within it.
+// precision is the wanted relative CI half-width; zero means
+// DefaultBenchmarkPrecision.
+func (v *VectorClockAgent) Benchmarks(runID string, precision float64) ([]Benchmark, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT scenario_name, COALESCE(feature_uri, ''), COALESCE(example_line, scenario_line, 0),
+			step_text, COALESCE(attempt, 1), `+durationNs+`
+		FROM step_timings
+		WHERE run_id = ? AND COALESCE(phase, 'primary') IN ('primary', 'benchmark')
+		AND ',' || COALESCE(tags, '') || ',' LIKE ?
+		ORDER BY id
+	`, runID, "%,"+BenchmarkTag+",%")
+	if err != nil {
+		return nil, fmt.Errorf("failed to load benchmark samples of run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	// repetition is one execution of a scenario.
+	type repetition struct {
+		scenario   string
+		featureURI string
+		line       int
+		attempt    int
+	}
+	type repetitionStep struct {
+		repetition
+		step string
+	}
+	stepTotals := make(map[repetitionStep]time.Duration)
+	scenarios := make(map[repetition]time.Duration)
+	for rows.Next() {
+		var r repetitionStep
+		var durationNs int64
+		if err := rows.Scan(&r.scenario, &r.featureURI, &r.line, &r.step, &r.attempt, &durationNs); err != nil {
+			return nil, err
+		}
+		stepTotals[r] += time.Duration(durationNs)
+		scenarios[r.repetition] += time.Duration(durationNs)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	steps := make(map[StepKey][]time.Duration)
+	for r, d := range stepTotals {
+		key := StepKey{Scenario: r.scenario, Step: r.step}
+		steps[key] = append(steps[key], d)
+	}
+	for r, d := range scenarios {
+		key := StepKey{Scenario: r.scenario}
+		steps[key] = append(steps[key], d)
+	}
+	if precision <= 0 {
+		precision = DefaultBenchmarkPrecision
+	}
+	benchmarks := make([]Benchmark, 0, len(steps))
+	for key, samples := range steps {
+		benchmarks = append(benchmarks, newBenchmark(key, samples, precision))
+	}
+	sort.Slice(benchmarks, func(i, j int) bool {
+		if benchmarks[i].Key.Scenario != benchmarks[j].Key.Scenario {
+			return benchmarks[i].Key.Scenario < benchmarks[j].Key.Scenario
+		}
+		return benchmarks[i].Key.Step < benchmarks[j].Key.Step
+	})
+	return benchmarks, nil
+}
+
+func newBenchmark(key StepKey, samples []time.Duration, precision float64) Benchmark {
+	b := Benchmark{Key: key, Samples: len(samples)}
+	var sum float64
+	for _, d := range samples {
+		sum += float64(d)
+	}
+	mean := sum / float64(len(samples))
+	b.Mean = time.Duration(mean)
+	if len(samples) < 2 {
+		return b
+	}
+
+	var sq float64
+	for _, d := range samples {
+		sq += (float64(d) - mean) * (float64(d) - mean)
+	}
+	sd := math.Sqrt(sq / float64(len(samples)-1))
+	b.StdDev = time.Duration(sd)
+	b.CI = time.Duration(tCritical95(len(samples)-1) * sd / math.Sqrt(float64(len(samples))))
+	if mean > 0 {
+		b.Required = int(math.Ceil(math.Pow(1.96*sd/(precision*mean), 2)))
+		b.Required = max(b.Required, 2)
+	}
+	return b
+}
+
+// tCritical95 is the two-sided 95% critical value of Student's t
+// distribution with df degrees of freedom.
+func tCritical95(df int) float64 {
+	table := []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+	switch {
+	case df < 1:
+		return math.Inf(1)
+	case df <= len(table):
+		return table[df-1]
+	case df <= 60:
+		return 2.000
+	case df <= 120:
+		return 1.980
+	}
+	return 1.960
+}
+
+// WriteBenchmarks prints benchmarks as a table, each scenario followed by
+// its steps.
+func WriteBenchmarks(w io.Writer, benchmarks []Benchmark) error {
+	if len(benchmarks) == 0 {
+		_, err := fmt.Fprintln(w, "no benchmark scenarios")
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%-50s %7s %12s %12s %12s %9s\n", "scenario / step", "samples", "mean", "±95% CI", "stddev", "required"); err != nil {
+		return err
+	}
+	for _, b := range benchmarks {
+		name := b.Key.Scenario
+		if b.Key.Step != "" {
+			name = "  " + b.Key.Step
+		}
+		required := "-"
+		if b.Required > 0 {
+			required = fmt.Sprint(b.Required)
+		}
+		_, err := fmt.Fprintf(w, "%-50s %7d %12s %12s %12s %9s\n",
+			name, b.Samples, b.Mean.Round(time.Microsecond), b.CI.Round(time.Microsecond), b.StdDev.Round(time.Microsecond), required)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reportBenchmarks prints the benchmarks of the current run.
+func (v *VectorClockAgent) reportBenchmarks() {
+	if v.verbosity < VerbositySummary {
+		return
+	}
+	benchmarks, err := v.Benchmarks(v.runID, v.benchmark.precision())
+	if err != nil {
+		v.handleError(fmt.Errorf("benchmarks: %w", err))
+		return
+	}
+	fmt.Println("=== Benchmarks ===")
+	if err := WriteBenchmarks(os.Stdout, benchmarks); err != nil {
+		v.handleError(err)
+	}
+}
@@ -0,0 +1,64 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestBenchmarksSampleEachExecution(t *testing.T) {
+	a, _ := newTestAgent(t)
+	defer a.Close()
+	start := time.Now().Add(-time.Hour)
+	var batch []stepRecord
+	add := func(attempt, row int, text string, d time.Duration) {
+		phase := phasePrimary
+		if attempt > 1 {
+			phase = phaseBenchmark
+		}
+		batch = append(batch, stepRecord{
+			stepID:       fmt.Sprintf("s%d", len(batch)),
+			runID:        "r1",
+			scenarioName: "outline",
+			stepText:     text,
+			tags:         BenchmarkTag,
+			featureURI:   "bench.feature",
+			phase:        phase,
+			attempt:      attempt,
+			example:      exampleRow{line: row},
+			scenarioLine: 2,
+			duration:     d,
+			startedAt:    start,
+			endedAt:      start.Add(d),
+		})
+	}
+	// Two rows of one outline run twice each; "I wait" runs twice per row.
+	for attempt := 1; attempt <= 2; attempt++ {
+		for _, row := range []int{7, 8} {
+			add(attempt, row, "I wait", 10*time.Millisecond)
+			add(attempt, row, "I wait", 10*time.Millisecond)
+			add(attempt, row, "I check", 5*time.Millisecond)
+		}
+	}
+	if err := a.writeBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	benchmarks, err := a.Benchmarks("r1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[StepKey]time.Duration{
+		{Scenario: "outline"}:                  25 * time.Millisecond,
+		{Scenario: "outline", Step: "I wait"}:  20 * time.Millisecond,
+		{Scenario: "outline", Step: "I check"}: 5 * time.Millisecond,
+	}
+	if len(benchmarks) != len(want) {
+		t.Fatalf("got %d benchmarks, want %d: %+v", len(benchmarks), len(want), benchmarks)
+	}
+	for _, b := range benchmarks {
+		if b.Samples != 4 || b.Mean != want[b.Key] || b.StdDev != 0 {
+			t.Errorf("%s: %d samples, mean %s, stddev %s; want 4 samples of %s", b.Key, b.Samples, b.Mean, b.StdDev, want[b.Key])
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// BudgetExitCode is the status RunSuite returns when the suite passed but a
+// failing tag budget was exceeded.
+const BudgetExitCode = 4
+
+// BudgetPolicy declares time budgets per scenario tag.
+type BudgetPolicy struct {
+	// Budgets maps a tag such as "@smoke" to the total step time its
+	// scenarios may take in one run.
+	Budgets map[string]time.Duration
+	// Fail makes RunSuite return BudgetExitCode when a budget is exceeded;
+	// otherwise the burn is only reported.
+	Fail bool
+}
+
+// WithTagBudgets makes RunSuite report how much of each tag budget the run
+// used once the suite finished.
+func WithTagBudgets(policy BudgetPolicy) Option {
+	return func(v *VectorClockAgent) {
+		if len(policy.Budgets) > 0 {
+			v.budgets = &policy
+		}
+	}
+}
+
+// ParseBudgets parses a comma-separated list of tag=duration pairs such as
+// "@smoke=60s,@api=5m". The @ of a tag is optional.
+func ParseBudgets(s string) (map[string]time.Duration, error) {
+	budgets := make(map[string]time.Duration)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		tag, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("budget %q: want tag=duration", field)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("budget %q: %w", field, err)
+		}
+		budgets[budgetTag(tag)] = d
+	}
+	return budgets, nil
+}
+
+func budgetTag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if !strings.HasPrefix(tag, "@") {
+		tag = "@" + tag
+	}
+	return tag
+}
+
+// BudgetBurn is how much of a tag budget one run used.
+type BudgetBurn struct {
+	Tag       string
+	Budget    time.Duration
+	Spent     time.Duration
+	Scenarios int
+}
+
+// Burn is the share of the budget used; above 1 the budget is exceeded.
+func (b BudgetBurn) Burn() float64 {
+	if b.Budget <= 0 {
+		return 0
+	}
+	return float64(b.Spent) / float64(b.Budget)
+}
+
+// Exceeded reports whether the run used more than the budget.
+func (b BudgetBurn) Exceeded() bool {
+	return b.Spent > b.Budget
+}
+
+// TagBudgets sums the primary-phase step durations of the scenarios of
+// runID carrying each budgeted tag and returns the burn per tag, highest
+// first. Scenarios running in parallel all count in full, so the spent
+// time is work done rather than wall-clock time.
+func (v *VectorClockAgent) TagBudgets(runID string, budgets map[string]time.Duration) ([]BudgetBurn, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT scenario_name, COALESCE(tags, ''), SUM(`+durationNs+`)
+		FROM step_timings
+		WHERE run_id = ? AND `+primaryPhase+`
+		GROUP BY scenario_name, tags
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load tagged scenarios of run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	burns := make(map[string]*BudgetBurn, len(budgets))
+	for tag, budget := range budgets {
+		tag = budgetTag(tag)
+		burns[tag] = &BudgetBurn{Tag: tag, Budget: budget}
+	}
+	for rows.Next() {
+		var scenario, tags string
+		var durationNs int64
+		if err := rows.Scan(&scenario, &tags, &durationNs); err != nil {
+			return nil, err
+		}
+		for _, tag := range strings.Split(tags, ",") {
+			if b, ok := burns[tag]; ok {
+				b.Spent += time.Duration(durationNs)
+				b.Scenarios++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]BudgetBurn, 0, len(burns))
+	for _, b := range burns {
+		result = append(result, *b)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Burn() != result[j].Burn() {
+			return result[i].Burn() > result[j].Burn()
+		}
+		return result[i].Tag < result[j].Tag
+	})
+	return result, nil
+}
+
+// WriteBudgets prints burns as a table, one tag per row.
+func WriteBudgets(w io.Writer, burns []BudgetBurn) error {
+	if _, err := fmt.Fprintf(w, "%-20s %9s %9s %7s %9s\n", "tag", "spent", "budget", "burn", "scenarios"); err != nil {
+		return err
+	}
+	for _, b := range burns {
+		mark := ""
+		if b.Exceeded() {
+			mark = "  EXCEEDED"
+		}
+		_, err := fmt.Fprintf(w, "%-20s %9s %9s %6.0f%% %9d%s\n",
+			b.Tag, b.Spent.Round(time.Millisecond), b.Budget, 100*b.Burn(), b.Scenarios, mark)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyBudgets reports the tag budgets after the suite and returns the final
+// status.
+func (v *VectorClockAgent) applyBudgets(status int) int {
+	burns, err := v.TagBudgets(v.runID, v.budgets.Budgets)
+	if err != nil {
+		v.handleError(fmt.Errorf("tag budgets: %w", err))
+		return status
+	}
+
+	exceeded := false
+	for _, b := range burns {
+		exceeded = exceeded || b.Exceeded()
+	}
+	if v.verbosity >= VerbositySummary {
+		fmt.Println("=== Tag Budgets ===")
+		if err := WriteBudgets(os.Stdout, burns); err != nil {
+			v.handleError(err)
+		}
+	}
+	if exceeded && v.budgets.Fail && status == 0 {
+		status = BudgetExitCode
+	}
+	return status
+}
@@ -0,0 +1,202 @@
+package vectorclocks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInjectedFault is the error returned by instrumented helpers when a
+// fault injects a failure.
+var ErrInjectedFault = errors.New("injected fault")
+
+// Fault targets of the instrumented helpers.
+const (
+	FaultHTTP = "http"
+	FaultDB   = "db"
+)
+
+// Fault slows down or fails the instrumented helpers called by the steps of
+// matching scenarios.
+type Fault struct {
+	// Tag selects the scenarios, e.g. "@payments"; empty matches every
+	// scenario.
+	Tag string
+	// Target is FaultHTTP, FaultDB, or empty for every helper.
+	Target string
+	// Delay is added before the call.
+	Delay time.Duration
+	// Error makes the call fail with ErrInjectedFault after the delay.
+	Error bool
+	// Rate is the fraction of matching calls affected; 0 affects all.
+	Rate float64
+}
+
+// WithFaults enables fault injection. Injected delays are recorded per
+// step in injected_ns and subtracted from the step's duration, so resilience
+// runs do not distort timing analysis.
+func WithFaults(faults ...Fault) Option {
+	return func(v *VectorClockAgent) {
+		v.faults = append(v.faults, faults...)
+	}
+}
+
+// ParseFaults parses a comma-separated list of [tag:]target=effect, where
+// target is http, db or * and effect is a delay such as 200ms, "error", or
+// both as 200ms+error, optionally followed by @rate:
+// "@payments:http=500ms,@search:db=error@0.1".
+func ParseFaults(s string) ([]Fault, error) {
+	var faults []Fault
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		spec, effect, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, fmt.Errorf("fault %q: want [tag:]target=effect", field)
+		}
+		var f Fault
+		if tag, target, ok := strings.Cut(spec, ":"); ok {
+			f.Tag, f.Target = strings.TrimSpace(tag), strings.TrimSpace(target)
+		} else {
+			f.Target = strings.TrimSpace(spec)
+		}
+		if f.Target == "*" {
+			f.Target = ""
+		}
+		effect, rate, hasRate := strings.Cut(effect, "@")
+		if hasRate {
+			r, err := strconv.ParseFloat(rate, 64)
+			if err != nil || r <= 0 || r > 1 {
+				return nil, fmt.Errorf("fault %q: rate must be in (0, 1]", field)
+			}
+			f.Rate = r
+		}
+		for _, part := range strings.Split(effect, "+") {
+			part = strings.TrimSpace(part)
+			if part == "error" {
+				f.Error = true
+				continue
+			}
+			d, err := time.ParseDuration(part)
+			if err != nil {
+				return nil, fmt.Errorf("fault %q: effect must be a delay, error, or both", field)
+			}
+			f.Delay = d
+		}
+		faults = append(faults, f)
+	}
+	return faults, nil
+}
+
+func (f Fault) matches(target string, tags []string) bool {
+	if f.Target != "" && f.Target != target {
+		return false
+	}
+	if f.Tag == "" {
+		return true
+	}
+	for _, tag := range tags {
+		if tag == f.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Inject applies the faults matching target and the scenario of the step
+// running in ctx: it sleeps for their delays, recording them as injected
+// latency of the step, and returns ErrInjectedFault when one injects an
+// error. Instrumented helpers call it before each operation; custom
+// helpers may call it with their own target. Outside a recorded step, or
+// without WithFaults, it does nothing.
+func (v *VectorClockAgent) Inject(ctx context.Context, target string) error {
+	if len(v.faults) == 0 {
+		return nil
+	}
+	info, ok := ctx.Value(stepKey).(*stepInfo)
+	if !ok {
+		return nil
+	}
+	for _, f := range v.faults {
+		if !f.matches(target, info.scenario.tags) || (f.Rate > 0 && rand.Float64() >= f.Rate) {
+			continue
+		}
+		if f.Delay > 0 {
+			started := v.now()
+			timer := time.NewTimer(f.Delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+			info.mu.Lock()
+			info.injected += v.since(started)
+			info.mu.Unlock()
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
+		if f.Error {
+			return fmt.Errorf("%w on %s", ErrInjectedFault, target)
+		}
+	}
+	return nil
+}
+
+// InstrumentTransport wraps base, http.DefaultTransport when nil, so that
+// requests made with the context of a step are subject to FaultHTTP faults.
+func (v *VectorClockAgent) InstrumentTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return faultTransport{agent: v, base: base}
+}
+
+type faultTransport struct {
+	agent *VectorClockAgent
+	base  http.RoundTripper
+}
+
+func (t faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.agent.Inject(req.Context(), FaultHTTP); err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// InstrumentedDB is a database whose calls made with the context of a step
+// are subject to FaultDB faults.
+type InstrumentedDB struct {
+	*sql.DB
+	agent *VectorClockAgent
+}
+
+// InstrumentDB wraps db for fault injection. Only the context-taking
+// methods ExecContext and QueryContext are instrumented.
+func (v *VectorClockAgent) InstrumentDB(db *sql.DB) *InstrumentedDB {
+	return &InstrumentedDB{DB: db, agent: v}
+}
+
+// ExecContext injects faults and then executes query.
+func (db *InstrumentedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if err := db.agent.Inject(ctx, FaultDB); err != nil {
+		return nil, err
+	}
+	return db.DB.ExecContext(ctx, query, args...)
+}
+
+// QueryContext injects faults and then runs query.
+func (db *InstrumentedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if err := db.agent.Inject(ctx, FaultDB); err != nil {
+		return nil, err
+	}
+	return db.DB.QueryContext(ctx, query, args...)
+}
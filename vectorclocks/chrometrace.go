@@ -0,0 +1,180 @@
+package vectorclocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// FormatChromeTrace is a Chrome Trace Event file of one run, opened by
+// chrome://tracing, Perfetto (ui.perfetto.dev) and speedscope. Each worker
+// is a track, each scenario a slice on it and each step a slice nested in
+// its scenario, so a parallel run can be inspected in a trace viewer.
+const FormatChromeTrace = "chrome-trace"
+
+// traceEvent is an event of the Trace Event Format. Times are in
+// microseconds since the start of the run.
+type traceEvent struct {
+	Name string                 `json:"name"`
+	Cat  string                 `json:"cat,omitempty"`
+	Ph   string                 `json:"ph"`
+	Ts   float64                `json:"ts"`
+	Dur  float64                `json:"dur,omitempty"`
+	Pid  int                    `json:"pid"`
+	Tid  int                    `json:"tid"`
+	Args map[string]interface{} `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes timings, the steps of one run, and executions,
+// where the run's scenarios ran (see Executions), as a Chrome Trace Event
+// file. Scenarios are placed on the worker recorded for them; scenarios of
+// runs recorded without executions are laid out on as many tracks as it
+// takes for none to overlap, which is how godog's workers ran them. Only
+// primary-phase steps are written.
+func WriteChromeTrace(w io.Writer, timings []StepTiming, executions []ScenarioExecution) error {
+	type executionKey struct {
+		feature, scenario    string
+		exampleLine, attempt int
+	}
+	type scenarioSlice struct {
+		name, feature  string
+		line           int
+		worker         int
+		start, end     time.Time
+		steps          []StepTiming
+		failed, placed bool
+	}
+	var order []executionKey
+	slices := make(map[executionKey]*scenarioSlice)
+	var runID string
+	for _, t := range timings {
+		if t.Phase != "" && t.Phase != phasePrimary {
+			continue
+		}
+		runID = t.RunID
+		k := executionKey{t.FeatureURI, t.ScenarioName, t.ExampleLine, t.Attempt}
+		s, ok := slices[k]
+		if !ok {
+			s = &scenarioSlice{name: t.ScenarioName, feature: t.FeatureURI, line: t.ScenarioLine, start: stepStart(t)}
+			slices[k] = s
+			order = append(order, k)
+		}
+		start := stepStart(t)
+		if start.Before(s.start) {
+			s.start = start
+		}
+		if end := start.Add(t.Duration); end.After(s.end) {
+			s.end = end
+		}
+		s.failed = s.failed || failedStatus(t.Status)
+		s.steps = append(s.steps, t)
+	}
+
+	// Match each scenario to the recorded execution it ran in.
+	maxWorker := -1
+	used := make([]bool, len(executions))
+	for _, e := range executions {
+		if e.Worker > maxWorker {
+			maxWorker = e.Worker
+		}
+	}
+	for _, k := range order {
+		s := slices[k]
+		for i, e := range executions {
+			if used[i] || e.Scenario != s.name || (e.FeatureURI != "" && e.FeatureURI != s.feature) {
+				continue
+			}
+			if s.start.Before(e.Start.Add(-time.Millisecond)) || s.start.After(e.End.Add(time.Millisecond)) {
+				continue
+			}
+			used[i] = true
+			s.worker, s.start, s.end, s.placed = e.Worker, e.Start, e.End, true
+			break
+		}
+	}
+
+	// Lay out the rest on extra tracks, each taken by the first scenario
+	// that fits after the one before it ended.
+	sort.SliceStable(order, func(i, j int) bool { return slices[order[i]].start.Before(slices[order[j]].start) })
+	var laneEnds []time.Time
+	for _, k := range order {
+		s := slices[k]
+		if s.placed {
+			continue
+		}
+		lane := -1
+		for i, end := range laneEnds {
+			if !s.start.Before(end) {
+				lane = i
+				break
+			}
+		}
+		if lane < 0 {
+			laneEnds = append(laneEnds, time.Time{})
+			lane = len(laneEnds) - 1
+		}
+		laneEnds[lane] = s.end
+		s.worker = maxWorker + 1 + lane
+	}
+
+	var origin time.Time
+	for _, k := range order {
+		if s := slices[k]; origin.IsZero() || s.start.Before(origin) {
+			origin = s.start
+		}
+	}
+	micros := func(t time.Time) float64 { return float64(t.Sub(origin).Nanoseconds()) / 1e3 }
+
+	events := []traceEvent{{Name: "process_name", Ph: "M", Pid: 1, Args: map[string]interface{}{"name": "run " + runID}}}
+	workers := make(map[int]bool)
+	for _, k := range order {
+		s := slices[k]
+		if !workers[s.worker] {
+			workers[s.worker] = true
+			events = append(events,
+				traceEvent{Name: "thread_name", Ph: "M", Pid: 1, Tid: s.worker, Args: map[string]interface{}{"name": fmt.Sprintf("worker %d", s.worker)}},
+				traceEvent{Name: "thread_sort_index", Ph: "M", Pid: 1, Tid: s.worker, Args: map[string]interface{}{"sort_index": s.worker}},
+			)
+		}
+		args := map[string]interface{}{"feature": s.feature, "result": "passed"}
+		if s.failed {
+			args["result"] = "failed"
+		}
+		if s.line > 0 {
+			args["line"] = s.line
+		}
+		if k.exampleLine > 0 {
+			args["example"] = s.steps[0].Example
+		}
+		if k.attempt > 1 {
+			args["attempt"] = k.attempt
+		}
+		events = append(events, traceEvent{
+			Name: s.name, Cat: "scenario", Ph: "X",
+			Ts: micros(s.start), Dur: float64(s.end.Sub(s.start).Nanoseconds()) / 1e3,
+			Pid: 1, Tid: s.worker, Args: args,
+		})
+		for _, t := range s.steps {
+			args := make(map[string]interface{})
+			for key, value := range t.Annotations {
+				args[key] = value
+			}
+			args["status"] = t.Status
+			if t.StepLine > 0 {
+				args["line"] = t.StepLine
+			}
+			events = append(events, traceEvent{
+				Name: t.StepText, Cat: "step", Ph: "X",
+				Ts: micros(stepStart(t)), Dur: float64(t.Duration.Nanoseconds()) / 1e3,
+				Pid: 1, Tid: s.worker, Args: args,
+			})
+		}
+	}
+
+	return json.NewEncoder(w).Encode(struct {
+		TraceEvents     []traceEvent `json:"traceEvents"`
+		DisplayTimeUnit string       `json:"displayTimeUnit"`
+	}{events, "ms"})
+}
@@ -0,0 +1,279 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// RunMetadata describes what triggered a run. Fields a CI system does not
+// expose are left empty.
+type RunMetadata struct {
+	// Provider names the CI system, e.g. "github-actions".
+	Provider string
+	// PipelineURL links to the whole pipeline or workflow run.
+	PipelineURL string
+	// JobURL links to the job whose log contains this run.
+	JobURL string
+	// ArtifactsURL links to the artifacts uploaded by the job.
+	ArtifactsURL string
+	PRNumber     string
+	Actor        string
+	Branch       string
+	Commit       string
+	// Environment names the environment the suite ran against, such as
+	// "staging"; see WithEnvironment.
+	Environment string
+}
+
+// MetadataProvider detects a CI system from its environment variables and
+// returns the metadata of the current run. ok is false when the process does
+// not run under that system.
+type MetadataProvider func(getenv func(string) string) (md RunMetadata, ok bool)
+
+// DefaultMetadataProviders are consulted in order when no providers are set
+// with WithMetadataProviders.
+var DefaultMetadataProviders = []MetadataProvider{
+	GitHubActions,
+	GitLabCI,
+	Jenkins,
+	CircleCI,
+}
+
+// WithMetadataProviders replaces DefaultMetadataProviders. The first provider
+// that detects its CI system wins.
+func WithMetadataProviders(providers ...MetadataProvider) Option {
+	return func(v *VectorClockAgent) {
+		v.providers = providers
+	}
+}
+
+// WithRunMetadata sets the run metadata explicitly and skips detection.
+func WithRunMetadata(md RunMetadata) Option {
+	return func(v *VectorClockAgent) {
+		v.metadata = md
+		v.providers = []MetadataProvider{}
+	}
+}
+
+// EnvironmentEnv is the environment variable naming the environment a run
+// targets when WithEnvironment is not used.
+const EnvironmentEnv = "VECTORCLOCKS_ENV"
+
+// WithEnvironment records the runs as made against the environment name,
+// e.g. "dev", "staging" or "prod-mirror", for CompareEnvironments. Without
+// it the environment is read from $VECTORCLOCKS_ENV.
+func WithEnvironment(name string) Option {
+	return func(v *VectorClockAgent) {
+		v.environment = name
+	}
+}
+
+// detectMetadata fills in the run metadata from the first matching provider
+// and the environment.
+func (v *VectorClockAgent) detectMetadata() {
+	providers := v.providers
+	if providers == nil {
+		providers = DefaultMetadataProviders
+	}
+	for _, detect := range providers {
+		if md, ok := detect(os.Getenv); ok {
+			v.metadata = md
+			break
+		}
+	}
+	switch {
+	case v.environment != "":
+		v.metadata.Environment = v.environment
+	case v.metadata.Environment == "":
+		v.metadata.Environment = os.Getenv(EnvironmentEnv)
+	}
+}
+
+// Metadata returns what triggered the current run.
+func (v *VectorClockAgent) Metadata() RunMetadata {
+	return v.metadata
+}
+
+// RunMetadataOf loads the metadata recorded for runID. Runs without CI
+// metadata, or unknown runs, yield the zero RunMetadata.
+func (v *VectorClockAgent) RunMetadataOf(runID string) (RunMetadata, error) {
+	var md RunMetadata
+	var fields [9]sql.NullString
+	err := v.db.QueryRow(`
+		SELECT ci_provider, pipeline_url, job_url, artifacts_url, pr_number, actor, branch, commit_sha, environment
+		FROM runs WHERE run_id = ?
+	`, runID).Scan(&fields[0], &fields[1], &fields[2], &fields[3], &fields[4], &fields[5], &fields[6], &fields[7], &fields[8])
+	if errors.Is(err, sql.ErrNoRows) {
+		return md, nil
+	}
+	if err != nil {
+		return md, fmt.Errorf("failed to load metadata of run %s: %w", runID, err)
+	}
+	md.Provider, md.PipelineURL, md.JobURL, md.ArtifactsURL = fields[0].String, fields[1].String, fields[2].String, fields[3].String
+	md.PRNumber, md.Actor, md.Branch, md.Commit = fields[4].String, fields[5].String, fields[6].String, fields[7].String
+	md.Environment = fields[8].String
+	return md, nil
+}
+
+// RunLink is a run together with where it came from, for linking report rows
+// to the CI pipeline, job log and artifacts.
+type RunLink struct {
+	RunID     string
+	StartedAt time.Time
+	RunMetadata
+}
+
+// runLinks returns the runs started at or after from, oldest first.
+func (v *VectorClockAgent) runLinks(from time.Time) ([]RunLink, error) {
+	rows, err := v.db.Query(`
+		SELECT run_id, started_at FROM runs WHERE started_at >= ? ORDER BY started_at, rowid
+	`, from.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+	var links []RunLink
+	for rows.Next() {
+		var l RunLink
+		var startedAt timestamp
+		if err := rows.Scan(&l.RunID, &startedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		l.StartedAt = startedAt.Time
+		links = append(links, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range links {
+		if links[i].RunMetadata, err = v.RunMetadataOf(links[i].RunID); err != nil {
+			return nil, err
+		}
+	}
+	return links, nil
+}
+
+// markdownLinks renders the CI links of md, or "" when there are none.
+func markdownLinks(md RunMetadata) string {
+	var links []string
+	for _, l := range []struct{ label, url string }{
+		{"pipeline", md.PipelineURL},
+		{"job log", md.JobURL},
+		{"artifacts", md.ArtifactsURL},
+	} {
+		if l.url != "" {
+			links = append(links, fmt.Sprintf("[%s](%s)", l.label, l.url))
+		}
+	}
+	return strings.Join(links, " · ")
+}
+
+// GitHubActions detects GitHub Actions.
+func GitHubActions(getenv func(string) string) (RunMetadata, bool) {
+	if getenv("GITHUB_ACTIONS") != "true" {
+		return RunMetadata{}, false
+	}
+	md := RunMetadata{
+		Provider: "github-actions",
+		Actor:    getenv("GITHUB_ACTOR"),
+		Branch:   firstNonEmpty(getenv("GITHUB_HEAD_REF"), getenv("GITHUB_REF_NAME")),
+		Commit:   getenv("GITHUB_SHA"),
+	}
+	if server, repo, run := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY"), getenv("GITHUB_RUN_ID"); server != "" && repo != "" && run != "" {
+		md.PipelineURL = server + "/" + repo + "/actions/runs/" + run
+		if attempt := getenv("GITHUB_RUN_ATTEMPT"); attempt != "" {
+			md.JobURL = md.PipelineURL + "/attempts/" + attempt
+		}
+		md.ArtifactsURL = md.PipelineURL + "#artifacts"
+	}
+	// Pull request builds check out refs/pull/<number>/merge.
+	if ref := getenv("GITHUB_REF"); strings.HasPrefix(ref, "refs/pull/") {
+		md.PRNumber = strings.SplitN(strings.TrimPrefix(ref, "refs/pull/"), "/", 2)[0]
+	}
+	return md, true
+}
+
+// GitLabCI detects GitLab CI/CD.
+func GitLabCI(getenv func(string) string) (RunMetadata, bool) {
+	if getenv("GITLAB_CI") != "true" {
+		return RunMetadata{}, false
+	}
+	md := RunMetadata{
+		Provider:    "gitlab-ci",
+		PipelineURL: getenv("CI_PIPELINE_URL"),
+		JobURL:      getenv("CI_JOB_URL"),
+		PRNumber:    getenv("CI_MERGE_REQUEST_IID"),
+		Actor:       getenv("GITLAB_USER_LOGIN"),
+		Branch:      getenv("CI_COMMIT_REF_NAME"),
+		Commit:      getenv("CI_COMMIT_SHA"),
+	}
+	if md.JobURL != "" {
+		md.ArtifactsURL = md.JobURL + "/artifacts/browse"
+	}
+	return md, true
+}
+
+// Jenkins detects Jenkins. The actor is only known when the build user vars
+// plugin is installed or the build is for a change request.
+func Jenkins(getenv func(string) string) (RunMetadata, bool) {
+	if getenv("JENKINS_URL") == "" {
+		return RunMetadata{}, false
+	}
+	build := getenv("BUILD_URL")
+	md := RunMetadata{
+		Provider:    "jenkins",
+		PipelineURL: build,
+		PRNumber:    getenv("CHANGE_ID"),
+		Actor:       firstNonEmpty(getenv("BUILD_USER_ID"), getenv("CHANGE_AUTHOR")),
+		Branch:      firstNonEmpty(getenv("BRANCH_NAME"), getenv("GIT_BRANCH")),
+		Commit:      getenv("GIT_COMMIT"),
+	}
+	if build != "" {
+		build = strings.TrimSuffix(build, "/") + "/"
+		md.JobURL = build + "console"
+		md.ArtifactsURL = build + "artifact/"
+	}
+	return md, true
+}
+
+// CircleCI detects CircleCI.
+func CircleCI(getenv func(string) string) (RunMetadata, bool) {
+	if getenv("CIRCLECI") != "true" {
+		return RunMetadata{}, false
+	}
+	md := RunMetadata{
+		Provider: "circleci",
+		JobURL:   getenv("CIRCLE_BUILD_URL"),
+		PRNumber: getenv("CIRCLE_PR_NUMBER"),
+		Actor:    getenv("CIRCLE_USERNAME"),
+		Branch:   getenv("CIRCLE_BRANCH"),
+		Commit:   getenv("CIRCLE_SHA1"),
+	}
+	if workflow := getenv("CIRCLE_WORKFLOW_ID"); workflow != "" {
+		md.PipelineURL = "https://app.circleci.com/pipelines/workflows/" + workflow
+	}
+	if md.JobURL != "" {
+		md.ArtifactsURL = strings.TrimSuffix(md.JobURL, "/") + "/artifacts"
+	}
+	// CIRCLE_PR_NUMBER is only set for forked PRs; otherwise take it from
+	// the pull request URL.
+	if pr := getenv("CIRCLE_PULL_REQUEST"); md.PRNumber == "" && pr != "" {
+		md.PRNumber = path.Base(pr)
+	}
+	return md, true
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, s := range values {
+		if s != "" {
+			return s
+		}
+	}
+	return ""
+}
@@ -0,0 +1,193 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Formats accepted by WithCIAnnotations and WriteCIAnnotations.
+const (
+	// AnnotateGitHub prints GitHub Actions workflow commands
+	// ("::warning file=...,line=...::..."), shown inline on the run and
+	// the pull request diff.
+	AnnotateGitHub = "github"
+	// AnnotateTeamCity prints TeamCity service messages
+	// ("##teamcity[message ...]") with status WARNING.
+	AnnotateTeamCity = "teamcity"
+	// AnnotateAuto picks AnnotateGitHub or AnnotateTeamCity from the CI
+	// environment, and prints nothing elsewhere.
+	AnnotateAuto = "auto"
+)
+
+// CIAnnotation is a timing problem to surface in a CI system's UI. File and
+// Line point at the scenario or step when known.
+type CIAnnotation struct {
+	Title   string
+	Message string
+	File    string
+	Line    int
+}
+
+// WithCIAnnotations makes the agent print a CI annotation in format for
+// every regression found by WithRegressionGate, every band exceeded in
+// WithBaselineFile, and every step that outruns WithSlowStepWarnings, so
+// timing problems show up inline in the CI UI. Annotations go to stdout,
+// where both CI systems look for them, whatever the verbosity.
+func WithCIAnnotations(format string) Option {
+	return func(v *VectorClockAgent) {
+		if format == AnnotateAuto {
+			format = DetectAnnotationFormat(os.Getenv)
+		}
+		v.annotate = format
+	}
+}
+
+// DetectAnnotationFormat returns the annotation format of the CI system
+// running the process, or "" when it is neither GitHub Actions nor
+// TeamCity.
+func DetectAnnotationFormat(getenv func(string) string) string {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		return AnnotateGitHub
+	case getenv("TEAMCITY_VERSION") != "":
+		return AnnotateTeamCity
+	default:
+		return ""
+	}
+}
+
+// ParseAnnotationFormat checks an annotation format given on the command
+// line or in the config file.
+func ParseAnnotationFormat(s string) (string, error) {
+	switch s {
+	case "", AnnotateGitHub, AnnotateTeamCity, AnnotateAuto:
+		return s, nil
+	default:
+		return "", fmt.Errorf("unknown annotation format %q (want %s, %s or %s)", s, AnnotateGitHub, AnnotateTeamCity, AnnotateAuto)
+	}
+}
+
+// WriteCIAnnotations prints annotations in format, one per line.
+func WriteCIAnnotations(w io.Writer, format string, annotations []CIAnnotation) error {
+	for _, a := range annotations {
+		var line string
+		switch format {
+		case AnnotateGitHub:
+			var props []string
+			if a.File != "" {
+				props = append(props, "file="+githubProperty(a.File))
+				if a.Line > 0 {
+					props = append(props, fmt.Sprintf("line=%d", a.Line))
+				}
+			}
+			props = append(props, "title="+githubProperty(a.Title))
+			line = "::warning " + strings.Join(props, ",") + "::" + githubData(a.Message)
+		case AnnotateTeamCity:
+			text := a.Title + ": " + a.Message
+			if a.File != "" {
+				text += " (" + fileLine(a.File, a.Line) + ")"
+			}
+			line = "##teamcity[message text='" + teamCityValue(text) + "' status='WARNING']"
+		default:
+			return fmt.Errorf("unknown annotation format %q (want %s or %s)", format, AnnotateGitHub, AnnotateTeamCity)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func fileLine(file string, line int) string {
+	if line <= 0 {
+		return file
+	}
+	return fmt.Sprintf("%s:%d", file, line)
+}
+
+// githubData escapes the message of a workflow command.
+func githubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubProperty escapes a property value of a workflow command.
+func githubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// teamCityValue escapes a service message attribute value.
+func teamCityValue(s string) string {
+	return strings.NewReplacer("|", "||", "'", "|'", "\n", "|n", "\r", "|r", "[", "|[", "]", "|]").Replace(s)
+}
+
+// DeltaAnnotations returns an annotation per delta, such as the regressions
+// of a Comparison.
+func DeltaAnnotations(deltas []Delta) []CIAnnotation {
+	annotations := make([]CIAnnotation, 0, len(deltas))
+	for _, d := range deltas {
+		annotations = append(annotations, CIAnnotation{
+			Title: "Timing regression",
+			Message: fmt.Sprintf("%s: %s -> %s (%s, %s)", d.Name,
+				d.Base.Round(time.Millisecond), d.Head.Round(time.Millisecond), formatChange(d.Change()), deltaChange(d)),
+		})
+	}
+	return annotations
+}
+
+// ViolationAnnotations returns an annotation per exceeded baseline band.
+func ViolationAnnotations(violations []BandViolation) []CIAnnotation {
+	annotations := make([]CIAnnotation, 0, len(violations))
+	for _, b := range violations {
+		annotations = append(annotations, CIAnnotation{
+			Title: "Baseline band exceeded",
+			Message: fmt.Sprintf("%s: %s exceeds band (median %s, upper %s)", b.Name,
+				b.Duration.Round(time.Millisecond), b.Median.Round(time.Millisecond), b.Upper.Round(time.Millisecond)),
+		})
+	}
+	return annotations
+}
+
+// printAnnotations prints annotations about the items named names (as in
+// Delta.Name) in the agent's format, pointing them at the feature file
+// lines recorded for the current run.
+func (v *VectorClockAgent) printAnnotations(names []string, annotations []CIAnnotation) {
+	if v.annotate == "" || len(annotations) == 0 {
+		return
+	}
+	locations := v.runLocations()
+	for i := range annotations {
+		if loc, ok := locations[names[i]]; ok {
+			annotations[i].File, annotations[i].Line = loc.file, loc.line
+		}
+	}
+	if err := WriteCIAnnotations(os.Stdout, v.annotate, annotations); err != nil {
+		v.handleError(err)
+	}
+}
+
+type sourceLocation struct {
+	file string
+	line int
+}
+
+// runLocations maps the scenario names and StepKey strings of the current
+// run to where they are written.
+func (v *VectorClockAgent) runLocations() map[string]sourceLocation {
+	locations := make(map[string]sourceLocation)
+	timings, err := v.Timings(TimingFilter{RunID: v.runID})
+	if err != nil {
+		v.handleError(err)
+		return locations
+	}
+	for _, t := range timings {
+		if t.FeatureURI == "" {
+			continue
+		}
+		locations[t.ScenarioName] = sourceLocation{t.FeatureURI, t.ScenarioLine}
+		locations[StepKey{Scenario: t.ScenarioName, Step: t.StepText}.String()] = sourceLocation{t.FeatureURI, t.StepLine}
+	}
+	return locations
+}
@@ -0,0 +1,48 @@
+package vectorclocks
+
+import "time"
+
+// Clock tells the agent the time. Durations are differences of its
+// readings, so a Clock returning times with a monotonic reading, as
+// time.Now does, keeps them immune to wall-clock jumps.
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// WithClock makes the agent read step, scenario and run times from c
+// instead of time.Now, so tests can drive it with a fake clock. Retention,
+// downsampling and report periods keep using the system clock.
+func WithClock(c Clock) Option {
+	return func(v *VectorClockAgent) {
+		v.timeSource = c
+	}
+}
+
+// IDGenerator returns the ID of a new step of scenarioName. IDs must be
+// unique within the database; a step whose ID is already stored by
+// another run is handled by the ConflictPolicy.
+type IDGenerator func(scenarioName, stepText string) string
+
+// WithIDGenerator makes the agent name steps with gen instead of its
+// counter, e.g. to use UUIDs or ULIDs, IDs that line up with a tracing
+// system, or deterministic IDs in tests. The IDs are used as returned;
+// WithNamespace does not prefix them.
+func WithIDGenerator(gen IDGenerator) Option {
+	return func(v *VectorClockAgent) {
+		v.newID = gen
+	}
+}
+
+// now reads the agent's clock.
+func (v *VectorClockAgent) now() time.Time {
+	return v.timeSource.Now()
+}
+
+// since returns the time elapsed on the agent's clock since t.
+func (v *VectorClockAgent) since(t time.Time) time.Duration {
+	return v.timeSource.Now().Sub(t)
+}
@@ -0,0 +1,178 @@
+package vectorclocks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ArchivedRun is the stub left behind by a run moved to cold storage.
+type ArchivedRun struct {
+	RunID      string
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// Steps is the number of step rows the run had.
+	Steps      int
+	Location   string
+	ArchivedAt time.Time
+}
+
+// archivedRow is a line of an archive file.
+type archivedRow struct {
+	Table string                 `json:"table"`
+	Row   map[string]interface{} `json:"row"`
+}
+
+// ArchiveBefore moves the runs started before before to store: each run's
+// rows, from every table keyed by run, are written to <run-id>.jsonl.gz as
+// one {"table": ..., "row": ...} JSON object per line, then deleted. A stub
+// in archived_runs (see ArchivedRuns) records where each run went. Step
+// digests and daily aggregates keep covering archived runs.
+func (v *VectorClockAgent) ArchiveBefore(ctx context.Context, before time.Time, store ObjectStore) ([]ArchivedRun, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`SELECT run_id FROM runs WHERE started_at < ? ORDER BY started_at, rowid`, before.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs to archive: %w", err)
+	}
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var archived []ArchivedRun
+	for _, runID := range runIDs {
+		a, err := v.archiveToStore(ctx, runID, store)
+		if err != nil {
+			return archived, err
+		}
+		archived = append(archived, a)
+	}
+
+	if len(archived) > 0 {
+		if _, err := v.db.Exec(fmt.Sprintf(`VACUUM %q`, v.naming.schemaName())); err != nil {
+			return archived, fmt.Errorf("failed to vacuum: %w", err)
+		}
+	}
+	return archived, nil
+}
+
+// archiveToStore uploads runID and replaces its rows with a stub.
+func (v *VectorClockAgent) archiveToStore(ctx context.Context, runID string, store ObjectStore) (ArchivedRun, error) {
+	a := ArchivedRun{RunID: runID, ArchivedAt: time.Now().UTC()}
+	var started, finished timestamp
+	err := v.db.QueryRow(`
+		SELECT started_at, finished_at, (SELECT COUNT(*) FROM step_timings WHERE run_id = ?)
+		FROM runs WHERE run_id = ?
+	`, runID, runID).Scan(&started, &finished, &a.Steps)
+	if err != nil {
+		return a, fmt.Errorf("failed to load run %s: %w", runID, err)
+	}
+	a.StartedAt, a.FinishedAt = started.Time, finished.Time
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, table := range runTables {
+		if err := v.dumpRows(enc, table, runID); err != nil {
+			return a, err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return a, err
+	}
+	if a.Location, err = store.Put(ctx, runID+".jsonl.gz", &buf); err != nil {
+		return a, err
+	}
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return a, err
+	}
+	defer tx.Rollback()
+	for _, table := range runTables {
+		if _, err := tx.Exec(`DELETE FROM `+table+` WHERE run_id = ?`, runID); err != nil {
+			return a, fmt.Errorf("failed to delete %s of run %s: %w", table, runID, err)
+		}
+	}
+	_, err = tx.Exec(`
+		INSERT OR REPLACE INTO archived_runs (run_id, started_at, finished_at, steps, location, archived_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, runID, a.StartedAt.Format(sqliteTimeLayout), a.FinishedAt.Format(sqliteTimeLayout), a.Steps, a.Location, a.ArchivedAt.Format(sqliteTimeLayout))
+	if err != nil {
+		return a, fmt.Errorf("failed to record archived run %s: %w", runID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return a, fmt.Errorf("failed to archive run %s: %w", runID, err)
+	}
+	v.logger.Debug("archived run", "run_id", runID, "location", a.Location)
+	return a, nil
+}
+
+// dumpRows encodes the rows of table belonging to runID.
+func (v *VectorClockAgent) dumpRows(enc *json.Encoder, table, runID string) error {
+	rows, err := v.db.Query(`SELECT * FROM `+table+` WHERE run_id = ?`, runID)
+	if err != nil {
+		return fmt.Errorf("failed to read %s of run %s: %w", table, runID, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, c := range columns {
+			row[c] = values[i]
+		}
+		if err := enc.Encode(archivedRow{Table: table, Row: row}); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// ArchivedRuns returns the stubs of the runs moved to cold storage, oldest
+// first.
+func (v *VectorClockAgent) ArchivedRuns() ([]ArchivedRun, error) {
+	rows, err := v.db.Query(`
+		SELECT run_id, started_at, finished_at, steps, location, archived_at
+		FROM archived_runs ORDER BY started_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived runs: %w", err)
+	}
+	defer rows.Close()
+
+	var archived []ArchivedRun
+	for rows.Next() {
+		var a ArchivedRun
+		var started, finished, at timestamp
+		if err := rows.Scan(&a.RunID, &started, &finished, &a.Steps, &a.Location, &at); err != nil {
+			return nil, err
+		}
+		a.StartedAt, a.FinishedAt, a.ArchivedAt = started.Time, finished.Time, at.Time
+		archived = append(archived, a)
+	}
+	return archived, rows.Err()
+}
@@ -0,0 +1,390 @@
+package vectorclocks
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Collector endpoints, relative to the collector URL.
+const (
+	collectorStepsPath      = "/v1/steps"
+	collectorRunsPath       = "/v1/runs"
+	collectorExecutionsPath = "/v1/executions"
+	collectorOutputPath     = "/v1/output"
+)
+
+// collectorMaxBody limits the size of a request to the collector. Agents
+// ship steps in writer batches, far below it; captured output is the
+// largest payload.
+const collectorMaxBody = 64 << 20
+
+// collectedStep is the wire form of a step record.
+type collectedStep struct {
+	StepID       string            `json:"step_id"`
+	RunID        string            `json:"run_id"`
+	Scenario     string            `json:"scenario"`
+	Step         string            `json:"step"`
+	DurationNs   int64             `json:"duration_ns"`
+	Status       string            `json:"status"`
+	Tags         string            `json:"tags,omitempty"`
+	FeatureURI   string            `json:"feature_uri,omitempty"`
+	Phase        string            `json:"phase"`
+	Attempt      int               `json:"attempt"`
+	StartedAt    time.Time         `json:"started_at"`
+	EndedAt      time.Time         `json:"ended_at"`
+	Resources    []string          `json:"resources,omitempty"`
+	Spans        []collectedSpan   `json:"spans,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+	InjectedNs   int64             `json:"injected_ns,omitempty"`
+	SampleRate   int               `json:"sample_rate,omitempty"`
+	Memory       *MemoryDelta      `json:"memory,omitempty"`
+	ExampleLine  int               `json:"example_line,omitempty"`
+	Example      string            `json:"example,omitempty"`
+	Origin       string            `json:"origin,omitempty"`
+	ScenarioLine int               `json:"scenario_line,omitempty"`
+	StepLine     int               `json:"step_line,omitempty"`
+	Process      string            `json:"process_id,omitempty"`
+	VectorClock  string            `json:"vector_clock,omitempty"`
+	GapNs        int64             `json:"gap_ns,omitempty"`
+}
+
+type collectedSpan struct {
+	ID         string    `json:"id"`
+	ParentID   string    `json:"parent_id,omitempty"`
+	Name       string    `json:"name"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationNs int64     `json:"duration_ns"`
+}
+
+func collectStep(rec stepRecord) collectedStep {
+	s := collectedStep{
+		StepID:       rec.stepID,
+		RunID:        rec.runID,
+		Scenario:     rec.scenarioName,
+		Step:         rec.stepText,
+		DurationNs:   rec.duration.Nanoseconds(),
+		Status:       rec.status,
+		Tags:         rec.tags,
+		FeatureURI:   rec.featureURI,
+		Phase:        rec.phase,
+		Attempt:      rec.attempt,
+		StartedAt:    rec.startedAt,
+		EndedAt:      rec.endedAt,
+		Resources:    rec.resources,
+		Annotations:  rec.annotations,
+		InjectedNs:   rec.injected.Nanoseconds(),
+		SampleRate:   rec.sampleRate,
+		Memory:       rec.memory,
+		ExampleLine:  rec.example.line,
+		Example:      rec.example.values,
+		Origin:       rec.origin,
+		ScenarioLine: rec.scenarioLine,
+		StepLine:     rec.stepLine,
+		Process:      rec.process,
+		VectorClock:  rec.clock,
+		GapNs:        rec.gap.Nanoseconds(),
+	}
+	for _, span := range rec.spans {
+		s.Spans = append(s.Spans, collectedSpan{
+			ID:         span.id,
+			ParentID:   span.parentID,
+			Name:       span.name,
+			StartedAt:  span.startedAt,
+			DurationNs: span.duration.Nanoseconds(),
+		})
+	}
+	return s
+}
+
+func (s collectedStep) record() stepRecord {
+	rec := stepRecord{
+		stepID:       s.StepID,
+		scenarioName: s.Scenario,
+		stepText:     s.Step,
+		duration:     time.Duration(s.DurationNs),
+		runID:        s.RunID,
+		status:       s.Status,
+		tags:         s.Tags,
+		featureURI:   s.FeatureURI,
+		phase:        s.Phase,
+		attempt:      s.Attempt,
+		startedAt:    s.StartedAt,
+		endedAt:      s.EndedAt,
+		resources:    s.Resources,
+		annotations:  s.Annotations,
+		injected:     time.Duration(s.InjectedNs),
+		sampleRate:   s.SampleRate,
+		memory:       s.Memory,
+		example:      exampleRow{line: s.ExampleLine, values: s.Example},
+		origin:       s.Origin,
+		scenarioLine: s.ScenarioLine,
+		stepLine:     s.StepLine,
+		process:      s.Process,
+		clock:        s.VectorClock,
+		gap:          time.Duration(s.GapNs),
+	}
+	for _, span := range s.Spans {
+		rec.spans = append(rec.spans, spanRecord{
+			id:        span.ID,
+			parentID:  span.ParentID,
+			name:      span.Name,
+			startedAt: span.StartedAt,
+			duration:  time.Duration(span.DurationNs),
+		})
+	}
+	return rec
+}
+
+// collectedExecutions is the wire form of the scenario executions of a run.
+type collectedExecutions struct {
+	RunID      string               `json:"run_id"`
+	Executions []collectedExecution `json:"executions"`
+}
+
+type collectedExecution struct {
+	Scenario   string     `json:"scenario"`
+	FeatureURI string     `json:"feature_uri,omitempty"`
+	Line       int        `json:"line,omitempty"`
+	Worker     int        `json:"worker"`
+	Phase      string     `json:"phase"`
+	StartedAt  time.Time  `json:"started_at"`
+	EndedAt    time.Time  `json:"ended_at"`
+	WaitNs     int64      `json:"wait_ns,omitempty"`
+	Leak       *LeakDelta `json:"leak,omitempty"`
+	StepNs     int64      `json:"step_ns,omitempty"`
+}
+
+func collectExecution(e executionRecord) collectedExecution {
+	return collectedExecution{
+		Scenario:   e.Scenario,
+		FeatureURI: e.FeatureURI,
+		Line:       e.Line,
+		Worker:     e.Worker,
+		Phase:      e.phase,
+		StartedAt:  e.Start,
+		EndedAt:    e.End,
+		WaitNs:     e.Wait.Nanoseconds(),
+		Leak:       e.Leak,
+		StepNs:     e.StepTime.Nanoseconds(),
+	}
+}
+
+func (e collectedExecution) record() executionRecord {
+	return executionRecord{
+		ScenarioExecution: ScenarioExecution{
+			Scenario:   e.Scenario,
+			FeatureURI: e.FeatureURI,
+			Line:       e.Line,
+			Worker:     e.Worker,
+			Start:      e.StartedAt,
+			End:        e.EndedAt,
+			Wait:       time.Duration(e.WaitNs),
+			Leak:       e.Leak,
+			StepTime:   time.Duration(e.StepNs),
+		},
+		phase: e.Phase,
+	}
+}
+
+// collectedOutput is the captured output of a run, gzip-compressed as it
+// is stored in run_output.
+type collectedOutput struct {
+	RunID  string `json:"run_id"`
+	Format string `json:"format,omitempty"`
+	Size   int    `json:"size"`
+	Output []byte `json:"output"`
+}
+
+// collectorClient ships records to a collector instead of the local
+// database.
+type collectorClient struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// WithCollector makes the agent send its steps, its runs row, its scenario
+// executions and its captured output to the collector at url (see
+// CollectorHandler) instead of writing them to its own database, so the
+// shards of a suite running on many CI machines land in one central
+// database. token, when not empty, is sent as a bearer token. Queries,
+// reports and gates still read the local database, which should be the
+// collector's when they are wanted.
+func WithCollector(url, token string) Option {
+	return func(v *VectorClockAgent) {
+		if url != "" {
+			v.collector = &collectorClient{
+				url:    strings.TrimRight(url, "/"),
+				token:  token,
+				client: &http.Client{Timeout: 30 * time.Second},
+			}
+		}
+	}
+}
+
+func (c *collectorClient) post(path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.url+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build collector request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach collector: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (c *collectorClient) sendSteps(batch []stepRecord) error {
+	steps := make([]collectedStep, len(batch))
+	for i, rec := range batch {
+		steps[i] = collectStep(rec)
+	}
+	if err := c.post(collectorStepsPath, steps); err != nil {
+		return fmt.Errorf("failed to ship %d steps: %w", len(batch), err)
+	}
+	return nil
+}
+
+func (c *collectorClient) sendRun(r runRecord) error {
+	if err := c.post(collectorRunsPath, r); err != nil {
+		return fmt.Errorf("failed to ship run %s: %w", r.RunID, err)
+	}
+	return nil
+}
+
+func (c *collectorClient) sendExecutions(runID string, executions []executionRecord) error {
+	payload := collectedExecutions{RunID: runID, Executions: make([]collectedExecution, len(executions))}
+	for i, e := range executions {
+		payload.Executions[i] = collectExecution(e)
+	}
+	if err := c.post(collectorExecutionsPath, payload); err != nil {
+		return fmt.Errorf("failed to ship %d scenario executions: %w", len(executions), err)
+	}
+	return nil
+}
+
+func (c *collectorClient) sendOutput(out collectedOutput) error {
+	if err := c.post(collectorOutputPath, out); err != nil {
+		return fmt.Errorf("failed to ship output of run %s: %w", out.RunID, err)
+	}
+	return nil
+}
+
+// decodeStatus is the status answering a request whose body failed to
+// decode with err: too large or bad.
+func decodeStatus(err error) int {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// CollectorHandler accepts the steps and runs of remote agents configured
+// with WithCollector and stores them in this agent's database. Steps are
+// POSTed as a JSON array to /v1/steps and written like local ones; a run
+// is POSTed to /v1/runs when its agent closes, which also merges its steps
+// into the step digests, followed by its scenario executions to
+// /v1/executions and its captured output to /v1/output. Collected steps
+// skip this agent's record filter, sampling and reconciliation, and
+// request bodies are limited to 64 MiB. With a token, requests must carry
+// it as a bearer token.
+func (v *VectorClockAgent) CollectorHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST "+collectorStepsPath, func(w http.ResponseWriter, r *http.Request) {
+		var steps []collectedStep
+		if err := json.NewDecoder(r.Body).Decode(&steps); err != nil {
+			http.Error(w, fmt.Sprintf("invalid steps: %v", err), decodeStatus(err))
+			return
+		}
+		records := make([]stepRecord, 0, len(steps))
+		for _, s := range steps {
+			if s.StepID == "" || s.RunID == "" {
+				http.Error(w, "steps need a step_id and a run_id", http.StatusBadRequest)
+				return
+			}
+			records = append(records, s.record())
+		}
+		// The remote agent already filtered and sampled its steps.
+		if err := v.submit(records, false); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("POST "+collectorRunsPath, func(w http.ResponseWriter, r *http.Request) {
+		var run runRecord
+		if err := json.NewDecoder(r.Body).Decode(&run); err != nil || run.RunID == "" {
+			http.Error(w, "invalid run", decodeStatus(err))
+			return
+		}
+		if err := v.Flush(); err != nil {
+			v.handleError(err)
+		}
+		if err := v.insertRun(run); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := v.mergeDigests(run.RunID, `run_id = ? AND `+primaryPhase, run.RunID); err != nil {
+			v.handleError(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST "+collectorExecutionsPath, func(w http.ResponseWriter, r *http.Request) {
+		var payload collectedExecutions
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil || payload.RunID == "" {
+			http.Error(w, "invalid scenario executions", decodeStatus(err))
+			return
+		}
+		executions := make([]executionRecord, len(payload.Executions))
+		for i, e := range payload.Executions {
+			executions[i] = e.record()
+		}
+		if err := v.insertExecutions(payload.RunID, executions); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST "+collectorOutputPath, func(w http.ResponseWriter, r *http.Request) {
+		var out collectedOutput
+		if err := json.NewDecoder(r.Body).Decode(&out); err != nil || out.RunID == "" {
+			http.Error(w, "invalid run output", decodeStatus(err))
+			return
+		}
+		if err := v.insertOutput(out); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, collectorMaxBody)
+		mux.ServeHTTP(w, r)
+	})
+}
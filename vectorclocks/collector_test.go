@@ -0,0 +1,129 @@
+package vectorclocks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/cucumber/godog"
+)
+
+func TestCollectorStoresRunRecords(t *testing.T) {
+	central, _ := newTestAgent(t)
+	defer central.Close()
+	srv := httptest.NewServer(central.CollectorHandler("secret"))
+	defer srv.Close()
+
+	remote, _ := newTestAgent(t, WithCollector(srv.URL, "secret"), WithCapturedOutput())
+	runID := remote.RunID()
+	status := runFeature(t, remote, 1, `Feature: collected
+  Scenario: one
+    Given a step
+
+  Scenario: two
+    Given a step
+`, func(ctx *godog.ScenarioContext) {
+		ctx.Step(`^a step$`, func(context.Context) error { return nil })
+	})
+	if status != 0 {
+		t.Fatalf("suite status = %d, want 0", status)
+	}
+	if err := remote.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	timings, err := central.Timings(TimingFilter{RunID: runID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timings) != 2 {
+		t.Errorf("central database has %d steps of the run, want 2", len(timings))
+	}
+	if runs, err := central.RecentRuns(1); err != nil || len(runs) != 1 || runs[0] != runID {
+		t.Errorf("RecentRuns = %v, %v; want [%s]", runs, err, runID)
+	}
+	executions, err := central.Executions(runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(executions) != 2 {
+		t.Errorf("central database has %d scenario executions, want 2", len(executions))
+	}
+	for _, e := range executions {
+		if e.Duration() <= 0 || e.StepTime <= 0 {
+			t.Errorf("execution of %s lost its times: %+v", e.Scenario, e)
+		}
+	}
+	output, format, err := central.RunOutput(runID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(output) == 0 || format == "" {
+		t.Errorf("RunOutput = %d bytes of %q, want the captured output", len(output), format)
+	}
+}
+
+func TestCollectorSkipsLocalBookkeeping(t *testing.T) {
+	// The central agent's own filter would drop every collected step.
+	central, _ := newTestAgent(t, WithRecordFilter(RecordFilter{ExcludeSteps: regexp.MustCompile(`.`)}))
+	defer central.Close()
+	srv := httptest.NewServer(central.CollectorHandler(""))
+	defer srv.Close()
+
+	remote, _ := newTestAgent(t, WithCollector(srv.URL, ""))
+	runID := remote.RunID()
+	status := runFeature(t, remote, 1, `Feature: collected
+  Scenario: one
+    Given a step
+`, func(ctx *godog.ScenarioContext) {
+		ctx.Step(`^a step$`, func(context.Context) error { return nil })
+	})
+	if status != 0 {
+		t.Fatalf("suite status = %d, want 0", status)
+	}
+	if err := remote.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if timings, err := central.Timings(TimingFilter{RunID: runID}); err != nil || len(timings) != 1 {
+		t.Errorf("central database has %d steps of the run, %v; want 1", len(timings), err)
+	}
+	r, err := central.Reconcile(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Recorded != 0 || !r.OK() {
+		t.Errorf("Reconcile = %s, want the collected steps left out", r)
+	}
+}
+
+// spaces reads as an endless run of JSON whitespace.
+type spaces struct{}
+
+func (spaces) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = ' '
+	}
+	return len(p), nil
+}
+
+func TestCollectorLimitsRequestBodies(t *testing.T) {
+	central, _ := newTestAgent(t)
+	defer central.Close()
+	srv := httptest.NewServer(central.CollectorHandler(""))
+	defer srv.Close()
+
+	body := io.MultiReader(io.LimitReader(spaces{}, collectorMaxBody), strings.NewReader("[]"))
+	resp, err := http.Post(srv.URL+collectorStepsPath, "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusRequestEntityTooLarge)
+	}
+}
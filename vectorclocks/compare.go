@@ -0,0 +1,364 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RunTimings is the aggregated duration of every scenario and step of one
+// run. Steps that occur several times in a scenario are summed.
+type RunTimings struct {
+	RunID     string
+	Metadata  RunMetadata
+	Total     time.Duration
+	Scenarios map[string]time.Duration
+	Steps     map[StepKey]time.Duration
+}
+
+// StepKey identifies a step across runs.
+type StepKey struct {
+	Scenario string
+	Step     string
+}
+
+func (k StepKey) String() string {
+	return k.Scenario + " / " + k.Step
+}
+
+// RunTimings loads the primary-phase timings of runID. Steps kept by step
+// sampling count once for every step they stand for, so the totals of a
+// sampled run estimate those of a fully recorded one.
+func (v *VectorClockAgent) RunTimings(runID string) (RunTimings, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT scenario_name, step_text, SUM(`+durationNs+` * COALESCE(sample_rate, 1))
+		FROM step_timings
+		WHERE run_id = ? AND `+primaryPhase+`
+		GROUP BY scenario_name, step_text
+	`, runID)
+	if err != nil {
+		return RunTimings{}, fmt.Errorf("failed to load run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	rt := RunTimings{
+		RunID:     runID,
+		Scenarios: make(map[string]time.Duration),
+		Steps:     make(map[StepKey]time.Duration),
+	}
+	for rows.Next() {
+		var key StepKey
+		var durationNs int64
+		if err := rows.Scan(&key.Scenario, &key.Step, &durationNs); err != nil {
+			return RunTimings{}, err
+		}
+		d := time.Duration(durationNs)
+		rt.Steps[key] = d
+		rt.Scenarios[key.Scenario] += d
+		rt.Total += d
+	}
+	if err := rows.Err(); err != nil {
+		return RunTimings{}, err
+	}
+	if len(rt.Steps) == 0 {
+		return RunTimings{}, fmt.Errorf("run %s has no recorded steps", runID)
+	}
+	if rt.Metadata, err = v.RunMetadataOf(runID); err != nil {
+		return RunTimings{}, err
+	}
+	return rt, nil
+}
+
+// Delta is the change of one duration between two runs. A zero Base or
+// Head means the item only exists in the other run.
+type Delta struct {
+	Name       string
+	Base       time.Duration
+	Head       time.Duration
+	Regression bool
+}
+
+// Change is Head minus Base.
+func (d Delta) Change() time.Duration {
+	return d.Head - d.Base
+}
+
+// Percent is the relative change against Base, or 0 when Base is zero.
+func (d Delta) Percent() float64 {
+	if d.Base == 0 {
+		return 0
+	}
+	return float64(d.Head-d.Base) / float64(d.Base) * 100
+}
+
+// Comparison holds the deltas between a base and a head run.
+type Comparison struct {
+	Base      string
+	Head      string
+	Threshold float64
+	Total     Delta
+	Scenarios []Delta
+	Steps     []Delta
+	// StepTexts sums the steps of every scenario by step text, so a step
+	// shared by many scenarios shows its whole effect.
+	StepTexts []Delta
+
+	// BaseMetadata and HeadMetadata link the runs to their CI pipelines.
+	BaseMetadata RunMetadata
+	HeadMetadata RunMetadata
+}
+
+// Regressions returns the scenario and step deltas flagged as regressions.
+func (c Comparison) Regressions() []Delta {
+	var out []Delta
+	for _, d := range append(append([]Delta{}, c.Scenarios...), c.Steps...) {
+		if d.Regression {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Compare computes per-scenario and per-step deltas between base and head.
+// Items that got more than threshold percent slower are regressions.
+func Compare(base, head RunTimings, threshold float64) Comparison {
+	c := Comparison{
+		Base:         base.RunID,
+		Head:         head.RunID,
+		BaseMetadata: base.Metadata,
+		HeadMetadata: head.Metadata,
+		Threshold:    threshold,
+	}
+	c.Total = newDelta("total", base.Total, head.Total, threshold)
+
+	scenarios := make(map[string]bool)
+	for name := range base.Scenarios {
+		scenarios[name] = true
+	}
+	for name := range head.Scenarios {
+		scenarios[name] = true
+	}
+	for name := range scenarios {
+		c.Scenarios = append(c.Scenarios, newDelta(name, base.Scenarios[name], head.Scenarios[name], threshold))
+	}
+
+	steps := make(map[StepKey]bool)
+	for key := range base.Steps {
+		steps[key] = true
+	}
+	for key := range head.Steps {
+		steps[key] = true
+	}
+	texts := make(map[string]*Delta)
+	for key := range steps {
+		c.Steps = append(c.Steps, newDelta(key.String(), base.Steps[key], head.Steps[key], threshold))
+		if texts[key.Step] == nil {
+			texts[key.Step] = &Delta{Name: key.Step}
+		}
+		texts[key.Step].Base += base.Steps[key]
+		texts[key.Step].Head += head.Steps[key]
+	}
+	for _, d := range texts {
+		c.StepTexts = append(c.StepTexts, newDelta(d.Name, d.Base, d.Head, threshold))
+	}
+
+	sortDeltas(c.Scenarios)
+	sortDeltas(c.Steps)
+	sortDeltas(c.StepTexts)
+	return c
+}
+
+func newDelta(name string, base, head time.Duration, threshold float64) Delta {
+	d := Delta{Name: name, Base: base, Head: head}
+	d.Regression = base > 0 && head > 0 && d.Percent() > threshold
+	return d
+}
+
+// sortDeltas orders deltas by absolute change, largest first.
+func sortDeltas(deltas []Delta) {
+	abs := func(d time.Duration) time.Duration {
+		if d < 0 {
+			return -d
+		}
+		return d
+	}
+	sort.Slice(deltas, func(i, j int) bool {
+		ci, cj := abs(deltas[i].Change()), abs(deltas[j].Change())
+		if ci != cj {
+			return ci > cj
+		}
+		return deltas[i].Name < deltas[j].Name
+	})
+}
+
+// maxContributions is the number of contributing steps reports list before
+// folding the rest into one line.
+const maxContributions = 10
+
+// Contribution is the share of a comparison's total change caused by one
+// step.
+type Contribution struct {
+	Delta
+	// Share is the step's change divided by the total change. Steps that
+	// moved against the total have a negative share.
+	Share float64
+}
+
+// Contributions decomposes the change of the total into the changes of each
+// step text summed over all scenarios, which add up to it. Steps are ordered by how much they pushed the total
+// in its direction, so a suite that got slower lists its biggest
+// slowdowns first. Unchanged steps are left out.
+func (c Comparison) Contributions() []Contribution {
+	total := c.Total.Change()
+	var out []Contribution
+	for _, d := range c.StepTexts {
+		if d.Change() == 0 {
+			continue
+		}
+		contribution := Contribution{Delta: d}
+		if total != 0 {
+			contribution.Share = float64(d.Change()) / float64(total)
+		}
+		out = append(out, contribution)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		ci, cj := out[i].Change(), out[j].Change()
+		if total < 0 {
+			ci, cj = -ci, -cj
+		}
+		if ci != cj {
+			return ci > cj
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// foldContributions splits contributions into the ones listed and the
+// summed change of the rest.
+func foldContributions(contributions []Contribution) ([]Contribution, time.Duration, int) {
+	if len(contributions) <= maxContributions {
+		return contributions, 0, 0
+	}
+	var rest time.Duration
+	for _, c := range contributions[maxContributions:] {
+		rest += c.Change()
+	}
+	return contributions[:maxContributions], rest, len(contributions) - maxContributions
+}
+
+// WriteComparison prints c as a plain-text report. Regressions are marked
+// with "!!".
+func WriteComparison(w io.Writer, c Comparison) error {
+	fmt.Fprintf(w, "=== Comparison: %s -> %s (regression threshold %.1f%%) ===\n", c.Base, c.Head, c.Threshold)
+	writeDelta(w, c.Total)
+	if contributions := c.Contributions(); len(contributions) > 0 && c.Total.Change() != 0 {
+		fmt.Fprintf(w, "--- Contributions to %s ---\n", formatChange(c.Total.Change()))
+		listed, rest, more := foldContributions(contributions)
+		for _, d := range listed {
+			fmt.Fprintf(w, "%6.1f%% %s: %s\n", 100*d.Share, d.Name, formatChange(d.Change()))
+		}
+		if more > 0 {
+			fmt.Fprintf(w, "%6.1f%% %d more steps: %s\n", 100*float64(rest)/float64(c.Total.Change()), more, formatChange(rest))
+		}
+	}
+	fmt.Fprintln(w, "--- Scenarios ---")
+	for _, d := range c.Scenarios {
+		writeDelta(w, d)
+	}
+	fmt.Fprintln(w, "--- Steps ---")
+	for _, d := range c.Steps {
+		if err := writeDelta(w, d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteComparisonMarkdown prints c as Markdown, e.g. for a pull request
+// comment. Each run links to its CI pipeline, job log and artifacts when
+// those were recorded.
+func WriteComparisonMarkdown(w io.Writer, c Comparison) error {
+	fmt.Fprintf(w, "### Comparison: `%s` → `%s`\n\n", c.Base, c.Head)
+	fmt.Fprintln(w, "| Run | ID | Links |")
+	fmt.Fprintln(w, "|---|---|---|")
+	fmt.Fprintf(w, "| base | `%s` | %s |\n", c.Base, markdownLinks(c.BaseMetadata))
+	fmt.Fprintf(w, "| head | `%s` | %s |\n\n", c.Head, markdownLinks(c.HeadMetadata))
+	fmt.Fprintf(w, "Regression threshold %.1f%%. Total: %s → %s (%s)\n",
+		c.Threshold, c.Total.Base.Round(time.Millisecond), c.Total.Head.Round(time.Millisecond), formatChange(c.Total.Change()))
+
+	if contributions := c.Contributions(); len(contributions) > 0 && c.Total.Change() != 0 {
+		fmt.Fprintf(w, "\n#### Contributions to %s\n\n", formatChange(c.Total.Change()))
+		fmt.Fprintln(w, "| Step | Change | Share |")
+		fmt.Fprintln(w, "|---|---|---|")
+		listed, rest, more := foldContributions(contributions)
+		for _, d := range listed {
+			fmt.Fprintf(w, "| %s | %s | %.1f%% |\n", markdownEscape(d.Name), formatChange(d.Change()), 100*d.Share)
+		}
+		if more > 0 {
+			fmt.Fprintf(w, "| %d more steps | %s | %.1f%% |\n", more, formatChange(rest), 100*float64(rest)/float64(c.Total.Change()))
+		}
+	}
+
+	for _, section := range []struct {
+		title  string
+		deltas []Delta
+	}{
+		{"Scenarios", c.Scenarios},
+		{"Steps", c.Steps},
+	} {
+		fmt.Fprintf(w, "\n#### %s\n\n", section.title)
+		fmt.Fprintln(w, "| | Name | Base | Head | Change |")
+		fmt.Fprintln(w, "|---|---|---|---|---|")
+		for _, d := range section.deltas {
+			marker := ""
+			if d.Regression {
+				marker = "⚠️"
+			}
+			_, err := fmt.Fprintf(w, "| %s | %s | %s | %s | %s (%s) |\n", marker, markdownEscape(d.Name),
+				d.Base.Round(time.Millisecond), d.Head.Round(time.Millisecond), formatChange(d.Change()), deltaChange(d))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// markdownEscape keeps pipes in scenario and step names from breaking table
+// cells.
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+func writeDelta(w io.Writer, d Delta) error {
+	marker := "  "
+	if d.Regression {
+		marker = "!!"
+	}
+	_, err := fmt.Fprintf(w, "%s %s: %s -> %s (%s, %s)\n", marker, d.Name,
+		d.Base.Round(time.Millisecond), d.Head.Round(time.Millisecond), formatChange(d.Change()), deltaChange(d))
+	return err
+}
+
+// deltaChange describes d as a percentage, or as new or removed.
+func deltaChange(d Delta) string {
+	switch {
+	case d.Base == 0:
+		return "new"
+	case d.Head == 0:
+		return "removed"
+	}
+	return fmt.Sprintf("%+.1f%%", d.Percent())
+}
+
+func formatChange(d time.Duration) string {
+	if d >= 0 {
+		return "+" + d.Round(time.Millisecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
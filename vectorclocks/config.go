@@ -0,0 +1,674 @@
+package vectorclocks
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds settings that can come from a config file and VECTORCLOCKS_*
+// environment variables instead of code, so CI pipelines can change them
+// without recompiling.
+type Config struct {
+	// DB is the SQLite database path or DSN (key "db").
+	DB string
+	// TablePrefix, Schema and SchemaFile place the agent's tables inside a
+	// shared database (keys "table_prefix", "schema" and "schema_file"; see
+	// WithTablePrefix and WithSchema).
+	TablePrefix string
+	Schema      string
+	SchemaFile  string
+	// Verbosity is the agent output level (key "verbosity").
+	Verbosity Verbosity
+	// LogFormat writes the agent's diagnostics to stderr as "text" or
+	// "json" slog records (key "log_format"; see ParseLogFormat).
+	LogFormat string
+	// Assets is a directory overriding the embedded templates (key "assets").
+	Assets string
+	// ReadOnly opens the database read-only (key "read_only"; see
+	// WithReadOnly).
+	ReadOnly bool
+
+	// Concurrency is the number of scenarios godog runs in parallel
+	// (key "run.concurrency").
+	Concurrency int
+	// Retries re-runs failed scenarios (key "run.retries").
+	Retries int
+
+	// ReportFormat is the output format of reports (key "report.format").
+	ReportFormat string
+	// Unit is the unit report durations are printed in (key "report.unit";
+	// see ParseDisplayUnit). Empty prints whole milliseconds.
+	Unit DisplayUnit
+	// Threshold is the percentage slowdown flagged as a regression
+	// (key "report.threshold").
+	Threshold float64
+
+	// Buckets are the histogram bucket bounds (key "report.buckets", a
+	// comma-separated list such as "100ms,500ms,2s").
+	Buckets []time.Duration
+
+	// FlakyThreshold is the instability score from which steps are reported
+	// as flaky (key "report.flaky_threshold").
+	FlakyThreshold float64
+
+	// KeepRuns and KeepDays are the retention limits (keys
+	// "retention.keep_runs" and "retention.keep_days").
+	KeepRuns int
+	KeepDays int
+	// RawDays is how long raw step rows are kept before they are rolled
+	// up into daily aggregates (key "retention.raw_days").
+	RawDays int
+
+	// Gate is the regression gate (keys "gate.percent", "gate.absolute",
+	// "gate.window", "gate.min_runs", "gate.baseline" and "gate.stored");
+	// it is off while both thresholds are zero.
+	Gate GatePolicy
+	// BaselineFile is checked after each run (key "gate.baseline_file";
+	// see WithBaselineFile).
+	BaselineFile string
+
+	// Baselines is the window of the stored baselines recomputed after
+	// each run (keys "baselines.runs" and "baselines.branch"); off while
+	// Runs is zero. See WithStoredBaselines.
+	Baselines BaselineWindow
+
+	// Budgets are the tag time budgets (key "budget.tags", a
+	// comma-separated list such as "@smoke=60s,@api=5m"; key
+	// "budget.fail" makes exceeding one fail the run).
+	Budgets BudgetPolicy
+
+	// Rounding is the persisted duration rounding (keys "rounding.round"
+	// and "rounding.exact_above").
+	Rounding RoundingPolicy
+
+	// Benchmark repeats @benchmark scenarios (keys "benchmark.runs" and
+	// "benchmark.precision").
+	Benchmark BenchmarkPolicy
+
+	// ResourceLimits caps concurrent scenarios per resource (key
+	// "run.resource_limits", a comma-separated list such as
+	// "db:users=1,browser=2"; see WithResourceLimits).
+	ResourceLimits map[string]int
+
+	// Webhook is the URL the run summary is posted to (key
+	// "notify.webhook"; see WithWebhook).
+	Webhook string
+
+	// Collector is the URL of the collector steps are shipped to instead of
+	// the local database, and CollectorToken its bearer token (keys
+	// "collector.url" and "collector.token"; see WithCollector).
+	Collector      string
+	CollectorToken string
+
+	// Upload sends the database or an export of the run to object storage
+	// (keys "upload.url", "upload.path" and "upload.format"; see
+	// WithUpload).
+	Upload UploadPolicy
+
+	// FeatureFlags are recorded with every run (key "run.flags", a
+	// comma-separated list such as "new_checkout=on"; see
+	// WithFeatureFlags).
+	FeatureFlags map[string]string
+
+	// Labels are attached to every run (key "run.labels", a
+	// comma-separated list such as "release=1.4,env=staging"; see
+	// WithLabels).
+	Labels map[string]string
+
+	// Suite names the suite runs belong to (key "run.suite"; see
+	// WithSuite).
+	Suite string
+
+	// ProcessID names the runner's component of vector clocks (key
+	// "run.process_id"; see WithProcessID).
+	ProcessID string
+
+	// Faults are injected into the instrumented helpers (key "run.faults",
+	// a list such as "@payments:http=500ms"; see ParseFaults).
+	Faults []Fault
+
+	// Environment names the environment runs target (key
+	// "run.environment"; see WithEnvironment).
+	Environment string
+
+	// Sampling records one in Rate step executions (keys "sampling.rate"
+	// and "sampling.slow"; see WithStepSampling).
+	Sampling SamplingPolicy
+
+	// Record selects the recorded steps (keys "record.include_tags" and
+	// "record.exclude_tags", comma-separated, and the regular expressions
+	// "record.include_steps" and "record.exclude_steps"; see
+	// WithRecordFilter).
+	Record RecordFilter
+
+	// TrackMemory records each step's allocations (key
+	// "record.memory"; see WithMemoryTracking).
+	TrackMemory bool
+
+	// Profiles captures CPU profiles of slow steps (keys "profile.dir",
+	// "profile.threshold" and "profile.watchdog"; see
+	// WithSlowStepProfiles).
+	Profiles ProfilePolicy
+
+	// DetectLeaks flags scenarios leaking goroutines or file descriptors
+	// beyond Leaks (keys "leaks.detect", "leaks.goroutines", "leaks.fds"
+	// and "leaks.settle"; see WithLeakDetection).
+	DetectLeaks bool
+	Leaks       LeakPolicy
+
+	// WarnSlow logs steps still running after this long (key
+	// "run.warn_slow"; see WithSlowStepWarnings).
+	WarnSlow time.Duration
+
+	// Annotations prints regressions and slow steps as CI annotations
+	// (key "run.annotations": github, teamcity or auto; see
+	// WithCIAnnotations).
+	Annotations string
+
+	// ETA predicts the runtime and logs progress estimates (key
+	// "run.eta"; see WithETA).
+	ETA bool
+
+	// MetricsFile receives an OpenMetrics snapshot of every run (key
+	// "run.metrics_file"; see WithOpenMetrics).
+	MetricsFile string
+
+	// AllureDir receives the scenarios of every run as Allure results
+	// (key "run.allure_dir"; see WithAllureResults).
+	AllureDir string
+
+	// Events is the file an NDJSON event per finished step is appended
+	// to, or "-" for stdout (key "run.events"; see WithEventStream).
+	// Options does not open it, since the caller has to close it.
+	Events string
+
+	// CaptureOutput stores the godog output with the run (key
+	// "run.capture_output"; see WithCapturedOutput).
+	CaptureOutput bool
+
+	// RewriteMissing writes steps missing from the database again when
+	// the run ends (key "record.rewrite_missing"; see
+	// WithRewriteMissing).
+	RewriteMissing bool
+
+	// ApproxSamples keeps that many sampled durations per step for the
+	// statistics (key "report.approx_samples"; see
+	// WithApproximateStats). Zero scans every row.
+	ApproxSamples int
+
+	// Conflicts is what happens to a step whose ID another run already
+	// stored (key "record.conflicts"; see WithConflictPolicy).
+	Conflicts ConflictPolicy
+
+	// StepTimeouts limit how long steps may run (key "run.step_timeouts"
+	// in the format of ParseStepTimeouts; see WithStepTimeouts).
+	StepTimeouts []StepTimeout
+
+	// Connection tunes the SQLite connections (keys
+	// "sqlite.busy_timeout", "sqlite.cache_size", "sqlite.synchronous",
+	// "sqlite.foreign_keys", "sqlite.max_open_conns" and "sqlite.key";
+	// see WithConnectionOptions).
+	Connection ConnectionOptions
+}
+
+// DefaultConfig returns the settings used when nothing is configured.
+func DefaultConfig() Config {
+	return Config{
+		DB:             "step_timings.db",
+		Verbosity:      VerbosityReport,
+		Concurrency:    1,
+		ReportFormat:   "text",
+		Threshold:      10,
+		FlakyThreshold: DefaultFlakyThreshold,
+		RawDays:        DefaultRawDays,
+		Gate:           GatePolicy{Window: 10, MinRuns: 1},
+		Buckets:        DefaultHistogramBuckets,
+		Leaks:          LeakPolicy{Settle: DefaultLeakSettle},
+	}
+}
+
+// configKeys maps every config key to the function that sets it.
+var configKeys = map[string]func(c *Config, value string) error{
+	"db":           func(c *Config, s string) error { c.DB = s; return nil },
+	"assets":       func(c *Config, s string) error { c.Assets = s; return nil },
+	"table_prefix": func(c *Config, s string) error { c.TablePrefix = s; return nil },
+	"schema":       func(c *Config, s string) error { c.Schema = s; return nil },
+	"schema_file":  func(c *Config, s string) error { c.SchemaFile = s; return nil },
+	"verbosity": func(c *Config, s string) (err error) {
+		c.Verbosity, err = ParseVerbosity(s)
+		return err
+	},
+	"log_format": func(c *Config, s string) (err error) {
+		c.LogFormat, err = ParseLogFormat(s)
+		return err
+	},
+	"read_only": func(c *Config, s string) (err error) {
+		c.ReadOnly, err = strconv.ParseBool(s)
+		return err
+	},
+	"run.concurrency":        intKey(func(c *Config) *int { return &c.Concurrency }),
+	"run.retries":            intKey(func(c *Config) *int { return &c.Retries }),
+	"report.format":          func(c *Config, s string) error { c.ReportFormat = s; return nil },
+	"report.threshold":       floatKey(func(c *Config) *float64 { return &c.Threshold }),
+	"report.flaky_threshold": floatKey(func(c *Config) *float64 { return &c.FlakyThreshold }),
+	"report.buckets": func(c *Config, s string) error {
+		c.Buckets = nil
+		for _, field := range strings.Split(s, ",") {
+			d, err := time.ParseDuration(strings.TrimSpace(field))
+			if err != nil {
+				return err
+			}
+			c.Buckets = append(c.Buckets, d)
+		}
+		return nil
+	},
+	"retention.keep_runs":  intKey(func(c *Config) *int { return &c.KeepRuns }),
+	"retention.keep_days":  intKey(func(c *Config) *int { return &c.KeepDays }),
+	"retention.raw_days":   intKey(func(c *Config) *int { return &c.RawDays }),
+	"gate.percent":         floatKey(func(c *Config) *float64 { return &c.Gate.Percent }),
+	"gate.absolute":        durationKey(func(c *Config) *time.Duration { return &c.Gate.Absolute }),
+	"gate.window":          intKey(func(c *Config) *int { return &c.Gate.Window }),
+	"gate.min_runs":        intKey(func(c *Config) *int { return &c.Gate.MinRuns }),
+	"gate.baseline":        func(c *Config, s string) error { c.Gate.Baseline = s; return nil },
+	"baselines.runs":       intKey(func(c *Config) *int { return &c.Baselines.Runs }),
+	"baselines.branch":     func(c *Config, s string) error { c.Baselines.Branch = s; return nil },
+	"rounding.round":       durationKey(func(c *Config) *time.Duration { return &c.Rounding.Round }),
+	"rounding.exact_above": durationKey(func(c *Config) *time.Duration { return &c.Rounding.ExactAbove }),
+	"report.unit": func(c *Config, s string) (err error) {
+		c.Unit, err = ParseDisplayUnit(s)
+		return err
+	},
+	"budget.tags": func(c *Config, s string) (err error) {
+		c.Budgets.Budgets, err = ParseBudgets(s)
+		return err
+	},
+	"budget.fail": func(c *Config, s string) (err error) {
+		c.Budgets.Fail, err = strconv.ParseBool(s)
+		return err
+	},
+	"gate.stored": func(c *Config, s string) (err error) {
+		c.Gate.Stored, err = strconv.ParseBool(s)
+		return err
+	},
+	"benchmark.runs":      intKey(func(c *Config) *int { return &c.Benchmark.Runs }),
+	"benchmark.precision": floatKey(func(c *Config) *float64 { return &c.Benchmark.Precision }),
+	"run.resource_limits": func(c *Config, s string) (err error) {
+		c.ResourceLimits, err = ParseResourceLimits(s)
+		return err
+	},
+	"notify.webhook":  func(c *Config, s string) error { c.Webhook = s; return nil },
+	"collector.url":   func(c *Config, s string) error { c.Collector = s; return nil },
+	"collector.token": func(c *Config, s string) error { c.CollectorToken = s; return nil },
+	"upload.url":      func(c *Config, s string) error { c.Upload.URL = s; return nil },
+	"upload.path":     func(c *Config, s string) error { c.Upload.Path = s; return nil },
+	"upload.format":   func(c *Config, s string) error { c.Upload.Format = s; return nil },
+	"run.flags": func(c *Config, s string) (err error) {
+		c.FeatureFlags, err = ParseFeatureFlags(s)
+		return err
+	},
+	"run.labels": func(c *Config, s string) (err error) {
+		c.Labels, err = ParseLabels(s)
+		return err
+	},
+	"run.faults": func(c *Config, s string) (err error) {
+		c.Faults, err = ParseFaults(s)
+		return err
+	},
+	"run.environment":     func(c *Config, s string) error { c.Environment = s; return nil },
+	"run.process_id":      func(c *Config, s string) error { c.ProcessID = s; return nil },
+	"run.suite":           func(c *Config, s string) error { c.Suite = s; return nil },
+	"sampling.rate":       intKey(func(c *Config) *int { return &c.Sampling.Rate }),
+	"sampling.slow":       durationKey(func(c *Config) *time.Duration { return &c.Sampling.Slow }),
+	"record.include_tags": func(c *Config, s string) error { c.Record.IncludeTags = ParseTags(s); return nil },
+	"record.exclude_tags": func(c *Config, s string) error { c.Record.ExcludeTags = ParseTags(s); return nil },
+	"record.include_steps": func(c *Config, s string) (err error) {
+		c.Record.IncludeSteps, err = regexp.Compile(s)
+		return err
+	},
+	"record.exclude_steps": func(c *Config, s string) (err error) {
+		c.Record.ExcludeSteps, err = regexp.Compile(s)
+		return err
+	},
+	"record.memory": func(c *Config, s string) (err error) {
+		c.TrackMemory, err = strconv.ParseBool(s)
+		return err
+	},
+	"profile.dir":       func(c *Config, s string) error { c.Profiles.Dir = s; return nil },
+	"profile.threshold": durationKey(func(c *Config) *time.Duration { return &c.Profiles.Threshold }),
+	"profile.watchdog": func(c *Config, s string) (err error) {
+		c.Profiles.Watchdog, err = strconv.ParseBool(s)
+		return err
+	},
+	"leaks.detect": func(c *Config, s string) (err error) {
+		c.DetectLeaks, err = strconv.ParseBool(s)
+		return err
+	},
+	"leaks.goroutines": intKey(func(c *Config) *int { return &c.Leaks.Goroutines }),
+	"leaks.fds":        intKey(func(c *Config) *int { return &c.Leaks.FDs }),
+	"leaks.settle":     durationKey(func(c *Config) *time.Duration { return &c.Leaks.Settle }),
+	"run.warn_slow":    durationKey(func(c *Config) *time.Duration { return &c.WarnSlow }),
+	"run.annotations": func(c *Config, s string) (err error) {
+		c.Annotations, err = ParseAnnotationFormat(s)
+		return err
+	},
+	"run.metrics_file": func(c *Config, s string) error { c.MetricsFile = s; return nil },
+	"run.allure_dir":   func(c *Config, s string) error { c.AllureDir = s; return nil },
+	"run.events":       func(c *Config, s string) error { c.Events = s; return nil },
+	"run.capture_output": func(c *Config, s string) (err error) {
+		c.CaptureOutput, err = strconv.ParseBool(s)
+		return err
+	},
+	"record.rewrite_missing": func(c *Config, s string) (err error) {
+		c.RewriteMissing, err = strconv.ParseBool(s)
+		return err
+	},
+	"report.approx_samples": intKey(func(c *Config) *int { return &c.ApproxSamples }),
+	"gate.baseline_file":    func(c *Config, s string) error { c.BaselineFile = s; return nil },
+	"run.eta": func(c *Config, s string) (err error) {
+		c.ETA, err = strconv.ParseBool(s)
+		return err
+	},
+	"record.conflicts": func(c *Config, s string) (err error) {
+		c.Conflicts, err = ParseConflictPolicy(s)
+		return err
+	},
+	"run.step_timeouts": func(c *Config, s string) (err error) {
+		c.StepTimeouts, err = ParseStepTimeouts(s)
+		return err
+	},
+	"sqlite.busy_timeout": durationKey(func(c *Config) *time.Duration { return &c.Connection.BusyTimeout }),
+	"sqlite.cache_size":   intKey(func(c *Config) *int { return &c.Connection.CacheSize }),
+	"sqlite.synchronous": func(c *Config, s string) (err error) {
+		c.Connection.Synchronous, err = ParseSynchronous(s)
+		return err
+	},
+	"sqlite.foreign_keys": func(c *Config, s string) (err error) {
+		c.Connection.ForeignKeys, err = strconv.ParseBool(s)
+		return err
+	},
+	"sqlite.max_open_conns": intKey(func(c *Config) *int { return &c.Connection.MaxOpenConns }),
+	"sqlite.key":            func(c *Config, s string) error { c.Connection.Key = s; return nil },
+}
+
+func intKey(field func(*Config) *int) func(*Config, string) error {
+	return func(c *Config, s string) (err error) {
+		*field(c), err = strconv.Atoi(s)
+		return err
+	}
+}
+
+func floatKey(field func(*Config) *float64) func(*Config, string) error {
+	return func(c *Config, s string) (err error) {
+		*field(c), err = strconv.ParseFloat(s, 64)
+		return err
+	}
+}
+
+func durationKey(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(c *Config, s string) (err error) {
+		*field(c), err = time.ParseDuration(s)
+		return err
+	}
+}
+
+// LoadConfig starts from DefaultConfig, applies the config file at path (if
+// path is not empty) and then the VECTORCLOCKS_* environment variables.
+//
+// Files ending in .toml use TOML tables and "key = value" lines; files
+// ending in .yaml or .yml use one level of nesting and "key: value" lines.
+// Only flat string, number and duration values are supported.
+// Environment variables name a key in upper case with dots replaced by
+// underscores, e.g. VECTORCLOCKS_RETENTION_KEEP_RUNS.
+func LoadConfig(path string) (Config, error) {
+	c := DefaultConfig()
+	if path != "" {
+		values, err := readConfigFile(path)
+		if err != nil {
+			return c, err
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if err := c.set(key, values[key]); err != nil {
+				return c, fmt.Errorf("%s: %w", path, err)
+			}
+		}
+	}
+	for key := range configKeys {
+		env := ConfigEnvVar(key)
+		if value, ok := os.LookupEnv(env); ok {
+			if err := c.set(key, value); err != nil {
+				return c, fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+	return c, nil
+}
+
+// ConfigEnvVar returns the environment variable overriding key.
+func ConfigEnvVar(key string) string {
+	return "VECTORCLOCKS_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+func (c *Config) set(key, value string) error {
+	set, ok := configKeys[key]
+	if !ok {
+		return fmt.Errorf("unknown config key %q", key)
+	}
+	if err := set(c, value); err != nil {
+		return fmt.Errorf("invalid value %q for %s: %w", value, key, err)
+	}
+	return nil
+}
+
+// Options returns the agent options described by c, except the event
+// stream of Events. The run command builds its agent from them, with its
+// flags applied to c first.
+func (c Config) Options() []Option {
+	opts := []Option{
+		WithVerbosity(c.Verbosity),
+		WithConnectionOptions(c.Connection),
+		WithTablePrefix(c.TablePrefix),
+		WithSchema(c.Schema, c.SchemaFile),
+		WithAssetDir(c.Assets),
+		WithRounding(c.Rounding),
+		WithHistogramBuckets(c.Buckets...),
+		WithDisplayUnit(c.Unit),
+		KeepRuns(c.KeepRuns),
+		KeepDays(c.KeepDays),
+		DownsampleAfter(c.RawDays),
+		WithRetries(c.Retries),
+	}
+	if c.KeepRuns > 0 || c.KeepDays > 0 {
+		opts = append(opts, PruneOnStart())
+	}
+	if c.Gate.Percent > 0 || c.Gate.Absolute > 0 {
+		opts = append(opts, WithRegressionGate(c.Gate))
+	}
+	if c.BaselineFile != "" {
+		opts = append(opts, WithBaselineFile(c.BaselineFile))
+	}
+	if c.Baselines.Runs > 0 {
+		opts = append(opts, WithStoredBaselines(c.Baselines))
+	}
+	if len(c.Budgets.Budgets) > 0 {
+		opts = append(opts, WithTagBudgets(c.Budgets))
+	}
+	if c.Benchmark.Runs > 1 {
+		opts = append(opts, WithBenchmarks(c.Benchmark))
+	}
+	if len(c.ResourceLimits) > 0 {
+		opts = append(opts, WithResourceLimits(c.ResourceLimits))
+	}
+	if c.Webhook != "" {
+		opts = append(opts, WithWebhook(c.Webhook))
+	}
+	if c.Collector != "" {
+		opts = append(opts, WithCollector(c.Collector, c.CollectorToken))
+	}
+	if c.Upload.URL != "" {
+		opts = append(opts, WithUpload(c.Upload))
+	}
+	if len(c.FeatureFlags) > 0 {
+		opts = append(opts, WithFeatureFlags(c.FeatureFlags))
+	}
+	if len(c.Labels) > 0 {
+		opts = append(opts, WithLabels(c.Labels))
+	}
+	if c.Suite != "" {
+		opts = append(opts, WithSuite(c.Suite))
+	}
+	if c.ProcessID != "" {
+		opts = append(opts, WithProcessID(c.ProcessID))
+	}
+	if len(c.Faults) > 0 {
+		opts = append(opts, WithFaults(c.Faults...))
+	}
+	if c.Environment != "" {
+		opts = append(opts, WithEnvironment(c.Environment))
+	}
+	if c.Sampling.Rate > 1 {
+		opts = append(opts, WithStepSampling(c.Sampling))
+	}
+	opts = append(opts, WithRecordFilter(c.Record))
+	if c.TrackMemory {
+		opts = append(opts, WithMemoryTracking())
+	}
+	opts = append(opts, WithSlowStepProfiles(c.Profiles))
+	if c.DetectLeaks {
+		opts = append(opts, WithLeakDetection(c.Leaks))
+	}
+	opts = append(opts, WithSlowStepWarnings(c.WarnSlow))
+	if c.Annotations != "" {
+		opts = append(opts, WithCIAnnotations(c.Annotations))
+	}
+	if c.MetricsFile != "" {
+		opts = append(opts, WithOpenMetrics(c.MetricsFile))
+	}
+	if c.AllureDir != "" {
+		opts = append(opts, WithAllureResults(c.AllureDir))
+	}
+	if c.CaptureOutput {
+		opts = append(opts, WithCapturedOutput())
+	}
+	if c.RewriteMissing {
+		opts = append(opts, WithRewriteMissing())
+	}
+	if c.ApproxSamples > 0 {
+		opts = append(opts, WithApproximateStats(c.ApproxSamples))
+	}
+	if c.LogFormat != "" {
+		opts = append(opts, WithLogger(stderrLogger(c.LogFormat, c.Verbosity)))
+	}
+	if c.ETA {
+		opts = append(opts, WithETA())
+	}
+	if c.ReadOnly {
+		opts = append(opts, WithReadOnly())
+	}
+	opts = append(opts, WithConflictPolicy(c.Conflicts))
+	if len(c.StepTimeouts) > 0 {
+		opts = append(opts, WithStepTimeouts(c.StepTimeouts...))
+	}
+	return opts
+}
+
+// readConfigFile flattens the file at path into dotted keys.
+func readConfigFile(path string) (map[string]string, error) {
+	var sep, kind string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		sep, kind = "=", "toml"
+	case ".yaml", ".yml":
+		sep, kind = ":", "yaml"
+	default:
+		return nil, fmt.Errorf("config file %s: want a .toml, .yaml or .yml extension", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		raw := stripComment(scanner.Text())
+		line := strings.TrimSpace(raw)
+		if line == "" || line == "---" {
+			continue
+		}
+
+		if kind == "toml" && strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%s:%d: malformed table header", path, n)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		indented := raw != strings.TrimLeft(raw, " \t")
+		if kind == "yaml" && !indented {
+			section = ""
+		}
+
+		key, value, ok := strings.Cut(line, sep)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: expected key %s value", path, n, sep)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if kind == "yaml" && value == "" && !indented {
+			section = key
+			continue
+		}
+		if section != "" {
+			key = section + "." + key
+		}
+		values[key] = unquote(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return values, nil
+}
+
+// stripComment removes a trailing # comment outside quotes.
+func stripComment(line string) string {
+	var quote rune
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		if s[0] == '"' {
+			if u, err := strconv.Unquote(s); err == nil {
+				return u
+			}
+		}
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,133 @@
+package vectorclocks
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, name, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	files := map[string]string{
+		"vectorclocks.toml": `db = "ci.db" # shared by the jobs
+verbosity = 'summary'
+
+[run]
+retries = 2
+
+[report]
+threshold = 25.5
+buckets = "100ms, 1s"
+`,
+		"vectorclocks.yaml": `---
+db: "ci.db" # shared by the jobs
+verbosity: summary
+run:
+  retries: 2
+report:
+  threshold: 25.5
+  buckets: 100ms, 1s
+`,
+	}
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			c, err := LoadConfig(writeConfig(t, name, contents))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.DB != "ci.db" || c.Verbosity != VerbositySummary || c.Retries != 2 || c.Threshold != 25.5 {
+				t.Errorf("LoadConfig = db %q, verbosity %v, retries %d, threshold %v; want ci.db, summary, 2, 25.5",
+					c.DB, c.Verbosity, c.Retries, c.Threshold)
+			}
+			if len(c.Buckets) != 2 || c.Buckets[0] != 100*time.Millisecond || c.Buckets[1] != time.Second {
+				t.Errorf("Buckets = %v, want [100ms 1s]", c.Buckets)
+			}
+			// Keys the file leaves out keep their defaults.
+			if c.Concurrency != 1 || c.ReportFormat != "text" {
+				t.Errorf("Concurrency = %d, ReportFormat = %q; want the defaults", c.Concurrency, c.ReportFormat)
+			}
+		})
+	}
+}
+
+func TestLoadConfigEnvironment(t *testing.T) {
+	path := writeConfig(t, "vectorclocks.toml", "[run]\nretries = 2\n")
+	t.Setenv(ConfigEnvVar("run.retries"), "5")
+	t.Setenv("VECTORCLOCKS_RETENTION_KEEP_RUNS", "30")
+
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Retries != 5 || c.KeepRuns != 30 {
+		t.Errorf("Retries = %d, KeepRuns = %d; want the environment's 5 and 30", c.Retries, c.KeepRuns)
+	}
+
+	t.Setenv("VECTORCLOCKS_RUN_RETRIES", "many")
+	if _, err := LoadConfig(path); err == nil || !strings.Contains(err.Error(), "VECTORCLOCKS_RUN_RETRIES") {
+		t.Errorf("LoadConfig = %v, want an error naming the variable", err)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name, contents, want string
+	}{
+		{"vectorclocks.toml", "colour = \"blue\"\n", `unknown config key "colour"`},
+		{"vectorclocks.toml", "[run\nretries = 2\n", "malformed table header"},
+		{"vectorclocks.yaml", "run:\n  retries 2\n", "expected key : value"},
+		{"vectorclocks.json", "{}", "want a .toml, .yaml or .yml extension"},
+	}
+	for _, tt := range tests {
+		if _, err := LoadConfig(writeConfig(t, tt.name, tt.contents)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("LoadConfig(%q) = %v, want an error containing %q", tt.contents, err, tt.want)
+		}
+	}
+}
+
+func TestConfigOptions(t *testing.T) {
+	dir := t.TempDir()
+	c, err := LoadConfig(writeConfig(t, "vectorclocks.toml", `log_format = "json"
+
+[run]
+capture_output = true
+allure_dir = "`+filepath.Join(dir, "allure")+`"
+
+[record]
+rewrite_missing = true
+
+[report]
+approx_samples = 64
+
+[gate]
+baseline_file = "baseline.json"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := newTestAgent(t, append(c.Options(), WithVerbosity(VerbositySilent))...)
+	defer a.Close()
+	if a.output == nil || a.allureDir != filepath.Join(dir, "allure") || !a.rewriteMissing ||
+		a.reservoirSize != 64 || a.baselineFile != "baseline.json" {
+		t.Errorf("agent from Options: output %v, allure dir %q, rewrite missing %t, samples %d, baseline file %q",
+			a.output != nil, a.allureDir, a.rewriteMissing, a.reservoirSize, a.baselineFile)
+	}
+	if _, json := a.logger.Handler().(*slog.JSONHandler); !json {
+		t.Errorf("logger handler = %T, want JSON records", a.logger.Handler())
+	}
+
+	if _, err := LoadConfig(writeConfig(t, "vectorclocks.toml", `log_format = "xml"`)); err == nil {
+		t.Error("LoadConfig accepted the log format xml")
+	}
+}
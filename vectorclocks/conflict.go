@@ -0,0 +1,143 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// ConflictPolicy decides what happens to a step whose ID is already stored,
+// as happens when processes whose step counters restart write one
+// database.
+type ConflictPolicy string
+
+// Conflict policies accepted by WithConflictPolicy.
+const (
+	// ConflictAppend stores the step under its ID prefixed with its run ID,
+	// "<run ID>/<step ID>", keeping both rows. It is the default.
+	ConflictAppend ConflictPolicy = "append"
+	// ConflictError keeps the stored row and reports ErrStepConflict.
+	ConflictError ConflictPolicy = "error"
+	// ConflictUpsert overwrites the stored row with the step.
+	ConflictUpsert ConflictPolicy = "upsert"
+)
+
+// ErrStepConflict is reported for a step whose ID a different step already
+// holds: under ConflictError, and under ConflictAppend when the run-scoped
+// ID is taken as well, as when an ID generator repeats within a run.
+var ErrStepConflict = errors.New("vectorclocks: step ID already recorded by another step")
+
+// ParseConflictPolicy parses "append", "error" or "upsert".
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(strings.ToLower(s)); p {
+	case ConflictAppend, ConflictError, ConflictUpsert:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (want append, error or upsert)", s)
+}
+
+// WithConflictPolicy sets what happens to a step whose ID is already stored
+// by a different step. Steps are never dropped silently: by default they
+// are kept under a run-scoped ID, and ConflictError sends the conflict to
+// the error handler. A step whose stored row has the same run, step hash
+// and start, as when a collector request is retried, is the same record
+// and is skipped under every policy but ConflictUpsert.
+func WithConflictPolicy(p ConflictPolicy) Option {
+	return func(v *VectorClockAgent) {
+		v.conflicts = p
+	}
+}
+
+// stepColumns are the step_timings columns a step record is inserted into,
+// in the order writeBatch passes them.
+var stepColumns = []string{
+	"step_id", "scenario_name", "step_text", "duration_ms", "duration_ns", "run_id", "status", "tags", "feature_uri", "phase", "attempt",
+	"started_at", "ended_at", "annotations", "injected_ns", "sample_rate", "alloc_bytes", "mallocs", "gc_cycles",
+	"example_line", "example", "origin", "scenario_line", "step_line", "step_hash",
+	"process_id", "vector_clock", "gap_ns",
+}
+
+// insertStepSQL returns the statement inserting a step record under p.
+func insertStepSQL(p ConflictPolicy) string {
+	query := fmt.Sprintf("INSERT INTO step_timings (%s) VALUES (?%s)",
+		strings.Join(stepColumns, ", "), strings.Repeat(", ?", len(stepColumns)-1))
+	if p == ConflictUpsert {
+		set := make([]string, 0, len(stepColumns)-1)
+		for _, c := range stepColumns[1:] {
+			set = append(set, c+" = excluded."+c)
+		}
+		query += " ON CONFLICT (step_id) DO UPDATE SET " + strings.Join(set, ", ")
+	}
+	return query
+}
+
+// insertStep inserts the step_timings row args, whose first value is the
+// step ID, and resolves a conflict on that ID by v's policy. It returns the
+// ID the row was stored under, or "" when it was already stored.
+func (v *VectorClockAgent) insertStep(tx *namedTx, stmt *sql.Stmt, runID string, args []interface{}) (string, error) {
+	stepID, _ := args[0].(string)
+	conflict, err := execStep(stmt, args)
+	if err != nil || !conflict {
+		return stepID, err
+	}
+	storedRun, same, err := storedStep(tx, stepID, args)
+	if err != nil || same {
+		return "", err
+	}
+	if v.conflicts == ConflictError {
+		return "", fmt.Errorf("%w: '%s' (run %s)", ErrStepConflict, stepID, storedRun)
+	}
+
+	scoped := runID + "/" + stepID
+	args[0] = scoped
+	if conflict, err = execStep(stmt, args); err != nil {
+		return "", err
+	}
+	if conflict {
+		storedRun, same, err := storedStep(tx, scoped, args)
+		if err != nil || same {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: '%s' (run %s)", ErrStepConflict, scoped, storedRun)
+	}
+	v.logger.Debug("step ID taken by another step", "step_id", stepID, "stored_as", scoped)
+	return scoped, nil
+}
+
+// execStep inserts a step row and reports whether its ID is taken.
+func execStep(stmt *sql.Stmt, args []interface{}) (bool, error) {
+	_, err := stmt.Exec(args...)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return true, nil
+	}
+	return false, err
+}
+
+// storedStep returns the run of the row stored under stepID and whether
+// that row is the record args: the same run, step hash and start.
+func storedStep(tx *namedTx, stepID string, args []interface{}) (string, bool, error) {
+	var runID, hash, startedAt sql.NullString
+	err := tx.QueryRow(`SELECT run_id, step_hash, started_at FROM step_timings WHERE step_id = ?`, stepID).Scan(&runID, &hash, &startedAt)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up conflicting step '%s': %w", stepID, err)
+	}
+	same := runID.String == stepArg(args, "run_id") &&
+		hash.String == stepArg(args, "step_hash") &&
+		startedAt.String == stepArg(args, "started_at")
+	return runID.String, same, nil
+}
+
+// stepArg returns the string value of column in args.
+func stepArg(args []interface{}, column string) string {
+	for i, c := range stepColumns {
+		if c == column {
+			s, _ := args[i].(string)
+			return s
+		}
+	}
+	return ""
+}
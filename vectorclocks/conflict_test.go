@@ -0,0 +1,101 @@
+package vectorclocks
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestConflictPolicies(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	step := func(runID, text string, at time.Duration) stepRecord {
+		return stepRecord{
+			stepID:       "s1",
+			runID:        runID,
+			scenarioName: "scenario",
+			stepText:     text,
+			featureURI:   "features/a.feature",
+			stepLine:     3,
+			phase:        phasePrimary,
+			duration:     time.Millisecond,
+			startedAt:    start.Add(at),
+			endedAt:      start.Add(at + time.Millisecond),
+		}
+	}
+	first := step("r1", "a step", 0)
+	otherRun := step("r2", "a step", time.Second)
+	sameRun := step("r1", "another step", time.Second)
+	sameRunAgain := step("r1", "a third step", 2*time.Second)
+
+	tests := []struct {
+		name    string
+		batches []stepRecord
+		// want lists the stored rows as "step ID@run ID" and whether a
+		// batch reported ErrStepConflict, per policy.
+		want map[ConflictPolicy]conflictOutcome
+	}{
+		{"another run", []stepRecord{first, otherRun}, map[ConflictPolicy]conflictOutcome{
+			ConflictAppend: {rows: []string{"r2/s1@r2", "s1@r1"}},
+			ConflictError:  {rows: []string{"s1@r1"}, conflict: true},
+			ConflictUpsert: {rows: []string{"s1@r2"}},
+		}},
+		{"repeated ID within a run", []stepRecord{first, sameRun}, map[ConflictPolicy]conflictOutcome{
+			ConflictAppend: {rows: []string{"r1/s1@r1", "s1@r1"}},
+			ConflictError:  {rows: []string{"s1@r1"}, conflict: true},
+			ConflictUpsert: {rows: []string{"s1@r1"}},
+		}},
+		{"ID repeated twice within a run", []stepRecord{first, sameRun, sameRunAgain}, map[ConflictPolicy]conflictOutcome{
+			ConflictAppend: {rows: []string{"r1/s1@r1", "s1@r1"}, conflict: true},
+			ConflictError:  {rows: []string{"s1@r1"}, conflict: true},
+			ConflictUpsert: {rows: []string{"s1@r1"}},
+		}},
+		{"retried record", []stepRecord{first, first}, map[ConflictPolicy]conflictOutcome{
+			ConflictAppend: {rows: []string{"s1@r1"}},
+			ConflictError:  {rows: []string{"s1@r1"}},
+			ConflictUpsert: {rows: []string{"s1@r1"}},
+		}},
+		{"retried run-scoped record", []stepRecord{first, otherRun, otherRun}, map[ConflictPolicy]conflictOutcome{
+			ConflictAppend: {rows: []string{"r2/s1@r2", "s1@r1"}},
+			ConflictError:  {rows: []string{"s1@r1"}, conflict: true},
+			ConflictUpsert: {rows: []string{"s1@r2"}},
+		}},
+	}
+	for _, tt := range tests {
+		for _, p := range []ConflictPolicy{ConflictAppend, ConflictError, ConflictUpsert} {
+			t.Run(tt.name+"/"+string(p), func(t *testing.T) {
+				a, _ := newTestAgent(t, WithConflictPolicy(p))
+				defer a.Close()
+				var got conflictOutcome
+				for _, rec := range tt.batches {
+					err := a.writeBatch([]stepRecord{rec})
+					if errors.Is(err, ErrStepConflict) {
+						got.conflict = true
+					} else if err != nil {
+						t.Fatal(err)
+					}
+				}
+				rows, err := a.db.Query(`SELECT step_id || '@' || run_id FROM step_timings ORDER BY step_id`)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer rows.Close()
+				for rows.Next() {
+					var row string
+					if err := rows.Scan(&row); err != nil {
+						t.Fatal(err)
+					}
+					got.rows = append(got.rows, row)
+				}
+				if want := tt.want[p]; !reflect.DeepEqual(got, want) {
+					t.Errorf("stored %v, conflict %v; want %v, conflict %v", got.rows, got.conflict, want.rows, want.conflict)
+				}
+			})
+		}
+	}
+}
+
+type conflictOutcome struct {
+	rows     []string
+	conflict bool
+}
@@ -0,0 +1,183 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// DefaultBusyTimeout is how long SQLite waits on a locked database before
+// failing, unless ConnectionOptions say otherwise.
+const DefaultBusyTimeout = 5 * time.Second
+
+// ErrNoCipher is returned for an encryption key when the binary's SQLite is
+// not SQLCipher.
+var ErrNoCipher = errors.New("vectorclocks: an encryption key needs SQLCipher; build with -tags libsqlite3 against libsqlcipher")
+
+// ConnectionOptions tune the SQLite connections the agent opens. Zero
+// fields keep the defaults.
+type ConnectionOptions struct {
+	// BusyTimeout is how long a statement waits for a locked database;
+	// DefaultBusyTimeout when 0.
+	BusyTimeout time.Duration
+	// CacheSize is SQLite's cache_size: pages when positive, KiB when
+	// negative.
+	CacheSize int
+	// Synchronous is OFF, NORMAL, FULL or EXTRA. NORMAL is safe in WAL
+	// mode and makes commits cheaper than SQLite's default of FULL.
+	Synchronous string
+	// ForeignKeys enforces foreign key constraints.
+	ForeignKeys bool
+	// MaxOpenConns caps the connections of the pool; unlimited when 0.
+	MaxOpenConns int
+	// Key encrypts the database, and a schema file of WithSchema, with
+	// SQLCipher. The binary must link SQLCipher instead of the SQLite
+	// bundled with go-sqlite3; see ErrNoCipher.
+	Key string
+}
+
+// WithConnectionOptions sets the options of the SQLite connections. They
+// do not apply to a database passed with WithDB.
+func WithConnectionOptions(o ConnectionOptions) Option {
+	return func(v *VectorClockAgent) {
+		v.connection = o
+	}
+}
+
+// WithDB makes the agent use db, such as an application's own SQLite
+// database, instead of opening dbPath, which is then ignored. db must use
+// the go-sqlite3 driver; WAL mode and a busy timeout are recommended. The
+// agent does not close db, and a schema of WithSchema must already be
+// attached on its connections.
+func WithDB(db *sql.DB) Option {
+	return func(v *VectorClockAgent) {
+		v.sharedDB = db
+	}
+}
+
+// ParseSynchronous checks a synchronous mode for ConnectionOptions.
+func ParseSynchronous(s string) (string, error) {
+	switch mode := strings.ToUpper(s); mode {
+	case "OFF", "NORMAL", "FULL", "EXTRA":
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown synchronous mode %q (want off, normal, full or extra)", s)
+}
+
+// openDB opens the database the agent records into.
+func (v *VectorClockAgent) openDB(dbPath string) (*namedDB, error) {
+	if v.sharedDB != nil {
+		return &namedDB{DB: v.sharedDB, naming: v.naming, borrowed: true}, nil
+	}
+	dsn := sqliteDSN(dbPath, v.connection)
+	if v.readOnly {
+		dsn = readOnlyDSN(dbPath, v.connection)
+	}
+	sqlDB, err := sql.Open(driverName(v.connection.Key, v.naming), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	if v.connection.MaxOpenConns > 0 {
+		sqlDB.SetMaxOpenConns(v.connection.MaxOpenConns)
+	}
+	if v.connection.Key != "" {
+		if err := checkCipher(sqlDB); err != nil {
+			sqlDB.Close()
+			return nil, err
+		}
+	}
+	return &namedDB{DB: sqlDB, naming: v.naming}, nil
+}
+
+// driverConfig is what a driver registered by driverName does on connect.
+type driverConfig struct {
+	key, attach, schema string
+}
+
+var (
+	driversMu sync.Mutex
+	drivers   = make(map[driverConfig]string)
+)
+
+// driverName returns the database/sql driver to open, registering one that
+// sets the encryption key and attaches the schema database on connect when
+// needed. sql.Register cannot be undone, so each configuration is
+// registered once and reused by later opens.
+func driverName(key string, n naming) string {
+	if key == "" && n.attach == "" {
+		return "sqlite3"
+	}
+	cfg := driverConfig{key: key, attach: n.attach, schema: n.schema}
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if name, ok := drivers[cfg]; ok {
+		return name
+	}
+	name := fmt.Sprintf("sqlite3_vectorclocks_%d", len(drivers)+1)
+	sql.Register(name, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if key != "" {
+				// PRAGMA key takes no bound parameters.
+				if _, err := conn.Exec("PRAGMA key = '"+strings.ReplaceAll(key, "'", "''")+"'", nil); err != nil {
+					return fmt.Errorf("failed to set encryption key: %w", err)
+				}
+			}
+			if n.attach != "" {
+				// An attached database is encrypted with the main key.
+				_, err := conn.Exec(fmt.Sprintf("ATTACH DATABASE ? AS %q", n.schema), []driver.Value{n.attach})
+				return err
+			}
+			return nil
+		},
+	})
+	drivers[cfg] = name
+	return name
+}
+
+// checkCipher fails unless db runs on SQLCipher, which is what keeps a
+// configured key from silently leaving the database in plain text.
+func checkCipher(db *sql.DB) error {
+	var version string
+	if err := db.QueryRow(`PRAGMA cipher_version`).Scan(&version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoCipher
+		}
+		return fmt.Errorf("failed to open encrypted database: %w", err)
+	}
+	return nil
+}
+
+// sqliteDSN opens dbPath in WAL mode, which lets report queries read while
+// the writer commits, with a busy timeout so concurrent writers wait for the
+// lock instead of failing with "database is locked".
+func sqliteDSN(dbPath string, o ConnectionOptions) string {
+	busyTimeout := o.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = DefaultBusyTimeout
+	}
+	params := url.Values{}
+	params.Set("_journal_mode", "WAL")
+	params.Set("_busy_timeout", strconv.FormatInt(busyTimeout.Milliseconds(), 10))
+	if o.CacheSize != 0 {
+		params.Set("_cache_size", strconv.Itoa(o.CacheSize))
+	}
+	if o.Synchronous != "" {
+		params.Set("_synchronous", o.Synchronous)
+	}
+	if o.ForeignKeys {
+		params.Set("_foreign_keys", "1")
+	}
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return dbPath + sep + params.Encode()
+}
@@ -0,0 +1,27 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestDriverNameReusesRegistrations(t *testing.T) {
+	before := len(sql.Drivers())
+	dir := t.TempDir()
+	a := naming{schema: "vc", attach: filepath.Join(dir, "a.db")}
+	b := naming{schema: "vc", attach: filepath.Join(dir, "b.db")}
+	first := driverName("", a)
+	if again := driverName("", a); again != first {
+		t.Errorf("second open registered %s, want %s reused", again, first)
+	}
+	if other := driverName("", b); other == first {
+		t.Errorf("another attached database reuses driver %s", first)
+	}
+	if got := driverName("", naming{}); got != "sqlite3" {
+		t.Errorf("plain driver = %s, want sqlite3", got)
+	}
+	if added := len(sql.Drivers()) - before; added != 2 {
+		t.Errorf("registered %d drivers, want 2", added)
+	}
+}
@@ -0,0 +1,273 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// workerPool hands out worker numbers to running scenarios. godog does not
+// expose its workers, so a scenario takes the lowest number no running
+// scenario holds; with concurrency N that yields N lanes that each run one
+// scenario at a time, like godog's workers.
+type workerPool struct {
+	mu   sync.Mutex
+	busy []bool
+}
+
+func (p *workerPool) acquire() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, busy := range p.busy {
+		if !busy {
+			p.busy[i] = true
+			return i
+		}
+	}
+	p.busy = append(p.busy, true)
+	return len(p.busy) - 1
+}
+
+func (p *workerPool) release(worker int) {
+	p.mu.Lock()
+	p.busy[worker] = false
+	p.mu.Unlock()
+}
+
+// ScenarioExecution is one scenario run on one worker.
+type ScenarioExecution struct {
+	Scenario   string
+	FeatureURI string
+	// Line is the line declaring the scenario in FeatureURI; 0 when
+	// unknown.
+	Line   int
+	Worker int
+	Start  time.Time
+	End    time.Time
+	// Wait is how long the scenario was held back before Start waiting for
+	// resource tokens (see WithResourceLimits).
+	Wait time.Duration
+	// Leak is what the scenario left running, recorded with
+	// WithLeakDetection; nil without it.
+	Leak *LeakDelta
+	// StepTime is the summed duration of the scenario's recorded steps;
+	// zero for executions recorded before it was stored.
+	StepTime time.Duration
+}
+
+// Duration is how long the scenario ran.
+func (e ScenarioExecution) Duration() time.Duration {
+	return e.End.Sub(e.Start)
+}
+
+// Untimed is the part of the scenario's duration outside its steps: hooks,
+// framework overhead and unrecorded steps.
+func (e ScenarioExecution) Untimed() time.Duration {
+	return max(e.Duration()-e.StepTime, 0)
+}
+
+// noteExecution remembers a finished scenario; the executions are stored
+// when the agent closes.
+func (v *VectorClockAgent) noteExecution(e ScenarioExecution, phase string) {
+	v.executionsMu.Lock()
+	v.executions = append(v.executions, executionRecord{ScenarioExecution: e, phase: phase})
+	v.executionsMu.Unlock()
+}
+
+type executionRecord struct {
+	ScenarioExecution
+	phase string
+}
+
+// storeExecutions writes the scenario executions of the run, or sends them
+// to the collector.
+func (v *VectorClockAgent) storeExecutions() error {
+	v.executionsMu.Lock()
+	executions := v.executions
+	v.executions = nil
+	v.executionsMu.Unlock()
+	if len(executions) == 0 {
+		return nil
+	}
+	if v.collector != nil {
+		return v.collector.sendExecutions(v.runID, executions)
+	}
+	return v.insertExecutions(v.runID, executions)
+}
+
+// insertExecutions writes the scenario executions of runID.
+func (v *VectorClockAgent) insertExecutions(runID string, executions []executionRecord) error {
+	tx, err := v.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, e := range executions {
+		var goroutines, fds sql.NullInt64
+		if e.Leak != nil {
+			goroutines = sql.NullInt64{Int64: int64(e.Leak.Goroutines), Valid: true}
+			fds = sql.NullInt64{Int64: int64(e.Leak.FDs), Valid: true}
+		}
+		_, err := tx.Exec(`
+			INSERT INTO scenario_executions (run_id, scenario_name, feature_uri, scenario_line, worker, phase, started_at, ended_at, wait_ns, goroutine_delta, fd_delta, step_ns)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, runID, e.Scenario, e.FeatureURI, sql.NullInt64{Int64: int64(e.Line), Valid: e.Line > 0}, e.Worker, e.phase, formatPrecise(e.Start), formatPrecise(e.End), e.Wait.Nanoseconds(), goroutines, fds, e.StepTime.Nanoseconds())
+		if err != nil {
+			return fmt.Errorf("failed to store execution of scenario '%s': %w", e.Scenario, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %d scenario executions: %w", len(executions), err)
+	}
+	return nil
+}
+
+// Executions returns the scenario executions of runID in start order.
+func (v *VectorClockAgent) Executions(runID string) ([]ScenarioExecution, error) {
+	rows, err := v.db.Query(`
+		SELECT COALESCE(scenario_name, ''), COALESCE(feature_uri, ''), COALESCE(scenario_line, 0), worker, started_at, ended_at, COALESCE(wait_ns, 0),
+			goroutine_delta, COALESCE(fd_delta, 0), COALESCE(step_ns, 0)
+		FROM scenario_executions
+		WHERE run_id = ?
+		ORDER BY started_at, worker
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scenario executions of run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var executions []ScenarioExecution
+	for rows.Next() {
+		var e ScenarioExecution
+		var start, end timestamp
+		var waitNs, stepNs int64
+		var goroutines sql.NullInt64
+		var fds int
+		if err := rows.Scan(&e.Scenario, &e.FeatureURI, &e.Line, &e.Worker, &start, &end, &waitNs, &goroutines, &fds, &stepNs); err != nil {
+			return nil, err
+		}
+		e.Start, e.End, e.Wait, e.StepTime = start.Time, end.Time, time.Duration(waitNs), time.Duration(stepNs)
+		if goroutines.Valid {
+			e.Leak = &LeakDelta{Goroutines: int(goroutines.Int64), FDs: fds}
+		}
+		executions = append(executions, e)
+	}
+	return executions, rows.Err()
+}
+
+// CriticalPath is the chain of scenarios that bounded the wall time of a
+// run.
+type CriticalPath struct {
+	RunID   string
+	Workers int
+	// Start is when the first scenario of the run started.
+	Start time.Time
+	// Wall is the time from the first scenario start to the last end.
+	Wall time.Duration
+	// Work is the summed duration of every scenario; Work / Wall is the
+	// parallelism the run achieved.
+	Work time.Duration
+	// Chain runs from the first scenario of the path to the one that
+	// finished last.
+	Chain []ScenarioExecution
+	// Idle is the part of Wall the chain's worker spent between scenarios.
+	Idle time.Duration
+	// Wait is the summed time scenarios waited for resource tokens.
+	Wait time.Duration
+}
+
+// CriticalPath finds the chain of scenarios bounding the wall time of
+// runID: starting from the scenario that finished last, it follows each
+// worker back to the scenario that freed it. Shortening any scenario off
+// this chain does not make the run finish sooner.
+func (v *VectorClockAgent) CriticalPath(runID string) (CriticalPath, error) {
+	executions, err := v.Executions(runID)
+	if err != nil {
+		return CriticalPath{}, err
+	}
+	cp := CriticalPath{RunID: runID}
+	if len(executions) == 0 {
+		return cp, fmt.Errorf("run %s recorded no scenario executions", runID)
+	}
+
+	first, last := executions[0], executions[0]
+	workers := make(map[int]bool)
+	for _, e := range executions {
+		cp.Work += e.Duration()
+		cp.Wait += e.Wait
+		workers[e.Worker] = true
+		if e.End.After(last.End) {
+			last = e
+		}
+	}
+	cp.Workers = len(workers)
+	cp.Start = first.Start
+	cp.Wall = last.End.Sub(first.Start)
+
+	chain := []ScenarioExecution{last}
+	for cur := last; ; {
+		prev, ok := previousOnWorker(executions, cur)
+		if !ok {
+			break
+		}
+		chain = append(chain, prev)
+		cur = prev
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	cp.Chain = chain
+	var busy time.Duration
+	for _, e := range chain {
+		busy += e.Duration()
+	}
+	cp.Idle = cp.Wall - busy
+	return cp, nil
+}
+
+// previousOnWorker returns the execution that ended last on cur's worker
+// before cur started.
+func previousOnWorker(executions []ScenarioExecution, cur ScenarioExecution) (ScenarioExecution, bool) {
+	var prev ScenarioExecution
+	found := false
+	for _, e := range executions {
+		if e.Worker != cur.Worker || e.End.After(cur.Start) {
+			continue
+		}
+		if !found || e.End.After(prev.End) {
+			prev, found = e, true
+		}
+	}
+	return prev, found
+}
+
+// WriteCriticalPath prints the critical path, one scenario per row with its
+// offset from the start of the run.
+func WriteCriticalPath(w io.Writer, cp CriticalPath) error {
+	parallelism := 0.0
+	if cp.Wall > 0 {
+		parallelism = float64(cp.Work) / float64(cp.Wall)
+	}
+	ms := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	_, err := fmt.Fprintf(w, "run %s: wall %s, work %s on %d workers (parallelism %.1f), critical path %d scenarios, idle %s\n",
+		cp.RunID, ms(cp.Wall), ms(cp.Work), cp.Workers, parallelism, len(cp.Chain), ms(cp.Idle))
+	if err == nil && cp.Wait > 0 {
+		_, err = fmt.Fprintf(w, "scenarios waited %s for resource tokens\n", ms(cp.Wait))
+	}
+	if err != nil || len(cp.Chain) == 0 {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "%10s %10s %6s  %s\n", "at", "duration", "worker", "scenario"); err != nil {
+		return err
+	}
+	for _, e := range cp.Chain {
+		_, err := fmt.Fprintf(w, "%10s %10s %6d  %s\n", ms(e.Start.Sub(cp.Start)), ms(e.Duration()), e.Worker, e.Scenario)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
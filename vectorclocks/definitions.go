@@ -0,0 +1,221 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// Step registers stepFunc for expr on ctx, as ctx.Step does, and records
+// expr as a step definition of the run, so that DefinitionCoverage can
+// tell which definitions no step used. Register every step definition
+// through it; definitions registered directly on ctx are unknown to the
+// agent.
+func (v *VectorClockAgent) Step(ctx *godog.ScenarioContext, expr, stepFunc interface{}) {
+	ctx.Step(expr, stepFunc)
+	pattern := definitionPattern(expr)
+	v.definitionsMu.Lock()
+	if v.definitions == nil {
+		v.definitions = make(map[string]bool)
+	}
+	v.definitions[pattern] = true
+	v.definitionsMu.Unlock()
+}
+
+// definitionPattern returns the regular expression of a godog step
+// expression, which is a string, a []byte or a *regexp.Regexp.
+func definitionPattern(expr interface{}) string {
+	switch e := expr.(type) {
+	case *regexp.Regexp:
+		return e.String()
+	case []byte:
+		return string(e)
+	default:
+		return fmt.Sprint(e)
+	}
+}
+
+// StepDefinitions returns the patterns of the step definitions registered
+// with Step, sorted.
+func (v *VectorClockAgent) StepDefinitions() []string {
+	v.definitionsMu.Lock()
+	defer v.definitionsMu.Unlock()
+	patterns := make([]string, 0, len(v.definitions))
+	for p := range v.definitions {
+		patterns = append(patterns, p)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// insertDefinitions replaces the step definitions stored for runID.
+func (v *VectorClockAgent) insertDefinitions(runID string, patterns []string) error {
+	if _, err := v.db.Exec(`DELETE FROM step_definitions WHERE run_id = ?`, runID); err != nil {
+		return fmt.Errorf("failed to record step definitions of run %s: %w", runID, err)
+	}
+	for _, p := range patterns {
+		if _, err := v.db.Exec(`INSERT INTO step_definitions (run_id, pattern) VALUES (?, ?)`, runID, p); err != nil {
+			return fmt.Errorf("failed to record step definitions of run %s: %w", runID, err)
+		}
+	}
+	return nil
+}
+
+// DefinitionRuns returns the IDs of the n most recently started runs that
+// recorded their step definitions, newest first.
+func (v *VectorClockAgent) DefinitionRuns(n int) ([]string, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT run_id FROM runs
+		WHERE run_id IN (SELECT run_id FROM step_definitions)
+		ORDER BY started_at DESC, rowid DESC
+		LIMIT ?
+	`, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs with step definitions: %w", err)
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	return runIDs, rows.Err()
+}
+
+// DefinitionUse is how much the steps of some runs used a step definition.
+type DefinitionUse struct {
+	Pattern string
+	// Steps counts the executed steps the definition matched, and Runs the
+	// runs those steps belong to; both are 0 for a dead definition.
+	Steps int
+	Runs  int
+	// Time is the time spent in the matched steps.
+	Time time.Duration
+}
+
+// Unused reports whether no step used the definition.
+func (d DefinitionUse) Unused() bool {
+	return d.Steps == 0
+}
+
+// DefinitionCoverage matches the steps executed in runIDs against the step
+// definitions each of those runs registered with Step, and returns every
+// definition with its use: dead definitions first, then by increasing
+// number of steps. A step text matching several definitions counts for
+// each of them.
+func (v *VectorClockAgent) DefinitionCoverage(runIDs ...string) ([]DefinitionUse, error) {
+	v.sync()
+
+	uses := make(map[string]*DefinitionUse)
+	for _, runID := range runIDs {
+		patterns, err := v.runDefinitions(runID)
+		if err != nil {
+			return nil, err
+		}
+		if len(patterns) == 0 {
+			continue
+		}
+		exprs := make(map[string]*regexp.Regexp, len(patterns))
+		for _, p := range patterns {
+			if uses[p] == nil {
+				uses[p] = &DefinitionUse{Pattern: p}
+			}
+			if re, err := regexp.Compile(p); err == nil {
+				exprs[p] = re
+			}
+		}
+
+		rows, err := v.db.Query(`
+			SELECT COALESCE(step_text, ''), COUNT(*), COALESCE(SUM(`+durationNs+`), 0)
+			FROM step_timings
+			WHERE run_id = ?
+			GROUP BY step_text
+		`, runID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load steps of run %s: %w", runID, err)
+		}
+		used := make(map[string]bool)
+		for rows.Next() {
+			var text string
+			var steps int
+			var ns int64
+			if err := rows.Scan(&text, &steps, &ns); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			for p, re := range exprs {
+				if re.MatchString(text) {
+					uses[p].Steps += steps
+					uses[p].Time += time.Duration(ns)
+					used[p] = true
+				}
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		for p := range used {
+			uses[p].Runs++
+		}
+	}
+
+	coverage := make([]DefinitionUse, 0, len(uses))
+	for _, u := range uses {
+		coverage = append(coverage, *u)
+	}
+	sort.Slice(coverage, func(i, j int) bool {
+		if coverage[i].Steps != coverage[j].Steps {
+			return coverage[i].Steps < coverage[j].Steps
+		}
+		return coverage[i].Pattern < coverage[j].Pattern
+	})
+	return coverage, nil
+}
+
+// runDefinitions returns the step definitions recorded with runID.
+func (v *VectorClockAgent) runDefinitions(runID string) ([]string, error) {
+	rows, err := v.db.Query(`SELECT pattern FROM step_definitions WHERE run_id = ? ORDER BY pattern`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load step definitions of run %s: %w", runID, err)
+	}
+	defer rows.Close()
+	var patterns []string
+	for rows.Next() {
+		var p string
+		if err := rows.Scan(&p); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, rows.Err()
+}
+
+// WriteDefinitionCoverage prints coverage as a table, one definition per
+// row, followed by the number of unused definitions.
+func WriteDefinitionCoverage(w io.Writer, coverage []DefinitionUse) error {
+	if _, err := fmt.Fprintf(w, "%7s %5s %10s  %s\n", "steps", "runs", "time", "definition"); err != nil {
+		return err
+	}
+	unused := 0
+	for _, u := range coverage {
+		if u.Unused() {
+			unused++
+		}
+		if _, err := fmt.Fprintf(w, "%7d %5d %10s  %s\n", u.Steps, u.Runs, u.Time.Round(time.Millisecond), u.Pattern); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d of %d step definitions unused\n", unused, len(coverage))
+	return err
+}
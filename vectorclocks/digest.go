@@ -0,0 +1,150 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StepDigest returns the persisted t-digest of the durations of a step
+// text, in milliseconds, or nil when the step has none yet. Digests are
+// updated when an agent that recorded a run closes, and cover all history;
+// Prune and Downsample do not shrink them.
+func (v *VectorClockAgent) StepDigest(stepText string) (*TDigest, error) {
+	var data []byte
+	err := v.db.QueryRow(`SELECT digest FROM step_digest WHERE step_text = ?`, normalizeStepText(stepText)).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load digest of step %q: %w", stepText, err)
+	}
+	t := new(TDigest)
+	if err := t.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("step %q: %w", stepText, err)
+	}
+	return t, nil
+}
+
+// StepDigests returns the persisted digest of every step text.
+func (v *VectorClockAgent) StepDigests() (map[string]*TDigest, error) {
+	rows, err := v.db.Query(`SELECT step_text, digest FROM step_digest`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load step digests: %w", err)
+	}
+	defer rows.Close()
+
+	digests := make(map[string]*TDigest)
+	for rows.Next() {
+		var text string
+		var data []byte
+		if err := rows.Scan(&text, &data); err != nil {
+			return nil, err
+		}
+		t := new(TDigest)
+		if err := t.UnmarshalBinary(data); err != nil {
+			return nil, fmt.Errorf("step %q: %w", text, err)
+		}
+		digests[text] = t
+	}
+	return digests, rows.Err()
+}
+
+// digestQuantile converts a quantile of a millisecond digest to a duration.
+func digestQuantile(t *TDigest, q float64) time.Duration {
+	return time.Duration(t.Quantile(q) * float64(time.Millisecond))
+}
+
+// updateDigests merges the primary-phase durations of the current run into
+// the step digests. A digest already updated by this run is left alone.
+func (v *VectorClockAgent) updateDigests() error {
+	return v.mergeDigests(v.runID, `run_id = ? AND `+primaryPhase, v.runID)
+}
+
+// backfillDigests builds the step digests from the rows already recorded the
+// first time an agent opens a database without them.
+func (v *VectorClockAgent) backfillDigests() error {
+	var digested, recorded bool
+	if err := v.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM step_digest)`).Scan(&digested); err != nil {
+		return fmt.Errorf("failed to check step digests: %w", err)
+	}
+	if digested {
+		return nil
+	}
+	if err := v.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM step_timings)`).Scan(&recorded); err != nil {
+		return fmt.Errorf("failed to check step timings: %w", err)
+	}
+	if !recorded {
+		return nil
+	}
+	return v.mergeDigests("", primaryPhase)
+}
+
+// mergeDigests adds the durations of the step rows matching where to the
+// step digests in one transaction, marking them as updated by runID.
+func (v *VectorClockAgent) mergeDigests(runID, where string, args ...interface{}) error {
+	rows, err := v.db.Query(`SELECT step_text, `+durationNs+` FROM step_timings WHERE `+where, args...)
+	if err != nil {
+		return fmt.Errorf("failed to load step durations: %w", err)
+	}
+	added := make(map[string]*TDigest)
+	for rows.Next() {
+		var text string
+		var durationNs int64
+		if err := rows.Scan(&text, &durationNs); err != nil {
+			rows.Close()
+			return err
+		}
+		text = normalizeStepText(text)
+		if added[text] == nil {
+			added[text] = NewTDigest(DefaultCompression)
+		}
+		added[text].Add(float64(durationNs) / float64(time.Millisecond))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for text, t := range added {
+		var data []byte
+		var lastRun sql.NullString
+		err := tx.QueryRow(`SELECT digest, last_run_id FROM step_digest WHERE step_text = ?`, text).Scan(&data, &lastRun)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return fmt.Errorf("failed to load digest of step %q: %w", text, err)
+		case runID != "" && lastRun.String == runID:
+			continue
+		default:
+			stored := new(TDigest)
+			if err := stored.UnmarshalBinary(data); err != nil {
+				return fmt.Errorf("step %q: %w", text, err)
+			}
+			stored.Merge(t)
+			t = stored
+		}
+		if data, err = t.MarshalBinary(); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`INSERT OR REPLACE INTO step_digest (step_text, digest, last_run_id) VALUES (?, ?, ?)`,
+			text, data, nullString(runID))
+		if err != nil {
+			return fmt.Errorf("failed to save digest of step %q: %w", text, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit step digests: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultRawDays is how long the CLI keeps raw step rows before rolling
+// them up into daily aggregates.
+const DefaultRawDays = 30
+
+// DownsampleAfter makes the agent roll raw step rows older than days into
+// daily per-step aggregates when it starts, keeping long-term trends in a
+// store of bounded size. Zero disables downsampling.
+func DownsampleAfter(days int) Option {
+	return func(v *VectorClockAgent) {
+		v.retention.rawDays = days
+	}
+}
+
+// Downsample rolls primary-phase step rows older than the DownsampleAfter
+// window into the step_daily table and deletes the raw rows, including
+// retry-phase rows and resource usage of the same period. It returns the
+// number of deleted step rows.
+func (v *VectorClockAgent) Downsample() (int64, error) {
+	if v.retention.rawDays <= 0 {
+		return 0, nil
+	}
+	v.sync()
+
+	cutoff := time.Now().UTC().AddDate(0, 0, -v.retention.rawDays).Format(sqliteTimeLayout)
+	tx, err := v.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO step_daily (day, scenario_name, step_text, count, total_ms, min_ms, max_ms, total_ns, min_ns, max_ns, failures)
+		SELECT date(created_at), scenario_name, step_text, COUNT(*), SUM(duration_ms),
+			MIN(duration_ms), MAX(duration_ms), SUM(`+durationNs+`), MIN(`+durationNs+`), MAX(`+durationNs+`),
+			SUM(COALESCE(status, '') = 'failed')
+		FROM step_timings
+		WHERE created_at < ? AND `+primaryPhase+`
+		GROUP BY date(created_at), scenario_name, step_text
+		ON CONFLICT (day, scenario_name, step_text) DO UPDATE SET
+			count = count + excluded.count,
+			total_ms = total_ms + excluded.total_ms,
+			min_ms = MIN(min_ms, excluded.min_ms),
+			max_ms = MAX(max_ms, excluded.max_ms),
+			total_ns = total_ns + excluded.total_ns,
+			min_ns = MIN(min_ns, excluded.min_ns),
+			max_ns = MAX(max_ns, excluded.max_ns),
+			failures = failures + excluded.failures
+	`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to roll up step timings: %w", err)
+	}
+	res, err := tx.Exec(`DELETE FROM step_timings WHERE created_at < ?`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete rolled-up step timings: %w", err)
+	}
+	deleted, _ := res.RowsAffected()
+	rawCutoff := formatPrecise(time.Now().AddDate(0, 0, -v.retention.rawDays))
+	if _, err := tx.Exec(`DELETE FROM step_resources WHERE ended_at < ?`, rawCutoff); err != nil {
+		return 0, fmt.Errorf("failed to delete rolled-up resource usage: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM scenario_executions WHERE ended_at < ?`, rawCutoff); err != nil {
+		return 0, fmt.Errorf("failed to delete rolled-up scenario executions: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	v.logger.Debug("rolled step rows into daily aggregates", "rows", deleted)
+	return deleted, nil
+}
+
+// DailyAggregate is one day of a step after downsampling.
+type DailyAggregate struct {
+	Day      time.Time
+	Scenario string
+	Step     string
+	Count    int
+	Mean     time.Duration
+	Min      time.Duration
+	Max      time.Duration
+	Failures int
+}
+
+// DailyAggregates returns the downsampled days from since onwards, oldest
+// first.
+func (v *VectorClockAgent) DailyAggregates(since time.Time) ([]DailyAggregate, error) {
+	rows, err := v.db.Query(`
+		SELECT day, scenario_name, step_text, count, total_ns, min_ns, max_ns, failures
+		FROM step_daily
+		WHERE day >= ?
+		ORDER BY day, scenario_name, step_text
+	`, since.UTC().Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load daily aggregates: %w", err)
+	}
+	defer rows.Close()
+
+	var days []DailyAggregate
+	for rows.Next() {
+		var a DailyAggregate
+		var day string
+		var totalNs, minNs, maxNs int64
+		if err := rows.Scan(&day, &a.Scenario, &a.Step, &a.Count, &totalNs, &minNs, &maxNs, &a.Failures); err != nil {
+			return nil, err
+		}
+		if a.Day, err = time.Parse("2006-01-02", day); err != nil {
+			return nil, fmt.Errorf("malformed day %q: %w", day, err)
+		}
+		a.Mean = time.Duration(totalNs / int64(a.Count))
+		a.Min = time.Duration(minNs)
+		a.Max = time.Duration(maxNs)
+		days = append(days, a)
+	}
+	return days, rows.Err()
+}
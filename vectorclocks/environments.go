@@ -0,0 +1,356 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultEnvironmentSpread is the factor by which a step's share of the run
+// must differ between environments to be flagged.
+const DefaultEnvironmentSpread = 2.0
+
+// EnvironmentRuns is the median run of one environment.
+type EnvironmentRuns struct {
+	Name  string
+	Runs  int
+	Total time.Duration
+}
+
+// EnvironmentStep is a step's cost in each compared environment, in the
+// order of EnvironmentComparison.Environments.
+type EnvironmentStep struct {
+	Key       StepKey
+	Durations []time.Duration
+	// Shares are the durations as fractions of their environment's total,
+	// which factors out environments that are uniformly faster or slower.
+	Shares []float64
+	// Spread is the largest share over the smallest; it is infinite when
+	// the step took no time somewhere.
+	Spread float64
+	// Outlier is set when Spread reaches the comparison's threshold.
+	Outlier bool
+}
+
+// EnvironmentScenario is a scenario's cost in each compared environment,
+// like EnvironmentStep.
+type EnvironmentScenario struct {
+	Name      string
+	Durations []time.Duration
+	Shares    []float64
+	Spread    float64
+	Outlier   bool
+}
+
+// EnvironmentComparison compares the runs of one commit across several
+// environments.
+type EnvironmentComparison struct {
+	// Commit is "" when no commit ran in every environment and the latest
+	// runs of each were compared instead.
+	Commit string
+	// Label is the run label naming the environments, or "" when they are
+	// named by WithEnvironment.
+	Label        string
+	Environments []EnvironmentRuns
+	Spread       float64
+	// Scenarios and Steps hold the scenarios and steps that ran in every
+	// environment, widest spread first.
+	Scenarios []EnvironmentScenario
+	Steps     []EnvironmentStep
+}
+
+// OutlierScenarios returns the scenarios whose relative cost differs by at
+// least the spread threshold between environments.
+func (c EnvironmentComparison) OutlierScenarios() []EnvironmentScenario {
+	var outliers []EnvironmentScenario
+	for _, s := range c.Scenarios {
+		if s.Outlier {
+			outliers = append(outliers, s)
+		}
+	}
+	return outliers
+}
+
+// Outliers returns the steps whose relative cost differs by at least the
+// spread threshold between environments, usually a configuration or data
+// volume problem rather than a slower machine.
+func (c EnvironmentComparison) Outliers() []EnvironmentStep {
+	var outliers []EnvironmentStep
+	for _, s := range c.Steps {
+		if s.Outlier {
+			outliers = append(outliers, s)
+		}
+	}
+	return outliers
+}
+
+// CompareEnvironments compares the runs of commit made against each of
+// envs (see WithEnvironment), each environment reduced to the median of its
+// last runs runs as the regression gate does. An empty commit picks the
+// latest commit that ran in every environment. Scenarios and steps whose
+// share of the run differs by a factor of spread or more between
+// environments are flagged.
+func (v *VectorClockAgent) CompareEnvironments(envs []string, commit string, runs int, spread float64) (EnvironmentComparison, error) {
+	return v.CompareEnvironmentsBy("", envs, commit, runs, spread)
+}
+
+// CompareEnvironmentsBy is CompareEnvironments for environments named by
+// the run label label, such as "env" for runs labelled env=staging (see
+// WithLabels); an empty label uses WithEnvironment. When commit is empty
+// and no commit ran in every environment, the latest runs of each
+// environment are compared, whatever their commit.
+func (v *VectorClockAgent) CompareEnvironmentsBy(label string, envs []string, commit string, runs int, spread float64) (EnvironmentComparison, error) {
+	if len(envs) < 2 {
+		return EnvironmentComparison{}, errors.New("need at least two environments to compare")
+	}
+	v.sync()
+
+	env, envArgs := environmentExpr(label)
+	if commit == "" {
+		args := append([]interface{}{}, envArgs...)
+		for _, e := range envs {
+			args = append(args, e)
+		}
+		args = append(append(args, envArgs...), len(envs))
+		err := v.db.QueryRow(`
+			SELECT commit_sha FROM runs
+			WHERE `+env+` IN (?`+strings.Repeat(", ?", len(envs)-1)+`) AND COALESCE(commit_sha, '') != ''
+			GROUP BY commit_sha
+			HAVING COUNT(DISTINCT `+env+`) = ?
+			ORDER BY MAX(started_at) DESC
+			LIMIT 1
+		`, args...).Scan(&commit)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return EnvironmentComparison{}, fmt.Errorf("failed to find a commit common to the environments: %w", err)
+		}
+	}
+
+	c := EnvironmentComparison{Commit: commit, Label: label, Spread: spread}
+	medians := make([]RunTimings, len(envs))
+	for i, e := range envs {
+		runIDs, err := v.environmentRuns(label, e, commit, runs)
+		if err != nil {
+			return EnvironmentComparison{}, err
+		}
+		if len(runIDs) == 0 && commit != "" {
+			return EnvironmentComparison{}, fmt.Errorf("no runs of commit %s in environment %s", commit, e)
+		}
+		if len(runIDs) == 0 {
+			return EnvironmentComparison{}, fmt.Errorf("no runs in environment %s", e)
+		}
+		timings := make([]RunTimings, 0, len(runIDs))
+		for _, runID := range runIDs {
+			rt, err := v.RunTimings(runID)
+			if err != nil {
+				return EnvironmentComparison{}, err
+			}
+			timings = append(timings, rt)
+		}
+		medians[i] = medianTimings(timings)
+		c.Environments = append(c.Environments, EnvironmentRuns{Name: e, Runs: len(runIDs), Total: medians[i].Total})
+	}
+
+	for name := range medians[0].Scenarios {
+		durations := make([]time.Duration, 0, len(medians))
+		for _, m := range medians {
+			if d, ok := m.Scenarios[name]; ok {
+				durations = append(durations, d)
+			}
+		}
+		if len(durations) < len(medians) {
+			continue
+		}
+		shares, s := environmentSpread(durations, medians)
+		c.Scenarios = append(c.Scenarios, EnvironmentScenario{Name: name, Durations: durations, Shares: shares, Spread: s, Outlier: s >= spread})
+	}
+	for key := range medians[0].Steps {
+		durations := make([]time.Duration, 0, len(medians))
+		for _, m := range medians {
+			if d, ok := m.Steps[key]; ok {
+				durations = append(durations, d)
+			}
+		}
+		if len(durations) < len(medians) {
+			continue
+		}
+		shares, s := environmentSpread(durations, medians)
+		c.Steps = append(c.Steps, EnvironmentStep{Key: key, Durations: durations, Shares: shares, Spread: s, Outlier: s >= spread})
+	}
+	sort.Slice(c.Scenarios, func(i, j int) bool {
+		if c.Scenarios[i].Spread != c.Scenarios[j].Spread {
+			return c.Scenarios[i].Spread > c.Scenarios[j].Spread
+		}
+		return c.Scenarios[i].Name < c.Scenarios[j].Name
+	})
+	sort.Slice(c.Steps, func(i, j int) bool {
+		if c.Steps[i].Spread != c.Steps[j].Spread {
+			return c.Steps[i].Spread > c.Steps[j].Spread
+		}
+		return c.Steps[i].Key.String() < c.Steps[j].Key.String()
+	})
+	return c, nil
+}
+
+// environmentSpread returns durations, one per environment, as shares of
+// their environment's median total, and the largest share over the
+// smallest.
+func environmentSpread(durations []time.Duration, medians []RunTimings) (shares []float64, spread float64) {
+	lowest, highest := math.Inf(1), 0.0
+	for i, d := range durations {
+		share := 0.0
+		if medians[i].Total > 0 {
+			share = float64(d) / float64(medians[i].Total)
+		}
+		shares = append(shares, share)
+		lowest, highest = math.Min(lowest, share), math.Max(highest, share)
+	}
+	switch {
+	case highest == 0:
+		return shares, 1
+	case lowest == 0:
+		return shares, math.Inf(1)
+	default:
+		return shares, highest / lowest
+	}
+}
+
+// environmentExpr returns the SQL expression naming the environment of a
+// runs row, the value of the run label label or the environment column,
+// and its arguments.
+func environmentExpr(label string) (string, []interface{}) {
+	if label == "" {
+		return "environment", nil
+	}
+	return "json_extract(labels, ?)", []interface{}{jsonPath(label)}
+}
+
+// environmentRuns returns the n most recent runs of commit in env, or of
+// any commit when commit is empty.
+func (v *VectorClockAgent) environmentRuns(label, env, commit string, n int) ([]string, error) {
+	expr, args := environmentExpr(label)
+	rows, err := v.db.Query(`
+		SELECT run_id FROM runs
+		WHERE `+expr+` = ? AND (? = '' OR commit_sha = ?)
+		ORDER BY started_at DESC, rowid DESC
+		LIMIT ?
+	`, append(args, env, commit, commit, n)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs of environment %s: %w", env, err)
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	return runIDs, rows.Err()
+}
+
+// comparedRuns describes the runs c compared, for report headings.
+func (c EnvironmentComparison) comparedRuns() string {
+	s := "latest runs"
+	if c.Commit != "" {
+		s = c.Commit
+	}
+	if c.Label != "" {
+		s += " by label " + c.Label
+	}
+	return s
+}
+
+// WriteEnvironmentComparison prints c as a plain-text report: every
+// scenario's duration and share of the run per environment side by side,
+// then the steps that are outliers.
+func WriteEnvironmentComparison(w io.Writer, c EnvironmentComparison) error {
+	fmt.Fprintf(w, "=== Environments at %s (spread threshold %.1fx) ===\n", c.comparedRuns(), c.Spread)
+	for _, e := range c.Environments {
+		fmt.Fprintf(w, "%s: median of %d runs, total %s\n", e.Name, e.Runs, e.Total.Round(time.Millisecond))
+	}
+	fmt.Fprintln(w, "--- Scenarios ---")
+	for _, s := range c.Scenarios {
+		cells := make([]string, len(s.Durations))
+		for i, d := range s.Durations {
+			cells[i] = fmt.Sprintf("%s %s (%.1f%%)", c.Environments[i].Name, d.Round(time.Millisecond), 100*s.Shares[i])
+		}
+		mark := " "
+		if s.Outlier {
+			mark = "!"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s %s: %s\n", mark, formatSpread(s.Spread), s.Name, strings.Join(cells, ", ")); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintln(w, "--- Steps with diverging relative cost ---")
+	for _, s := range c.Outliers() {
+		cells := make([]string, len(s.Durations))
+		for i, d := range s.Durations {
+			cells[i] = fmt.Sprintf("%s %s (%.1f%%)", c.Environments[i].Name, d.Round(time.Millisecond), 100*s.Shares[i])
+		}
+		if _, err := fmt.Fprintf(w, "%s %s: %s\n", formatSpread(s.Spread), s.Key, strings.Join(cells, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteEnvironmentComparisonMarkdown prints c as Markdown tables with a
+// column per environment: every scenario, then the steps that are
+// outliers.
+func WriteEnvironmentComparisonMarkdown(w io.Writer, c EnvironmentComparison) error {
+	fmt.Fprintf(w, "### Environments at `%s`\n\n", c.comparedRuns())
+	fmt.Fprintln(w, "| Environment | Runs | Total |")
+	fmt.Fprintln(w, "|---|---|---|")
+	for _, e := range c.Environments {
+		fmt.Fprintf(w, "| %s | %d | %s |\n", markdownEscape(e.Name), e.Runs, e.Total.Round(time.Millisecond))
+	}
+	header, rule := "", ""
+	for _, e := range c.Environments {
+		header += " " + markdownEscape(e.Name) + " |"
+		rule += "---|"
+	}
+	fmt.Fprintf(w, "\n#### Scenarios\n\n")
+	fmt.Fprintln(w, "| Scenario |"+header+" Spread |")
+	fmt.Fprintln(w, "|---|"+rule+"---|")
+	for _, s := range c.Scenarios {
+		row := "| " + markdownEscape(s.Name) + " |"
+		for i, d := range s.Durations {
+			row += fmt.Sprintf(" %s (%.1f%%) |", d.Round(time.Millisecond), 100*s.Shares[i])
+		}
+		spread := formatSpread(s.Spread)
+		if s.Outlier {
+			spread = "**" + spread + "**"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s |\n", row, spread); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(w, "\n#### Steps with diverging relative cost (spread ≥ %.1fx)\n\n", c.Spread)
+	fmt.Fprintln(w, "| Step |"+header+" Spread |")
+	fmt.Fprintln(w, "|---|"+rule+"---|")
+	for _, s := range c.Outliers() {
+		row := "| " + markdownEscape(s.Key.String()) + " |"
+		for i, d := range s.Durations {
+			row += fmt.Sprintf(" %s (%.1f%%) |", d.Round(time.Millisecond), 100*s.Shares[i])
+		}
+		if _, err := fmt.Fprintf(w, "%s %s |\n", row, formatSpread(s.Spread)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatSpread(spread float64) string {
+	if math.IsInf(spread, 1) {
+		return "∞x"
+	}
+	return fmt.Sprintf("%.1fx", spread)
+}
@@ -0,0 +1,274 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultETARuns is the number of recent runs scenario estimates average.
+const DefaultETARuns = 10
+
+// etaSteps is how many progress updates a run with WithETA logs.
+const etaSteps = 10
+
+// SuitePrediction is the expected runtime of a suite before it runs.
+type SuitePrediction struct {
+	Scenarios   int
+	Concurrency int
+	// Unknown counts the scenarios that never ran before; each is
+	// estimated at the mean of the others.
+	Unknown int
+	// Work is the summed duration of the scenarios and Wall the time they
+	// take on Concurrency workers, handed out in file order as godog does.
+	Work time.Duration
+	Wall time.Duration
+
+	estimates map[scenarioRef]time.Duration
+}
+
+// scenarioRef identifies a scenario of the suite.
+type scenarioRef struct {
+	featureURI, name string
+}
+
+// scheduledScenario is a pickle godog is to run, and the line of the
+// scenario or outline it comes from.
+type scheduledScenario struct {
+	scenarioRef
+	line int
+}
+
+// PredictSuite estimates the runtime of the scenarios in the feature files
+// and directories paths, as godog.Options.Paths, from their mean duration
+// over the last DefaultETARuns runs.
+func (v *VectorClockAgent) PredictSuite(paths []string, concurrency int) (SuitePrediction, error) {
+	scheduled, estimates, unknown, err := v.estimateSuite(paths)
+	if err != nil {
+		return SuitePrediction{}, err
+	}
+
+	p := SuitePrediction{Scenarios: len(scheduled), Concurrency: max(concurrency, 1), Unknown: unknown, estimates: estimates}
+	workers := make([]time.Duration, p.Concurrency)
+	for _, s := range scheduled {
+		d := p.estimates[s.scenarioRef]
+		p.Work += d
+		next := 0
+		for i := range workers {
+			if workers[i] < workers[next] {
+				next = i
+			}
+		}
+		workers[next] += d
+	}
+	for _, w := range workers {
+		p.Wall = max(p.Wall, w)
+	}
+	return p, nil
+}
+
+// estimateSuite lists the scenarios paths schedule and estimates the
+// duration of each from history, and returns how many had none.
+func (v *VectorClockAgent) estimateSuite(paths []string) ([]scheduledScenario, map[scenarioRef]time.Duration, int, error) {
+	scheduled, err := scheduledScenarios(paths)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	history, err := v.scenarioMeans(DefaultETARuns)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	// An outline's rows share a name, and history holds their sum.
+	rows := make(map[scenarioRef]int)
+	for _, s := range scheduled {
+		rows[s.scenarioRef]++
+	}
+	estimates := make(map[scenarioRef]time.Duration, len(rows))
+	var known time.Duration
+	unknown := 0
+	for ref, n := range rows {
+		if d, ok := history[ref]; ok {
+			estimates[ref] = d / time.Duration(n)
+			known += d
+		} else {
+			unknown += n
+		}
+	}
+	if unknown > 0 && unknown < len(scheduled) {
+		fallback := known / time.Duration(len(scheduled)-unknown)
+		for ref := range rows {
+			if _, ok := estimates[ref]; !ok {
+				estimates[ref] = fallback
+			}
+		}
+	}
+	return scheduled, estimates, unknown, nil
+}
+
+// scenarioMeans returns the mean duration of each scenario over the runs
+// among the last n it ran in.
+func (v *VectorClockAgent) scenarioMeans(n int) (map[scenarioRef]time.Duration, error) {
+	runIDs, err := v.RecentRuns(n)
+	if err != nil || len(runIDs) == 0 {
+		return nil, err
+	}
+	v.sync()
+
+	args := make([]interface{}, len(runIDs))
+	for i, runID := range runIDs {
+		args[i] = runID
+	}
+	rows, err := v.db.Query(`
+		SELECT COALESCE(feature_uri, ''), scenario_name,
+			SUM(`+durationNs+` * COALESCE(sample_rate, 1)) / COUNT(DISTINCT run_id)
+		FROM step_timings
+		WHERE `+primaryPhase+` AND run_id IN (?`+strings.Repeat(", ?", len(runIDs)-1)+`)
+		GROUP BY COALESCE(feature_uri, ''), scenario_name
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scenario durations: %w", err)
+	}
+	defer rows.Close()
+
+	means := make(map[scenarioRef]time.Duration)
+	for rows.Next() {
+		var ref scenarioRef
+		var meanNs int64
+		if err := rows.Scan(&ref.featureURI, &ref.name, &meanNs); err != nil {
+			return nil, err
+		}
+		means[ref] = time.Duration(meanNs)
+	}
+	return means, rows.Err()
+}
+
+var featureLineSuffix = regexp.MustCompile(`:(\d+)$`)
+
+// scheduledScenarios lists the scenarios godog runs for paths, one per
+// pickle in file order. A path with a ":line" suffix selects the scenario
+// at that line. Tag filters are not applied.
+func scheduledScenarios(paths []string) ([]scheduledScenario, error) {
+	if len(paths) == 0 {
+		paths = []string{"features"}
+	}
+	var scheduled []scheduledScenario
+	for _, p := range paths {
+		line := 0
+		if m := featureLineSuffix.FindStringSubmatch(p); m != nil {
+			line, _ = strconv.Atoi(m[1])
+			p = strings.TrimSuffix(p, m[0])
+		}
+		err := filepath.WalkDir(p, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(path, ".feature") {
+				return err
+			}
+			refs, err := featureScenarios(path, line)
+			scheduled = append(scheduled, refs...)
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read features: %w", err)
+		}
+	}
+	return scheduled, nil
+}
+
+// featureScenarios parses a feature file into its pickles, keeping only
+// the scenario declared at line when it is not 0.
+func featureScenarios(path string, line int) ([]scheduledScenario, error) {
+	doc, pickles, err := parseFeature(path)
+	if err != nil {
+		return nil, err
+	}
+	lines := make(map[string]int)
+	for _, s := range docScenarios(doc) {
+		if s.Location != nil {
+			lines[s.Id] = int(s.Location.Line)
+		}
+	}
+	uri := normalizeFeatureURI(path)
+	var scheduled []scheduledScenario
+	for _, pickle := range pickles {
+		at := lines[pickle.AstNodeIds[0]]
+		if len(pickle.Steps) == 0 || (line != 0 && at != line) {
+			continue
+		}
+		scheduled = append(scheduled, scheduledScenario{scenarioRef{featureURI: uri, name: pickle.Name}, at})
+	}
+	return scheduled, nil
+}
+
+// WritePrediction prints p on one line.
+func WritePrediction(w io.Writer, p SuitePrediction) error {
+	_, err := fmt.Fprintf(w, "predicted %s for %d scenarios on %d workers (%s of work, %d without history)\n",
+		p.Wall.Round(time.Second), p.Scenarios, p.Concurrency, p.Work.Round(time.Second), p.Unknown)
+	return err
+}
+
+// WithETA makes RunSuite predict the suite's runtime from recent runs
+// before it starts, and log an updated estimate of the time left as every
+// tenth of the scenarios finishes, for long suites such as nightly runs.
+func WithETA() Option {
+	return func(v *VectorClockAgent) {
+		v.eta = &etaTracker{}
+	}
+}
+
+// etaTracker follows a run against its prediction.
+type etaTracker struct {
+	mu        sync.Mutex
+	predicted SuitePrediction
+	started   time.Time
+	done      int
+	// left is the predicted work of the scenarios yet to finish.
+	left time.Duration
+}
+
+// startETA predicts the suite about to run and logs the prediction.
+func (v *VectorClockAgent) startETA(paths []string) {
+	p, err := v.PredictSuite(paths, v.concurrency)
+	if err != nil {
+		v.handleError(fmt.Errorf("failed to predict the suite runtime: %w", err))
+		return
+	}
+	v.eta.mu.Lock()
+	v.eta.predicted, v.eta.started, v.eta.left = p, time.Now(), p.Work
+	v.eta.mu.Unlock()
+	v.logger.Info("predicted suite runtime", "wall", p.Wall.Round(time.Second), "scenarios", p.Scenarios,
+		"workers", p.Concurrency, "unknown", p.Unknown)
+}
+
+// finishETA counts a finished scenario and logs the estimate when the run
+// passes another tenth of its scenarios.
+func (v *VectorClockAgent) finishETA(featureURI, name string) {
+	e := v.eta
+	e.mu.Lock()
+	total := e.predicted.Scenarios
+	if total == 0 || e.done >= total {
+		e.mu.Unlock()
+		return
+	}
+	e.done++
+	e.left = max(e.left-e.predicted.estimates[scenarioRef{featureURI, name}], 0)
+	done, left, elapsed := e.done, e.left, time.Since(e.started)
+	e.mu.Unlock()
+
+	if done*etaSteps/total == (done-1)*etaSteps/total {
+		return
+	}
+	// Scale the work left by the pace of the run so far, which also
+	// corrects for a prediction that was off throughout.
+	remaining := left / time.Duration(e.predicted.Concurrency)
+	if spent := e.predicted.Work - left; spent > 0 {
+		remaining = time.Duration(float64(left) * float64(elapsed) / float64(spent))
+	}
+	v.logger.Info("suite progress", "done", done, "of", total, "remaining", remaining.Round(time.Second),
+		"eta", time.Now().Add(remaining).Format(time.TimeOnly))
+}
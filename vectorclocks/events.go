@@ -0,0 +1,105 @@
+package vectorclocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// stepEvent is the NDJSON line written for a finished step.
+type stepEvent struct {
+	Event      string    `json:"event"`
+	RunID      string    `json:"run_id"`
+	StepID     string    `json:"step_id"`
+	Scenario   string    `json:"scenario"`
+	Step       string    `json:"step"`
+	FeatureURI string    `json:"feature_uri,omitempty"`
+	Status     string    `json:"status"`
+	Tags       []string  `json:"tags,omitempty"`
+	Phase      string    `json:"phase"`
+	Attempt    int       `json:"attempt"`
+	DurationMs int64     `json:"duration_ms"`
+	DurationNs int64     `json:"duration_ns"`
+	StartedAt  time.Time `json:"started_at"`
+	EndedAt    time.Time `json:"ended_at"`
+	// Annotations are the key/value pairs the step added with Annotate.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// InjectedNs is the fault latency excluded from the duration.
+	InjectedNs int64 `json:"injected_ns,omitempty"`
+	// Memory is the allocation delta recorded WithMemoryTracking.
+	Memory *MemoryDelta `json:"memory,omitempty"`
+	// ExampleLine and Example locate and describe the Examples row of an
+	// outline scenario.
+	ExampleLine int    `json:"example_line,omitempty"`
+	Example     string `json:"example,omitempty"`
+	// Origin tells Background steps from the scenario's own.
+	Origin string `json:"origin,omitempty"`
+	// ScenarioLine and StepLine locate the step in the feature file.
+	ScenarioLine int `json:"scenario_line,omitempty"`
+	StepLine     int `json:"step_line,omitempty"`
+	// StepHash identifies the step across runs.
+	StepHash string `json:"step_hash"`
+}
+
+// eventStream serializes the events of concurrent scenarios.
+type eventStream struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// WithEventStream writes an NDJSON event to w as each step finishes, so log
+// pipelines such as Loki or CloudWatch can ingest timings while the suite
+// runs. Each line is a JSON object with "event": "step" and the step's
+// run, scenario, text, status, duration and UTC start and end times. Write
+// errors go to the error handler.
+func WithEventStream(w io.Writer) Option {
+	return func(v *VectorClockAgent) {
+		if w != nil {
+			v.events = &eventStream{enc: json.NewEncoder(w)}
+		}
+	}
+}
+
+// emit writes the event of a finished step.
+func (v *VectorClockAgent) emit(rec stepRecord) {
+	if v.events == nil || (v.recordFilter != nil && !v.recordFilter.records(rec)) {
+		return
+	}
+	var tags []string
+	if rec.tags != "" {
+		tags = strings.Split(rec.tags, ",")
+	}
+	e := stepEvent{
+		Event:        "step",
+		RunID:        rec.runID,
+		StepID:       rec.stepID,
+		Scenario:     rec.scenarioName,
+		Step:         rec.stepText,
+		FeatureURI:   rec.featureURI,
+		Status:       rec.status,
+		Tags:         tags,
+		Phase:        rec.phase,
+		Attempt:      rec.attempt,
+		DurationMs:   rec.duration.Milliseconds(),
+		DurationNs:   rec.duration.Nanoseconds(),
+		StartedAt:    rec.startedAt.UTC(),
+		EndedAt:      rec.endedAt.UTC(),
+		Annotations:  rec.annotations,
+		InjectedNs:   rec.injected.Nanoseconds(),
+		Memory:       rec.memory,
+		ExampleLine:  rec.example.line,
+		Example:      rec.example.values,
+		Origin:       rec.origin,
+		ScenarioLine: rec.scenarioLine,
+		StepLine:     rec.stepLine,
+		StepHash:     rec.hash(),
+	}
+	v.events.mu.Lock()
+	defer v.events.mu.Unlock()
+	if err := v.events.enc.Encode(e); err != nil {
+		v.handleError(fmt.Errorf("failed to write step event: %w", err))
+	}
+}
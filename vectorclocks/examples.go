@@ -0,0 +1,37 @@
+package vectorclocks
+
+import "fmt"
+
+// exampleRow is the Examples table row a scenario outline ran with.
+type exampleRow struct {
+	// line is the row's line in the feature file; 0 for scenarios that
+	// are not outline rows.
+	line int
+	// values are the row's cells as "header=value" pairs in column order.
+	values string
+}
+
+// SlowestExamples returns the n slowest rows of scenario outlines by mean
+// duration across all recorded runs, named after the outline, the row's
+// location and its values, so the slow example of an outline stands out.
+func (v *VectorClockAgent) SlowestExamples(n int) ([]Hotspot, error) {
+	v.sync()
+
+	hotspots, err := v.hotspots(`
+		SELECT scenario_name || ' [' || COALESCE(feature_uri, '') || ':' || example_line || '] ' || COALESCE(example, ''),
+			COUNT(*), AVG(total_ns), MAX(total_ns)
+		FROM (
+			SELECT scenario_name, feature_uri, example_line, example, SUM(`+durationNs+`) AS total_ns
+			FROM step_timings
+			WHERE `+primaryPhase+` AND example_line IS NOT NULL
+			GROUP BY COALESCE(run_id, ''), scenario_name, feature_uri, example_line, example
+		)
+		GROUP BY scenario_name, feature_uri, example_line, example
+		ORDER BY AVG(total_ns) DESC
+		LIMIT ?
+	`, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load slowest examples: %w", err)
+	}
+	return hotspots, nil
+}
@@ -0,0 +1,175 @@
+package vectorclocks
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats accepted by WriteExport.
+const (
+	FormatJSON   = "json"
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// exportedTiming is the wire form of a StepTiming. Durations are written in
+// milliseconds and, exactly, in nanoseconds; times in RFC 3339 so the
+// output is readable without Go.
+type exportedTiming struct {
+	StepID       string   `json:"step_id"`
+	RunID        string   `json:"run_id"`
+	ScenarioName string   `json:"scenario"`
+	StepText     string   `json:"step"`
+	FeatureURI   string   `json:"feature_uri"`
+	Status       string   `json:"status"`
+	Tags         []string `json:"tags"`
+	Phase        string   `json:"phase"`
+	Attempt      int      `json:"attempt"`
+	DurationMs   int64    `json:"duration_ms"`
+	DurationNs   int64    `json:"duration_ns"`
+	CreatedAt    string   `json:"created_at"`
+	StartedAt    string   `json:"started_at,omitempty"`
+	EndedAt      string   `json:"ended_at,omitempty"`
+	// Annotations is omitted from JSON when empty and written as a JSON
+	// object in CSV.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	InjectedNs  int64             `json:"injected_ns,omitempty"`
+	// Memory is omitted from JSON for steps recorded without
+	// WithMemoryTracking and left empty in CSV.
+	Memory       *MemoryDelta `json:"memory,omitempty"`
+	ExampleLine  int          `json:"example_line,omitempty"`
+	Example      string       `json:"example,omitempty"`
+	Origin       string       `json:"origin,omitempty"`
+	ScenarioLine int          `json:"scenario_line,omitempty"`
+	StepLine     int          `json:"step_line,omitempty"`
+	StepHash     string       `json:"step_hash,omitempty"`
+	Process      string       `json:"process_id,omitempty"`
+	VectorClock  string       `json:"vector_clock,omitempty"`
+	GapNs        int64        `json:"gap_ns,omitempty"`
+}
+
+var exportColumns = []string{
+	"step_id", "run_id", "scenario", "step", "feature_uri", "status", "tags", "phase", "attempt", "duration_ms", "duration_ns", "created_at", "started_at", "ended_at", "annotations", "injected_ns",
+	"alloc_bytes", "mallocs", "gc_cycles", "example_line", "example", "origin",
+	"scenario_line", "step_line", "step_hash", "process_id", "vector_clock", "gap_ns",
+}
+
+func exportTiming(t StepTiming) exportedTiming {
+	tags := t.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	return exportedTiming{
+		StepID:       t.StepID,
+		RunID:        t.RunID,
+		ScenarioName: t.ScenarioName,
+		StepText:     t.StepText,
+		FeatureURI:   t.FeatureURI,
+		Status:       t.Status,
+		Tags:         tags,
+		Phase:        t.Phase,
+		Attempt:      t.Attempt,
+		DurationMs:   t.Duration.Milliseconds(),
+		DurationNs:   t.Duration.Nanoseconds(),
+		CreatedAt:    t.CreatedAt.UTC().Format(time.RFC3339),
+		StartedAt:    formatExportTime(t.StartedAt),
+		EndedAt:      formatExportTime(t.EndedAt),
+		Annotations:  t.Annotations,
+		InjectedNs:   t.Injected.Nanoseconds(),
+		Memory:       t.Memory,
+		ExampleLine:  t.ExampleLine,
+		Example:      t.Example,
+		Origin:       t.Origin,
+		ScenarioLine: t.ScenarioLine,
+		StepLine:     t.StepLine,
+		StepHash:     t.StepHash,
+		Process:      t.Process,
+		VectorClock:  t.Clock.String(),
+		GapNs:        t.Gap.Nanoseconds(),
+	}
+}
+
+// formatExportTime writes t with nanoseconds, or "" when it is unknown.
+func formatExportTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// WriteExport writes timings to w as a JSON array, CSV with a header row,
+// newline-delimited JSON, one of the split formats FormatKnapsack and
+// FormatJUnit, or FormatOpenMetrics. In CSV the tags are joined with
+// commas.
+func WriteExport(w io.Writer, format string, timings []StepTiming) error {
+	switch format {
+	case FormatJSON:
+		rows := make([]exportedTiming, 0, len(timings))
+		for _, t := range timings {
+			rows = append(rows, exportTiming(t))
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rows)
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, t := range timings {
+			if err := enc.Encode(exportTiming(t)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(exportColumns); err != nil {
+			return err
+		}
+		for _, t := range timings {
+			e := exportTiming(t)
+			annotations, err := encodeAnnotations(e.Annotations)
+			if err != nil {
+				return err
+			}
+			annotationsText, _ := annotations.(string)
+			var allocBytes, mallocs, gcCycles string
+			exampleLine, scenarioLine, stepLine := optionalInt(e.ExampleLine), optionalInt(e.ScenarioLine), optionalInt(e.StepLine)
+			if m := e.Memory; m != nil {
+				allocBytes = strconv.FormatUint(m.AllocBytes, 10)
+				mallocs = strconv.FormatUint(m.Mallocs, 10)
+				gcCycles = strconv.FormatUint(uint64(m.GCCycles), 10)
+			}
+			err = cw.Write([]string{
+				e.StepID, e.RunID, e.ScenarioName, e.StepText, e.FeatureURI, e.Status,
+				strings.Join(e.Tags, ","), e.Phase, strconv.Itoa(e.Attempt), strconv.FormatInt(e.DurationMs, 10), strconv.FormatInt(e.DurationNs, 10), e.CreatedAt, e.StartedAt, e.EndedAt, annotationsText, strconv.FormatInt(e.InjectedNs, 10),
+				allocBytes, mallocs, gcCycles, exampleLine, e.Example, e.Origin,
+				scenarioLine, stepLine, e.StepHash, e.Process, e.VectorClock, strconv.FormatInt(e.GapNs, 10),
+			})
+			if err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatKnapsack:
+		return writeKnapsack(w, timings)
+	case FormatJUnit:
+		return writeJUnit(w, timings)
+	case FormatOpenMetrics:
+		return writeOpenMetrics(w, timings)
+	default:
+		return fmt.Errorf("unknown export format %q (want %s, %s, %s, %s, %s or %s)", format, FormatJSON, FormatCSV, FormatNDJSON, FormatKnapsack, FormatJUnit, FormatOpenMetrics)
+	}
+}
+
+// optionalInt formats n for CSV, leaving it empty when not set.
+func optionalInt(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
@@ -0,0 +1,108 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// firstFailure is when, and after how many scenarios, the run first failed.
+type firstFailure struct {
+	after    time.Duration
+	position int
+}
+
+// noteFirstFailure records the first failing scenario of the primary phase.
+// position is the 1-based number of scenarios finished so far.
+func (v *VectorClockAgent) noteFirstFailure() {
+	v.firstFailureMu.Lock()
+	defer v.firstFailureMu.Unlock()
+	if v.firstFailure != nil {
+		return
+	}
+	v.firstFailure = &firstFailure{
+		after:    v.since(v.startedAt),
+		position: int(atomic.LoadUint64(&v.scenarios)),
+	}
+}
+
+// RunFailure is the fail-fast metric of one run: how long it took until the
+// first scenario failed and how many scenarios had finished by then.
+type RunFailure struct {
+	RunID     string
+	StartedAt time.Time
+	// Failed is false for runs without failures; the other fields are
+	// then zero.
+	Failed             bool
+	TimeToFirstFailure time.Duration
+	Position           int
+}
+
+// FailureHistory returns the fail-fast metric of the n most recent runs,
+// oldest first so it reads as a time series.
+func (v *VectorClockAgent) FailureHistory(n int) ([]RunFailure, error) {
+	rows, err := v.db.Query(`
+		SELECT run_id, started_at, first_failure_ms, first_failure_position
+		FROM (SELECT *, rowid AS seq FROM runs ORDER BY started_at DESC, rowid DESC LIMIT ?)
+		ORDER BY started_at, seq
+	`, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load failure history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []RunFailure
+	for rows.Next() {
+		var f RunFailure
+		var startedAt timestamp
+		var ms, position sql.NullInt64
+		if err := rows.Scan(&f.RunID, &startedAt, &ms, &position); err != nil {
+			return nil, err
+		}
+		f.StartedAt = startedAt.Time
+		if ms.Valid {
+			f.Failed = true
+			f.TimeToFirstFailure = time.Duration(ms.Int64) * time.Millisecond
+			f.Position = int(position.Int64)
+		}
+		history = append(history, f)
+	}
+	return history, rows.Err()
+}
+
+// WriteFailureChart prints history as a horizontal bar chart of
+// time-to-first-failure, scaled to width characters.
+func WriteFailureChart(w io.Writer, history []RunFailure, width int) error {
+	var longest time.Duration
+	for _, f := range history {
+		if f.TimeToFirstFailure > longest {
+			longest = f.TimeToFirstFailure
+		}
+	}
+
+	for _, f := range history {
+		label := f.StartedAt.Format("2006-01-02 15:04")
+		if !f.Failed {
+			if _, err := fmt.Fprintf(w, "%s %-28s | no failures\n", label, f.RunID); err != nil {
+				return err
+			}
+			continue
+		}
+		bar := 1
+		if longest > 0 {
+			bar = int(float64(width) * float64(f.TimeToFirstFailure) / float64(longest))
+		}
+		if bar < 1 {
+			bar = 1
+		}
+		_, err := fmt.Fprintf(w, "%s %-28s | %s %s (scenario #%d)\n", label, f.RunID,
+			strings.Repeat("#", bar), f.TimeToFirstFailure.Round(time.Millisecond), f.Position)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	gherkin "github.com/cucumber/gherkin/go/v26"
+	messages "github.com/cucumber/messages/go/v21"
+)
+
+// parseFeature parses a feature file into its document and pickles. The
+// IDs are numbered from 1 and so differ from the ones godog assigns.
+func parseFeature(path string) (*messages.GherkinDocument, []*messages.Pickle, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var ids int
+	newID := func() string {
+		ids++
+		return strconv.Itoa(ids)
+	}
+	doc, err := gherkin.ParseGherkinDocument(f, newID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc, gherkin.Pickles(*doc, path, newID), nil
+}
+
+// docScenarios returns the scenarios and outlines of doc, including those
+// inside rules, in file order.
+func docScenarios(doc *messages.GherkinDocument) []*messages.Scenario {
+	if doc.Feature == nil {
+		return nil
+	}
+	var scenarios []*messages.Scenario
+	for _, c := range doc.Feature.Children {
+		if c.Scenario != nil {
+			scenarios = append(scenarios, c.Scenario)
+		}
+		if c.Rule != nil {
+			for _, rc := range c.Rule.Children {
+				if rc.Scenario != nil {
+					scenarios = append(scenarios, rc.Scenario)
+				}
+			}
+		}
+	}
+	return scenarios
+}
@@ -0,0 +1,153 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// FlagEnvPrefix marks environment variables naming a feature flag of the
+// system under test: VECTORCLOCKS_FLAG_NEW_CHECKOUT=on records the flag
+// new_checkout as "on".
+const FlagEnvPrefix = "VECTORCLOCKS_FLAG_"
+
+// WithFeatureFlags records the feature flags active in the system under
+// test with the run, in addition to those found in FlagEnvPrefix
+// environment variables, so that CompareFlag can relate slowdowns to flag
+// rollouts.
+func WithFeatureFlags(flags map[string]string) Option {
+	return func(v *VectorClockAgent) {
+		for name, value := range flags {
+			if v.flags == nil {
+				v.flags = make(map[string]string)
+			}
+			v.flags[name] = value
+		}
+	}
+}
+
+// ParseFeatureFlags parses a comma-separated list such as
+// "new_checkout=on,search=v2". A flag without a value is "on".
+func ParseFeatureFlags(s string) (map[string]string, error) {
+	flags := make(map[string]string)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, "=")
+		if !ok {
+			value = "on"
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("feature flag %q: missing name", field)
+		}
+		flags[name] = strings.TrimSpace(value)
+	}
+	return flags, nil
+}
+
+// detectFlags adds the flags set in the environment. Flags set with
+// WithFeatureFlags win.
+func (v *VectorClockAgent) detectFlags() {
+	for _, kv := range os.Environ() {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, FlagEnvPrefix) || len(name) == len(FlagEnvPrefix) {
+			continue
+		}
+		name = strings.ToLower(strings.TrimPrefix(name, FlagEnvPrefix))
+		if v.flags == nil {
+			v.flags = make(map[string]string)
+		}
+		if _, ok := v.flags[name]; !ok {
+			v.flags[name] = value
+		}
+	}
+}
+
+// FeatureFlags returns the feature flags recorded with the current run.
+func (v *VectorClockAgent) FeatureFlags() map[string]string {
+	return v.flags
+}
+
+// insertFlags replaces the flags stored for runID.
+func (v *VectorClockAgent) insertFlags(runID string, flags map[string]string) error {
+	if _, err := v.db.Exec(`DELETE FROM run_flags WHERE run_id = ?`, runID); err != nil {
+		return fmt.Errorf("failed to record flags of run %s: %w", runID, err)
+	}
+	for name, value := range flags {
+		if _, err := v.db.Exec(`INSERT INTO run_flags (run_id, flag, value) VALUES (?, ?, ?)`, runID, name, value); err != nil {
+			return fmt.Errorf("failed to record flags of run %s: %w", runID, err)
+		}
+	}
+	return nil
+}
+
+// RunFlags returns the feature flags recorded with runID.
+func (v *VectorClockAgent) RunFlags(runID string) (map[string]string, error) {
+	rows, err := v.db.Query(`SELECT flag, value FROM run_flags WHERE run_id = ?`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load flags of run %s: %w", runID, err)
+	}
+	defer rows.Close()
+	flags := make(map[string]string)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, err
+		}
+		flags[name] = value
+	}
+	return flags, rows.Err()
+}
+
+// CompareFlag compares the runs among the last runs recorded runs that had
+// flag set to headValue with those that had it set to baseValue, each side
+// reduced to the median of its runs as the regression gate does. An empty
+// value matches runs that did not record the flag. Items more than
+// threshold percent slower with the head value are regressions.
+func (v *VectorClockAgent) CompareFlag(flag, baseValue, headValue string, runs int, threshold float64) (Comparison, error) {
+	v.sync()
+
+	runIDs, err := v.RecentRuns(runs)
+	if err != nil {
+		return Comparison{}, err
+	}
+	var base, head []RunTimings
+	for _, runID := range runIDs {
+		flags, err := v.RunFlags(runID)
+		if err != nil {
+			return Comparison{}, err
+		}
+		value := flags[flag]
+		if value != baseValue && value != headValue {
+			continue
+		}
+		rt, err := v.RunTimings(runID)
+		if err != nil {
+			return Comparison{}, err
+		}
+		if value == headValue {
+			head = append(head, rt)
+		} else {
+			base = append(base, rt)
+		}
+	}
+	if len(base) == 0 || len(head) == 0 {
+		return Comparison{}, fmt.Errorf("flag %s: need runs with both %s and %s among the last %d runs (found %d and %d)",
+			flag, flagValueName(baseValue), flagValueName(headValue), runs, len(base), len(head))
+	}
+
+	b, h := medianTimings(base), medianTimings(head)
+	b.RunID = fmt.Sprintf("%s=%s (%s)", flag, flagValueName(baseValue), b.RunID)
+	h.RunID = fmt.Sprintf("%s=%s (%s)", flag, flagValueName(headValue), h.RunID)
+	return Compare(b, h, threshold), nil
+}
+
+func flagValueName(value string) string {
+	if value == "" {
+		return "unset"
+	}
+	return value
+}
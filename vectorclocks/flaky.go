@@ -0,0 +1,144 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultFlakyThreshold is the score from which a step is reported as flaky.
+const DefaultFlakyThreshold = 0.3
+
+// FlakyStep describes how unstable a step was over recent runs.
+type FlakyStep struct {
+	Name string
+	Runs int
+	Mean time.Duration
+	// CV is the coefficient of variation of the step duration (standard
+	// deviation over mean).
+	CV float64
+	// Flips counts how often the outcome changed between consecutive runs
+	// in which the step passed or failed.
+	Flips    int
+	FlipRate float64
+	// Score is the worse of FlipRate and CV capped at 1, so 0 is perfectly
+	// stable and 1 is as unstable as it gets.
+	Score float64
+}
+
+// FlakySteps scores every step of the last n runs by duration variance and
+// outcome flip-flopping and returns those scoring at least threshold,
+// highest first. Steps that ran in fewer than three runs are not scored.
+func (v *VectorClockAgent) FlakySteps(n int, threshold float64) ([]FlakyStep, error) {
+	runIDs, err := v.RecentRuns(n)
+	if err != nil {
+		return nil, err
+	}
+	if len(runIDs) == 0 {
+		return nil, nil
+	}
+	v.sync()
+
+	order := make(map[string]int, len(runIDs))
+	args := make([]interface{}, len(runIDs))
+	for i, runID := range runIDs {
+		order[runID] = len(runIDs) - 1 - i
+		args[i] = runID
+	}
+	rows, err := v.db.Query(`
+		SELECT run_id, scenario_name, step_text, SUM(`+durationNs+`),
+			MAX(COALESCE(status, '') = 'failed'), MAX(COALESCE(status, '') = 'passed')
+		FROM step_timings
+		WHERE run_id IN (?`+strings.Repeat(", ?", len(runIDs)-1)+`) AND `+primaryPhase+`
+		GROUP BY run_id, scenario_name, step_text
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load step history: %w", err)
+	}
+	defer rows.Close()
+
+	type sample struct {
+		run            int
+		duration       time.Duration
+		failed, passed bool
+	}
+	history := make(map[StepKey][]sample)
+	for rows.Next() {
+		var runID string
+		var key StepKey
+		var durationNs int64
+		var s sample
+		if err := rows.Scan(&runID, &key.Scenario, &key.Step, &durationNs, &s.failed, &s.passed); err != nil {
+			return nil, err
+		}
+		s.run = order[runID]
+		s.duration = time.Duration(durationNs)
+		history[key] = append(history[key], s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var flaky []FlakyStep
+	for key, samples := range history {
+		if len(samples) < 3 {
+			continue
+		}
+		sort.Slice(samples, func(i, j int) bool { return samples[i].run < samples[j].run })
+
+		f := FlakyStep{Name: key.String(), Runs: len(samples)}
+		var sum, sumSq float64
+		for _, s := range samples {
+			sum += float64(s.duration)
+			sumSq += float64(s.duration) * float64(s.duration)
+		}
+		mean := sum / float64(len(samples))
+		f.Mean = time.Duration(mean)
+		if mean > 0 {
+			f.CV = math.Sqrt(math.Max(sumSq/float64(len(samples))-mean*mean, 0)) / mean
+		}
+
+		// Skipped or pending runs say nothing about the step's outcome.
+		var outcomes []bool
+		for _, s := range samples {
+			if s.failed || s.passed {
+				outcomes = append(outcomes, s.failed)
+			}
+		}
+		for i := 1; i < len(outcomes); i++ {
+			if outcomes[i] != outcomes[i-1] {
+				f.Flips++
+			}
+		}
+		if len(outcomes) > 1 {
+			f.FlipRate = float64(f.Flips) / float64(len(outcomes)-1)
+		}
+
+		f.Score = math.Max(f.FlipRate, math.Min(f.CV, 1))
+		if f.Score >= threshold {
+			flaky = append(flaky, f)
+		}
+	}
+	sort.Slice(flaky, func(i, j int) bool {
+		if flaky[i].Score != flaky[j].Score {
+			return flaky[i].Score > flaky[j].Score
+		}
+		return flaky[i].Name < flaky[j].Name
+	})
+	return flaky, nil
+}
+
+// WriteFlaky prints flaky steps one per line.
+func WriteFlaky(w io.Writer, flaky []FlakyStep) error {
+	for _, f := range flaky {
+		_, err := fmt.Fprintf(w, "%.2f %s: %d runs, mean %s, cv %.2f, %d outcome flips\n",
+			f.Score, f.Name, f.Runs, f.Mean.Round(time.Millisecond), f.CV, f.Flips)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
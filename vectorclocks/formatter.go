@@ -0,0 +1,145 @@
+package vectorclocks
+
+import (
+	"io"
+	"sync"
+
+	"github.com/cucumber/godog"
+	messages "github.com/cucumber/messages/go/v21"
+)
+
+// FormatName is the godog format RegisterFormatter registers.
+const FormatName = "vectorclocks"
+
+// RegisterFormatter registers the agent as the godog formatter FormatName,
+// or FormatName-<namespace> for an agent created WithNamespace, and returns
+// the name. Adding it to the suite's formats, e.g. Format: "pretty,vectorclocks",
+// records step and scenario timings from the formatter callbacks, so the
+// ScenarioInitializer need not call InitializeScenario; use one or the
+// other. The formatter writes no output of its own.
+//
+// Formatter callbacks carry no step context, so steps recorded this way
+// cannot use Uses, Span, Annotate or fault injection, and the timing
+// excludes the suite's own step hooks. Nor do they carry the suite's
+// options: undefined and pending steps fail their scenario only when the
+// suite runs through RunSuite with Strict set.
+func (v *VectorClockAgent) RegisterFormatter() string {
+	name := FormatName
+	if v.namespace != "" {
+		name += "-" + v.namespace
+	}
+	godog.Format(name, "records step timings in the vectorclocks database", v.Formatter)
+	return name
+}
+
+// Formatter is the godog.FormatterFunc behind RegisterFormatter, for suites
+// that register formatters themselves.
+func (v *VectorClockAgent) Formatter(suite string, out io.Writer) godog.Formatter {
+	return &formatter{agent: v, scenarios: make(map[string]*formatterScenario)}
+}
+
+// formatter records timings from godog's formatter callbacks. Callbacks of
+// concurrent scenarios arrive from their own goroutines.
+type formatter struct {
+	agent *VectorClockAgent
+
+	mu        sync.Mutex
+	scenarios map[string]*formatterScenario
+}
+
+// formatterScenario is a running scenario, keyed by pickle ID.
+type formatterScenario struct {
+	info   scenarioInfo
+	steps  map[string]*stepInfo
+	left   int
+	failed bool
+}
+
+func (f *formatter) TestRunStarted()                                   {}
+func (f *formatter) Feature(*messages.GherkinDocument, string, []byte) {}
+func (f *formatter) Summary()                                          {}
+
+func (f *formatter) Passed(p *godog.Scenario, s *godog.Step, _ *godog.StepDefinition) {
+	f.result(p, s, godog.StepPassed, "")
+}
+
+func (f *formatter) Skipped(p *godog.Scenario, s *godog.Step, _ *godog.StepDefinition) {
+	f.result(p, s, godog.StepSkipped, "")
+}
+
+func (f *formatter) Failed(p *godog.Scenario, s *godog.Step, _ *godog.StepDefinition, err error) {
+	f.result(p, s, godog.StepFailed, interruptedStatus(nil, err))
+}
+
+func (f *formatter) Undefined(p *godog.Scenario, s *godog.Step, _ *godog.StepDefinition) {
+	f.result(p, s, godog.StepUndefined, "")
+}
+
+func (f *formatter) Pending(p *godog.Scenario, s *godog.Step, _ *godog.StepDefinition) {
+	f.result(p, s, godog.StepPending, "")
+}
+
+func (f *formatter) Ambiguous(p *godog.Scenario, s *godog.Step, _ *godog.StepDefinition, _ error) {
+	f.result(p, s, godog.StepAmbiguous, "")
+}
+
+// Pickle is called as a scenario starts. godog runs no steps, and reports
+// none, for a scenario without steps.
+func (f *formatter) Pickle(p *godog.Scenario) {
+	if len(p.Steps) == 0 {
+		return
+	}
+	sc := &formatterScenario{info: f.agent.beginScenario(p), steps: make(map[string]*stepInfo), left: len(p.Steps)}
+	f.mu.Lock()
+	f.scenarios[p.Id] = sc
+	f.mu.Unlock()
+}
+
+// Defined is called right before a step runs.
+func (f *formatter) Defined(p *godog.Scenario, s *godog.Step, _ *godog.StepDefinition) {
+	f.mu.Lock()
+	sc, ok := f.scenarios[p.Id]
+	f.mu.Unlock()
+	if !ok {
+		return
+	}
+	// Timing starts outside the lock, which concurrent scenarios share.
+	info := f.agent.beginStep(sc.info, s)
+	f.mu.Lock()
+	sc.steps[s.Id] = info
+	f.mu.Unlock()
+}
+
+// result records a finished step. godog reports exactly one result per
+// step, so the scenario ends with the result of its last step.
+func (f *formatter) result(p *godog.Scenario, s *godog.Step, status godog.StepResultStatus, interrupted string) {
+	f.mu.Lock()
+	sc, ok := f.scenarios[p.Id]
+	if !ok {
+		f.mu.Unlock()
+		return
+	}
+	info := sc.steps[s.Id]
+	delete(sc.steps, s.Id)
+	switch status {
+	case godog.StepFailed, godog.StepAmbiguous:
+		sc.failed = true
+	case godog.StepUndefined, godog.StepPending:
+		sc.failed = sc.failed || f.agent.strict
+	}
+	sc.left--
+	done := sc.left == 0
+	if done {
+		delete(f.scenarios, p.Id)
+	}
+	f.mu.Unlock()
+
+	if info != nil {
+		f.agent.endStep(info, status, interrupted)
+	}
+	if done {
+		f.agent.logger.Debug("after scenario", "scenario", p.Name, "failed", sc.failed)
+		f.agent.endScenario(sc.info, sc.failed)
+		f.agent.countScenario(sc.info, sc.failed)
+	}
+}
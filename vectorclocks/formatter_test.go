@@ -0,0 +1,60 @@
+package vectorclocks
+
+import (
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cucumber/godog"
+)
+
+func TestFormatterFailsUndefinedStepsOnlyWhenStrict(t *testing.T) {
+	for _, strict := range []bool{false, true} {
+		name := "non-strict"
+		if strict {
+			name = "strict"
+		}
+		t.Run(name, func(t *testing.T) {
+			a, _ := newTestAgent(t)
+			defer a.Close()
+			// Formatters are looked up by name in a global registry.
+			format := FormatName + "-" + strings.ReplaceAll(t.Name(), "/", "-")
+			godog.Format(format, "", a.Formatter)
+
+			status := a.RunSuite(godog.TestSuite{
+				Name: t.Name(),
+				ScenarioInitializer: func(ctx *godog.ScenarioContext) {
+					ctx.Step(`^it passes$`, func() error { return nil })
+					ctx.Step(`^it is pending$`, func() error { return godog.ErrPending })
+				},
+				Options: &godog.Options{
+					Format: format,
+					Output: io.Discard,
+					Strict: strict,
+					FeatureContents: []godog.Feature{{Name: "test.feature", Contents: []byte(`Feature: formatter
+  Scenario: pending
+    Given it passes
+    And it is pending
+
+  Scenario: undefined
+    Given nobody defined this
+`)}},
+				},
+			})
+			var wantFailed uint64
+			if strict {
+				wantFailed = 2
+			}
+			if passed := status == 0; passed == strict {
+				t.Errorf("suite status = %d, want passed = %t", status, !strict)
+			}
+			if failed := atomic.LoadUint64(&a.failed); failed != wantFailed {
+				t.Errorf("failed = %d, want %d; %s", failed, wantFailed, a.Summary())
+			}
+			if scenarios := atomic.LoadUint64(&a.scenarios); scenarios != 2 {
+				t.Errorf("scenarios = %d, want 2", scenarios)
+			}
+		})
+	}
+}
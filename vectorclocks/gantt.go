@@ -0,0 +1,144 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// GanttBar is a scenario execution drawn on its worker's lane. Left and
+// Width are percentages of the run's wall time.
+type GanttBar struct {
+	ScenarioExecution
+	Left, Width float64
+	// Straggler marks scenarios still running after the first worker ran
+	// out of work, which kept the run going on fewer workers.
+	Straggler bool
+}
+
+// GanttLane is the timeline of one worker.
+type GanttLane struct {
+	Worker int
+	Bars   []GanttBar
+	// Busy is the time the worker spent running scenarios, and
+	// Utilization its share of the run's wall time, from 0 to 1.
+	Busy        time.Duration
+	Utilization float64
+}
+
+// Gantt is the timeline of a run's scenarios per worker, from the start
+// and end times of their executions.
+type Gantt struct {
+	RunID string
+	Start time.Time
+	Wall  time.Duration
+	Lanes []GanttLane
+	// Utilization is the share of the workers' time spent running
+	// scenarios, from 0 to 1.
+	Utilization float64
+	// TailStart is when the first worker ran out of work, as an offset
+	// from Start; the scenarios running after it are stragglers.
+	TailStart time.Duration
+
+	assets fs.FS
+}
+
+// Gantt lays out the scenario executions of runID on their workers.
+func (v *VectorClockAgent) Gantt(runID string) (Gantt, error) {
+	executions, err := v.Executions(runID)
+	if err != nil {
+		return Gantt{}, err
+	}
+	if len(executions) == 0 {
+		return Gantt{}, fmt.Errorf("run %s has no recorded scenario executions", runID)
+	}
+	g := NewGantt(runID, executions)
+	g.assets = v.assets
+	return g, nil
+}
+
+// NewGantt lays out executions, the scenarios of one run, on their
+// workers.
+func NewGantt(runID string, executions []ScenarioExecution) Gantt {
+	g := Gantt{RunID: runID}
+	if len(executions) == 0 {
+		return g
+	}
+	start, end := executions[0].Start, executions[0].End
+	lastEnd := make(map[int]time.Time)
+	byWorker := make(map[int][]ScenarioExecution)
+	for _, e := range executions {
+		if e.Start.Before(start) {
+			start = e.Start
+		}
+		if e.End.After(end) {
+			end = e.End
+		}
+		if e.End.After(lastEnd[e.Worker]) {
+			lastEnd[e.Worker] = e.End
+		}
+		byWorker[e.Worker] = append(byWorker[e.Worker], e)
+	}
+	g.Start, g.Wall = start, end.Sub(start)
+
+	tail := end
+	for _, t := range lastEnd {
+		if t.Before(tail) {
+			tail = t
+		}
+	}
+	g.TailStart = tail.Sub(start)
+
+	workers := make([]int, 0, len(byWorker))
+	for w := range byWorker {
+		workers = append(workers, w)
+	}
+	sort.Ints(workers)
+	var busy time.Duration
+	for _, w := range workers {
+		lane := GanttLane{Worker: w}
+		for _, e := range byWorker[w] {
+			bar := GanttBar{ScenarioExecution: e, Straggler: len(workers) > 1 && e.End.After(tail)}
+			if g.Wall > 0 {
+				bar.Left = 100 * float64(e.Start.Sub(start)) / float64(g.Wall)
+				bar.Width = 100 * float64(e.Duration()) / float64(g.Wall)
+			}
+			lane.Bars = append(lane.Bars, bar)
+			lane.Busy += e.Duration()
+		}
+		if g.Wall > 0 {
+			lane.Utilization = float64(lane.Busy) / float64(g.Wall)
+		}
+		busy += lane.Busy
+		g.Lanes = append(g.Lanes, lane)
+	}
+	if g.Wall > 0 {
+		g.Utilization = float64(busy) / float64(g.Wall) / float64(len(workers))
+	}
+	return g
+}
+
+var ganttFuncs = template.FuncMap{
+	"round":   func(d time.Duration) time.Duration { return d.Round(time.Millisecond) },
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+	"offset":  func(t, start time.Time) time.Duration { return t.Sub(start).Round(time.Millisecond) },
+	"share":   func(d, wall time.Duration) float64 { return 100 * float64(d) / float64(max(wall, 1)) },
+}
+
+// WriteHTML renders the timeline as a standalone HTML page using
+// templates/gantt.html.tmpl, with a lane per worker and stragglers
+// highlighted.
+func (g Gantt) WriteHTML(w io.Writer) error {
+	assets := g.assets
+	if assets == nil {
+		assets = embeddedAssets
+	}
+	tmpl, err := loadTemplate(assets, "templates/gantt.html.tmpl", ganttFuncs)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, g)
+}
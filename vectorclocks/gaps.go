@@ -0,0 +1,122 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// measureGaps sets the gap before each of records, the steps of a scenario
+// that started at start, and returns their summed duration.
+func measureGaps(start time.Time, records []stepRecord) time.Duration {
+	sort.SliceStable(records, func(i, j int) bool { return records[i].startedAt.Before(records[j].startedAt) })
+	var stepTime time.Duration
+	prevEnd := start
+	for i := range records {
+		r := &records[i]
+		if !prevEnd.IsZero() {
+			r.gap = max(r.startedAt.Sub(prevEnd), 0)
+		}
+		if r.endedAt.After(prevEnd) {
+			prevEnd = r.endedAt
+		}
+		stepTime += r.duration
+	}
+	return stepTime
+}
+
+// ScenarioGaps is the untimed time of one scenario execution.
+type ScenarioGaps struct {
+	ScenarioExecution
+	// LargestGap is the longest gap before one of the scenario's steps,
+	// the step BeforeStep.
+	LargestGap time.Duration
+	BeforeStep string
+}
+
+// GapReport is where the scenario time of a run went outside its steps.
+type GapReport struct {
+	RunID string
+	// Scenarios holds every scenario execution, the most untimed first;
+	// Wall, StepTime and Untimed are their totals.
+	Scenarios []ScenarioGaps
+	Wall      time.Duration
+	StepTime  time.Duration
+	Untimed   time.Duration
+}
+
+// Gaps returns the time the scenarios of runID spent outside their steps,
+// in scenario hooks, step hooks and the framework, which is why a
+// scenario's duration exceeds the sum of its steps. Executions recorded
+// before their step time was stored take it from their matching steps.
+func (v *VectorClockAgent) Gaps(runID string) (GapReport, error) {
+	v.sync()
+
+	executions, err := v.Executions(runID)
+	if err != nil {
+		return GapReport{}, err
+	}
+	if len(executions) == 0 {
+		return GapReport{}, fmt.Errorf("run %s has no recorded scenario executions", runID)
+	}
+	timings, err := v.Timings(TimingFilter{RunID: runID})
+	if err != nil {
+		return GapReport{}, err
+	}
+	steps := make(map[[2]string][]StepTiming)
+	for _, t := range timings {
+		key := [2]string{t.FeatureURI, t.ScenarioName}
+		steps[key] = append(steps[key], t)
+	}
+
+	r := GapReport{RunID: runID}
+	for _, e := range executions {
+		g := ScenarioGaps{ScenarioExecution: e}
+		var matched time.Duration
+		for _, t := range steps[[2]string{e.FeatureURI, e.Scenario}] {
+			start := stepStart(t)
+			if start.Before(e.Start) || start.After(e.End) {
+				continue
+			}
+			matched += t.Duration
+			if t.Gap > g.LargestGap {
+				g.LargestGap, g.BeforeStep = t.Gap, t.StepText
+			}
+		}
+		if g.StepTime == 0 {
+			g.StepTime = matched
+		}
+		r.Scenarios = append(r.Scenarios, g)
+		r.Wall += g.Duration()
+		r.StepTime += g.StepTime
+		r.Untimed += g.Untimed()
+	}
+	sort.SliceStable(r.Scenarios, func(i, j int) bool { return r.Scenarios[i].Untimed() > r.Scenarios[j].Untimed() })
+	return r, nil
+}
+
+// WriteGaps prints the untimed time of r, the limit most untimed
+// scenarios first with the largest gap of each; limit <= 0 prints them
+// all.
+func WriteGaps(w io.Writer, r GapReport, limit int) error {
+	fmt.Fprintf(w, "=== Untimed time of run %s: %s of %s scenario time (%.1f%%) ===\n",
+		r.RunID, r.Untimed.Round(time.Millisecond), r.Wall.Round(time.Millisecond), percentOf(r.Untimed, r.Wall))
+	for i, g := range r.Scenarios {
+		if limit > 0 && i == limit {
+			break
+		}
+		_, err := fmt.Fprintf(w, "%s: %s of %s untimed (%.1f%%)", g.Scenario,
+			g.Untimed().Round(time.Millisecond), g.Duration().Round(time.Millisecond), percentOf(g.Untimed(), g.Duration()))
+		if err != nil {
+			return err
+		}
+		if g.LargestGap > 0 {
+			fmt.Fprintf(w, ", largest gap %s before %q", g.LargestGap.Round(time.Millisecond), g.BeforeStep)
+		}
+		if _, err := fmt.Fprintln(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
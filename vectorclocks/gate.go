@@ -0,0 +1,222 @@
+package vectorclocks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// RegressionExitCode is the status RunSuite returns when the suite passed
+// but the regression gate failed, so CI can tell the two apart.
+const RegressionExitCode = 3
+
+// GateErrorExitCode is the status RunSuite returns when the suite passed
+// but the regression gate could not be checked, e.g. because its baseline
+// database is unreachable, so that a broken gate does not pass every run.
+const GateErrorExitCode = 5
+
+// GatePolicy decides when a scenario or step counts as a timing regression
+// against the rolling baseline. When both Percent and Absolute are set, an
+// item must exceed both to fail the gate.
+type GatePolicy struct {
+	// Percent is the allowed slowdown over the baseline in percent.
+	Percent float64
+	// Absolute is the allowed slowdown over the baseline.
+	Absolute time.Duration
+	// Window is the number of comparable earlier runs whose median forms
+	// the baseline.
+	Window int
+	// MinRuns is the number of baseline runs needed before the gate is
+	// enforced.
+	MinRuns int
+	// Baseline is the database the baseline runs come from, as accepted by
+	// FetchDatabase; empty for the agent's own. It lets feature-branch
+	// runs gate against the main branch's history.
+	Baseline string
+	// Stored makes the gate compare with the baseline stored for the
+	// suite by RecomputeBaselines, from Baseline when set, instead of
+	// loading Window runs; it falls back to loading them for sharded runs
+	// and suites without a stored baseline. MinRuns applies to the runs
+	// the stored baseline was taken over.
+	Stored bool
+}
+
+// WithRegressionGate makes RunSuite compare the finished run with the median
+// of its rolling baseline (see BaselineRuns) and fail with
+// RegressionExitCode when any scenario or step regressed.
+func WithRegressionGate(policy GatePolicy) Option {
+	return func(v *VectorClockAgent) {
+		if policy.Window <= 0 {
+			policy.Window = 10
+		}
+		if policy.MinRuns <= 0 {
+			policy.MinRuns = 1
+		}
+		v.gate = &policy
+	}
+}
+
+func (p GatePolicy) regressed(d Delta) bool {
+	if d.Base == 0 || d.Head == 0 || (p.Percent <= 0 && p.Absolute <= 0) {
+		return false
+	}
+	if p.Percent > 0 && d.Percent() <= p.Percent {
+		return false
+	}
+	if p.Absolute > 0 && d.Change() <= p.Absolute {
+		return false
+	}
+	return true
+}
+
+// CheckRegressions compares the current run with the median of up to
+// policy.Window baseline runs, taken from policy.Baseline when set. ok is
+// false when there are fewer than policy.MinRuns baseline runs to compare
+// with.
+func (v *VectorClockAgent) CheckRegressions(policy GatePolicy) (c Comparison, ok bool, err error) {
+	source := v
+	if policy.Baseline != "" {
+		base, closeBase, err := v.openBaseline(context.Background(), policy.Baseline)
+		if err != nil {
+			return c, false, err
+		}
+		defer closeBase()
+		source = base
+	}
+
+	baseline, ok, err := v.gateBaseline(source, policy)
+	if err != nil || !ok {
+		return c, false, err
+	}
+	head, err := v.RunTimings(v.runID)
+	if err != nil {
+		return c, false, err
+	}
+
+	c = Compare(baseline, head, policy.Percent)
+	c.Total.Regression = policy.regressed(c.Total)
+	for _, deltas := range [][]Delta{c.Scenarios, c.Steps} {
+		for i := range deltas {
+			deltas[i].Regression = policy.regressed(deltas[i])
+		}
+	}
+	return c, true, nil
+}
+
+// gateBaseline returns the baseline the current run is gated against,
+// taken from source; ok is false when it covers fewer than policy.MinRuns
+// runs.
+func (v *VectorClockAgent) gateBaseline(source *VectorClockAgent, policy GatePolicy) (RunTimings, bool, error) {
+	if policy.Stored && !v.sharded() {
+		stored, found, err := source.StoredBaselineOf(v.suite)
+		if err != nil {
+			return RunTimings{}, false, err
+		}
+		if found {
+			v.logger.Debug("gating against stored baseline", "runs", stored.Runs, "computed_at", stored.ComputedAt)
+			return stored.Timings, stored.Runs >= policy.MinRuns, nil
+		}
+	}
+
+	runIDs, err := v.baselineRunsIn(source.db, policy.Window)
+	if err != nil {
+		return RunTimings{}, false, err
+	}
+	if len(runIDs) < policy.MinRuns {
+		return RunTimings{}, false, nil
+	}
+	baselines := make([]RunTimings, 0, len(runIDs))
+	for _, runID := range runIDs {
+		rt, err := source.RunTimings(runID)
+		if err != nil {
+			return RunTimings{}, false, err
+		}
+		baselines = append(baselines, rt)
+	}
+	return medianTimings(baselines), true, nil
+}
+
+// medianTimings combines runs into a baseline holding the median duration of
+// every scenario, step and total. Items are only counted in runs that
+// contain them.
+func medianTimings(runs []RunTimings) RunTimings {
+	scenarios := make(map[string][]time.Duration)
+	steps := make(map[StepKey][]time.Duration)
+	totals := make([]time.Duration, 0, len(runs))
+	for _, rt := range runs {
+		totals = append(totals, rt.Total)
+		for name, d := range rt.Scenarios {
+			scenarios[name] = append(scenarios[name], d)
+		}
+		for key, d := range rt.Steps {
+			steps[key] = append(steps[key], d)
+		}
+	}
+
+	median := func(d []time.Duration) time.Duration {
+		sortDurations(d)
+		return percentile(d, 50)
+	}
+	base := RunTimings{
+		RunID:     fmt.Sprintf("median of %d runs", len(runs)),
+		Total:     median(totals),
+		Scenarios: make(map[string]time.Duration, len(scenarios)),
+		Steps:     make(map[StepKey]time.Duration, len(steps)),
+	}
+	for name, d := range scenarios {
+		base.Scenarios[name] = median(d)
+	}
+	for key, d := range steps {
+		base.Steps[key] = median(d)
+	}
+	return base
+}
+
+// addRegressions counts regressions for the summary line.
+func (v *VectorClockAgent) addRegressions(n int) {
+	atomic.AddUint64(&v.regressed, uint64(n))
+}
+
+// applyGate runs the regression gate after the suite and returns the final
+// status.
+func (v *VectorClockAgent) applyGate(status int) int {
+	c, ok, err := v.CheckRegressions(*v.gate)
+	if err != nil {
+		v.handleError(fmt.Errorf("regression gate: %w", err))
+		if status == 0 {
+			status = GateErrorExitCode
+		}
+		return status
+	}
+	if !ok {
+		v.logger.Warn("regression gate skipped, too few baseline runs", "min_runs", v.gate.MinRuns)
+		return status
+	}
+
+	regressions := c.Regressions()
+	if c.Total.Regression {
+		regressions = append(regressions, c.Total)
+	}
+	if len(regressions) == 0 {
+		return status
+	}
+	v.addRegressions(len(regressions))
+
+	if v.verbosity >= VerbositySummary {
+		fmt.Println("=== Timing Regressions ===")
+		for _, d := range regressions {
+			writeDelta(os.Stdout, d)
+		}
+	}
+	names := make([]string, len(regressions))
+	for i, d := range regressions {
+		names[i] = d.Name
+	}
+	v.printAnnotations(names, DeltaAnnotations(regressions))
+	if status == 0 {
+		status = RegressionExitCode
+	}
+	return status
+}
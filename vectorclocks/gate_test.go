@@ -0,0 +1,156 @@
+package vectorclocks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// recordRun runs a one-step suite against the database at dbPath on a clock
+// that advances by tick per reading, so every duration of the run scales
+// with tick, and returns RunSuite's status.
+func recordRun(t *testing.T, dbPath string, start time.Time, tick time.Duration, opts ...Option) int {
+	t.Helper()
+	opts = append([]Option{WithVerbosity(VerbositySilent), WithClock(&fakeClock{now: start, tick: tick})}, opts...)
+	a, err := NewVectorClockAgent(dbPath, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := runFeature(t, a, 1, `Feature: gate
+  Scenario: checkout
+    Given a cart
+    When I pay
+`, func(ctx *godog.ScenarioContext) {
+		ctx.Step(`^a cart$`, func(context.Context) error { return nil })
+		ctx.Step(`^I pay$`, func(context.Context) error { return nil })
+	})
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return status
+}
+
+func TestRegressionGate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy GatePolicy
+		// tick is the clock tick of the gated run; the baseline runs
+		// tick every millisecond.
+		tick time.Duration
+		want int
+	}{
+		{"slower than the threshold", GatePolicy{Percent: 50}, 10 * time.Millisecond, RegressionExitCode},
+		{"as fast as the baseline", GatePolicy{Percent: 50}, time.Millisecond, 0},
+		{"within the threshold", GatePolicy{Percent: 1500}, 10 * time.Millisecond, 0},
+		{"percent but not absolute exceeded", GatePolicy{Percent: 50, Absolute: time.Hour}, 10 * time.Millisecond, 0},
+		{"absolute exceeded", GatePolicy{Absolute: time.Millisecond}, 10 * time.Millisecond, RegressionExitCode},
+		{"too few baseline runs", GatePolicy{Percent: 50, MinRuns: 4}, 10 * time.Millisecond, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dbPath := filepath.Join(t.TempDir(), "timings.db")
+			start := time.Now().Add(-time.Hour)
+			for i := 0; i < 3; i++ {
+				if status := recordRun(t, dbPath, start.Add(time.Duration(i)*time.Minute), time.Millisecond); status != 0 {
+					t.Fatalf("baseline run %d: status = %d", i, status)
+				}
+			}
+			if status := recordRun(t, dbPath, start.Add(10*time.Minute), tt.tick, WithRegressionGate(tt.policy)); status != tt.want {
+				t.Errorf("status = %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegressionGateBrokenBaseline(t *testing.T) {
+	dir := t.TempDir()
+	corrupt := filepath.Join(dir, "corrupt.db")
+	if err := os.WriteFile(corrupt, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dbPath := filepath.Join(dir, "timings.db")
+	start := time.Now().Add(-time.Hour)
+	recordRun(t, dbPath, start, time.Millisecond)
+	var reported []error
+	status := recordRun(t, dbPath, start.Add(time.Minute), time.Millisecond,
+		WithRegressionGate(GatePolicy{Percent: 50, Baseline: corrupt}),
+		WithErrorHandler(func(err error) { reported = append(reported, err) }))
+	if status != GateErrorExitCode {
+		t.Errorf("status = %d, want %d", status, GateErrorExitCode)
+	}
+	if len(reported) == 0 {
+		t.Error("the gate error was not reported")
+	}
+}
+
+func TestRegressionGateStoredBaseline(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "timings.db")
+	start := time.Now().Add(-time.Hour)
+	run := func(i int, tick time.Duration, opts ...Option) int {
+		return recordRun(t, dbPath, start.Add(time.Duration(i)*time.Minute), tick, opts...)
+	}
+	// Three fast runs make up the stored baseline, three slow ones the
+	// most recent window.
+	for i := 0; i < 3; i++ {
+		run(i, time.Millisecond)
+	}
+	a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.RecomputeBaselines("", BaselineWindow{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 3; i < 6; i++ {
+		run(i, 10*time.Millisecond)
+	}
+
+	tests := []struct {
+		name   string
+		policy GatePolicy
+		want   int
+	}{
+		{"window of recent runs", GatePolicy{Percent: 50, Window: 3}, 0},
+		{"stored baseline", GatePolicy{Percent: 50, Window: 3, Stored: true}, RegressionExitCode},
+		{"stored baseline over too few runs", GatePolicy{Percent: 50, Window: 3, Stored: true, MinRuns: 4}, 0},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := run(10+i, 10*time.Millisecond, WithRegressionGate(tt.policy)); status != tt.want {
+				t.Errorf("status = %d, want %d", status, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegressionGateKeepsFailureStatus(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "timings.db")
+	start := time.Now().Add(-time.Hour)
+	recordRun(t, dbPath, start, time.Millisecond)
+
+	a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent),
+		WithClock(&fakeClock{now: start.Add(time.Minute), tick: 10 * time.Millisecond}),
+		WithRegressionGate(GatePolicy{Percent: 50}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	status := runFeature(t, a, 1, `Feature: gate
+  Scenario: checkout
+    Given a cart
+    When I pay
+`, func(ctx *godog.ScenarioContext) {
+		ctx.Step(`^a cart$`, func(context.Context) error { return nil })
+		ctx.Step(`^I pay$`, func(context.Context) error { return godog.ErrPending })
+	})
+	if status == 0 || status == RegressionExitCode {
+		t.Errorf("status = %d, want godog's failure status", status)
+	}
+}
@@ -0,0 +1,80 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// NewTestAgent creates an agent for the test t and closes it with
+// t.Cleanup, failing t when the agent cannot be created or when steps could
+// not be written. Errors while recording are logged to t.
+func NewTestAgent(t testing.TB, dbPath string, opts ...Option) *VectorClockAgent {
+	t.Helper()
+	opts = append([]Option{WithErrorHandler(func(err error) { t.Log("vectorclocks:", err) })}, opts...)
+	v, err := NewVectorClockAgent(dbPath, opts...)
+	if err != nil {
+		t.Fatalf("failed to create vectorclocks agent: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := v.Close(); err != nil {
+			t.Errorf("failed to close vectorclocks agent: %v", err)
+		}
+		t.Log(v.Summary())
+	})
+	return v
+}
+
+// RunTest runs suite under go test through RunSuite: each scenario becomes
+// a subtest of t, so go test reports per-scenario timing, and each
+// scenario's test log gets the duration of its steps. t fails when the
+// suite does. Register the agent's hooks from the ScenarioInitializer as
+// usual; call it from e.g. TestFeatures.
+func (v *VectorClockAgent) RunTest(t *testing.T, suite godog.TestSuite) {
+	t.Helper()
+	var opts godog.Options
+	if suite.Options != nil {
+		opts = *suite.Options
+	}
+	if opts.Format == "" {
+		opts.Format = "pretty"
+	}
+	opts.TestingT = t
+	suite.Options = &opts
+
+	v.testLog = true
+	defer func() { v.testLog = false }()
+	if status := v.RunSuite(suite); status != 0 {
+		t.Fatalf("godog suite failed with status %d", status)
+	}
+}
+
+// Main runs the tests of m, then closes the agent and prints the summary
+// line, for agents shared by the tests of a package from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		agent, _ = vectorclocks.NewVectorClockAgent("step_timings.db")
+//		os.Exit(agent.Main(m))
+//	}
+//
+// A Close error fails an otherwise passing run.
+func (v *VectorClockAgent) Main(m *testing.M) int {
+	code := m.Run()
+	if err := v.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if code == 0 {
+			code = 1
+		}
+	}
+	v.PrintSummary()
+	return code
+}
+
+// logTestStep reports a step's duration to the test log of its scenario
+// while RunTest runs.
+func logTestStep(t godog.TestingT, text string, d time.Duration) {
+	t.Logf("%s: %s", text, d.Round(time.Microsecond))
+}
@@ -0,0 +1,219 @@
+package vectorclocks
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Heatmap is a matrix of step time with scenarios as rows and steps as
+// columns, which shows at a glance which step dominates which scenario.
+// Steps are told apart by their whitespace-normalized text, so a step
+// shared by many scenarios is one column.
+type Heatmap struct {
+	// Scenarios are the rows, the slowest first.
+	Scenarios []string
+	// Steps are the columns, the step taking the most time overall first.
+	Steps []string
+	// Cells[i][j] is the mean time step j took per execution of scenario
+	// i, or zero when the scenario has no such step. A step written twice
+	// in a scenario counts both times.
+	Cells [][]time.Duration
+	// Executions[i] is how many times scenario i ran in the timings.
+	Executions []int
+
+	assets fs.FS
+}
+
+// Heatmap builds the heatmap of the primary-phase steps matching filter.
+func (v *VectorClockAgent) Heatmap(filter TimingFilter) (Heatmap, error) {
+	timings, err := v.Timings(filter)
+	if err != nil {
+		return Heatmap{}, fmt.Errorf("failed to load heatmap: %w", err)
+	}
+	h := NewHeatmap(timings)
+	h.assets = v.assets
+	return h, nil
+}
+
+// NewHeatmap builds the heatmap of timings. Only primary-phase steps are
+// counted.
+func NewHeatmap(timings []StepTiming) Heatmap {
+	type execution struct {
+		runID, feature, scenario string
+		exampleLine, attempt     int
+	}
+	totals := make(map[StepKey]time.Duration)
+	scenarioTime := make(map[string]time.Duration)
+	stepTime := make(map[string]time.Duration)
+	executions := make(map[string]map[execution]bool)
+	for _, t := range timings {
+		if t.Phase != "" && t.Phase != phasePrimary {
+			continue
+		}
+		step := normalizeStepText(t.StepText)
+		totals[StepKey{Scenario: t.ScenarioName, Step: step}] += t.Duration
+		stepTime[step] += t.Duration
+		if executions[t.ScenarioName] == nil {
+			executions[t.ScenarioName] = make(map[execution]bool)
+		}
+		executions[t.ScenarioName][execution{t.RunID, t.FeatureURI, t.ScenarioName, t.ExampleLine, t.Attempt}] = true
+	}
+
+	var h Heatmap
+	for step := range stepTime {
+		h.Steps = append(h.Steps, step)
+	}
+	sort.Slice(h.Steps, func(i, j int) bool {
+		if stepTime[h.Steps[i]] != stepTime[h.Steps[j]] {
+			return stepTime[h.Steps[i]] > stepTime[h.Steps[j]]
+		}
+		return h.Steps[i] < h.Steps[j]
+	})
+	for k, d := range totals {
+		scenarioTime[k.Scenario] += d / time.Duration(len(executions[k.Scenario]))
+	}
+	for scenario := range scenarioTime {
+		h.Scenarios = append(h.Scenarios, scenario)
+	}
+	sort.Slice(h.Scenarios, func(i, j int) bool {
+		if scenarioTime[h.Scenarios[i]] != scenarioTime[h.Scenarios[j]] {
+			return scenarioTime[h.Scenarios[i]] > scenarioTime[h.Scenarios[j]]
+		}
+		return h.Scenarios[i] < h.Scenarios[j]
+	})
+
+	for _, scenario := range h.Scenarios {
+		n := len(executions[scenario])
+		row := make([]time.Duration, len(h.Steps))
+		for j, step := range h.Steps {
+			row[j] = totals[StepKey{Scenario: scenario, Step: step}] / time.Duration(n)
+		}
+		h.Cells = append(h.Cells, row)
+		h.Executions = append(h.Executions, n)
+	}
+	return h
+}
+
+// heatmapScenario is the wire form of a Heatmap row. Durations are in
+// milliseconds; steps the scenario does not have are left out.
+type heatmapScenario struct {
+	Scenario   string             `json:"scenario"`
+	Executions int                `json:"executions"`
+	TotalMs    float64            `json:"total_ms"`
+	Steps      map[string]float64 `json:"steps"`
+}
+
+// WriteJSON writes the heatmap as a JSON object with the step columns in
+// order and a row per scenario mapping its steps to milliseconds.
+func (h Heatmap) WriteJSON(w io.Writer) error {
+	out := struct {
+		Steps     []string          `json:"steps"`
+		Scenarios []heatmapScenario `json:"scenarios"`
+	}{Steps: h.Steps, Scenarios: make([]heatmapScenario, 0, len(h.Scenarios))}
+	if out.Steps == nil {
+		out.Steps = []string{}
+	}
+	for i, scenario := range h.Scenarios {
+		row := heatmapScenario{Scenario: scenario, Executions: h.Executions[i], Steps: make(map[string]float64)}
+		for j, d := range h.Cells[i] {
+			if d > 0 {
+				row.Steps[h.Steps[j]] = durationMs(d)
+				row.TotalMs += durationMs(d)
+			}
+		}
+		out.Scenarios = append(out.Scenarios, row)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// WriteCSV writes the heatmap as a CSV matrix: a header row of step texts
+// and a row per scenario with its step times in milliseconds, empty where
+// the scenario has no such step.
+func (h Heatmap) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"scenario", "executions"}, h.Steps...)); err != nil {
+		return err
+	}
+	for i, scenario := range h.Scenarios {
+		record := []string{scenario, strconv.Itoa(h.Executions[i])}
+		for _, d := range h.Cells[i] {
+			cell := ""
+			if d > 0 {
+				cell = strconv.FormatFloat(durationMs(d), 'f', -1, 64)
+			}
+			record = append(record, cell)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Round(time.Microsecond)) / float64(time.Millisecond)
+}
+
+// HeatmapCell is a cell as drawn by WriteHTML. Share is the cell's part
+// of its scenario's time, from 0 to 1, which sets its colour.
+type HeatmapCell struct {
+	Duration time.Duration
+	Share    float64
+}
+
+// HeatmapRow is a scenario as drawn by WriteHTML.
+type HeatmapRow struct {
+	Scenario   string
+	Executions int
+	Total      time.Duration
+	Cells      []HeatmapCell
+}
+
+var heatmapFuncs = template.FuncMap{
+	"round":   func(d time.Duration) time.Duration { return d.Round(time.Millisecond) },
+	"percent": func(f float64) string { return fmt.Sprintf("%.0f%%", f*100) },
+}
+
+// WriteHTML renders the heatmap as a standalone HTML page using
+// templates/heatmap.html.tmpl. Each cell is shaded by its share of the
+// scenario's time, so the step dominating a scenario is the darkest cell
+// of its row.
+func (h Heatmap) WriteHTML(w io.Writer) error {
+	assets := h.assets
+	if assets == nil {
+		assets = embeddedAssets
+	}
+	tmpl, err := loadTemplate(assets, "templates/heatmap.html.tmpl", heatmapFuncs)
+	if err != nil {
+		return err
+	}
+	rows := make([]HeatmapRow, 0, len(h.Scenarios))
+	for i, scenario := range h.Scenarios {
+		row := HeatmapRow{Scenario: scenario, Executions: h.Executions[i]}
+		for _, d := range h.Cells[i] {
+			row.Total += d
+		}
+		for _, d := range h.Cells[i] {
+			cell := HeatmapCell{Duration: d}
+			if row.Total > 0 {
+				cell.Share = float64(d) / float64(row.Total)
+			}
+			row.Cells = append(row.Cells, cell)
+		}
+		rows = append(rows, row)
+	}
+	return tmpl.Execute(w, struct {
+		Steps []string
+		Rows  []HeatmapRow
+	}{h.Steps, rows})
+}
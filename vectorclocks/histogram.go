@@ -0,0 +1,107 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultHistogramBuckets are the bucket bounds used by Report unless
+// WithHistogramBuckets is set: <100ms, 100–500ms, 0.5–2s and >2s.
+var DefaultHistogramBuckets = []time.Duration{100 * time.Millisecond, 500 * time.Millisecond, 2 * time.Second}
+
+// WithHistogramBuckets sets the upper bounds of the duration histogram
+// buckets shown by Report. A final bucket holds everything above the last
+// bound.
+func WithHistogramBuckets(bounds ...time.Duration) Option {
+	return func(v *VectorClockAgent) {
+		v.buckets = bounds
+	}
+}
+
+// Bucket counts the steps with Lower <= duration < Upper. The last bucket
+// has no Upper.
+type Bucket struct {
+	Lower time.Duration
+	Upper time.Duration
+	Count int
+}
+
+func (b Bucket) String() string {
+	switch {
+	case b.Upper == 0:
+		return ">" + b.Lower.String()
+	case b.Lower == 0:
+		return "<" + b.Upper.String()
+	}
+	return b.Lower.String() + "–" + b.Upper.String()
+}
+
+// Histogram counts primary-phase steps per duration bucket, for runID or
+// for all runs when runID is empty.
+func (v *VectorClockAgent) Histogram(runID string) ([]Bucket, error) {
+	v.sync()
+
+	bounds := v.buckets
+	if len(bounds) == 0 {
+		bounds = DefaultHistogramBuckets
+	}
+	bounds = append([]time.Duration(nil), bounds...)
+	sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
+
+	buckets := make([]Bucket, len(bounds)+1)
+	var cases strings.Builder
+	var args []interface{}
+	for i, bound := range bounds {
+		buckets[i].Upper = bound
+		buckets[i+1].Lower = bound
+		cases.WriteString(fmt.Sprintf(" WHEN %s < ? THEN %d", durationNs, i))
+		args = append(args, bound.Nanoseconds())
+	}
+
+	query := `SELECT CASE` + cases.String() + fmt.Sprintf(` ELSE %d END AS bucket, COUNT(*)`, len(bounds)) + `
+		FROM step_timings
+		WHERE ` + primaryPhase
+	if runID != "" {
+		query += ` AND run_id = ?`
+		args = append(args, runID)
+	}
+	query += ` GROUP BY bucket`
+
+	rows, err := v.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute histogram: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		buckets[bucket].Count = count
+	}
+	return buckets, rows.Err()
+}
+
+// WriteHistogram prints buckets as horizontal bars scaled to width
+// characters.
+func WriteHistogram(w io.Writer, buckets []Bucket, width int) error {
+	most := 0
+	for _, b := range buckets {
+		if b.Count > most {
+			most = b.Count
+		}
+	}
+	for _, b := range buckets {
+		bar := 0
+		if most > 0 {
+			bar = b.Count * width / most
+		}
+		if _, err := fmt.Fprintf(w, "%12s %6d %s\n", b, b.Count, strings.Repeat("#", bar)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package vectorclocks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// StepHistory is what earlier runs recorded for a step text.
+type StepHistory struct {
+	Step string
+	// Count is the number of earlier first-attempt executions.
+	Count  int
+	Median time.Duration
+	P95    time.Duration
+	// LastStatus and LastDuration describe the most recent execution in an
+	// earlier run.
+	LastStatus   string
+	LastDuration time.Duration
+}
+
+// History returns the recorded history of the step running in ctx, for glue
+// code that adapts waits or timeouts to how long the step usually takes.
+// ok is false outside a step, when the history cannot be loaded, or when
+// the step never ran in an earlier run. The history is loaded once per step
+// text and cached for the rest of the run.
+func History(ctx context.Context) (h StepHistory, ok bool) {
+	info, found := ctx.Value(stepKey).(*stepInfo)
+	if !found || info.agent == nil {
+		return h, false
+	}
+	h, err := info.agent.StepHistory(info.text)
+	if err != nil {
+		info.agent.handleError(err)
+		return h, false
+	}
+	return h, h.Count > 0
+}
+
+// StepHistory returns the history of stepText from runs other than the
+// current one. Results are cached per step text.
+func (v *VectorClockAgent) StepHistory(stepText string) (StepHistory, error) {
+	stepText = normalizeStepText(stepText)
+	if cached, ok := v.histories.Load(stepText); ok {
+		return cached.(StepHistory), nil
+	}
+
+	rows, err := v.db.Query(`
+		SELECT `+durationNs+` FROM step_timings
+		WHERE step_text = ? AND COALESCE(run_id, '') != ? AND `+primaryPhase+`
+	`, stepText, v.runID)
+	if err != nil {
+		return StepHistory{}, fmt.Errorf("failed to load history of step %q: %w", stepText, err)
+	}
+	var durations []time.Duration
+	for rows.Next() {
+		var durationNs int64
+		if err := rows.Scan(&durationNs); err != nil {
+			rows.Close()
+			return StepHistory{}, err
+		}
+		durations = append(durations, time.Duration(durationNs))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return StepHistory{}, err
+	}
+
+	h := StepHistory{Step: stepText, Count: len(durations)}
+	if len(durations) > 0 {
+		sortDurations(durations)
+		h.Median, h.P95 = percentile(durations, 50), percentile(durations, 95)
+
+		var lastNs int64
+		err := v.db.QueryRow(`
+			SELECT COALESCE(status, ''), `+durationNs+` FROM step_timings
+			WHERE step_text = ? AND COALESCE(run_id, '') != ? AND `+primaryPhase+`
+			ORDER BY created_at DESC, id DESC
+			LIMIT 1
+		`, stepText, v.runID).Scan(&h.LastStatus, &lastNs)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return StepHistory{}, fmt.Errorf("failed to load last run of step %q: %w", stepText, err)
+		}
+		h.LastDuration = time.Duration(lastNs)
+	}
+	v.histories.Store(stepText, h)
+	return h, nil
+}
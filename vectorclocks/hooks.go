@@ -0,0 +1,343 @@
+package vectorclocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+type ctxKey int
+
+const (
+	scenarioKey ctxKey = iota
+	stepKey
+	spanKey
+)
+
+// scenarioInfo is what the scenario Before hook stores in the scenario
+// context.
+type scenarioInfo struct {
+	name       string
+	featureURI string
+	tags       []string
+	batch      *scenarioBatch
+	worker     int
+	startedAt  time.Time
+	// reserved are the resource tokens held while the scenario runs and
+	// wait is how long it waited for them.
+	reserved []string
+	wait     time.Duration
+	// example is the Examples row of an outline scenario and background
+	// holds the IDs of its steps that come from a Background; both are
+	// empty when the feature file cannot be read.
+	example    exampleRow
+	background map[string]bool
+	// line is the line declaring the scenario in its feature file and
+	// stepLines the lines of its steps by godog step ID; unknown when the
+	// feature file cannot be read.
+	line      int
+	stepLines map[string]int
+	// leakStart are the counts WithLeakDetection compares the scenario's
+	// end with.
+	leakStart leakCounts
+}
+
+// scenarioBatch collects the records of one scenario so they are committed
+// together once the scenario finishes, and the steps still running.
+type scenarioBatch struct {
+	mu      sync.Mutex
+	records []stepRecord
+	open    map[*stepInfo]bool
+}
+
+func (b *scenarioBatch) start(info *stepInfo) {
+	b.mu.Lock()
+	if b.open == nil {
+		b.open = make(map[*stepInfo]bool)
+	}
+	b.open[info] = true
+	b.mu.Unlock()
+}
+
+func (b *scenarioBatch) add(info *stepInfo, rec stepRecord) {
+	b.mu.Lock()
+	delete(b.open, info)
+	b.records = append(b.records, rec)
+	b.mu.Unlock()
+}
+
+// stepInfo is what the step Before hook hands to the matching After hook
+// through the step context. Step definitions add to it through Uses, Span
+// and Annotate.
+type stepInfo struct {
+	id       string
+	text     string
+	origin   string
+	line     int
+	scenario scenarioInfo
+	agent    *VectorClockAgent
+
+	mu          sync.Mutex
+	resources   []string
+	spans       []spanRecord
+	spanCount   int
+	annotations map[string]string
+	injected    time.Duration
+	memory      *memoryStats
+	profile     *stepProfile
+	slowTimer   *time.Timer
+	ended       bool
+	// cancel releases the deadline of a step given a timeout of
+	// timeout by WithStepTimeouts; parent is the context the deadline
+	// was derived from.
+	cancel  context.CancelFunc
+	timeout time.Duration
+	parent  context.Context
+}
+
+// ScenarioNameFromContext returns the name of the scenario the context
+// belongs to, as recorded by the agent's scenario Before hook.
+func ScenarioNameFromContext(ctx context.Context) (string, bool) {
+	info, ok := ctx.Value(scenarioKey).(scenarioInfo)
+	return info.name, ok
+}
+
+// StepIDFromContext returns the agent step ID of the step currently running
+// in ctx.
+func StepIDFromContext(ctx context.Context) (string, bool) {
+	info, ok := ctx.Value(stepKey).(*stepInfo)
+	if !ok {
+		return "", false
+	}
+	return info.id, true
+}
+
+// InitializeScenario registers the agent's scenario and step hooks. Call it
+// from the suite's ScenarioInitializer, or use the formatter registered by
+// RegisterFormatter instead.
+func (v *VectorClockAgent) InitializeScenario(ctx *godog.ScenarioContext) {
+	ctx.Before(func(ctx context.Context, s *godog.Scenario) (context.Context, error) {
+		return context.WithValue(ctx, scenarioKey, v.beginScenario(s)), nil
+	})
+
+	ctx.After(func(ctx context.Context, s *godog.Scenario, err error) (context.Context, error) {
+		v.logger.Debug("after scenario", "scenario", s.Name, "err", err)
+		info, ok := ctx.Value(scenarioKey).(scenarioInfo)
+		if ok {
+			v.endScenario(info, err != nil)
+		}
+		v.countScenario(info, err != nil)
+		return ctx, nil
+	})
+
+	stepCtx := ctx.StepContext()
+
+	stepCtx.Before(func(ctx context.Context, step *godog.Step) (context.Context, error) {
+		scenario, _ := ctx.Value(scenarioKey).(scenarioInfo)
+		info := v.beginStep(scenario, step)
+		return context.WithValue(v.withStepDeadline(ctx, info), stepKey, info), nil
+	})
+
+	stepCtx.After(func(ctx context.Context, step *godog.Step, status godog.StepResultStatus, err error) (context.Context, error) {
+		info, ok := ctx.Value(stepKey).(*stepInfo)
+		if !ok {
+			return ctx, nil
+		}
+		interrupted := interruptedStatus(ctx, err)
+		// A step that overran its limit timed out, whatever it returned.
+		overran := info.timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded)
+		if overran {
+			interrupted = StatusTimedOut
+		}
+		if info.cancel != nil {
+			info.cancel()
+			ctx = withoutDeadline(ctx, info.parent)
+		}
+		if d, ok := v.endStep(info, status, interrupted); ok && v.testLog {
+			logTestStep(godog.T(ctx), step.Text, d)
+		}
+		if overran {
+			return ctx, fmt.Errorf("%w: '%s' ran longer than %s", ErrStepTimeout, step.Text, info.timeout)
+		}
+		return ctx, nil
+	})
+}
+
+// beginScenario waits for the scenario's resources and a worker slot and
+// returns the state its steps are recorded under.
+func (v *VectorClockAgent) beginScenario(s *godog.Scenario) scenarioInfo {
+	v.logger.Debug("before scenario", "scenario", s.Name)
+	info := scenarioInfo{
+		name:       s.Name,
+		featureURI: normalizeFeatureURI(featureLineSuffix.ReplaceAllString(s.Uri, "")),
+		batch:      &scenarioBatch{},
+	}
+	if p, ok := v.pickleOf(s); ok {
+		info.example = p.row
+		info.background = make(map[string]bool, p.background)
+		for _, step := range s.Steps[:p.background] {
+			info.background[step.Id] = true
+		}
+		info.line = p.line
+		info.stepLines = make(map[string]int, len(s.Steps))
+		for i, step := range s.Steps {
+			info.stepLines[step.Id] = p.stepLines[i]
+		}
+	}
+	if v.reservations != nil {
+		info.reserved = v.scenarioResources(s.Name)
+		info.wait = v.reservations.acquire(info.reserved)
+		if info.wait > 0 {
+			v.logger.Debug("scenario waited for resources", "scenario", s.Name, "wait", info.wait, "resources", info.reserved)
+		}
+	}
+	info.worker = v.workers.acquire()
+	if v.leaks != nil {
+		info.leakStart = readLeakCounts()
+	}
+	info.startedAt = v.now()
+	for _, tag := range s.Tags {
+		info.tags = append(info.tags, tag.Name)
+	}
+	v.running.Store(info.batch, info)
+	return info
+}
+
+// endScenario releases what beginScenario acquired and writes the scenario's
+// steps.
+func (v *VectorClockAgent) endScenario(info scenarioInfo, failed bool) {
+	if _, running := v.running.LoadAndDelete(info.batch); !running {
+		return
+	}
+	v.abortOpenSteps(info.batch)
+	end := v.now()
+	var leak *LeakDelta
+	if v.leaks != nil {
+		leak = v.checkLeaks(info.name, info.leakStart)
+	}
+	v.workers.release(info.worker)
+	if v.reservations != nil {
+		v.reservations.release(info.reserved)
+	}
+	info.batch.mu.Lock()
+	records := info.batch.records
+	info.batch.records = nil
+	info.batch.mu.Unlock()
+	stepTime := measureGaps(info.startedAt, records)
+	v.noteExecution(ScenarioExecution{
+		Scenario:   info.name,
+		FeatureURI: info.featureURI,
+		Line:       info.line,
+		Worker:     info.worker,
+		Start:      info.startedAt,
+		End:        end,
+		Wait:       info.wait,
+		Leak:       leak,
+		StepTime:   stepTime,
+	}, v.phase())
+	if err := v.enqueue(records); err != nil {
+		v.handleError(err)
+	}
+	v.noteScenario(info.featureURI, info.name)
+	if v.eta != nil && !v.benchmarking.Load() && !v.retrying.Load() {
+		v.finishETA(info.featureURI, info.name)
+	}
+	if failed && !v.benchmarking.Load() {
+		v.noteFailure(info.retryKey())
+	}
+}
+
+// countScenario counts a finished scenario towards the phase it ran in.
+func (v *VectorClockAgent) countScenario(info scenarioInfo, failed bool) {
+	switch {
+	case v.benchmarking.Load():
+	case v.retrying.Load():
+		v.scenarioRetried(info.retryKey(), failed)
+	default:
+		v.ScenarioFinished(failed)
+		if failed {
+			v.noteFirstFailure()
+		}
+	}
+}
+
+// beginStep starts timing step of scenario.
+func (v *VectorClockAgent) beginStep(scenario scenarioInfo, step *godog.Step) *stepInfo {
+	var memory *memoryStats
+	if v.trackMemory {
+		m := readMemoryStats()
+		memory = &m
+	}
+	var origin string
+	if scenario.background != nil {
+		origin = OriginScenario
+		if scenario.background[step.Id] {
+			origin = OriginBackground
+		}
+	}
+	id := v.Start(scenario.name, step.Text)
+	info := &stepInfo{
+		id:        id,
+		text:      step.Text,
+		origin:    origin,
+		line:      scenario.stepLines[step.Id],
+		scenario:  scenario,
+		agent:     v,
+		memory:    memory,
+		profile:   v.profileStep(id, scenario.name, step.Text),
+		slowTimer: v.watchSlowStep(scenario.name, step.Text, scenario.featureURI, scenario.stepLines[step.Id]),
+	}
+	scenario.batch.start(info)
+	return info
+}
+
+// endStep measures a step started with beginStep, adds it to its
+// scenario's batch and returns its recorded duration. A non-empty
+// interrupted status, StatusAborted or StatusTimedOut, replaces status.
+func (v *VectorClockAgent) endStep(info *stepInfo, status godog.StepResultStatus, interrupted string) (time.Duration, bool) {
+	rec, err := v.finish(info.id, info.scenario.name, info.text, status, info.scenario.tags)
+	if info.slowTimer != nil {
+		info.slowTimer.Stop()
+	}
+	if err != nil {
+		v.handleError(err)
+		return 0, false
+	}
+	if info.memory != nil {
+		rec.memory = info.memory.since()
+	}
+	var profile string
+	if info.profile != nil {
+		if profile, err = info.profile.stop(); err != nil {
+			v.handleError(fmt.Errorf("step '%s': %w", info.id, err))
+		}
+	}
+	if interrupted != "" {
+		rec.status = interrupted
+	}
+	rec.featureURI = info.scenario.featureURI
+	rec.example = info.scenario.example
+	rec.origin = info.origin
+	rec.scenarioLine, rec.stepLine = info.scenario.line, info.line
+	info.mu.Lock()
+	rec.resources = info.resources
+	rec.spans = info.spans
+	if profile != "" {
+		if info.annotations == nil {
+			info.annotations = make(map[string]string)
+		}
+		info.annotations[ProfileAnnotation] = profile
+	}
+	rec.annotations = info.annotations
+	rec.injected = info.injected
+	rec.duration = max(rec.duration-rec.injected, 0)
+	info.ended = true
+	info.mu.Unlock()
+	info.scenario.batch.add(info, rec)
+	v.emit(rec)
+	return rec.duration, true
+}
@@ -0,0 +1,92 @@
+package vectorclocks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// TestConcurrentScenarioAttribution runs scenarios concurrently and checks
+// that every step is recorded under the scenario it ran in. Run it with
+// -race to check the hooks' shared state as well.
+func TestConcurrentScenarioAttribution(t *testing.T) {
+	const scenarios = 12
+	var feature strings.Builder
+	feature.WriteString("Feature: attribution\n")
+	for i := 1; i <= scenarios; i++ {
+		fmt.Fprintf(&feature, "\n  Scenario: scenario %d\n", i)
+		for j := 1; j <= 3; j++ {
+			fmt.Fprintf(&feature, "    Given step %d of scenario %d\n", j, i)
+		}
+	}
+
+	a, err := NewVectorClockAgent(filepath.Join(t.TempDir(), "timings.db"), WithVerbosity(VerbositySilent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+
+	suite := godog.TestSuite{
+		Name: t.Name(),
+		ScenarioInitializer: func(ctx *godog.ScenarioContext) {
+			a.InitializeScenario(ctx)
+			ctx.Step(`^step (\d+) of scenario (\d+)$`, func(ctx context.Context, step, scenario int) error {
+				if name, _ := ScenarioNameFromContext(ctx); name != fmt.Sprintf("scenario %d", scenario) {
+					return fmt.Errorf("step of scenario %d ran in the context of %q", scenario, name)
+				}
+				time.Sleep(time.Duration(scenario) * time.Millisecond)
+				return nil
+			})
+		},
+		Options: &godog.Options{
+			Format:          "progress",
+			Output:          io.Discard,
+			Concurrency:     4,
+			Strict:          true,
+			FeatureContents: []godog.Feature{{Name: "test.feature", Contents: []byte(feature.String())}},
+		},
+	}
+	if status := suite.Run(); status != 0 {
+		t.Fatalf("suite status = %d, want 0", status)
+	}
+	a.Flush()
+
+	rows, err := a.db.Query(`SELECT scenario_name, step_text, duration_ms FROM step_timings WHERE run_id = ?`, a.RunID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	recorded := 0
+	for rows.Next() {
+		var scenarioName, stepText string
+		var durationMs int64
+		if err := rows.Scan(&scenarioName, &stepText, &durationMs); err != nil {
+			t.Fatal(err)
+		}
+		recorded++
+
+		var step, scenario int
+		if _, err := fmt.Sscanf(stepText, "step %d of scenario %d", &step, &scenario); err != nil {
+			t.Fatalf("unexpected step %q", stepText)
+		}
+		if want := fmt.Sprintf("scenario %d", scenario); scenarioName != want {
+			t.Errorf("%q recorded under %q, want %q", stepText, scenarioName, want)
+		}
+		if durationMs < int64(scenario) {
+			t.Errorf("%q took %dms, less than the %dms it slept", stepText, durationMs, scenario)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if recorded != scenarios*3 {
+		t.Fatalf("recorded %d steps, want %d", recorded, scenarios*3)
+	}
+}
@@ -0,0 +1,29 @@
+package vectorclocks
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// StepHash is the identity of a step across runs: a hash of its feature
+// file, its line there and its normalized text. Step IDs count up within a
+// run, so the same step gets a new one every run; its hash stays the same
+// until the step is edited or moves. A step whose line is unknown, such as
+// one timed with Start and End directly, is identified by its scenario name
+// instead. Steps of a Background share the hash across the feature's
+// scenarios, and the rows of an outline differ only by their substituted
+// text.
+func StepHash(featureURI string, line int, scenarioName, stepText string) string {
+	place := scenarioName
+	if line > 0 {
+		place = strconv.Itoa(line)
+	}
+	sum := sha256.Sum256([]byte(featureURI + "\x00" + place + "\x00" + normalizeStepText(stepText)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// hash returns the StepHash of rec.
+func (rec stepRecord) hash() string {
+	return StepHash(rec.featureURI, rec.stepLine, rec.scenarioName, rec.stepText)
+}
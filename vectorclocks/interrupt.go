@@ -0,0 +1,67 @@
+package vectorclocks
+
+import (
+	"context"
+	"errors"
+
+	"github.com/cucumber/godog"
+)
+
+// Statuses of steps that did not run to completion. They take the place of
+// godog's status in step_timings, with the duration up to the interruption.
+const (
+	// StatusAborted marks a step whose context was cancelled, or that was
+	// still running when its scenario or the suite ended, as when godog
+	// stops on the first failure.
+	StatusAborted = "aborted"
+	// StatusTimedOut marks a step whose context deadline passed.
+	StatusTimedOut = "timed_out"
+)
+
+// interruptedStatus returns the status of a step that ended with err in
+// ctx, or "" when it was not interrupted.
+func interruptedStatus(ctx context.Context, err error) string {
+	if err == nil && ctx != nil {
+		err = ctx.Err()
+	}
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return StatusTimedOut
+	case errors.Is(err, context.Canceled):
+		return StatusAborted
+	}
+	return ""
+}
+
+// abortOpenSteps ends the steps of a scenario that never got their result,
+// recording them as aborted, or as interrupted once the run was.
+func (v *VectorClockAgent) abortOpenSteps(batch *scenarioBatch) {
+	status := StatusAborted
+	if v.interruption() != "" {
+		status = StatusInterrupted
+	}
+	batch.mu.Lock()
+	open := make([]*stepInfo, 0, len(batch.open))
+	for info := range batch.open {
+		open = append(open, info)
+	}
+	batch.mu.Unlock()
+	for _, info := range open {
+		v.logger.Debug("step aborted", "step_id", info.id, "status", status)
+		v.endStep(info, godog.StepSkipped, status)
+	}
+}
+
+// abortRunning ends the scenarios that were still running when the agent
+// closed, recording their open steps as aborted, so that a suite godog
+// stopped early keeps the partial timings of its last steps.
+func (v *VectorClockAgent) abortRunning() {
+	v.running.Range(func(key, value interface{}) bool {
+		info := value.(scenarioInfo)
+		v.logger.Warn("scenario still running at close", "scenario", info.name)
+		v.endScenario(info, true)
+		return true
+	})
+}
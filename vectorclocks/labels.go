@@ -0,0 +1,94 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"strings"
+)
+
+// WithLabels attaches labels such as release=1.4, env=staging or
+// ticket=QA-123 to the run. They are stored with its runs row, and
+// TimingFilter.Label and RunsLabeled select runs by them.
+func WithLabels(labels map[string]string) Option {
+	return func(v *VectorClockAgent) {
+		for key, value := range labels {
+			if v.labels == nil {
+				v.labels = make(map[string]string)
+			}
+			v.labels[key] = value
+		}
+	}
+}
+
+// ParseLabels parses a comma-separated list such as
+// "release=1.4,ticket=QA-123".
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("label %q: want key=value", field)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+// Label attaches a label to the current run while it is running, e.g. the
+// build number once the suite has deployed it. A key labelled twice keeps
+// the last value.
+func (v *VectorClockAgent) Label(key, value string) {
+	v.labelsMu.Lock()
+	defer v.labelsMu.Unlock()
+	if v.labels == nil {
+		v.labels = make(map[string]string)
+	}
+	v.labels[key] = value
+}
+
+// Labels returns the labels of the current run.
+func (v *VectorClockAgent) Labels() map[string]string {
+	v.labelsMu.Lock()
+	defer v.labelsMu.Unlock()
+	labels := make(map[string]string, len(v.labels))
+	for key, value := range v.labels {
+		labels[key] = value
+	}
+	return labels
+}
+
+// RunLabels returns the labels recorded with runID.
+func (v *VectorClockAgent) RunLabels(runID string) (map[string]string, error) {
+	var labels string
+	err := v.db.QueryRow(`SELECT COALESCE(MAX(labels), '') FROM runs WHERE run_id = ?`, runID).Scan(&labels)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load labels of run %s: %w", runID, err)
+	}
+	return decodeAnnotations(labels)
+}
+
+// RunsLabeled returns the IDs of the n most recently started runs carrying
+// label, either a key ("ticket") or a key and value ("release=1.4"),
+// newest first.
+func (v *VectorClockAgent) RunsLabeled(label string, n int) ([]string, error) {
+	match, args := jsonMatch("labels", label)
+	rows, err := v.db.Query(`SELECT run_id FROM runs WHERE `+match+` ORDER BY started_at DESC, rowid DESC LIMIT ?`, append(args, n)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs labelled %s: %w", label, err)
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	return runIDs, rows.Err()
+}
@@ -0,0 +1,142 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+// DefaultLeakSettle is how long a scenario's goroutines and files are given
+// to wind down before the scenario is flagged as leaking them.
+const DefaultLeakSettle = 100 * time.Millisecond
+
+// LeakPolicy configures WithLeakDetection.
+type LeakPolicy struct {
+	// Goroutines and FDs are how many goroutines and open file
+	// descriptors a scenario may leave behind before it is flagged.
+	Goroutines int
+	FDs        int
+	// Settle is how long the counts of a scenario over the limits are
+	// polled for goroutines and connections that are still shutting down.
+	Settle time.Duration
+}
+
+// LeakDelta is the growth in goroutines and open file descriptors from the
+// start to the end of a scenario.
+type LeakDelta struct {
+	Goroutines int
+	// FDs is 0 where open files cannot be counted, which is on systems
+	// with neither /proc/self/fd nor /dev/fd.
+	FDs int
+}
+
+// leaks reports whether d exceeds the limits of p.
+func (p LeakPolicy) leaks(d LeakDelta) bool {
+	return d.Goroutines > p.Goroutines || d.FDs > p.FDs
+}
+
+// WithLeakDetection counts goroutines and open file descriptors as each
+// scenario starts and ends, stores the difference with the scenario's
+// execution and logs a warning for scenarios that leave more behind than p
+// allows, the usual cause of a suite that slows down as it runs. The counts
+// are process-wide, so with concurrent scenarios a delta also holds what
+// the scenarios running alongside started or stopped; run the suite with
+// concurrency 1 to pin a leak down.
+func WithLeakDetection(p LeakPolicy) Option {
+	return func(v *VectorClockAgent) {
+		v.leaks = &p
+	}
+}
+
+// leakCounts is a count of goroutines and open file descriptors; fds is -1
+// when files cannot be counted.
+type leakCounts struct {
+	goroutines, fds int
+}
+
+func readLeakCounts() leakCounts {
+	return leakCounts{goroutines: runtime.NumGoroutine(), fds: countFDs()}
+}
+
+// countFDs counts the open file descriptors of the process, or returns -1.
+// The directory read opens one more, which cancels out in a delta.
+func countFDs() int {
+	for _, dir := range []string{"/proc/self/fd", "/dev/fd"} {
+		if entries, err := os.ReadDir(dir); err == nil {
+			return len(entries)
+		}
+	}
+	return -1
+}
+
+func (c leakCounts) delta(start leakCounts) LeakDelta {
+	d := LeakDelta{Goroutines: c.goroutines - start.goroutines}
+	if c.fds >= 0 && start.fds >= 0 {
+		d.FDs = c.fds - start.fds
+	}
+	return d
+}
+
+// checkLeaks measures what a finished scenario left behind, giving it up to
+// Settle to wind down, and warns when it leaked.
+func (v *VectorClockAgent) checkLeaks(scenario string, start leakCounts) *LeakDelta {
+	p := v.leaks
+	d := readLeakCounts().delta(start)
+	for deadline := time.Now().Add(p.Settle); p.leaks(d) && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		d = readLeakCounts().delta(start)
+	}
+	if p.leaks(d) {
+		v.leaking.Add(1)
+		v.logger.Warn("scenario leaked", "scenario", scenario, "goroutines", d.Goroutines, "fds", d.FDs)
+	}
+	return &d
+}
+
+// leakSummary logs how many scenarios of the run leaked.
+func (v *VectorClockAgent) leakSummary() {
+	if n := v.leaking.Load(); n > 0 {
+		v.logger.Warn("scenarios leaked goroutines or file descriptors", "scenarios", n)
+	}
+}
+
+// Leaks returns the scenario executions of runID that leaked more than p
+// allows, in start order.
+func (v *VectorClockAgent) Leaks(runID string, p LeakPolicy) ([]ScenarioExecution, error) {
+	executions, err := v.Executions(runID)
+	if err != nil {
+		return nil, err
+	}
+	var leaks []ScenarioExecution
+	for _, e := range executions {
+		if e.Leak != nil && p.leaks(*e.Leak) {
+			leaks = append(leaks, e)
+		}
+	}
+	return leaks, nil
+}
+
+// WriteLeaks prints the leaking executions one per line with the running
+// total of what the run has leaked so far.
+func WriteLeaks(w io.Writer, leaks []ScenarioExecution) error {
+	var goroutines, fds int
+	for _, e := range leaks {
+		goroutines += e.Leak.Goroutines
+		fds += e.Leak.FDs
+		name := e.Scenario
+		switch {
+		case e.Line > 0:
+			name += fmt.Sprintf(" (%s:%d)", e.FeatureURI, e.Line)
+		case e.FeatureURI != "":
+			name += " (" + e.FeatureURI + ")"
+		}
+		_, err := fmt.Fprintf(w, "%s %s: %+d goroutines, %+d fds (total %d, %d)\n",
+			e.Start.Format(time.TimeOnly), name, e.Leak.Goroutines, e.Leak.FDs, goroutines, fds)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// MemoryDelta is the allocation activity of the process while a step ran.
+type MemoryDelta struct {
+	// AllocBytes is the number of heap bytes allocated.
+	AllocBytes uint64 `json:"alloc_bytes"`
+	// Mallocs is the number of heap objects allocated.
+	Mallocs uint64 `json:"mallocs"`
+	// GCCycles is the number of garbage collections that completed.
+	GCCycles uint32 `json:"gc_cycles"`
+}
+
+// memoryStats is the part of runtime.MemStats a MemoryDelta is taken from.
+type memoryStats struct {
+	totalAlloc, mallocs uint64
+	numGC               uint32
+}
+
+// WithMemoryTracking records the heap bytes and objects allocated and the
+// garbage collections completed while each step ran, so steps that churn
+// memory show up next to the slow ones. The counters are process-wide:
+// with concurrent scenarios a step's delta includes what the steps running
+// alongside it allocated. Reading them stops the world briefly, outside
+// the timed part of the step.
+func WithMemoryTracking() Option {
+	return func(v *VectorClockAgent) {
+		v.trackMemory = true
+	}
+}
+
+func readMemoryStats() memoryStats {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return memoryStats{totalAlloc: m.TotalAlloc, mallocs: m.Mallocs, numGC: m.NumGC}
+}
+
+// since returns the allocations made after s was read.
+func (s memoryStats) since() *MemoryDelta {
+	now := readMemoryStats()
+	return &MemoryDelta{
+		AllocBytes: now.totalAlloc - s.totalAlloc,
+		Mallocs:    now.mallocs - s.mallocs,
+		GCCycles:   now.numGC - s.numGC,
+	}
+}
+
+// columns returns the step_timings columns of d, NULL when d is nil.
+func (d *MemoryDelta) columns() (allocBytes, mallocs, gcCycles sql.NullInt64) {
+	if d == nil {
+		return
+	}
+	return sql.NullInt64{Int64: int64(d.AllocBytes), Valid: true},
+		sql.NullInt64{Int64: int64(d.Mallocs), Valid: true},
+		sql.NullInt64{Int64: int64(d.GCCycles), Valid: true}
+}
+
+// MemoryHotspot is the allocation activity of a step aggregated across
+// runs.
+type MemoryHotspot struct {
+	Name      string
+	Count     int
+	MeanAlloc uint64
+	MaxAlloc  uint64
+	// MeanMallocs is the mean number of heap objects allocated.
+	MeanMallocs uint64
+	// GCCycles is the total number of collections the step ran through.
+	GCCycles int64
+}
+
+// MemoryHotspots returns the n steps allocating the most heap bytes on
+// average across the runs recorded WithMemoryTracking.
+func (v *VectorClockAgent) MemoryHotspots(n int) ([]MemoryHotspot, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT scenario_name || ' / ' || step_text, COUNT(*), AVG(alloc_bytes), MAX(alloc_bytes), AVG(mallocs), SUM(gc_cycles)
+		FROM step_timings
+		WHERE `+primaryPhase+` AND alloc_bytes IS NOT NULL
+		GROUP BY scenario_name, step_text
+		ORDER BY AVG(alloc_bytes) DESC
+		LIMIT ?
+	`, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load allocating steps: %w", err)
+	}
+	defer rows.Close()
+
+	var hotspots []MemoryHotspot
+	for rows.Next() {
+		var h MemoryHotspot
+		var meanAlloc, meanMallocs float64
+		var maxAlloc int64
+		if err := rows.Scan(&h.Name, &h.Count, &meanAlloc, &maxAlloc, &meanMallocs, &h.GCCycles); err != nil {
+			return nil, err
+		}
+		h.MeanAlloc, h.MaxAlloc, h.MeanMallocs = uint64(meanAlloc), uint64(maxAlloc), uint64(meanMallocs)
+		hotspots = append(hotspots, h)
+	}
+	return hotspots, rows.Err()
+}
+
+// WriteMemoryHotspots prints hotspots under title, most allocating first.
+func WriteMemoryHotspots(w io.Writer, title string, hotspots []MemoryHotspot) error {
+	if _, err := fmt.Fprintf(w, "%s\n%10s %10s %10s %6s %6s  %s\n", title, "mean", "max", "mallocs", "gcs", "runs", "name"); err != nil {
+		return err
+	}
+	for _, h := range hotspots {
+		_, err := fmt.Fprintf(w, "%10s %10s %10d %6d %6d  %s\n",
+			formatBytes(h.MeanAlloc), formatBytes(h.MaxAlloc), h.MeanMallocs, h.GCCycles, h.Count, h.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatBytes writes n with a binary unit, e.g. "1.5MiB".
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,256 @@
+package vectorclocks
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MergedRun is the outcome of merging one run of a source database.
+type MergedRun struct {
+	Source string
+	RunID  string
+	// MergedAs is the run ID in the merged database; it differs from RunID
+	// when another run already used RunID.
+	MergedAs string
+	// Duplicate is set when the same run was already in the database and
+	// nothing was copied.
+	Duplicate bool
+	// RenamedSteps is set when the run's step IDs collided with recorded
+	// ones and were prefixed with MergedAs.
+	RenamedSteps bool
+}
+
+// String describes the merge of the run.
+func (m MergedRun) String() string {
+	switch {
+	case m.Duplicate:
+		return fmt.Sprintf("%s: run %s already merged", m.Source, m.RunID)
+	case m.MergedAs != m.RunID:
+		return fmt.Sprintf("%s: run %s merged as %s", m.Source, m.RunID, m.MergedAs)
+	default:
+		return fmt.Sprintf("%s: run %s merged", m.Source, m.RunID)
+	}
+}
+
+// mergeIDColumns are the columns holding step or span IDs, which are
+// prefixed when a merged run's step IDs collide.
+var mergeIDColumns = map[string]bool{"step_id": true, "parent_step_id": true, "span_id": true, "parent_span_id": true}
+
+// Merge copies the runs of the databases at paths, e.g. those of parallel
+// CI shards, into the agent's database. A run already present with the
+// same start is skipped, so merging the same file twice is harmless; a
+// different run with the same ID is merged under the ID with a numeric
+// suffix, and step IDs that collide with recorded ones are prefixed with
+// the run ID. Merged runs are added to the step digests; the downsampled
+// daily aggregates are not rebuilt.
+func (v *VectorClockAgent) Merge(paths ...string) ([]MergedRun, error) {
+	v.sync()
+
+	var merged []MergedRun
+	for _, path := range paths {
+		runs, err := v.mergeFile(path)
+		merged = append(merged, runs...)
+		if err != nil {
+			return merged, err
+		}
+	}
+
+	var errs []error
+	for _, m := range merged {
+		if !m.Duplicate {
+			errs = append(errs, v.mergeDigests(m.MergedAs, `run_id = ? AND `+primaryPhase, m.MergedAs))
+		}
+	}
+	return merged, errors.Join(errs...)
+}
+
+// mergeFile copies the runs of the database at path in one transaction.
+func (v *VectorClockAgent) mergeFile(path string) ([]MergedRun, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+
+	ctx := context.Background()
+	conn, err := v.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, `ATTACH DATABASE ? AS vc_merge`, path); err != nil {
+		return nil, fmt.Errorf("failed to attach %s: %w", path, err)
+	}
+	defer conn.ExecContext(ctx, `DETACH DATABASE vc_merge`)
+
+	m := merger{v: v, ctx: ctx, conn: conn, path: path}
+	runIDs, err := m.sourceRuns()
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	m.tx = tx
+
+	var merged []MergedRun
+	for _, runID := range runIDs {
+		run, err := m.mergeRun(runID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge run %s of %s: %w", runID, path, err)
+		}
+		merged = append(merged, run)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to merge %s: %w", path, err)
+	}
+	return merged, nil
+}
+
+// merger copies runs from the database attached as vc_merge.
+type merger struct {
+	v    *VectorClockAgent
+	ctx  context.Context
+	conn *sql.Conn
+	tx   *sql.Tx
+	path string
+}
+
+// dest and source return the qualified name of table in the agent's and
+// the source database.
+func (m merger) dest(table string) string {
+	return fmt.Sprintf("%q.%q", m.v.naming.schemaName(), m.v.naming.table(table))
+}
+
+func (m merger) source(table string) string {
+	return fmt.Sprintf("vc_merge.%q", m.v.naming.table(table))
+}
+
+// sourceRuns lists the runs of the source database, including those whose
+// runs row was never written.
+func (m merger) sourceRuns() ([]string, error) {
+	rows, err := m.conn.QueryContext(m.ctx, fmt.Sprintf(`
+		SELECT run_id FROM %s
+		UNION
+		SELECT run_id FROM %s WHERE run_id IS NOT NULL
+	`, m.source("runs"), m.source("step_timings")))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs of %s: %w", m.path, err)
+	}
+	defer rows.Close()
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	sort.Strings(runIDs)
+	return runIDs, rows.Err()
+}
+
+// start identifies a run of the database holding runs and steps: its
+// recorded start, or its first step for runs without a runs row.
+func (m merger) start(runs, steps, runID string) (sql.NullString, error) {
+	var start sql.NullString
+	err := m.tx.QueryRowContext(m.ctx, fmt.Sprintf(`
+		SELECT COALESCE(
+			(SELECT started_at FROM %s WHERE run_id = ?),
+			(SELECT MIN(created_at) FROM %s WHERE run_id = ?))
+	`, runs, steps), runID, runID).Scan(&start)
+	return start, err
+}
+
+func (m merger) exists(runID string) (bool, error) {
+	var exists bool
+	err := m.tx.QueryRowContext(m.ctx, fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM %s WHERE run_id = ?) OR EXISTS (SELECT 1 FROM %s WHERE run_id = ?)
+	`, m.dest("runs"), m.dest("step_timings")), runID, runID).Scan(&exists)
+	return exists, err
+}
+
+func (m merger) mergeRun(runID string) (MergedRun, error) {
+	run := MergedRun{Source: m.path, RunID: runID, MergedAs: runID}
+
+	exists, err := m.exists(runID)
+	if err != nil {
+		return run, err
+	}
+	if exists {
+		theirs, err := m.start(m.source("runs"), m.source("step_timings"), runID)
+		if err != nil {
+			return run, err
+		}
+		ours, err := m.start(m.dest("runs"), m.dest("step_timings"), runID)
+		if err != nil {
+			return run, err
+		}
+		if theirs == ours {
+			run.Duplicate = true
+			return run, nil
+		}
+		for n := 2; exists; n++ {
+			run.MergedAs = fmt.Sprintf("%s-%d", runID, n)
+			if exists, err = m.exists(run.MergedAs); err != nil {
+				return run, err
+			}
+		}
+	}
+
+	err = m.tx.QueryRowContext(m.ctx, fmt.Sprintf(`
+		SELECT EXISTS (SELECT 1 FROM %s s JOIN %s d ON d.step_id = s.step_id WHERE s.run_id = ?)
+	`, m.source("step_timings"), m.dest("step_timings")), runID).Scan(&run.RenamedSteps)
+	if err != nil {
+		return run, err
+	}
+	prefix := ""
+	if run.RenamedSteps {
+		prefix = run.MergedAs + "/"
+	}
+
+	for _, table := range runTables {
+		into, err := tableColumns(m.ctx, m.tx, m.v.naming.schemaName(), m.v.naming.table(table))
+		if err != nil {
+			return run, err
+		}
+		from, err := tableColumns(m.ctx, m.tx, "vc_merge", m.v.naming.table(table))
+		if err != nil {
+			return run, err
+		}
+		var columns, values []string
+		var args []interface{}
+		for _, c := range sharedColumns(into, from) {
+			switch {
+			case c == "id":
+				// Row IDs are reassigned by the merged database.
+				continue
+			case c == "run_id":
+				values = append(values, "?")
+				args = append(args, run.MergedAs)
+			case mergeIDColumns[c]:
+				values = append(values, "? || "+c)
+				args = append(args, prefix)
+			default:
+				values = append(values, c)
+			}
+			columns = append(columns, c)
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		_, err = m.tx.ExecContext(m.ctx, fmt.Sprintf(`INSERT INTO %s (%s) SELECT %s FROM %s WHERE run_id = ?`,
+			m.dest(table), strings.Join(columns, ", "), strings.Join(values, ", "), m.source(table)), append(args, runID)...)
+		if err != nil {
+			return run, fmt.Errorf("failed to copy %s: %w", table, err)
+		}
+	}
+	return run, nil
+}
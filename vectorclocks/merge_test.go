@@ -0,0 +1,93 @@
+package vectorclocks
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// recordShard records one run of a two-step scenario in a new database and
+// returns the database and the run ID.
+func recordShard(t *testing.T, runID string, start time.Time) (string, string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "shard.db")
+	a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent), WithClock(&fakeClock{now: start}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runID != "" {
+		// Runs of separate databases sharing an ID, as merging must expect
+		// from databases of older versions.
+		a.runID = runID
+	}
+	status := runFeature(t, a, 1, `Feature: merge
+  Scenario: one
+    Given a step
+    And a step
+`, func(ctx *godog.ScenarioContext) {
+		ctx.Step(`^a step$`, func(context.Context) error { return nil })
+	})
+	if status != 0 {
+		t.Fatalf("suite status = %d, want 0", status)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return dbPath, a.RunID()
+}
+
+func TestMerge(t *testing.T) {
+	start := time.Now().Add(-time.Hour)
+	first, firstID := recordShard(t, "", start)
+	second, secondID := recordShard(t, "", start.Add(time.Minute))
+	clash, _ := recordShard(t, firstID, start.Add(2*time.Minute))
+
+	central, _ := newTestAgent(t)
+	defer central.Close()
+	tests := []struct {
+		name  string
+		paths []string
+		want  []MergedRun
+	}{
+		{"shards", []string{first, second}, []MergedRun{
+			{Source: first, RunID: firstID, MergedAs: firstID},
+			// Both shards numbered their steps from 1.
+			{Source: second, RunID: secondID, MergedAs: secondID, RenamedSteps: true},
+		}},
+		{"same file again", []string{first}, []MergedRun{
+			{Source: first, RunID: firstID, MergedAs: firstID, Duplicate: true},
+		}},
+		{"another run with the same ID", []string{clash}, []MergedRun{
+			{Source: clash, RunID: firstID, MergedAs: firstID + "-2", RenamedSteps: true},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := central.Merge(tt.paths...)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Merge = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	for _, runID := range []string{firstID, secondID, firstID + "-2"} {
+		timings, err := central.Timings(TimingFilter{RunID: runID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(timings) != 2 {
+			t.Errorf("run %s has %d steps after merging, want 2", runID, len(timings))
+		}
+	}
+	if d, err := central.StepDigest("a step"); err != nil || d == nil || d.Count() != 6 {
+		t.Errorf("StepDigest = %v, %v; want the 6 merged steps", d, err)
+	}
+	if _, err := central.Merge(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("merging a missing file succeeded")
+	}
+}
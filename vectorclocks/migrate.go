@@ -0,0 +1,154 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migration is one embedded migrations/NNNN_name.sql file.
+type migration struct {
+	version int
+	name    string
+}
+
+// embeddedMigrations lists the embedded migrations in version order.
+func embeddedMigrations() ([]migration, error) {
+	names, err := fs.Glob(embeddedAssets, "migrations/*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]migration, 0, len(names))
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(path.Base(name), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s has no numeric version prefix", name)
+		}
+		migrations = append(migrations, migration{version: version, name: name})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// migrate upgrades the database in place to the latest embedded schema.
+// Every migration runs in its own transaction together with the
+// schema_version row that records it.
+func migrate(db *namedDB) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return err
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if current == 0 {
+		if current, err = adoptUnversioned(db); err != nil {
+			return err
+		}
+	}
+
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		stmts, err := embeddedAssets.ReadFile(m.name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(string(stmts)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_version (version) VALUES (?)`, m.version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("%s: %w", m.name, err)
+		}
+	}
+	return nil
+}
+
+func schemaVersion(db *namedDB) (int, error) {
+	var version sql.NullInt64
+	if err := db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// adoptUnversioned records the schema version of a database written before
+// schema_version existed. Such databases hold the original step_timings
+// table, possibly with some of the columns of migration 2 already added,
+// so the missing ones are added here and the database continues from
+// version 2. An empty database stays at version 0.
+func adoptUnversioned(db *namedDB) (int, error) {
+	var tables int
+	err := db.QueryRow(fmt.Sprintf(`SELECT COUNT(*) FROM %q.sqlite_master WHERE type = 'table' AND name = ?`, db.naming.schemaName()),
+		db.naming.table("step_timings")).Scan(&tables)
+	if err != nil {
+		return 0, err
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+
+	for _, column := range []string{"run_id", "status", "tags", "feature_uri", "phase"} {
+		if err := ensureColumn(db, "step_timings", column, "TEXT"); err != nil {
+			return 0, fmt.Errorf("failed to add %s column: %w", column, err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO schema_version (version) VALUES (1), (2)`); err != nil {
+		return 0, err
+	}
+	return 2, nil
+}
+
+// ensureColumn adds column to table when it does not have it yet.
+func ensureColumn(db *namedDB, table, column, typ string) error {
+	rows, err := db.DB.Query(`SELECT * FROM pragma_table_info(?, ?)`, db.naming.table(table), db.naming.schemaName())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var dflt sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, typ))
+	return err
+}
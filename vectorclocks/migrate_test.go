@@ -0,0 +1,86 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func latestMigration(t *testing.T) int {
+	t.Helper()
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].version == migrations[i-1].version {
+			t.Fatalf("migrations %s and %s share version %d", migrations[i-1].name, migrations[i].name, migrations[i].version)
+		}
+	}
+	return migrations[len(migrations)-1].version
+}
+
+func TestMigrateFreshDatabase(t *testing.T) {
+	latest := latestMigration(t)
+	_, dbPath := newTestAgent(t)
+	// Opening the database again applies nothing twice.
+	for i := 0; i < 2; i++ {
+		a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent))
+		if err != nil {
+			t.Fatalf("open %d: %v", i, err)
+		}
+		var version, rows int
+		if err := a.db.QueryRow(`SELECT MAX(version), COUNT(*) FROM schema_version`).Scan(&version, &rows); err != nil {
+			t.Fatal(err)
+		}
+		if version != latest || rows != latest {
+			t.Errorf("open %d: schema_version has %d rows up to %d, want %d", i, rows, version, latest)
+		}
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrateUnversionedDatabase(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The table written by the agent before schema_version existed, with
+	// run_id already added by a later version of it.
+	_, err = db.Exec(`
+		CREATE TABLE step_timings (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			step_id TEXT UNIQUE,
+			scenario_name TEXT,
+			step_text TEXT,
+			duration_ms INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			run_id TEXT
+		);
+		INSERT INTO step_timings (step_id, scenario_name, step_text, duration_ms, run_id)
+		VALUES ('old-1', 'checkout', 'I pay', 42, 'old-run');
+	`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if version, err := schemaVersion(a.db); err != nil || version != latestMigration(t) {
+		t.Errorf("schema version = %d, %v; want %d", version, err, latestMigration(t))
+	}
+	timings, err := a.Timings(TimingFilter{RunID: "old-run"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timings) != 1 || timings[0].StepText != "I pay" || timings[0].Duration.Milliseconds() != 42 {
+		t.Errorf("Timings = %+v, want the old step of 42ms", timings)
+	}
+}
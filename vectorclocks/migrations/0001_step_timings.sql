@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS step_timings (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	step_id TEXT UNIQUE,
+	scenario_name TEXT,
+	step_text TEXT,
+	duration_ms INTEGER,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,5 @@
+ALTER TABLE step_timings ADD COLUMN run_id TEXT;
+ALTER TABLE step_timings ADD COLUMN status TEXT;
+ALTER TABLE step_timings ADD COLUMN tags TEXT;
+ALTER TABLE step_timings ADD COLUMN feature_uri TEXT;
+ALTER TABLE step_timings ADD COLUMN phase TEXT;
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS runs (
+	run_id TEXT PRIMARY KEY,
+	started_at DATETIME,
+	finished_at DATETIME,
+	shard_index INTEGER,
+	shard_total INTEGER,
+	composition_hash TEXT
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS step_resources (
+	run_id TEXT,
+	step_id TEXT,
+	scenario_name TEXT,
+	resource TEXT,
+	started_at TEXT,
+	ended_at TEXT
+);
+CREATE INDEX IF NOT EXISTS step_resources_run ON step_resources (run_id, resource);
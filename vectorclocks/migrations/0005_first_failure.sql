@@ -0,0 +1,2 @@
+ALTER TABLE runs ADD COLUMN first_failure_ms INTEGER;
+ALTER TABLE runs ADD COLUMN first_failure_position INTEGER;
@@ -0,0 +1,8 @@
+ALTER TABLE runs ADD COLUMN ci_provider TEXT;
+ALTER TABLE runs ADD COLUMN pipeline_url TEXT;
+ALTER TABLE runs ADD COLUMN job_url TEXT;
+ALTER TABLE runs ADD COLUMN artifacts_url TEXT;
+ALTER TABLE runs ADD COLUMN pr_number TEXT;
+ALTER TABLE runs ADD COLUMN actor TEXT;
+ALTER TABLE runs ADD COLUMN branch TEXT;
+ALTER TABLE runs ADD COLUMN commit_sha TEXT;
@@ -0,0 +1,12 @@
+ALTER TABLE runs ADD COLUMN concurrency INTEGER;
+
+CREATE TABLE IF NOT EXISTS parallel_safety (
+	scenario_name TEXT PRIMARY KEY,
+	verdict TEXT NOT NULL,
+	reason TEXT NOT NULL,
+	serial_runs INTEGER NOT NULL,
+	serial_failures INTEGER NOT NULL,
+	parallel_runs INTEGER NOT NULL,
+	parallel_failures INTEGER NOT NULL,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS concurrency_rollout (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	level INTEGER NOT NULL,
+	max_safe INTEGER NOT NULL,
+	ceiling INTEGER,
+	level_since DATETIME NOT NULL,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS step_daily (
+	day TEXT NOT NULL,
+	scenario_name TEXT NOT NULL,
+	step_text TEXT NOT NULL,
+	count INTEGER NOT NULL,
+	total_ms INTEGER NOT NULL,
+	min_ms INTEGER NOT NULL,
+	max_ms INTEGER NOT NULL,
+	failures INTEGER NOT NULL,
+	PRIMARY KEY (day, scenario_name, step_text)
+);
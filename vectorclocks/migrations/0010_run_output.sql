@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS run_output (
+	run_id TEXT PRIMARY KEY,
+	format TEXT,
+	size INTEGER NOT NULL,
+	output BLOB NOT NULL
+);
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS step_summary (
+	step_text TEXT PRIMARY KEY,
+	count INTEGER NOT NULL,
+	total_ms INTEGER NOT NULL,
+	min_ms INTEGER NOT NULL,
+	max_ms INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS step_reservoir (
+	step_text TEXT NOT NULL,
+	slot INTEGER NOT NULL,
+	duration_ms INTEGER NOT NULL,
+	PRIMARY KEY (step_text, slot)
+);
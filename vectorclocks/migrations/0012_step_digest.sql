@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS step_digest (
+	step_text TEXT PRIMARY KEY,
+	digest BLOB NOT NULL,
+	last_run_id TEXT
+);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS scenario_executions (
+	run_id TEXT NOT NULL,
+	scenario_name TEXT,
+	feature_uri TEXT,
+	worker INTEGER NOT NULL,
+	phase TEXT,
+	started_at TEXT NOT NULL,
+	ended_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS scenario_executions_run ON scenario_executions (run_id, worker, started_at);
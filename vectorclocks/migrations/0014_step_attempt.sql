@@ -0,0 +1,2 @@
+ALTER TABLE step_timings ADD COLUMN attempt INTEGER;
+UPDATE step_timings SET attempt = 1 WHERE COALESCE(phase, 'primary') = 'primary';
@@ -0,0 +1,2 @@
+ALTER TABLE step_timings ADD COLUMN duration_ns INTEGER;
+UPDATE step_timings SET duration_ns = duration_ms * 1000000;
@@ -0,0 +1,3 @@
+ALTER TABLE step_timings ADD COLUMN started_at TEXT;
+ALTER TABLE step_timings ADD COLUMN ended_at TEXT;
+CREATE INDEX IF NOT EXISTS step_timings_run_start ON step_timings (run_id, started_at);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS step_lifecycle (
+	step_text TEXT PRIMARY KEY,
+	first_pending_at TEXT NOT NULL,
+	first_passed_at TEXT
+);
+
+INSERT OR IGNORE INTO step_lifecycle (step_text, first_pending_at)
+SELECT step_text, MIN(created_at) FROM step_timings
+WHERE status IN ('pending', 'undefined')
+GROUP BY step_text;
+
+UPDATE step_lifecycle SET first_passed_at = (
+	SELECT MIN(s.created_at) FROM step_timings s
+	WHERE s.step_text = step_lifecycle.step_text AND s.status = 'passed' AND s.created_at >= step_lifecycle.first_pending_at
+);
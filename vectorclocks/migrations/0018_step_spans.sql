@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS step_spans (
+	span_id TEXT PRIMARY KEY,
+	run_id TEXT,
+	parent_step_id TEXT NOT NULL,
+	parent_span_id TEXT,
+	name TEXT NOT NULL,
+	started_at TEXT NOT NULL,
+	ended_at TEXT NOT NULL,
+	duration_ns INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS step_spans_step ON step_spans (parent_step_id);
@@ -0,0 +1 @@
+ALTER TABLE scenario_executions ADD COLUMN wait_ns INTEGER;
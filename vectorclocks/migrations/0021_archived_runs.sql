@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS archived_runs (
+	run_id TEXT PRIMARY KEY,
+	started_at DATETIME,
+	finished_at DATETIME,
+	steps INTEGER NOT NULL,
+	location TEXT NOT NULL,
+	archived_at DATETIME NOT NULL
+);
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS run_flags (
+	run_id TEXT NOT NULL,
+	flag TEXT NOT NULL,
+	value TEXT NOT NULL,
+	PRIMARY KEY (run_id, flag)
+);
+CREATE INDEX IF NOT EXISTS run_flags_flag ON run_flags (flag, value);
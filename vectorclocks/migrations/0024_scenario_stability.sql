@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS scenario_stability (
+	scenario_name TEXT PRIMARY KEY,
+	feature_uri TEXT,
+	run_count INTEGER NOT NULL,
+	pass_rate REAL NOT NULL,
+	duration_cv REAL NOT NULL,
+	retry_rate REAL NOT NULL,
+	score REAL NOT NULL,
+	updated_at DATETIME NOT NULL
+);
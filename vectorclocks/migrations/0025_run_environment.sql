@@ -0,0 +1,2 @@
+ALTER TABLE runs ADD COLUMN environment TEXT;
+CREATE INDEX IF NOT EXISTS runs_environment ON runs (environment, commit_sha);
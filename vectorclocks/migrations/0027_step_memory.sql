@@ -0,0 +1,3 @@
+ALTER TABLE step_timings ADD COLUMN alloc_bytes INTEGER;
+ALTER TABLE step_timings ADD COLUMN mallocs INTEGER;
+ALTER TABLE step_timings ADD COLUMN gc_cycles INTEGER;
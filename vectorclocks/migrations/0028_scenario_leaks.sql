@@ -0,0 +1,2 @@
+ALTER TABLE scenario_executions ADD COLUMN goroutine_delta INTEGER;
+ALTER TABLE scenario_executions ADD COLUMN fd_delta INTEGER;
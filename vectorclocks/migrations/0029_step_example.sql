@@ -0,0 +1,2 @@
+ALTER TABLE step_timings ADD COLUMN example_line INTEGER;
+ALTER TABLE step_timings ADD COLUMN example TEXT;
@@ -0,0 +1 @@
+ALTER TABLE step_timings ADD COLUMN origin TEXT;
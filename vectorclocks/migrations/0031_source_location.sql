@@ -0,0 +1,3 @@
+ALTER TABLE step_timings ADD COLUMN scenario_line INTEGER;
+ALTER TABLE step_timings ADD COLUMN step_line INTEGER;
+ALTER TABLE scenario_executions ADD COLUMN scenario_line INTEGER;
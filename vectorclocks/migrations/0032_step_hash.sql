@@ -0,0 +1,2 @@
+ALTER TABLE step_timings ADD COLUMN step_hash TEXT;
+CREATE INDEX IF NOT EXISTS step_timings_hash ON step_timings (step_hash);
@@ -0,0 +1,2 @@
+ALTER TABLE step_timings ADD COLUMN process_id TEXT;
+ALTER TABLE step_timings ADD COLUMN vector_clock TEXT;
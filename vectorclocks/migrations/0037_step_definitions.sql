@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS step_definitions (
+	run_id TEXT NOT NULL,
+	pattern TEXT NOT NULL,
+	PRIMARY KEY (run_id, pattern)
+);
@@ -0,0 +1,2 @@
+ALTER TABLE step_timings ADD COLUMN gap_ns INTEGER;
+ALTER TABLE scenario_executions ADD COLUMN step_ns INTEGER;
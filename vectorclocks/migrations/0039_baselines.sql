@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS baselines (
+	suite TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	scenario_name TEXT NOT NULL,
+	step_text TEXT NOT NULL,
+	median_ns INTEGER NOT NULL,
+	run_count INTEGER NOT NULL,
+	computed_at TEXT NOT NULL,
+	PRIMARY KEY (suite, kind, scenario_name, step_text)
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS imported_baselines (
+	source TEXT NOT NULL,
+	suite TEXT NOT NULL,
+	kind TEXT NOT NULL,
+	scenario_name TEXT NOT NULL,
+	step_text TEXT NOT NULL,
+	median_ns INTEGER NOT NULL,
+	run_count INTEGER NOT NULL,
+	imported_at TEXT NOT NULL,
+	PRIMARY KEY (source, suite, kind, scenario_name, step_text)
+);
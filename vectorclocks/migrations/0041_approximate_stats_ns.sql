@@ -0,0 +1,6 @@
+ALTER TABLE step_summary ADD COLUMN total_ns INTEGER;
+ALTER TABLE step_summary ADD COLUMN min_ns INTEGER;
+ALTER TABLE step_summary ADD COLUMN max_ns INTEGER;
+UPDATE step_summary SET total_ns = total_ms * 1000000, min_ns = min_ms * 1000000, max_ns = max_ms * 1000000;
+ALTER TABLE step_reservoir ADD COLUMN duration_ns INTEGER;
+UPDATE step_reservoir SET duration_ns = duration_ms * 1000000;
@@ -0,0 +1,4 @@
+ALTER TABLE step_daily ADD COLUMN total_ns INTEGER;
+ALTER TABLE step_daily ADD COLUMN min_ns INTEGER;
+ALTER TABLE step_daily ADD COLUMN max_ns INTEGER;
+UPDATE step_daily SET total_ns = total_ms * 1000000, min_ns = min_ms * 1000000, max_ns = max_ms * 1000000;
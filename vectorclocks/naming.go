@@ -0,0 +1,198 @@
+package vectorclocks
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// schemaObjects are the tables and indexes the agent owns. Queries name them
+// unqualified and naming rewrites them.
+var schemaObjects = []string{
+	"schema_version",
+	"step_timings",
+	"step_resources",
+	"step_resources_run",
+	"runs",
+	"runs_environment",
+	"archived_runs",
+	"run_flags",
+	"run_flags_flag",
+	"parallel_safety",
+	"concurrency_rollout",
+	"step_daily",
+	"run_output",
+	"step_summary",
+	"step_reservoir",
+	"step_digest",
+	"step_timings_run_start",
+	"step_timings_hash",
+	"step_lifecycle",
+	"step_spans",
+	"step_spans_step",
+	"scenario_executions",
+	"scenario_executions_run",
+	"scenario_stability",
+	"step_definitions",
+	"baselines",
+	"imported_baselines",
+}
+
+// schemaObjectRE matches an owned name where a statement names a table or
+// index: after FROM, JOIN, INTO, UPDATE, TABLE, INDEX or IF [NOT] EXISTS
+// (group 2), as the table of CREATE INDEX ... ON, which SQLite requires to
+// be unqualified (group 4), or qualifying a column (group 6). Columns and
+// aliases named like a table are left alone.
+var schemaObjectRE = regexp.MustCompile(`\b(?:` +
+	`((?i:FROM|JOIN|INTO|UPDATE|TABLE|INDEX|EXISTS)\s+)(` + schemaObjectNames + `)\b|` +
+	`((?i:ON)\s+)(` + schemaObjectNames + `)(\s*\()|` +
+	`(` + schemaObjectNames + `)(\.))`)
+
+var schemaObjectNames = strings.Join(schemaObjects, "|")
+
+// naming places the agent's tables in a schema and behind a prefix, so they
+// can share an application database without colliding with its tables.
+type naming struct {
+	prefix string
+	schema string
+	// attach is the database file attached as schema on every connection;
+	// empty when the schema already exists, such as "main".
+	attach string
+}
+
+// WithTablePrefix prepends prefix to every table and index the agent
+// creates, e.g. "bdd_" for bdd_step_timings.
+func WithTablePrefix(prefix string) Option {
+	return func(v *VectorClockAgent) {
+		v.naming.prefix = prefix
+	}
+}
+
+// WithSchema places the agent's tables in the SQLite schema name. When path
+// is not empty the database file at path is attached under that name on
+// every connection, which keeps the timing tables in their own file next to
+// an application database opened as main.
+func WithSchema(name, path string) Option {
+	return func(v *VectorClockAgent) {
+		v.naming.schema = name
+		v.naming.attach = path
+	}
+}
+
+// qualified reports whether naming changes any query.
+func (n naming) qualified() bool {
+	return n.prefix != "" || n.schema != ""
+}
+
+// schemaName is the schema the tables live in.
+func (n naming) schemaName() string {
+	if n.schema == "" {
+		return "main"
+	}
+	return n.schema
+}
+
+// table returns the unqualified, prefixed name of an owned table.
+func (n naming) table(name string) string {
+	return n.prefix + name
+}
+
+// qualify returns the schema-qualified, prefixed name of an owned table.
+func (n naming) qualify(name string) string {
+	if n.schema == "" {
+		return n.prefix + name
+	}
+	return n.schema + "." + n.prefix + name
+}
+
+func (n naming) rewrite(query string) string {
+	return schemaObjectRE.ReplaceAllStringFunc(query, func(m string) string {
+		sub := schemaObjectRE.FindStringSubmatch(m)
+		switch {
+		case sub[2] != "":
+			return sub[1] + n.qualify(sub[2])
+		case sub[4] != "":
+			return sub[3] + n.prefix + sub[4] + sub[5]
+		default:
+			return n.qualify(sub[6]) + sub[7]
+		}
+	})
+}
+
+// namedDB rewrites the agent's table names in every statement it runs.
+type namedDB struct {
+	*sql.DB
+	naming naming
+	cache  sync.Map // query -> rewritten query
+	// borrowed is set for a database passed with WithDB, which its owner
+	// closes.
+	borrowed bool
+}
+
+// Close closes the database unless it is borrowed.
+func (d *namedDB) Close() error {
+	if d.borrowed {
+		return nil
+	}
+	return d.DB.Close()
+}
+
+func (d *namedDB) q(query string) string {
+	if !d.naming.qualified() {
+		return query
+	}
+	if rewritten, ok := d.cache.Load(query); ok {
+		return rewritten.(string)
+	}
+	rewritten := d.naming.rewrite(query)
+	d.cache.Store(query, rewritten)
+	return rewritten
+}
+
+func (d *namedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return d.DB.Exec(d.q(query), args...)
+}
+
+func (d *namedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return d.DB.Query(d.q(query), args...)
+}
+
+func (d *namedDB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.DB.QueryRow(d.q(query), args...)
+}
+
+func (d *namedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return d.DB.QueryRowContext(ctx, d.q(query), args...)
+}
+
+func (d *namedDB) Prepare(query string) (*sql.Stmt, error) {
+	return d.DB.Prepare(d.q(query))
+}
+
+func (d *namedDB) Begin() (*namedTx, error) {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &namedTx{Tx: tx, db: d}, nil
+}
+
+// namedTx is the transaction counterpart of namedDB.
+type namedTx struct {
+	*sql.Tx
+	db *namedDB
+}
+
+func (t *namedTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(t.db.q(query), args...)
+}
+
+func (t *namedTx) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(t.db.q(query), args...)
+}
+
+func (t *namedTx) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(t.db.q(query), args...)
+}
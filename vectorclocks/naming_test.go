@@ -0,0 +1,128 @@
+package vectorclocks
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/cucumber/godog"
+)
+
+func TestNaming(t *testing.T) {
+	tests := []struct {
+		name string
+		opts func(dir string) []Option
+	}{
+		{"prefix", func(string) []Option { return []Option{WithTablePrefix("bdd_")} }},
+		{"schema", func(dir string) []Option { return []Option{WithSchema("timings", filepath.Join(dir, "timings.db"))} }},
+		{"schema and prefix", func(dir string) []Option {
+			return []Option{WithSchema("timings", filepath.Join(dir, "timings.db")), WithTablePrefix("bdd_")}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts(t.TempDir())
+			a, dbPath := newTestAgent(t, opts...)
+			status := runFeature(t, a, 1, `Feature: naming
+  Scenario: one
+    Given a step
+
+  Scenario: two
+    Given a step
+`, func(ctx *godog.ScenarioContext) {
+				ctx.Step(`^a step$`, func(context.Context) error { return nil })
+			})
+			if status != 0 {
+				t.Fatalf("suite status = %d, want 0", status)
+			}
+			if err := a.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			a, err := NewVectorClockAgent(dbPath, append(opts, WithVerbosity(VerbositySilent))...)
+			if err != nil {
+				t.Fatalf("reopening: %v", err)
+			}
+			defer a.Close()
+			if _, err := a.UpdateStability(DefaultStabilityRuns); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := a.Stability(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := a.RecomputeBaselines("", BaselineWindow{}); err != nil {
+				t.Fatal(err)
+			}
+			b, ok, err := a.StoredBaselineOf("")
+			if err != nil || !ok || b.Runs != 1 {
+				t.Fatalf("StoredBaselineOf = %d runs, %v, %v; want 1 run", b.Runs, ok, err)
+			}
+
+			for _, table := range []string{"scenario_stability", "baselines"} {
+				if !hasColumn(t, a, table, "run_count") {
+					t.Errorf("%s has no run_count column", a.naming.table(table))
+				}
+			}
+		})
+	}
+}
+
+// hasColumn reports whether the agent's table has column, looked up
+// without the rewriting of table names.
+func hasColumn(t *testing.T, a *VectorClockAgent, table, column string) bool {
+	t.Helper()
+	var n int
+	err := a.db.DB.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?, ?) WHERE name = ?`,
+		a.naming.table(table), a.naming.schemaName(), column).Scan(&n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n == 1
+}
+
+func TestNamingRewrite(t *testing.T) {
+	n := naming{prefix: "bdd_", schema: "timings"}
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`SELECT run_id FROM runs WHERE suite = ?`, `SELECT run_id FROM timings.bdd_runs WHERE suite = ?`},
+		{`SELECT runs, baselines FROM baselines`, `SELECT runs, baselines FROM timings.bdd_baselines`},
+		{`SELECT COUNT(*) AS runs FROM step_timings s JOIN runs ON runs.run_id = s.run_id`,
+			`SELECT COUNT(*) AS runs FROM timings.bdd_step_timings s JOIN timings.bdd_runs ON timings.bdd_runs.run_id = s.run_id`},
+		{`INSERT OR REPLACE INTO runs (run_id, runs) VALUES (?, ?)`, `INSERT OR REPLACE INTO timings.bdd_runs (run_id, runs) VALUES (?, ?)`},
+		{`UPDATE step_lifecycle SET runs = runs + 1`, `UPDATE timings.bdd_step_lifecycle SET runs = runs + 1`},
+		{`delete from step_timings where run_id = ?`, `delete from timings.bdd_step_timings where run_id = ?`},
+		{`CREATE TABLE IF NOT EXISTS runs (runs INTEGER)`, `CREATE TABLE IF NOT EXISTS timings.bdd_runs (runs INTEGER)`},
+		{`ALTER TABLE step_timings ADD COLUMN runs INTEGER`, `ALTER TABLE timings.bdd_step_timings ADD COLUMN runs INTEGER`},
+		{`CREATE INDEX IF NOT EXISTS runs_environment ON runs (environment)`,
+			`CREATE INDEX IF NOT EXISTS timings.bdd_runs_environment ON bdd_runs (environment)`},
+		{`SELECT s.runs FROM step_timingsx s`, `SELECT s.runs FROM step_timingsx s`},
+	}
+	for _, tt := range tests {
+		if got := n.rewrite(tt.query); got != tt.want {
+			t.Errorf("rewrite(%q)\n got %q\nwant %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+// TestNamingColumnNamedLikeTable stores and reads a column named like an
+// owned table in a prefixed schema.
+func TestNamingColumnNamedLikeTable(t *testing.T) {
+	a, _ := newTestAgent(t, WithSchema("timings", filepath.Join(t.TempDir(), "timings.db")), WithTablePrefix("bdd_"))
+	defer a.Close()
+	if _, err := a.db.Exec(`CREATE TABLE baselines_copy (runs INTEGER, step_timings TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.db.Exec(`INSERT INTO baselines_copy (runs, step_timings) SELECT 3, run_id FROM runs UNION ALL SELECT 3, 'none'`); err != nil {
+		t.Fatal(err)
+	}
+	var runs int
+	if err := a.db.QueryRow(`SELECT runs FROM baselines_copy JOIN baselines ON baselines.run_count = baselines_copy.runs`).Scan(&runs); err != sql.ErrNoRows {
+		t.Fatalf("joined empty baselines: %d, %v; want no rows", runs, err)
+	}
+	if err := a.db.QueryRow(`SELECT runs FROM baselines_copy`).Scan(&runs); err != nil || runs != 3 {
+		t.Fatalf("runs = %d, %v; want 3", runs, err)
+	}
+}
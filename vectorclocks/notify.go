@@ -0,0 +1,189 @@
+package vectorclocks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultNotifySlowest is the number of slowest steps a notification lists.
+const DefaultNotifySlowest = 5
+
+// Notification summarizes a finished suite for notifiers.
+type Notification struct {
+	RunID     string
+	Status    int
+	Wall      time.Duration
+	Scenarios int
+	Failed    int
+	// Slowest are the slowest steps of the run, slowest first.
+	Slowest []Delta
+	// Regressions are the scenarios and steps slower than the baseline;
+	// empty when there were too few baseline runs to compare with.
+	Regressions []Delta
+	Metadata    RunMetadata
+}
+
+// Notifier delivers the summary of a finished suite, e.g. to a chat
+// channel.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// WithNotifier makes RunSuite send a Notification to n once the suite and
+// its gates have finished. Regressions are checked against the
+// WithRegressionGate policy, or a 10% slowdown over the median of the last
+// 10 comparable runs without one. Notifier errors go to the error handler
+// and do not change the suite status.
+func WithNotifier(n Notifier) Option {
+	return func(v *VectorClockAgent) {
+		if n != nil {
+			v.notifiers = append(v.notifiers, n)
+		}
+	}
+}
+
+// WithWebhook notifies url with a Slack-compatible payload; see
+// WebhookNotifier.
+func WithWebhook(url string) Option {
+	if url == "" {
+		return func(*VectorClockAgent) {}
+	}
+	return WithNotifier(&WebhookNotifier{URL: url})
+}
+
+// WebhookNotifier posts notifications as JSON with a "text" field in Slack
+// mrkdwn, the payload of Slack incoming webhooks that Mattermost, Rocket.Chat
+// and Teams connectors accept as well.
+type WebhookNotifier struct {
+	URL string
+	// Client sends the request; nil uses a client with a 10 second timeout.
+	Client *http.Client
+}
+
+// Notify posts n to the webhook.
+func (w *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(map[string]string{"text": FormatNotification(n)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := w.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// FormatNotification renders n as Slack mrkdwn.
+func FormatNotification(n Notification) string {
+	var b strings.Builder
+	outcome := ":white_check_mark: passed"
+	switch {
+	case n.Status == RegressionExitCode:
+		outcome = ":snail: passed with timing regressions"
+	case n.Status == BudgetExitCode:
+		outcome = ":hourglass: passed over budget"
+	case n.Status == GateErrorExitCode:
+		outcome = ":warning: passed, but the regression gate could not be checked"
+	case n.Status != 0:
+		outcome = ":x: failed"
+	}
+	fmt.Fprintf(&b, "*Suite %s* in %s: %d scenarios, %d failed (run `%s`)", outcome, n.Wall.Round(time.Second), n.Scenarios, n.Failed, n.RunID)
+	if url := n.Metadata.JobURL; url != "" {
+		fmt.Fprintf(&b, " <%s|job>", url)
+	}
+	b.WriteString("\n")
+
+	if len(n.Slowest) > 0 {
+		b.WriteString("*Slowest steps*\n")
+		for _, d := range n.Slowest {
+			fmt.Fprintf(&b, "• %s: %s\n", d.Name, d.Head.Round(time.Millisecond))
+		}
+	}
+	if len(n.Regressions) > 0 {
+		b.WriteString("*Regressions vs baseline*\n")
+		for _, d := range n.Regressions {
+			fmt.Fprintf(&b, "• %s: %s → %s (%s)\n", d.Name, d.Base.Round(time.Millisecond), d.Head.Round(time.Millisecond), deltaChange(d))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// notification builds the Notification of the current run.
+func (v *VectorClockAgent) notification(status int) (Notification, error) {
+	n := Notification{
+		RunID:     v.runID,
+		Status:    status,
+		Wall:      v.since(v.startedAt),
+		Scenarios: int(atomic.LoadUint64(&v.scenarios)),
+		Failed:    int(atomic.LoadUint64(&v.failed)),
+		Metadata:  v.metadata,
+	}
+
+	rt, err := v.RunTimings(v.runID)
+	if err != nil {
+		return n, err
+	}
+	for key, d := range rt.Steps {
+		n.Slowest = append(n.Slowest, Delta{Name: key.String(), Head: d})
+	}
+	sort.Slice(n.Slowest, func(i, j int) bool {
+		if n.Slowest[i].Head != n.Slowest[j].Head {
+			return n.Slowest[i].Head > n.Slowest[j].Head
+		}
+		return n.Slowest[i].Name < n.Slowest[j].Name
+	})
+	n.Slowest = n.Slowest[:min(len(n.Slowest), DefaultNotifySlowest)]
+
+	policy := GatePolicy{Percent: 10, Window: 10, MinRuns: 1}
+	if v.gate != nil {
+		policy = *v.gate
+	}
+	c, ok, err := v.CheckRegressions(policy)
+	if err != nil {
+		return n, err
+	}
+	if ok {
+		n.Regressions = c.Regressions()
+		if c.Total.Regression {
+			n.Regressions = append(n.Regressions, c.Total)
+		}
+	}
+	return n, nil
+}
+
+// notify sends the summary of the run to every notifier.
+func (v *VectorClockAgent) notify(status int) {
+	n, err := v.notification(status)
+	if err != nil {
+		v.handleError(fmt.Errorf("notification: %w", err))
+		return
+	}
+	for _, notifier := range v.notifiers {
+		if err := notifier.Notify(context.Background(), n); err != nil {
+			v.handleError(fmt.Errorf("notification: %w", err))
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package vectorclocks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ObjectStore stores named files in a bucket or directory.
+type ObjectStore interface {
+	// Put stores the contents of r as name and returns where it went.
+	Put(ctx context.Context, name string, r io.Reader) (string, error)
+}
+
+// OpenObjectStore returns the store for url: s3://bucket/prefix and
+// gs://bucket/prefix upload with the aws and gcloud command line tools, so
+// their usual credentials apply; anything else is a local directory,
+// optionally written as file://path.
+func OpenObjectStore(url string) (ObjectStore, error) {
+	switch {
+	case url == "":
+		return nil, fmt.Errorf("no object store given")
+	case strings.HasPrefix(url, "s3://"):
+		return cliStore{base: strings.TrimRight(url, "/"), command: []string{"aws", "s3", "cp", "-"}}, nil
+	case strings.HasPrefix(url, "gs://"):
+		return cliStore{base: strings.TrimRight(url, "/"), command: []string{"gcloud", "storage", "cp", "-"}}, nil
+	default:
+		return dirStore(strings.TrimPrefix(url, "file://")), nil
+	}
+}
+
+// dirStore writes files below a local directory.
+type dirStore string
+
+func (d dirStore) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	path := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", filepath.Dir(path), err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// cliStore streams files to a bucket through a cloud CLI reading stdin.
+type cliStore struct {
+	base    string
+	command []string
+}
+
+func (c cliStore) Put(ctx context.Context, name string, r io.Reader) (string, error) {
+	dest := c.base + "/" + name
+	cmd := exec.CommandContext(ctx, c.command[0], append(c.command[1:], dest)...)
+	cmd.Stdin = r
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w: %s", dest, err, strings.TrimSpace(stderr.String()))
+	}
+	return dest, nil
+}
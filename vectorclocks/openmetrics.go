@@ -0,0 +1,195 @@
+package vectorclocks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FormatOpenMetrics is an OpenMetrics text snapshot of suite metrics:
+// step time, scenario and step counts by status, and the step time of every
+// scenario and step.
+const FormatOpenMetrics = "openmetrics"
+
+// WithOpenMetrics makes Close write an OpenMetrics snapshot of the run (see
+// FormatOpenMetrics) to path, for node_exporter's textfile collector or a
+// CI artifact. The file is replaced atomically, so a collector never reads
+// half of it.
+func WithOpenMetrics(path string) Option {
+	return func(v *VectorClockAgent) {
+		v.metricsFile = path
+	}
+}
+
+// failedStatus reports whether a step status fails its scenario.
+func failedStatus(status string) bool {
+	switch status {
+	case "passed", "skipped", "pending":
+		return false
+	default:
+		return true
+	}
+}
+
+// writeOpenMetrics writes timings, normally those of one run, as an
+// OpenMetrics snapshot. Only primary-phase steps are counted.
+func writeOpenMetrics(w io.Writer, timings []StepTiming) error {
+	type scenarioKey struct{ feature, name string }
+	type execution struct {
+		runID, feature, scenario string
+		exampleLine, attempt     int
+	}
+	var total time.Duration
+	var finished time.Time
+	runs := make(map[string]int)
+	steps := make(map[string]int)
+	failedExecutions := make(map[execution]bool)
+	scenarioTime := make(map[scenarioKey]time.Duration)
+	stepTime := make(map[StepKey]time.Duration)
+	for _, t := range timings {
+		if t.Phase != "" && t.Phase != phasePrimary {
+			continue
+		}
+		runs[t.RunID]++
+		total += t.Duration
+		steps[t.Status]++
+		e := execution{t.RunID, t.FeatureURI, t.ScenarioName, t.ExampleLine, t.Attempt}
+		failedExecutions[e] = failedExecutions[e] || failedStatus(t.Status)
+		scenarioTime[scenarioKey{t.FeatureURI, t.ScenarioName}] += t.Duration
+		stepTime[StepKey{Scenario: t.ScenarioName, Step: t.StepText}] += t.Duration
+		if end := stepStart(t).Add(t.Duration); end.After(finished) {
+			finished = end
+		}
+	}
+	scenarios := map[string]int{"passed": 0, "failed": 0}
+	for _, failed := range failedExecutions {
+		if failed {
+			scenarios["failed"]++
+		} else {
+			scenarios["passed"]++
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	metric := func(name, typ, unit, help string) {
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, typ)
+		if unit != "" {
+			fmt.Fprintf(bw, "# UNIT %s %s\n", name, unit)
+		}
+		fmt.Fprintf(bw, "# HELP %s %s\n", name, help)
+	}
+	sample := func(name string, value float64, labels ...string) {
+		var pairs []string
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, labels[i]+`="`+metricLabel(labels[i+1])+`"`)
+		}
+		if len(pairs) > 0 {
+			name += "{" + strings.Join(pairs, ",") + "}"
+		}
+		fmt.Fprintf(bw, "%s %s\n", name, strconv.FormatFloat(value, 'f', -1, 64))
+	}
+
+	// An info-style gauge rather than an OpenMetrics info metric, which
+	// node_exporter's Prometheus text parser does not accept.
+	metric("vectorclocks_run_info", "gauge", "", "Runs the snapshot covers.")
+	for _, runID := range sortedKeys(runs) {
+		sample("vectorclocks_run_info", 1, "run_id", runID)
+	}
+	metric("vectorclocks_run_finished_timestamp_seconds", "gauge", "seconds", "When the last step of the snapshot ended.")
+	if !finished.IsZero() {
+		sample("vectorclocks_run_finished_timestamp_seconds", float64(finished.UnixMilli())/1000)
+	}
+	metric("vectorclocks_step_time_seconds", "gauge", "seconds", "Summed duration of all steps.")
+	sample("vectorclocks_step_time_seconds", total.Seconds())
+	metric("vectorclocks_scenarios", "gauge", "", "Scenario executions by result.")
+	for _, status := range sortedKeys(scenarios) {
+		sample("vectorclocks_scenarios", float64(scenarios[status]), "result", status)
+	}
+	metric("vectorclocks_steps", "gauge", "", "Step executions by status.")
+	for _, status := range sortedKeys(steps) {
+		sample("vectorclocks_steps", float64(steps[status]), "status", status)
+	}
+
+	metric("vectorclocks_scenario_duration_seconds", "gauge", "seconds", "Summed step time of each scenario.")
+	scenarioKeys := make([]scenarioKey, 0, len(scenarioTime))
+	for k := range scenarioTime {
+		scenarioKeys = append(scenarioKeys, k)
+	}
+	sort.Slice(scenarioKeys, func(i, j int) bool {
+		if scenarioKeys[i].feature != scenarioKeys[j].feature {
+			return scenarioKeys[i].feature < scenarioKeys[j].feature
+		}
+		return scenarioKeys[i].name < scenarioKeys[j].name
+	})
+	for _, k := range scenarioKeys {
+		sample("vectorclocks_scenario_duration_seconds", scenarioTime[k].Seconds(), "feature", k.feature, "scenario", k.name)
+	}
+
+	metric("vectorclocks_step_duration_seconds", "gauge", "seconds", "Summed duration of each step of each scenario.")
+	stepKeys := make([]StepKey, 0, len(stepTime))
+	for k := range stepTime {
+		stepKeys = append(stepKeys, k)
+	}
+	sort.Slice(stepKeys, func(i, j int) bool { return stepKeys[i].String() < stepKeys[j].String() })
+	for _, k := range stepKeys {
+		sample("vectorclocks_step_duration_seconds", stepTime[k].Seconds(), "scenario", k.Scenario, "step", k.Step)
+	}
+
+	fmt.Fprintln(bw, "# EOF")
+	return bw.Flush()
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// metricLabel escapes an OpenMetrics label value.
+func metricLabel(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+// writeMetricsFile writes the OpenMetrics snapshot of the current run as
+// configured by WithOpenMetrics.
+func (v *VectorClockAgent) writeMetricsFile() error {
+	if v.metricsFile == "" || v.collector != nil {
+		return nil
+	}
+	timings, err := v.Timings(TimingFilter{RunID: v.runID})
+	if err != nil {
+		return err
+	}
+	if len(timings) == 0 {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(v.metricsFile), ".vectorclocks-metrics-*")
+	if err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := writeOpenMetrics(tmp, timings); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), v.metricsFile); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	return nil
+}
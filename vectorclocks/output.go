@@ -0,0 +1,114 @@
+package vectorclocks
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/cucumber/godog"
+)
+
+// WithCapturedOutput makes RunSuite keep a copy of godog's formatter output
+// and store it gzip-compressed in the run_output table when the agent is
+// closed. The output is still written to its original destination.
+func WithCapturedOutput() Option {
+	return func(v *VectorClockAgent) {
+		v.output = &capturedOutput{}
+	}
+}
+
+// capturedOutput buffers formatter output of all suite attempts.
+type capturedOutput struct {
+	mu     sync.Mutex
+	format string
+	buf    bytes.Buffer
+}
+
+func (c *capturedOutput) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Write(p)
+}
+
+// capture returns a copy of suite whose output is also written to c.
+func (c *capturedOutput) capture(suite godog.TestSuite) godog.TestSuite {
+	var opts godog.Options
+	if suite.Options != nil {
+		opts = *suite.Options
+	}
+	out := opts.Output
+	if out == nil {
+		out = os.Stdout
+	}
+	opts.Output = io.MultiWriter(out, c)
+	c.format = opts.Format
+	suite.Options = &opts
+	return suite
+}
+
+// storeOutput writes the captured output of the run, or sends it to the
+// collector.
+func (v *VectorClockAgent) storeOutput() error {
+	if v.output == nil {
+		return nil
+	}
+	v.output.mu.Lock()
+	defer v.output.mu.Unlock()
+	if v.output.buf.Len() == 0 {
+		return nil
+	}
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	if _, err := zw.Write(v.output.buf.Bytes()); err != nil {
+		return fmt.Errorf("failed to compress run output: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress run output: %w", err)
+	}
+	out := collectedOutput{RunID: v.runID, Format: v.output.format, Size: v.output.buf.Len(), Output: compressed.Bytes()}
+	if v.collector != nil {
+		return v.collector.sendOutput(out)
+	}
+	return v.insertOutput(out)
+}
+
+// insertOutput writes the compressed output of a run.
+func (v *VectorClockAgent) insertOutput(out collectedOutput) error {
+	_, err := v.db.Exec(`
+		INSERT OR REPLACE INTO run_output (run_id, format, size, output) VALUES (?, ?, ?, ?)
+	`, out.RunID, nullString(out.Format), out.Size, out.Output)
+	if err != nil {
+		return fmt.Errorf("failed to store run output: %w", err)
+	}
+	return nil
+}
+
+// RunOutput returns the godog output captured for runID and the formatter
+// that produced it. It returns sql.ErrNoRows when nothing was captured.
+func (v *VectorClockAgent) RunOutput(runID string) (output []byte, format string, err error) {
+	var compressed []byte
+	var f sql.NullString
+	err = v.db.QueryRow(`SELECT format, output FROM run_output WHERE run_id = ?`, runID).Scan(&f, &compressed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, "", err
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load output of run %s: %w", runID, err)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decompress output of run %s: %w", runID, err)
+	}
+	defer zr.Close()
+	if output, err = io.ReadAll(zr); err != nil {
+		return nil, "", fmt.Errorf("failed to decompress output of run %s: %w", runID, err)
+	}
+	return output, f.String, nil
+}
@@ -0,0 +1,55 @@
+package vectorclocks
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// normalizeFeatureURI turns a godog feature URI into the form stored in the
+// database: forward slashes, no "./" prefix, and relative to the working
+// directory when the feature lives below it. Windows and Linux runners of
+// the same checkout therefore store identical URIs.
+func normalizeFeatureURI(uri string) string {
+	if uri == "" {
+		return ""
+	}
+	p := strings.ReplaceAll(uri, `\`, "/")
+
+	if isAbsURI(p) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, ok := trimDirPrefix(p, strings.ReplaceAll(wd, `\`, "/")); ok {
+				p = rel
+			}
+		}
+	}
+	if hasDriveLetter(p) {
+		p = strings.ToUpper(p[:1]) + p[1:]
+	}
+
+	p = path.Clean(p)
+	return strings.TrimPrefix(p, "./")
+}
+
+func isAbsURI(p string) bool {
+	return strings.HasPrefix(p, "/") || hasDriveLetter(p) || filepath.IsAbs(p)
+}
+
+func hasDriveLetter(p string) bool {
+	return len(p) >= 2 && p[1] == ':' && (p[0]|0x20 >= 'a' && p[0]|0x20 <= 'z')
+}
+
+// trimDirPrefix returns p relative to dir. Paths on Windows are case
+// insensitive, so the prefix is compared without regard to case there.
+func trimDirPrefix(p, dir string) (string, bool) {
+	dir = strings.TrimSuffix(dir, "/") + "/"
+	if len(p) < len(dir) {
+		return "", false
+	}
+	prefix := p[:len(dir)]
+	if prefix == dir || (hasDriveLetter(dir) && strings.EqualFold(prefix, dir)) {
+		return p[len(dir):], true
+	}
+	return "", false
+}
@@ -0,0 +1,58 @@
+package vectorclocks
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeFeatureURI(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd = strings.ReplaceAll(wd, `\`, "/")
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{"", ""},
+		{"features/a.feature", "features/a.feature"},
+		{"./features/a.feature", "features/a.feature"},
+		{`features\sub\a.feature`, "features/sub/a.feature"},
+		{`.\features\a.feature`, "features/a.feature"},
+		{"features//sub/../a.feature", "features/a.feature"},
+		{"../shared/a.feature", "../shared/a.feature"},
+		{wd + "/features/a.feature", "features/a.feature"},
+		{wd + "x/a.feature", wd + "x/a.feature"},
+		{"/nonexistent/features/a.feature", "/nonexistent/features/a.feature"},
+		{`c:\src\features\a.feature`, "C:/src/features/a.feature"},
+		{"D:/src/a.feature", "D:/src/a.feature"},
+	}
+	for _, tt := range tests {
+		if got := normalizeFeatureURI(tt.uri); got != tt.want {
+			t.Errorf("normalizeFeatureURI(%q) = %q, want %q", tt.uri, got, tt.want)
+		}
+	}
+}
+
+func TestTrimDirPrefix(t *testing.T) {
+	tests := []struct {
+		p, dir string
+		want   string
+		ok     bool
+	}{
+		{"/src/app/features/a.feature", "/src/app", "features/a.feature", true},
+		{"/src/app/features/a.feature", "/src/app/", "features/a.feature", true},
+		{"/src/application/a.feature", "/src/app", "", false},
+		{"/src/App/a.feature", "/src/app", "", false},
+		{"C:/Src/App/a.feature", "c:/src/app", "a.feature", true},
+		{"/src", "/src/app", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := trimDirPrefix(tt.p, tt.dir)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("trimDirPrefix(%q, %q) = %q, %v; want %q, %v", tt.p, tt.dir, got, ok, tt.want, tt.ok)
+		}
+	}
+}
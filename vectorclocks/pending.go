@@ -0,0 +1,136 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// PendingStep follows a step text from the first run in which it was
+// pending or undefined to the first run in which it passed.
+type PendingStep struct {
+	Step         string
+	FirstPending time.Time
+	// FirstPassed is zero while the step has not passed yet.
+	FirstPassed time.Time
+}
+
+// Converted reports whether the step has been implemented.
+func (p PendingStep) Converted() bool {
+	return !p.FirstPassed.IsZero()
+}
+
+// LeadTime is how long the step took to go from pending to passing, or
+// how long it has been pending so far.
+func (p PendingStep) LeadTime(now time.Time) time.Duration {
+	if p.Converted() {
+		return p.FirstPassed.Sub(p.FirstPending)
+	}
+	return now.Sub(p.FirstPending)
+}
+
+// updateLifecycle notes the steps of the current run that are pending for
+// the first time and the pending steps that passed for the first time.
+func (v *VectorClockAgent) updateLifecycle() error {
+	if _, err := v.db.Exec(`
+		INSERT OR IGNORE INTO step_lifecycle (step_text, first_pending_at)
+		SELECT step_text, MIN(created_at) FROM step_timings
+		WHERE run_id = ? AND status IN ('pending', 'undefined')
+		GROUP BY step_text
+	`, v.runID); err != nil {
+		return fmt.Errorf("failed to record pending steps: %w", err)
+	}
+	if _, err := v.db.Exec(`
+		UPDATE step_lifecycle SET first_passed_at = (
+			SELECT MIN(s.created_at) FROM step_timings s
+			WHERE s.run_id = ? AND s.step_text = step_lifecycle.step_text AND s.status = 'passed'
+				AND s.created_at >= step_lifecycle.first_pending_at
+		)
+		WHERE first_passed_at IS NULL
+	`, v.runID); err != nil {
+		return fmt.Errorf("failed to record implemented steps: %w", err)
+	}
+	return nil
+}
+
+// PendingSteps returns every step text that was ever pending or undefined,
+// oldest first.
+func (v *VectorClockAgent) PendingSteps() ([]PendingStep, error) {
+	rows, err := v.db.Query(`SELECT step_text, first_pending_at, first_passed_at FROM step_lifecycle`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load pending steps: %w", err)
+	}
+	defer rows.Close()
+
+	var steps []PendingStep
+	for rows.Next() {
+		var p PendingStep
+		var pending, passed timestamp
+		if err := rows.Scan(&p.Step, &pending, &passed); err != nil {
+			return nil, err
+		}
+		p.FirstPending, p.FirstPassed = pending.Time, passed.Time
+		steps = append(steps, p)
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		if !steps[i].FirstPending.Equal(steps[j].FirstPending) {
+			return steps[i].FirstPending.Before(steps[j].FirstPending)
+		}
+		return steps[i].Step < steps[j].Step
+	})
+	return steps, rows.Err()
+}
+
+// MeanLeadTime is the average time converted steps took from pending to
+// passing; ok is false when no step has been converted.
+func MeanLeadTime(steps []PendingStep) (mean time.Duration, ok bool) {
+	var total time.Duration
+	var n int
+	for _, p := range steps {
+		if p.Converted() {
+			total += p.LeadTime(p.FirstPassed)
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return total / time.Duration(n), true
+}
+
+// WritePendingSteps prints the conversion rate and mean lead time followed
+// by one line per step.
+func WritePendingSteps(w io.Writer, steps []PendingStep, now time.Time) error {
+	converted := 0
+	for _, p := range steps {
+		if p.Converted() {
+			converted++
+		}
+	}
+	summary := fmt.Sprintf("%d of %d pending steps implemented", converted, len(steps))
+	if mean, ok := MeanLeadTime(steps); ok {
+		summary += fmt.Sprintf(", mean lead time %s", formatDays(mean))
+	}
+	if _, err := fmt.Fprintln(w, summary); err != nil {
+		return err
+	}
+	for _, p := range steps {
+		state := "pending for " + formatDays(p.LeadTime(now))
+		if p.Converted() {
+			state = "implemented after " + formatDays(p.LeadTime(now))
+		}
+		if _, err := fmt.Fprintf(w, "  %s  %-28s %s\n", p.FirstPending.Format("2006-01-02"), state, p.Step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatDays prints lead times, which span days rather than seconds.
+func formatDays(d time.Duration) string {
+	if d < 24*time.Hour {
+		return d.Round(time.Minute).String()
+	}
+	return fmt.Sprintf("%.1fd", d.Hours()/24)
+}
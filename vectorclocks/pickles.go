@@ -0,0 +1,154 @@
+package vectorclocks
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/cucumber/godog"
+	messages "github.com/cucumber/messages/go/v21"
+)
+
+// indexedPickle is what the feature file says about a pickle beyond what
+// godog hands the hooks.
+type indexedPickle struct {
+	name  string
+	steps []string
+	// line is the line of the scenario or outline, and stepLines the
+	// lines of the steps, 0 where unknown.
+	line      int
+	stepLines []int
+	// row is the Examples row of an outline scenario.
+	row exampleRow
+	// background is the number of leading steps that come from the
+	// feature's and the rule's Background.
+	background int
+}
+
+// pickleIndex caches the parsed pickles of feature files by path.
+type pickleIndex struct {
+	mu       sync.Mutex
+	features map[string][]indexedPickle
+}
+
+// pickleOf looks scenario s up in its feature file. godog's pickles carry
+// the node IDs of their rows and steps but not their lines, values or
+// origin, and the IDs are random, so the feature file is parsed once and s
+// is matched to the pickle with the same name and step texts; identical
+// pickles match the first of them. It reports false when the file cannot
+// be read.
+func (v *VectorClockAgent) pickleOf(s *godog.Scenario) (indexedPickle, bool) {
+	path := featureLineSuffix.ReplaceAllString(s.Uri, "")
+	pickles, err := v.pickles.feature(path)
+	if err != nil {
+		v.logger.Debug("feature file unavailable", "feature", path, "error", err)
+		return indexedPickle{}, false
+	}
+next:
+	for _, p := range pickles {
+		if p.name != s.Name || len(p.steps) != len(s.Steps) {
+			continue
+		}
+		for i, step := range s.Steps {
+			if step.Text != p.steps[i] {
+				continue next
+			}
+		}
+		return p, true
+	}
+	return indexedPickle{}, false
+}
+
+// feature returns the pickles of the feature file at path, parsing it on
+// first use.
+func (x *pickleIndex) feature(path string) ([]indexedPickle, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if pickles, ok := x.features[path]; ok {
+		return pickles, nil
+	}
+	doc, pickles, err := parseFeature(path)
+	if err != nil {
+		return nil, err
+	}
+
+	background := make(map[string]bool)
+	lines := make(map[string]int)
+	addSteps := func(steps []*messages.Step) {
+		for _, step := range steps {
+			if step.Location != nil {
+				lines[step.Id] = int(step.Location.Line)
+			}
+		}
+	}
+	addBackground := func(b *messages.Background) {
+		if b != nil {
+			addSteps(b.Steps)
+			for _, step := range b.Steps {
+				background[step.Id] = true
+			}
+		}
+	}
+	if doc.Feature != nil {
+		for _, c := range doc.Feature.Children {
+			addBackground(c.Background)
+			if c.Rule != nil {
+				for _, rc := range c.Rule.Children {
+					addBackground(rc.Background)
+				}
+			}
+		}
+	}
+	rows := make(map[string]exampleRow)
+	for _, s := range docScenarios(doc) {
+		if s.Location != nil {
+			lines[s.Id] = int(s.Location.Line)
+		}
+		addSteps(s.Steps)
+		for _, ex := range s.Examples {
+			if ex.TableHeader == nil {
+				continue
+			}
+			for _, r := range ex.TableBody {
+				pairs := make([]string, 0, len(r.Cells))
+				for i, c := range r.Cells {
+					if i < len(ex.TableHeader.Cells) {
+						pairs = append(pairs, ex.TableHeader.Cells[i].Value+"="+c.Value)
+					}
+				}
+				row := exampleRow{values: strings.Join(pairs, " ")}
+				if r.Location != nil {
+					row.line = int(r.Location.Line)
+				}
+				rows[r.Id] = row
+			}
+		}
+	}
+
+	indexed := make([]indexedPickle, 0, len(pickles))
+	for _, p := range pickles {
+		ip := indexedPickle{name: p.Name}
+		if len(p.AstNodeIds) > 0 {
+			ip.line = lines[p.AstNodeIds[0]]
+		}
+		if len(p.AstNodeIds) > 1 {
+			ip.row = rows[p.AstNodeIds[1]]
+		}
+		for _, step := range p.Steps {
+			ip.steps = append(ip.steps, step.Text)
+			line := 0
+			if len(step.AstNodeIds) > 0 {
+				line = lines[step.AstNodeIds[0]]
+				if background[step.AstNodeIds[0]] {
+					ip.background++
+				}
+			}
+			ip.stepLines = append(ip.stepLines, line)
+		}
+		indexed = append(indexed, ip)
+	}
+	if x.features == nil {
+		x.features = make(map[string][]indexedPickle)
+	}
+	x.features[path] = indexed
+	return indexed, nil
+}
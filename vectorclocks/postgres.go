@@ -0,0 +1,204 @@
+package vectorclocks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// postgresNull is the text psql writes for NULL in the copied tables.
+const postgresNull = `\N`
+
+// fetchPostgres copies the agent's tables from the PostgreSQL database at
+// dsn into a temporary SQLite database that cleanup removes. Tables are
+// looked up under the names n gives them, without a schema; tables and
+// columns the PostgreSQL database lacks are left empty, and columns only it
+// has are ignored.
+func fetchPostgres(ctx context.Context, dsn string, n naming, conn ConnectionOptions) (path string, cleanup func(), err error) {
+	f, err := os.CreateTemp("", "vectorclocks-baseline-*.db")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create baseline file: %w", err)
+	}
+	f.Close()
+	cleanup = func() {
+		for _, suffix := range []string{"", "-wal", "-shm"} {
+			os.Remove(f.Name() + suffix)
+		}
+	}
+	if err := copyPostgres(ctx, dsn, f.Name(), naming{prefix: n.prefix}, conn); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to copy baseline %s: %w", redactURL(dsn), err)
+	}
+	return f.Name(), cleanup, nil
+}
+
+func copyPostgres(ctx context.Context, dsn, path string, n naming, conn ConnectionOptions) error {
+	sqlDB, err := sql.Open(driverName(conn.Key, n), sqliteDSN(path, conn))
+	if err != nil {
+		return fmt.Errorf("failed to open SQLite database: %w", err)
+	}
+	db := &namedDB{DB: sqlDB, naming: n}
+	defer db.Close()
+	if err := migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+
+	var listed bytes.Buffer
+	if err := psql(ctx, dsn, `SELECT table_name FROM information_schema.tables WHERE table_schema = current_schema()`, &listed); err != nil {
+		return err
+	}
+	remote := make(map[string]bool)
+	for _, name := range strings.Fields(listed.String()) {
+		remote[name] = true
+	}
+	for _, name := range schemaObjects {
+		table := n.table(name)
+		if name == "schema_version" || !remote[table] {
+			continue
+		}
+		if err := copyPostgresTable(ctx, dsn, db, table); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyPostgresTable copies the rows of table into the SQLite table of the
+// same name.
+func copyPostgresTable(ctx context.Context, dsn string, db *namedDB, table string) error {
+	local := make(map[string]bool)
+	rows, err := db.DB.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return fmt.Errorf("failed to read columns of %s: %w", table, err)
+	}
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return err
+		}
+		local[column] = true
+	}
+	rows.Close()
+	if len(local) == 0 {
+		// Not a table, such as an index.
+		return nil
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		err := psql(ctx, dsn, fmt.Sprintf(`COPY %q TO STDOUT WITH (FORMAT csv, HEADER, NULL '%s')`, table, postgresNull), pw)
+		pw.CloseWithError(err)
+		done <- err
+	}()
+	err = insertCSV(db, table, local, csv.NewReader(pr))
+	pr.CloseWithError(err)
+	return errors.Join(err, <-done)
+}
+
+// insertCSV inserts the records of r, whose first record names their
+// columns, into table, keeping the columns in local.
+func insertCSV(db *namedDB, table string, local map[string]bool, r *csv.Reader) error {
+	header, err := r.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", table, err)
+	}
+	var columns []string
+	var keep []int
+	for i, column := range header {
+		if local[column] {
+			columns = append(columns, fmt.Sprintf("%q", column))
+			keep = append(keep, i)
+		}
+	}
+	if len(columns) == 0 {
+		return nil
+	}
+
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(fmt.Sprintf(`INSERT OR IGNORE INTO %q (%s) VALUES (?%s)`,
+		table, strings.Join(columns, ", "), strings.Repeat(", ?", len(columns)-1)))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy of %s: %w", table, err)
+	}
+	defer stmt.Close()
+	args := make([]interface{}, len(keep))
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		for i, field := range keep {
+			if record[field] == postgresNull {
+				args[i] = nil
+			} else {
+				args[i] = record[field]
+			}
+		}
+		if _, err := stmt.Exec(args...); err != nil {
+			return fmt.Errorf("failed to copy a row of %s: %w", table, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit copy of %s: %w", table, err)
+	}
+	return nil
+}
+
+// psql runs query against the PostgreSQL database at dsn with the psql
+// command line tool, writing its unaligned output to w. The password goes
+// to psql through PGPASSWORD, so that it does not show in the process list.
+func psql(ctx context.Context, dsn, query string, w io.Writer) error {
+	target, password := psqlTarget(dsn)
+	cmd := exec.CommandContext(ctx, "psql", "-X", "-q", "-A", "-t", "-v", "ON_ERROR_STOP=1", "-d", target, "-c", query)
+	if password != "" {
+		cmd.Env = append(os.Environ(), "PGPASSWORD="+password)
+	}
+	cmd.Stdout = w
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("psql: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// psqlTarget splits the password, given in the user info or as a password
+// parameter, off the DSN URL dsn.
+func psqlTarget(dsn string) (target, password string) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return dsn, ""
+	}
+	if q := u.Query(); q.Has("password") {
+		password = q.Get("password")
+		q.Del("password")
+		u.RawQuery = q.Encode()
+	}
+	if u.User != nil {
+		if p, ok := u.User.Password(); ok {
+			password = p
+			u.User = url.User(u.User.Username())
+		}
+	}
+	return u.String(), password
+}
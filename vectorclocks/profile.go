@@ -0,0 +1,165 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/pprof"
+	"sync"
+	"time"
+)
+
+// ProfileAnnotation is the annotation key under which a profiled step
+// stores the path of its CPU profile.
+const ProfileAnnotation = "cpu_profile"
+
+// ProfilePolicy configures WithSlowStepProfiles.
+type ProfilePolicy struct {
+	// Dir receives a <step ID>.pprof file per profiled step.
+	Dir string
+	// Threshold is the duration from which a step counts as slow.
+	Threshold time.Duration
+	// Watchdog profiles any step still running after Threshold, from that
+	// moment on, instead of profiling from their start the steps that were
+	// slow in the previous run.
+	Watchdog bool
+}
+
+// WithSlowStepProfiles writes a CPU profile of slow steps to p.Dir, so a hot
+// step can be diagnosed with go tool pprof rather than only measured. By
+// default the steps that took at least p.Threshold in the previous run are
+// profiled from their start; with p.Watchdog every step is watched and
+// profiled once it has run for p.Threshold. The profile's path is stored
+// as the step's ProfileAnnotation.
+//
+// Go allows one CPU profile at a time, so while one step is profiled a
+// concurrent slow step is not, and no step is while the test binary itself
+// runs with -cpuprofile. Profiles are process-wide and include whatever
+// else ran meanwhile.
+func WithSlowStepProfiles(p ProfilePolicy) Option {
+	return func(v *VectorClockAgent) {
+		if p.Dir != "" && p.Threshold > 0 {
+			v.profiles = &p
+		}
+	}
+}
+
+// stepProfile is the CPU profile of one step, pending on its watchdog or
+// running.
+type stepProfile struct {
+	mu    sync.Mutex
+	timer *time.Timer
+	file  *os.File
+	done  bool
+}
+
+// loadSlowSteps prepares the profile directory and, unless profiling by
+// watchdog, remembers which steps of the previous run were slow.
+func (v *VectorClockAgent) loadSlowSteps() error {
+	p := v.profiles
+	if p == nil {
+		return nil
+	}
+	if err := os.MkdirAll(p.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create profile directory: %w", err)
+	}
+	if p.Watchdog {
+		return nil
+	}
+	runIDs, err := v.RecentRuns(1)
+	if err != nil || len(runIDs) == 0 {
+		return err
+	}
+	rows, err := v.db.Query(`
+		SELECT DISTINCT scenario_name, step_text
+		FROM step_timings
+		WHERE run_id = ? AND `+primaryPhase+` AND `+durationNs+` >= ?
+	`, runIDs[0], p.Threshold.Nanoseconds())
+	if err != nil {
+		return fmt.Errorf("failed to load slow steps: %w", err)
+	}
+	defer rows.Close()
+
+	v.slowSteps = make(map[StepKey]bool)
+	for rows.Next() {
+		var k StepKey
+		if err := rows.Scan(&k.Scenario, &k.Step); err != nil {
+			return err
+		}
+		v.slowSteps[k] = true
+	}
+	return rows.Err()
+}
+
+// profileStep starts profiling a step that just started, right away or on
+// its watchdog. It returns nil for steps that are not profiled.
+func (v *VectorClockAgent) profileStep(stepID, scenario, step string) *stepProfile {
+	p := v.profiles
+	if p == nil {
+		return nil
+	}
+	sp := &stepProfile{}
+	switch {
+	case p.Watchdog:
+		sp.timer = time.AfterFunc(p.Threshold, func() { v.startProfile(sp, stepID) })
+	case v.slowSteps[StepKey{Scenario: scenario, Step: step}]:
+		v.startProfile(sp, stepID)
+	default:
+		return nil
+	}
+	return sp
+}
+
+func (v *VectorClockAgent) startProfile(sp *stepProfile, stepID string) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	if sp.done {
+		return
+	}
+	f, err := os.Create(filepath.Join(v.profiles.Dir, profileFileName(stepID)))
+	if err != nil {
+		v.handleError(fmt.Errorf("failed to create CPU profile of step '%s': %w", stepID, err))
+		return
+	}
+	if err := pprof.StartCPUProfile(f); err != nil {
+		// Another step, or the test binary, holds the profiler.
+		v.logger.Debug("CPU profile skipped", "step_id", stepID, "reason", err)
+		f.Close()
+		os.Remove(f.Name())
+		return
+	}
+	sp.file = f
+}
+
+// stop ends the profile of a finished step and returns the profile's path,
+// or "" when none was written.
+func (sp *stepProfile) stop() (string, error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	sp.done = true
+	if sp.timer != nil {
+		sp.timer.Stop()
+	}
+	if sp.file == nil {
+		return "", nil
+	}
+	pprof.StopCPUProfile()
+	if err := sp.file.Close(); err != nil {
+		return "", fmt.Errorf("failed to write CPU profile: %w", err)
+	}
+	return sp.file.Name(), nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// profileFileName turns a step ID, which holds free step text, into a file
+// name.
+func profileFileName(stepID string) string {
+	name := unsafeFileChars.ReplaceAllString(stepID, "_")
+	if len(name) > 200 {
+		// Keep the counter at the end that makes the ID unique.
+		name = name[:160] + name[len(name)-40:]
+	}
+	return name + ".pprof"
+}
@@ -0,0 +1,287 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// StepTiming is one persisted step execution.
+type StepTiming struct {
+	StepID       string
+	RunID        string
+	ScenarioName string
+	StepText     string
+	FeatureURI   string
+	Status       string
+	Tags         []string
+	Phase        string
+	// Attempt is 1 for the first execution of the step in a run and counts
+	// up with each retry of its scenario; 0 for rows recorded before
+	// attempts were tracked.
+	Attempt   int
+	Duration  time.Duration
+	CreatedAt time.Time
+	// StartedAt and EndedAt are the UTC times the step ran; zero for rows
+	// recorded before they were tracked.
+	StartedAt time.Time
+	EndedAt   time.Time
+	// Annotations are the key/value pairs the step added with Annotate.
+	Annotations map[string]string
+	// Injected is the latency added by WithFaults, which Duration
+	// excludes.
+	Injected time.Duration
+	// Memory is the allocation delta recorded WithMemoryTracking; nil for
+	// steps recorded without it.
+	Memory *MemoryDelta
+	// ExampleLine is the line of the Examples row an outline scenario ran
+	// with, and Example its values as "header=value" pairs; zero for other
+	// scenarios.
+	ExampleLine int
+	Example     string
+	// Origin is OriginBackground for steps that come from a Background and
+	// OriginScenario for the scenario's own; "" when unknown.
+	Origin string
+	// ScenarioLine and StepLine are the lines of the scenario and the step
+	// in FeatureURI; 0 for rows recorded without them.
+	ScenarioLine int
+	StepLine     int
+	// StepHash is the StepHash of the step, the same in every run; "" for
+	// rows recorded before it was stored.
+	StepHash string
+	// Process is the ProcessID of the agent that recorded the step, and
+	// Clock its vector clock when the step ended; empty for rows recorded
+	// before they were stored.
+	Process string
+	Clock   VectorClock
+	// Gap is the untimed time before the step: from the end of the
+	// scenario's previous step, or the scenario's start for its first
+	// step, to the start of this one. Zero when unknown.
+	Gap time.Duration
+}
+
+// Location returns where the step is written as "file:line", or just the
+// feature file, or "" when neither is known.
+func (t StepTiming) Location() string {
+	if t.FeatureURI == "" || t.StepLine == 0 {
+		return t.FeatureURI
+	}
+	return fmt.Sprintf("%s:%d", t.FeatureURI, t.StepLine)
+}
+
+// stepStart is when t started, derived from when it was recorded for
+// rows stored before start times were tracked.
+func stepStart(t StepTiming) time.Time {
+	if !t.StartedAt.IsZero() {
+		return t.StartedAt
+	}
+	return t.CreatedAt.Add(-t.Duration)
+}
+
+// Sort orders accepted by TimingFilter.SortBy.
+const (
+	SortByTime     = "time"
+	SortByDuration = "duration"
+	// SortByCausal orders steps by their vector clocks; see CausalOrder.
+	SortByCausal = "causal"
+)
+
+// TimingFilter selects rows for Timings. Zero fields match everything.
+type TimingFilter struct {
+	// RunID matches the run exactly.
+	RunID string
+	// Scenario matches the scenario name exactly.
+	Scenario string
+	// FeatureURI matches the feature file exactly, which tells apart
+	// identically named scenarios of different features.
+	FeatureURI string
+	// StepHash matches one step across runs by its StepHash.
+	StepHash string
+	// StepContains matches step texts containing the substring.
+	StepContains string
+	// Tag matches steps whose scenario carries the tag, including its
+	// leading "@".
+	Tag string
+	// Annotation matches steps annotated with a key ("key") or with a key
+	// and value ("key=value").
+	Annotation string
+	// Label matches steps of runs labelled with a key ("key") or with a
+	// key and value ("key=value"); see WithLabels.
+	Label string
+	// Suite matches steps of runs of the suite named with WithSuite.
+	Suite string
+	// MinDuration drops steps faster than this.
+	MinDuration time.Duration
+	// SortBy is SortByTime (oldest first, the default), SortByDuration
+	// (slowest first) or SortByCausal.
+	SortBy string
+	// Limit caps the number of returned rows.
+	Limit int
+}
+
+// Timings returns the persisted step timings matching filter.
+func (v *VectorClockAgent) Timings(filter TimingFilter) ([]StepTiming, error) {
+	v.sync()
+
+	var where []string
+	var args []interface{}
+	if filter.RunID != "" {
+		where = append(where, "run_id = ?")
+		args = append(args, filter.RunID)
+	}
+	if filter.Scenario != "" {
+		where = append(where, "scenario_name = ?")
+		args = append(args, filter.Scenario)
+	}
+	if filter.FeatureURI != "" {
+		where = append(where, "feature_uri = ?")
+		args = append(args, filter.FeatureURI)
+	}
+	if filter.StepHash != "" {
+		where = append(where, "step_hash = ?")
+		args = append(args, filter.StepHash)
+	}
+	if filter.StepContains != "" {
+		where = append(where, "instr(step_text, ?) > 0")
+		args = append(args, filter.StepContains)
+	}
+	if filter.Tag != "" {
+		where = append(where, "instr(',' || COALESCE(tags, '') || ',', ',' || ? || ',') > 0")
+		args = append(args, filter.Tag)
+	}
+	if filter.Annotation != "" {
+		match, matchArgs := jsonMatch("annotations", filter.Annotation)
+		where = append(where, match)
+		args = append(args, matchArgs...)
+	}
+	if filter.Label != "" {
+		match, matchArgs := jsonMatch("labels", filter.Label)
+		where = append(where, "run_id IN (SELECT run_id FROM runs WHERE "+match+")")
+		args = append(args, matchArgs...)
+	}
+	if filter.Suite != "" {
+		where = append(where, "run_id IN (SELECT run_id FROM runs WHERE suite = ?)")
+		args = append(args, filter.Suite)
+	}
+	if filter.MinDuration > 0 {
+		where = append(where, durationNs+" >= ?")
+		args = append(args, filter.MinDuration.Nanoseconds())
+	}
+
+	query := `
+		SELECT step_id, COALESCE(run_id, ''), scenario_name, step_text, COALESCE(feature_uri, ''),
+			COALESCE(status, ''), COALESCE(tags, ''), COALESCE(phase, 'primary'), COALESCE(attempt, 0), ` + durationNs + `, created_at, started_at, ended_at,
+			COALESCE(annotations, ''), COALESCE(injected_ns, 0), alloc_bytes, mallocs, gc_cycles,
+			COALESCE(example_line, 0), COALESCE(example, ''), COALESCE(origin, ''),
+			COALESCE(scenario_line, 0), COALESCE(step_line, 0), COALESCE(step_hash, ''),
+			COALESCE(process_id, ''), COALESCE(vector_clock, ''), COALESCE(gap_ns, 0)
+		FROM step_timings`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	switch filter.SortBy {
+	case "", SortByTime, SortByCausal:
+		query += " ORDER BY created_at, id"
+	case SortByDuration:
+		query += " ORDER BY " + durationNs + " DESC, id"
+	default:
+		return nil, fmt.Errorf("unknown sort order %q (want %s, %s or %s)", filter.SortBy, SortByTime, SortByDuration, SortByCausal)
+	}
+	// The causal order is only known once all rows are loaded.
+	if filter.Limit > 0 && filter.SortBy != SortByCausal {
+		query += " LIMIT ?"
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := v.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch step timings: %w", err)
+	}
+	defer rows.Close()
+
+	var timings []StepTiming
+	for rows.Next() {
+		var t StepTiming
+		var tags, annotations, clock string
+		var durationNs, injectedNs, gapNs int64
+		var allocBytes, mallocs, gcCycles sql.NullInt64
+		var createdAt, startedAt, endedAt timestamp
+		if err := rows.Scan(&t.StepID, &t.RunID, &t.ScenarioName, &t.StepText, &t.FeatureURI,
+			&t.Status, &tags, &t.Phase, &t.Attempt, &durationNs, &createdAt, &startedAt, &endedAt, &annotations, &injectedNs,
+			&allocBytes, &mallocs, &gcCycles, &t.ExampleLine, &t.Example, &t.Origin,
+			&t.ScenarioLine, &t.StepLine, &t.StepHash, &t.Process, &clock, &gapNs); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if t.Annotations, err = decodeAnnotations(annotations); err != nil {
+			return nil, fmt.Errorf("step '%s': %w", t.StepID, err)
+		}
+		if t.Clock, err = ParseVectorClock(clock); err != nil {
+			return nil, fmt.Errorf("step '%s': %w", t.StepID, err)
+		}
+		if tags != "" {
+			t.Tags = strings.Split(tags, ",")
+		}
+		t.Duration = time.Duration(durationNs)
+		t.Injected, t.Gap = time.Duration(injectedNs), time.Duration(gapNs)
+		if allocBytes.Valid {
+			t.Memory = &MemoryDelta{AllocBytes: uint64(allocBytes.Int64), Mallocs: uint64(mallocs.Int64), GCCycles: uint32(gcCycles.Int64)}
+		}
+		t.CreatedAt = createdAt.Time
+		t.StartedAt, t.EndedAt = startedAt.Time, endedAt.Time
+		timings = append(timings, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if filter.SortBy == SortByCausal {
+		timings = CausalOrder(timings)
+		if filter.Limit > 0 && len(timings) > filter.Limit {
+			timings = timings[:filter.Limit]
+		}
+	}
+	return timings, nil
+}
+
+// WriteTimings prints timings one per line in the format of Report, with
+// durations in whole milliseconds.
+func WriteTimings(w io.Writer, timings []StepTiming) error {
+	return WriteTimingsIn(w, timings, "")
+}
+
+// WriteTimingsIn is WriteTimings with durations printed in unit. An empty
+// unit prints whole milliseconds.
+func WriteTimingsIn(w io.Writer, timings []StepTiming, unit DisplayUnit) error {
+	for _, t := range timings {
+		duration := fmt.Sprintf("%d ms", t.Duration.Milliseconds())
+		if unit != "" {
+			duration = unit.Format(t.Duration)
+		}
+		annotations := ""
+		if len(t.Annotations) > 0 {
+			annotations = ", Annotations: " + formatAnnotations(t.Annotations)
+		}
+		if t.Injected > 0 {
+			annotations += fmt.Sprintf(", Injected: %d ms", t.Injected.Milliseconds())
+		}
+		if t.StepLine > 0 {
+			annotations += ", Source: " + t.Location()
+		}
+		if t.Origin == OriginBackground {
+			annotations += ", Background"
+		}
+		if t.ExampleLine > 0 {
+			annotations += fmt.Sprintf(", Example: line %d %s", t.ExampleLine, t.Example)
+		}
+		if m := t.Memory; m != nil {
+			annotations += fmt.Sprintf(", Allocated: %s in %d objects", formatBytes(m.AllocBytes), m.Mallocs)
+		}
+		_, err := fmt.Fprintf(w, "StepID: %s, Scenario: %s, Step: %s, Duration: %s, Timestamp: %s%s\n",
+			t.StepID, t.ScenarioName, t.StepText, duration, t.CreatedAt.Format(sqliteTimeLayout), annotations)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package vectorclocks
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrReadOnly is returned for steps recorded by an agent opened
+// WithReadOnly.
+var ErrReadOnly = errors.New("vectorclocks: agent is read-only")
+
+// WithReadOnly opens the database read-only, for reports and analyses
+// against a database a live suite is writing to. The agent neither
+// migrates the schema nor writes anything: no run row, digests or
+// maintenance on open and close, and recorded steps fail with ErrReadOnly.
+// The schema must already be current. Methods that store what they
+// compute, such as UpdateStability, fail, except ParallelSafety, which
+// returns its verdicts without storing them.
+func WithReadOnly() Option {
+	return func(v *VectorClockAgent) {
+		v.readOnly = true
+	}
+}
+
+// readOnlyDSN opens dbPath read-only, as a URI filename, waiting out the
+// locks of the writers' checkpoints like sqliteDSN.
+func readOnlyDSN(dbPath string, o ConnectionOptions) string {
+	if !strings.HasPrefix(dbPath, "file:") {
+		dbPath = "file:" + dbPath
+	}
+	busyTimeout := o.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = DefaultBusyTimeout
+	}
+	sep := "?"
+	if strings.Contains(dbPath, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%smode=ro&_busy_timeout=%d", dbPath, sep, busyTimeout.Milliseconds())
+}
+
+// checkSchema fails unless db has the latest embedded schema, which a
+// read-only agent cannot migrate to.
+func checkSchema(db *namedDB) error {
+	current, err := schemaVersion(db)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	migrations, err := embeddedMigrations()
+	if err != nil {
+		return err
+	}
+	if latest := migrations[len(migrations)-1].version; current < latest {
+		return fmt.Errorf("schema is at version %d of %d; open the database once without read-only mode to migrate it", current, latest)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WithRewriteMissing makes the end-of-run reconciliation in Close write the
+// steps that are missing from the database again.
+func WithRewriteMissing() Option {
+	return func(v *VectorClockAgent) {
+		v.rewriteMissing = true
+	}
+}
+
+// Reconciliation compares the steps recorded in memory during this run with
+// the rows persisted for it.
+type Reconciliation struct {
+	// Recorded is the number of steps handed to the writer.
+	Recorded int
+	// Persisted is the number of those steps found in the database.
+	Persisted int
+	// Missing lists the step IDs without a row.
+	Missing []string
+	// Mismatched lists the step IDs whose persisted duration differs from
+	// the recorded one.
+	Mismatched []string
+	// Rewritten is the number of missing steps written again.
+	Rewritten int
+}
+
+// OK reports whether every recorded step was persisted unchanged.
+func (r Reconciliation) OK() bool {
+	return len(r.Missing) == 0 && len(r.Mismatched) == 0
+}
+
+func (r Reconciliation) String() string {
+	return fmt.Sprintf("%d of %d steps persisted, %d missing, %d with a different duration, %d rewritten",
+		r.Persisted, r.Recorded, len(r.Missing), len(r.Mismatched), r.Rewritten)
+}
+
+// Reconcile waits for pending writes and compares the steps recorded by this
+// run with the database. With rewrite set, missing steps are written again.
+// A step stored under its run-scoped ID after a conflict counts as
+// persisted.
+func (v *VectorClockAgent) Reconcile(rewrite bool) (Reconciliation, error) {
+	v.sync()
+	return v.reconcile(rewrite)
+}
+
+// reconcile is Reconcile without the flush, for use after the writer has
+// stopped.
+func (v *VectorClockAgent) reconcile(rewrite bool) (Reconciliation, error) {
+	recorded := make(map[string]stepRecord)
+	v.durations.Range(func(key, value interface{}) bool {
+		rec := value.(stepRecord)
+		recorded[rec.stepID] = rec
+		return true
+	})
+
+	r, err := v.compareRecorded(recorded)
+	if err != nil || !rewrite || len(r.Missing) == 0 {
+		return r, err
+	}
+
+	batch := make([]stepRecord, 0, len(r.Missing))
+	for _, id := range r.Missing {
+		batch = append(batch, recorded[id])
+	}
+	if err := v.writeBatch(batch); err != nil {
+		return r, fmt.Errorf("failed to rewrite missing steps: %w", err)
+	}
+	before := len(r.Missing)
+	if r, err = v.compareRecorded(recorded); err != nil {
+		return r, err
+	}
+	r.Rewritten = before - len(r.Missing)
+	return r, nil
+}
+
+func (v *VectorClockAgent) compareRecorded(recorded map[string]stepRecord) (Reconciliation, error) {
+	r := Reconciliation{Recorded: len(recorded)}
+	if len(recorded) == 0 {
+		return r, nil
+	}
+
+	rows, err := v.db.Query(`SELECT step_id, `+durationNs+` FROM step_timings WHERE run_id = ?`, v.runID)
+	if err != nil {
+		return r, fmt.Errorf("failed to load persisted steps: %w", err)
+	}
+	defer rows.Close()
+
+	persisted := make(map[string]int64)
+	for rows.Next() {
+		var id string
+		var durationNs int64
+		if err := rows.Scan(&id, &durationNs); err != nil {
+			return r, err
+		}
+		persisted[strings.TrimPrefix(id, v.runID+"/")] = durationNs
+	}
+	if err := rows.Err(); err != nil {
+		return r, err
+	}
+
+	for id, rec := range recorded {
+		durationNs, ok := persisted[id]
+		switch {
+		case !ok:
+			r.Missing = append(r.Missing, id)
+		case durationNs != v.rounding.apply(rec.duration).Nanoseconds():
+			r.Persisted++
+			r.Mismatched = append(r.Mismatched, id)
+		default:
+			r.Persisted++
+		}
+	}
+	sort.Strings(r.Missing)
+	sort.Strings(r.Mismatched)
+	return r, nil
+}
@@ -0,0 +1,82 @@
+package vectorclocks
+
+import (
+	"regexp"
+	"strings"
+)
+
+// RecordFilter selects the steps the agent records. Steps left out are still
+// run and counted in the summary but are neither stored nor streamed.
+type RecordFilter struct {
+	// IncludeTags, when not empty, records only scenarios carrying one of
+	// the tags, e.g. "@perf".
+	IncludeTags []string
+	// ExcludeTags skips scenarios carrying one of the tags.
+	ExcludeTags []string
+	// IncludeSteps, when set, records only steps whose text matches.
+	IncludeSteps *regexp.Regexp
+	// ExcludeSteps skips steps whose text matches, e.g. trivial setup
+	// steps such as `^I am on the home page$`.
+	ExcludeSteps *regexp.Regexp
+}
+
+// WithRecordFilter records only the steps f selects. Exclusions win over
+// inclusions.
+func WithRecordFilter(f RecordFilter) Option {
+	return func(v *VectorClockAgent) {
+		if len(f.IncludeTags) > 0 || len(f.ExcludeTags) > 0 || f.IncludeSteps != nil || f.ExcludeSteps != nil {
+			v.recordFilter = &f
+		}
+	}
+}
+
+// ParseTags splits a comma-separated tag list such as "@perf,@smoke".
+func ParseTags(s string) []string {
+	var tags []string
+	for _, tag := range strings.Split(s, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// records reports whether rec passes f.
+func (f *RecordFilter) records(rec stepRecord) bool {
+	var tags []string
+	if rec.tags != "" {
+		tags = strings.Split(rec.tags, ",")
+	}
+	if hasAnyTag(tags, f.ExcludeTags) {
+		return false
+	}
+	if len(f.IncludeTags) > 0 && !hasAnyTag(tags, f.IncludeTags) {
+		return false
+	}
+	if f.ExcludeSteps != nil && f.ExcludeSteps.MatchString(rec.stepText) {
+		return false
+	}
+	return f.IncludeSteps == nil || f.IncludeSteps.MatchString(rec.stepText)
+}
+
+func hasAnyTag(tags, wanted []string) bool {
+	for _, tag := range tags {
+		for _, w := range wanted {
+			if tag == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterRecords drops the records the record filter leaves out.
+func (v *VectorClockAgent) filterRecords(records []stepRecord) []stepRecord {
+	kept := records[:0:0]
+	for _, rec := range records {
+		if v.recordFilter.records(rec) {
+			kept = append(kept, rec)
+		}
+	}
+	return kept
+}
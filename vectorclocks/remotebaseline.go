@@ -0,0 +1,134 @@
+package vectorclocks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// FetchDatabase makes the database at source available as a local file,
+// for comparisons against history kept elsewhere, such as the main
+// branch's database published by its CI. source is a local path, an
+// http:// or https:// URL, or an s3://bucket/key or gs://bucket/key object
+// downloaded with the aws and gcloud command line tools, so their usual
+// credentials apply. source may also be a postgres:// or postgresql:// DSN
+// of a PostgreSQL database holding the agent's tables, such as one the
+// main branch's CI replicates its runs into; the tables are copied with
+// the psql command line tool. Remote databases are copied to a temporary
+// file that cleanup removes; for local paths cleanup does nothing. opts are
+// those the copy will be opened with: WithTablePrefix names the copied
+// tables and WithConnectionOptions sets the key of the copy.
+func FetchDatabase(ctx context.Context, source string, opts ...Option) (path string, cleanup func(), err error) {
+	var v VectorClockAgent
+	for _, opt := range opts {
+		opt(&v)
+	}
+	return fetchDatabase(ctx, source, naming{prefix: v.naming.prefix}, v.connection)
+}
+
+// fetchDatabase is FetchDatabase for an agent whose tables are named by n
+// and whose databases are opened with conn.
+func fetchDatabase(ctx context.Context, source string, n naming, conn ConnectionOptions) (path string, cleanup func(), err error) {
+	var fetch func(context.Context, string, io.Writer) error
+	switch {
+	case source == "":
+		return "", nil, fmt.Errorf("no baseline database given")
+	case strings.HasPrefix(source, "postgres://"), strings.HasPrefix(source, "postgresql://"):
+		return fetchPostgres(ctx, source, n, conn)
+	case strings.HasPrefix(source, "http://"), strings.HasPrefix(source, "https://"):
+		fetch = fetchHTTP
+	case strings.HasPrefix(source, "s3://"):
+		fetch = fetchCLI("aws", "s3", "cp")
+	case strings.HasPrefix(source, "gs://"):
+		fetch = fetchCLI("gcloud", "storage", "cp")
+	default:
+		return strings.TrimPrefix(source, "file://"), func() {}, nil
+	}
+
+	f, err := os.CreateTemp("", "vectorclocks-baseline-*.db")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create baseline file: %w", err)
+	}
+	cleanup = func() { os.Remove(f.Name()) }
+	if err := fetch(ctx, source, f); err != nil {
+		f.Close()
+		cleanup()
+		return "", nil, err
+	}
+	if err := f.Close(); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("failed to write baseline file: %w", err)
+	}
+	return f.Name(), cleanup, nil
+}
+
+func fetchHTTP(ctx context.Context, source string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+	if err != nil {
+		return fmt.Errorf("failed to build baseline request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download baseline %s: %w", redactURL(source), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download baseline %s: %s", redactURL(source), resp.Status)
+	}
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("failed to download baseline %s: %w", redactURL(source), err)
+	}
+	return nil
+}
+
+// fetchCLI downloads objects with a cloud CLI writing them to stdout.
+func fetchCLI(command ...string) func(context.Context, string, io.Writer) error {
+	return func(ctx context.Context, src string, w io.Writer) error {
+		cmd := exec.CommandContext(ctx, command[0], append(command[1:], src, "-")...)
+		cmd.Stdout = w
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("failed to download baseline %s: %w: %s", src, err, strings.TrimSpace(stderr.String()))
+		}
+		return nil
+	}
+}
+
+// redactURL hides the password in source for error messages.
+func redactURL(source string) string {
+	u, err := url.Parse(source)
+	if err != nil {
+		return source
+	}
+	return u.Redacted()
+}
+
+// openBaseline opens the database at source read-only, with the agent's
+// table prefix and key. closeBase also removes a downloaded copy.
+func (v *VectorClockAgent) openBaseline(ctx context.Context, source string) (base *VectorClockAgent, closeBase func(), err error) {
+	path, cleanup, err := fetchDatabase(ctx, source, naming{prefix: v.naming.prefix}, v.connection)
+	if err != nil {
+		return nil, nil, err
+	}
+	base, err = NewVectorClockAgent(path,
+		WithReadOnly(),
+		WithConnectionOptions(v.connection),
+		WithTablePrefix(v.naming.prefix),
+		WithVerbosity(VerbositySilent),
+	)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to open baseline %s: %w", redactURL(source), err)
+	}
+	return base, func() {
+		base.Close()
+		cleanup()
+	}, nil
+}
@@ -0,0 +1,76 @@
+package report
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+var pageTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"round": func(d time.Duration) time.Duration { return d.Round(time.Millisecond) },
+	"width": func(part, whole time.Duration) float64 {
+		if whole <= 0 {
+			return 0
+		}
+		return 100 * float64(part) / float64(whole)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Run {{.Run.RunID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+td, th { padding: 0.2em 0.8em; text-align: left; }
+.bar { background: #4a90d9; height: 0.8em; }
+.regression { color: #c0392b; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Run {{.Run.RunID}}</h1>
+<p>Total {{round .Run.Total}}{{with .Run.Metadata.Commit}} at <code>{{.}}</code>{{end}}{{with .Run.Metadata.Environment}} in {{.}}{{end}}</p>
+<h2>Scenarios</h2>
+<table>
+{{- range .Scenarios}}
+<tr><td>{{.Name}}</td><td>{{round .Duration}}</td><td style="width: 20em"><div class="bar" style="width: {{printf "%.1f" (width .Duration $.Run.Total)}}%"></div></td></tr>
+{{- end}}
+</table>
+<h2>Slowest steps</h2>
+<table>
+<tr><th>Scenario</th><th>Step</th><th>Duration</th></tr>
+{{- range .Slowest}}
+<tr><td>{{.ScenarioName}}</td><td>{{.StepText}}</td><td>{{round .Duration}}</td></tr>
+{{- end}}
+</table>
+{{- with .Comparison}}
+<h2>Compared with {{.Base}}</h2>
+<p>Total {{round .Total.Base}} → {{round .Total.Head}}</p>
+<table>
+<tr><th>Step</th><th>Base</th><th>Head</th><th>Change</th></tr>
+{{- range .Steps}}
+<tr{{if .Regression}} class="regression"{{end}}><td>{{.Name}}</td><td>{{round .Base}}</td><td>{{round .Head}}</td><td>{{printf "%+.1f%%" .Percent}}</td></tr>
+{{- end}}
+</table>
+{{- end}}
+</body>
+</html>
+`))
+
+// HTML writes snap as a standalone HTML page.
+func HTML(w io.Writer, snap Snapshot) error {
+	var buf bytes.Buffer
+	if err := pageTemplate.Execute(&buf, snap); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// markdownEscape keeps pipes in scenario and step names from breaking table
+// cells.
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
@@ -0,0 +1,104 @@
+package report
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+// jsonReport is the wire form of a Snapshot. Durations are written in
+// milliseconds and, exactly, in nanoseconds, as in the exports.
+type jsonReport struct {
+	RunID       string          `json:"run_id"`
+	Branch      string          `json:"branch,omitempty"`
+	Commit      string          `json:"commit,omitempty"`
+	Environment string          `json:"environment,omitempty"`
+	PipelineURL string          `json:"pipeline_url,omitempty"`
+	TotalMs     int64           `json:"total_ms"`
+	TotalNs     int64           `json:"total_ns"`
+	Scenarios   []jsonDuration  `json:"scenarios"`
+	Slowest     []jsonStep      `json:"slowest_steps"`
+	Comparison  *jsonComparison `json:"comparison,omitempty"`
+}
+
+type jsonDuration struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"duration_ms"`
+	DurationNs int64  `json:"duration_ns"`
+}
+
+type jsonStep struct {
+	StepID     string `json:"step_id"`
+	Scenario   string `json:"scenario"`
+	Step       string `json:"step"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	DurationNs int64  `json:"duration_ns"`
+}
+
+type jsonComparison struct {
+	Base      string      `json:"base"`
+	Threshold float64     `json:"threshold"`
+	Total     jsonDelta   `json:"total"`
+	Scenarios []jsonDelta `json:"scenarios"`
+	Steps     []jsonDelta `json:"steps"`
+}
+
+type jsonDelta struct {
+	Name       string  `json:"name"`
+	BaseNs     int64   `json:"base_ns"`
+	HeadNs     int64   `json:"head_ns"`
+	Percent    float64 `json:"percent"`
+	Regression bool    `json:"regression"`
+}
+
+func newJSONDuration(name string, d time.Duration) jsonDuration {
+	return jsonDuration{Name: name, DurationMs: d.Milliseconds(), DurationNs: d.Nanoseconds()}
+}
+
+func newJSONDeltas(deltas []vectorclocks.Delta) []jsonDelta {
+	out := make([]jsonDelta, 0, len(deltas))
+	for _, d := range deltas {
+		out = append(out, jsonDelta{Name: d.Name, BaseNs: d.Base.Nanoseconds(), HeadNs: d.Head.Nanoseconds(), Percent: d.Percent(), Regression: d.Regression})
+	}
+	return out
+}
+
+// JSON writes snap as an indented JSON object, for dashboards and scripts.
+func JSON(w io.Writer, snap Snapshot) error {
+	md := snap.Run.Metadata
+	r := jsonReport{
+		RunID:       snap.Run.RunID,
+		Branch:      md.Branch,
+		Commit:      md.Commit,
+		Environment: md.Environment,
+		PipelineURL: md.PipelineURL,
+		TotalMs:     snap.Run.Total.Milliseconds(),
+		TotalNs:     snap.Run.Total.Nanoseconds(),
+		Scenarios:   make([]jsonDuration, 0, len(snap.Scenarios)),
+		Slowest:     make([]jsonStep, 0, len(snap.Slowest)),
+	}
+	for _, s := range snap.Scenarios {
+		r.Scenarios = append(r.Scenarios, newJSONDuration(s.Name, s.Duration))
+	}
+	for _, t := range snap.Slowest {
+		r.Slowest = append(r.Slowest, jsonStep{
+			StepID: t.StepID, Scenario: t.ScenarioName, Step: t.StepText, Status: t.Status,
+			DurationMs: t.Duration.Milliseconds(), DurationNs: t.Duration.Nanoseconds(),
+		})
+	}
+	if c := snap.Comparison; c != nil {
+		r.Comparison = &jsonComparison{
+			Base:      c.Base,
+			Threshold: c.Threshold,
+			Total:     newJSONDeltas([]vectorclocks.Delta{c.Total})[0],
+			Scenarios: newJSONDeltas(c.Scenarios),
+			Steps:     newJSONDeltas(c.Steps),
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,159 @@
+// Package report renders the run reports of the vectorclocks CLI, so tools
+// such as internal developer portals can embed them without shelling out.
+//
+// A Snapshot is taken from a Store, which *vectorclocks.VectorClockAgent
+// implements, and rendered with Terminal, Markdown, HTML or JSON:
+//
+//	agent, err := vectorclocks.NewVectorClockAgent("step_timings.db")
+//	...
+//	snap, err := report.Take(agent, "", "", 10)
+//	...
+//	err = report.HTML(w, snap)
+//
+// Each format is a Reporter registered under its name; Register adds
+// custom formats, which Render then selects by name like the built-in ones.
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+// DefaultSlowestSteps is the number of slowest steps a Snapshot holds.
+const DefaultSlowestSteps = 10
+
+// Store is the read side of a timings database. *vectorclocks.VectorClockAgent
+// implements it; tools keeping timings elsewhere can implement it over their
+// own storage.
+type Store interface {
+	// RecentRuns returns the IDs of up to n runs, newest first.
+	RecentRuns(n int) ([]string, error)
+	// RunTimings returns the aggregated timings of a run.
+	RunTimings(runID string) (vectorclocks.RunTimings, error)
+	// Timings returns the individual step executions matching filter.
+	Timings(filter vectorclocks.TimingFilter) ([]vectorclocks.StepTiming, error)
+}
+
+// Snapshot is everything a report shows about one run.
+type Snapshot struct {
+	Run vectorclocks.RunTimings
+	// Scenarios are the scenarios of Run, slowest first.
+	Scenarios []Scenario
+	// Slowest are the slowest step executions of Run, slowest first.
+	Slowest []vectorclocks.StepTiming
+	// Comparison compares Run with a base run; nil without one.
+	Comparison *vectorclocks.Comparison
+}
+
+// Scenario is the total duration of a scenario in a run.
+type Scenario struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Take reads a snapshot of runID from s, compared with baseID at the given
+// regression threshold in percent. An empty runID takes the latest run,
+// and then an empty baseID compares it with the run before it; with an
+// explicit runID an empty baseID takes no comparison.
+func Take(s Store, runID, baseID string, threshold float64) (Snapshot, error) {
+	if runID == "" {
+		runs, err := s.RecentRuns(2)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		if len(runs) == 0 {
+			return Snapshot{}, errors.New("no runs recorded")
+		}
+		runID = runs[0]
+		if baseID == "" && len(runs) > 1 {
+			baseID = runs[1]
+		}
+	}
+
+	run, err := s.RunTimings(runID)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	snap := Snapshot{Run: run}
+	for name, d := range run.Scenarios {
+		snap.Scenarios = append(snap.Scenarios, Scenario{Name: name, Duration: d})
+	}
+	sort.Slice(snap.Scenarios, func(i, j int) bool {
+		if snap.Scenarios[i].Duration != snap.Scenarios[j].Duration {
+			return snap.Scenarios[i].Duration > snap.Scenarios[j].Duration
+		}
+		return snap.Scenarios[i].Name < snap.Scenarios[j].Name
+	})
+	snap.Slowest, err = s.Timings(vectorclocks.TimingFilter{
+		RunID:  runID,
+		SortBy: vectorclocks.SortByDuration,
+		Limit:  DefaultSlowestSteps,
+	})
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	if baseID != "" {
+		base, err := s.RunTimings(baseID)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		c := vectorclocks.Compare(base, run, threshold)
+		snap.Comparison = &c
+	}
+	return snap, nil
+}
+
+// Terminal writes snap as the plain-text report the CLI prints.
+func Terminal(w io.Writer, snap Snapshot) error {
+	fmt.Fprintf(w, "=== Run %s: %s ===\n", snap.Run.RunID, snap.Run.Total.Round(time.Millisecond))
+	if md := snap.Run.Metadata; md.Branch != "" || md.Commit != "" || md.Environment != "" {
+		fmt.Fprintf(w, "branch %s, commit %s, environment %s\n", orNone(md.Branch), orNone(md.Commit), orNone(md.Environment))
+	}
+	fmt.Fprintln(w, "--- Scenarios ---")
+	for _, s := range snap.Scenarios {
+		fmt.Fprintf(w, "%s: %s\n", s.Name, s.Duration.Round(time.Millisecond))
+	}
+	fmt.Fprintln(w, "--- Slowest steps ---")
+	for _, t := range snap.Slowest {
+		fmt.Fprintf(w, "%s / %s: %s\n", t.ScenarioName, t.StepText, t.Duration.Round(time.Millisecond))
+	}
+	if snap.Comparison == nil {
+		return nil
+	}
+	return vectorclocks.WriteComparison(w, *snap.Comparison)
+}
+
+// Markdown writes snap as Markdown, e.g. for a pull request comment or a
+// wiki page.
+func Markdown(w io.Writer, snap Snapshot) error {
+	fmt.Fprintf(w, "## Run `%s`\n\nTotal %s", snap.Run.RunID, snap.Run.Total.Round(time.Millisecond))
+	if md := snap.Run.Metadata; md.Commit != "" {
+		fmt.Fprintf(w, " at `%s`", md.Commit)
+	}
+	fmt.Fprint(w, "\n\n| Scenario | Duration |\n|---|---|\n")
+	for _, s := range snap.Scenarios {
+		fmt.Fprintf(w, "| %s | %s |\n", markdownEscape(s.Name), s.Duration.Round(time.Millisecond))
+	}
+	fmt.Fprint(w, "\n### Slowest steps\n\n| Scenario | Step | Duration |\n|---|---|---|\n")
+	for _, t := range snap.Slowest {
+		fmt.Fprintf(w, "| %s | %s | %s |\n", markdownEscape(t.ScenarioName), markdownEscape(t.StepText), t.Duration.Round(time.Millisecond))
+	}
+	if snap.Comparison == nil {
+		return nil
+	}
+	fmt.Fprintln(w)
+	return vectorclocks.WriteComparisonMarkdown(w, *snap.Comparison)
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,220 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/infiniteCrank/vectorColcks/vectorclocks"
+)
+
+// memoryStore is a Store over runs held in memory, newest first.
+type memoryStore struct {
+	runs  []vectorclocks.RunTimings
+	steps map[string][]vectorclocks.StepTiming
+}
+
+func (s memoryStore) RecentRuns(n int) ([]string, error) {
+	var ids []string
+	for _, r := range s.runs {
+		if len(ids) < n {
+			ids = append(ids, r.RunID)
+		}
+	}
+	return ids, nil
+}
+
+func (s memoryStore) RunTimings(runID string) (vectorclocks.RunTimings, error) {
+	for _, r := range s.runs {
+		if r.RunID == runID {
+			return r, nil
+		}
+	}
+	return vectorclocks.RunTimings{}, errors.New("no run " + runID)
+}
+
+func (s memoryStore) Timings(f vectorclocks.TimingFilter) ([]vectorclocks.StepTiming, error) {
+	return s.steps[f.RunID], nil
+}
+
+func testStore() memoryStore {
+	ms := time.Millisecond
+	run := func(id string, login, search time.Duration) vectorclocks.RunTimings {
+		return vectorclocks.RunTimings{
+			RunID:    id,
+			Metadata: vectorclocks.RunMetadata{Branch: "main", Commit: "abc123"},
+			Total:    login + search,
+			Scenarios: map[string]time.Duration{
+				"login":          login,
+				"search | <all>": search,
+			},
+			Steps: map[vectorclocks.StepKey]time.Duration{
+				{Scenario: "login", Step: "I log in"}:                  login,
+				{Scenario: "search | <all>", Step: "I search for a|b"}: search,
+			},
+		}
+	}
+	return memoryStore{
+		runs: []vectorclocks.RunTimings{run("head", 100*ms, 300*ms), run("base", 100*ms, 150*ms), run("old", 90*ms, 90*ms)},
+		steps: map[string][]vectorclocks.StepTiming{
+			"head": {
+				{StepID: "s2", ScenarioName: "search | <all>", StepText: "I search for a|b", Status: "passed", Duration: 300 * ms},
+				{StepID: "s1", ScenarioName: "login", StepText: "I log in", Status: "passed", Duration: 100 * ms},
+			},
+		},
+	}
+}
+
+func TestTake(t *testing.T) {
+	tests := []struct {
+		name          string
+		store         memoryStore
+		runID, baseID string
+		wantRun       string
+		wantBase      string
+		wantErr       bool
+	}{
+		{"latest against the run before", testStore(), "", "", "head", "base", false},
+		{"latest against an explicit base", testStore(), "", "old", "head", "old", false},
+		{"explicit run without base", testStore(), "base", "", "base", "", false},
+		{"explicit run and base", testStore(), "head", "old", "head", "old", false},
+		{"only run", memoryStore{runs: testStore().runs[:1]}, "", "", "head", "", false},
+		{"no runs", memoryStore{}, "", "", "", "", true},
+		{"unknown run", testStore(), "missing", "", "", "", true},
+	}
+	for _, tt := range tests {
+		snap, err := Take(tt.store, tt.runID, tt.baseID, 10)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: Take error = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if snap.Run.RunID != tt.wantRun {
+			t.Errorf("%s: run %s, want %s", tt.name, snap.Run.RunID, tt.wantRun)
+		}
+		base := ""
+		if snap.Comparison != nil {
+			base = snap.Comparison.Base
+		}
+		if base != tt.wantBase {
+			t.Errorf("%s: compared with %q, want %q", tt.name, base, tt.wantBase)
+		}
+	}
+
+	snap, err := Take(testStore(), "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Scenario{{"search | <all>", 300 * time.Millisecond}, {"login", 100 * time.Millisecond}}
+	if !reflect.DeepEqual(snap.Scenarios, want) {
+		t.Errorf("Scenarios = %v, want slowest first %v", snap.Scenarios, want)
+	}
+	if len(snap.Slowest) != 2 {
+		t.Errorf("Slowest holds %d steps, want 2", len(snap.Slowest))
+	}
+}
+
+func TestFormats(t *testing.T) {
+	snap, err := Take(testStore(), "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		format  string
+		want    []string
+		notWant []string
+	}{
+		{FormatText, []string{"=== Run head: 400ms ===", "branch main, commit abc123", "search | <all>: 300ms", "login / I log in: 100ms"}, nil},
+		{FormatMarkdown, []string{"## Run `head`", "at `abc123`", `| search \| <all> | 300ms |`, `| I search for a\|b |`}, nil},
+		{FormatHTML, []string{"<title>Run head</title>", "search | &lt;all&gt;", "<code>abc123</code>", `class="regression"`}, []string{"<all>"}},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := Render(tt.format, snap, &buf); err != nil {
+			t.Fatalf("%s: %v", tt.format, err)
+		}
+		out := buf.String()
+		for _, s := range tt.want {
+			if !strings.Contains(out, s) {
+				t.Errorf("%s report lacks %q:\n%s", tt.format, s, out)
+			}
+		}
+		for _, s := range tt.notWant {
+			if strings.Contains(out, s) {
+				t.Errorf("%s report contains %q", tt.format, s)
+			}
+		}
+	}
+}
+
+func TestJSON(t *testing.T) {
+	snap, err := Take(testStore(), "", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := JSON(&buf, snap); err != nil {
+		t.Fatal(err)
+	}
+	var got jsonReport
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.RunID != "head" || got.TotalMs != 400 || got.TotalNs != int64(400*time.Millisecond) || got.Commit != "abc123" {
+		t.Errorf("report = %+v", got)
+	}
+	if len(got.Scenarios) != 2 || got.Scenarios[0].Name != "search | <all>" || got.Scenarios[0].DurationMs != 300 {
+		t.Errorf("scenarios = %+v", got.Scenarios)
+	}
+	if len(got.Slowest) != 2 || got.Slowest[0].StepID != "s2" {
+		t.Errorf("slowest steps = %+v", got.Slowest)
+	}
+	c := got.Comparison
+	if c == nil || c.Base != "base" || c.Total.BaseNs != int64(250*time.Millisecond) {
+		t.Fatalf("comparison = %+v", c)
+	}
+	var regressions []string
+	for _, d := range c.Steps {
+		if d.Regression {
+			regressions = append(regressions, d.Name)
+		}
+	}
+	if want := []string{"search | <all> / I search for a|b"}; !reflect.DeepEqual(regressions, want) {
+		t.Errorf("regressed steps = %v, want %v", regressions, want)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("count", ReporterFunc(func(w io.Writer, snap Snapshot) error {
+		_, err := io.WriteString(w, snap.Run.RunID)
+		return err
+	}))
+	defer func() {
+		reportersMu.Lock()
+		delete(reporters, "count")
+		reportersMu.Unlock()
+	}()
+
+	if _, ok := Lookup("count"); !ok {
+		t.Fatal("registered reporter not found")
+	}
+	want := []string{"count", FormatHTML, FormatJSON, FormatMarkdown, FormatText}
+	if got := Formats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Formats = %v, want %v", got, want)
+	}
+	var buf bytes.Buffer
+	if err := Render("count", Snapshot{Run: vectorclocks.RunTimings{RunID: "r1"}}, &buf); err != nil || buf.String() != "r1" {
+		t.Errorf("Render = %q, %v", buf.String(), err)
+	}
+	err := Render("pdf", Snapshot{}, &buf)
+	if err == nil || !strings.Contains(err.Error(), "html, json, markdown, text") {
+		t.Errorf("Render of an unknown format = %v, want the known formats listed", err)
+	}
+}
@@ -0,0 +1,81 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Formats of the built-in reporters.
+const (
+	FormatText     = "text"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatJSON     = "json"
+)
+
+// Reporter renders a Snapshot in one output format. Register makes a
+// reporter available under a format name, so organizations can add their
+// own formats next to the built-in ones.
+type Reporter interface {
+	// RenderRun writes the report of snap to w.
+	RenderRun(snap Snapshot, w io.Writer) error
+}
+
+// ReporterFunc adapts a function such as Terminal or HTML to Reporter.
+type ReporterFunc func(w io.Writer, snap Snapshot) error
+
+// RenderRun calls f(w, snap).
+func (f ReporterFunc) RenderRun(snap Snapshot, w io.Writer) error {
+	return f(w, snap)
+}
+
+var (
+	reportersMu sync.RWMutex
+	reporters   = map[string]Reporter{
+		FormatText:     ReporterFunc(Terminal),
+		FormatMarkdown: ReporterFunc(Markdown),
+		FormatHTML:     ReporterFunc(HTML),
+		FormatJSON:     ReporterFunc(JSON),
+	}
+)
+
+// Register makes r the reporter of format name, replacing the reporter
+// registered under it before, built-in ones included. Call it from an init
+// function or before rendering.
+func Register(name string, r Reporter) {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+	reporters[name] = r
+}
+
+// Lookup returns the reporter registered under format name.
+func Lookup(name string) (Reporter, bool) {
+	reportersMu.RLock()
+	defer reportersMu.RUnlock()
+	r, ok := reporters[name]
+	return r, ok
+}
+
+// Formats returns the names of the registered reporters, sorted.
+func Formats() []string {
+	reportersMu.RLock()
+	defer reportersMu.RUnlock()
+	names := make([]string, 0, len(reporters))
+	for name := range reporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render writes snap to w with the reporter of format name.
+func Render(name string, snap Snapshot, w io.Writer) error {
+	r, ok := Lookup(name)
+	if !ok {
+		return fmt.Errorf("unknown report format %q (want %s)", name, strings.Join(Formats(), ", "))
+	}
+	return r.RenderRun(snap, w)
+}
@@ -0,0 +1,139 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithResourceLimits caps how many scenarios may hold each resource at the
+// same time, e.g. {"db:users": 1, "browser": 2}. Before a scenario starts
+// the agent looks up the resources its steps declared with Uses in earlier
+// runs and takes one token per limited resource, delaying the scenario
+// while any of them is exhausted. The delay is stored as the wait of the
+// scenario execution, separately from its duration.
+func WithResourceLimits(limits map[string]int) Option {
+	return func(v *VectorClockAgent) {
+		if len(limits) > 0 {
+			v.reservations = newReservations(limits)
+		}
+	}
+}
+
+// ParseResourceLimits parses a comma-separated list of resource=tokens pairs
+// such as "db:users=1,browser=2".
+func ParseResourceLimits(s string) (map[string]int, error) {
+	limits := make(map[string]int)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		i := strings.LastIndex(field, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("resource limit %q: want resource=tokens", field)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(field[i+1:]))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("resource limit %q: tokens must be a positive integer", field)
+		}
+		limits[strings.TrimSpace(field[:i])] = n
+	}
+	return limits, nil
+}
+
+// reservations hands out resource tokens to starting scenarios.
+type reservations struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limits map[string]int
+	inUse  map[string]int
+
+	history sync.Map // scenario name -> []string of limited resources
+}
+
+func newReservations(limits map[string]int) *reservations {
+	r := &reservations{limits: limits, inUse: make(map[string]int)}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+// acquire blocks until a token of every resource is free and takes them
+// all at once, so two scenarios never hold part of each other's resources.
+func (r *reservations) acquire(resources []string) time.Duration {
+	if len(resources) == 0 {
+		return 0
+	}
+	start := time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for !r.available(resources) {
+		r.cond.Wait()
+	}
+	for _, res := range resources {
+		r.inUse[res]++
+	}
+	return time.Since(start)
+}
+
+func (r *reservations) available(resources []string) bool {
+	for _, res := range resources {
+		if r.inUse[res] >= r.limits[res] {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *reservations) release(resources []string) {
+	if len(resources) == 0 {
+		return
+	}
+	r.mu.Lock()
+	for _, res := range resources {
+		r.inUse[res]--
+	}
+	r.mu.Unlock()
+	r.cond.Broadcast()
+}
+
+// scenarioResources returns the limited resources the steps of scenario
+// used in earlier runs. Lookups are cached for the rest of the run.
+func (v *VectorClockAgent) scenarioResources(scenario string) []string {
+	r := v.reservations
+	if cached, ok := r.history.Load(scenario); ok {
+		return cached.([]string)
+	}
+
+	rows, err := v.db.Query(`
+		SELECT DISTINCT resource FROM step_resources
+		WHERE scenario_name = ? AND COALESCE(run_id, '') != ?
+	`, scenario, v.runID)
+	if err != nil {
+		v.handleError(fmt.Errorf("failed to load resources of scenario '%s': %w", scenario, err))
+		return nil
+	}
+	defer rows.Close()
+
+	var resources []string
+	for rows.Next() {
+		var res string
+		if err := rows.Scan(&res); err != nil {
+			v.handleError(err)
+			return nil
+		}
+		if _, limited := r.limits[res]; limited {
+			resources = append(resources, res)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		v.handleError(err)
+		return nil
+	}
+	sort.Strings(resources)
+	r.history.Store(scenario, resources)
+	return resources
+}
@@ -0,0 +1,82 @@
+package vectorclocks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
+
+// preciseTimeLayout is a fixed-width UTC layout whose text order matches
+// time order, so stored instants can be compared in SQL.
+const preciseTimeLayout = "2006-01-02 15:04:05.000000000"
+
+// Uses declares that the step running in ctx touches the named resources,
+// for example "db:users" or "queue:emails". Steps of different scenarios
+// that use the same resource while running concurrently are reported by
+// Conflicts. Uses is a no-op outside a step recorded by the agent.
+func Uses(ctx context.Context, resources ...string) {
+	info, ok := ctx.Value(stepKey).(*stepInfo)
+	if !ok {
+		return
+	}
+	info.mu.Lock()
+	info.resources = append(info.resources, resources...)
+	info.mu.Unlock()
+}
+
+// ResourceConflict is a pair of scenarios whose steps used the same
+// resource at overlapping times.
+type ResourceConflict struct {
+	Resource  string
+	ScenarioA string
+	ScenarioB string
+	Overlaps  int
+}
+
+// Conflicts returns the scenario pairs of runID that used a resource
+// concurrently. They are probable sources of order-dependent failures when
+// the suite runs in parallel.
+func (v *VectorClockAgent) Conflicts(runID string) ([]ResourceConflict, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT a.resource, a.scenario_name, b.scenario_name, COUNT(*)
+		FROM step_resources a
+		JOIN step_resources b
+			ON a.run_id = b.run_id AND a.resource = b.resource AND a.scenario_name < b.scenario_name
+			AND a.started_at < b.ended_at AND b.started_at < a.ended_at
+		WHERE a.run_id = ?
+		GROUP BY a.resource, a.scenario_name, b.scenario_name
+		ORDER BY COUNT(*) DESC, a.resource
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect resource conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	var conflicts []ResourceConflict
+	for rows.Next() {
+		var c ResourceConflict
+		if err := rows.Scan(&c.Resource, &c.ScenarioA, &c.ScenarioB, &c.Overlaps); err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, rows.Err()
+}
+
+// WriteConflicts prints conflicts one per line.
+func WriteConflicts(w io.Writer, conflicts []ResourceConflict) error {
+	for _, c := range conflicts {
+		_, err := fmt.Fprintf(w, "Resource: %s, Scenarios: %s <-> %s, Overlaps: %d\n", c.Resource, c.ScenarioA, c.ScenarioB, c.Overlaps)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatPrecise(t time.Time) string {
+	return t.UTC().Format(preciseTimeLayout)
+}
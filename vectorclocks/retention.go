@@ -0,0 +1,129 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"time"
+)
+
+// retention limits how much history Prune keeps. Zero values keep
+// everything.
+type retention struct {
+	runs    int
+	days    int
+	onStart bool
+	// rawDays is the DownsampleAfter window.
+	rawDays int
+}
+
+// KeepRuns makes Prune keep only the n most recent runs.
+func KeepRuns(n int) Option {
+	return func(v *VectorClockAgent) {
+		v.retention.runs = n
+	}
+}
+
+// KeepDays makes Prune delete runs whose last step was recorded more than
+// d days ago.
+func KeepDays(d int) Option {
+	return func(v *VectorClockAgent) {
+		v.retention.days = d
+	}
+}
+
+// PruneOnStart runs Prune when the agent is created.
+func PruneOnStart() Option {
+	return func(v *VectorClockAgent) {
+		v.retention.onStart = true
+	}
+}
+
+// Prune deletes the runs that fall outside the KeepRuns/KeepDays retention
+// from every table holding rows of a run, and vacuums the database. Runs
+// are ordered by when they started; steps written before runs were
+// tracked count as a single run, dated by their last step. It returns the
+// number of deleted step rows.
+func (v *VectorClockAgent) Prune() (int64, error) {
+	if v.retention.runs <= 0 && v.retention.days <= 0 {
+		return 0, nil
+	}
+	v.sync()
+
+	runIDs, err := v.prunedRuns()
+	if err != nil {
+		return 0, err
+	}
+	if len(runIDs) == 0 {
+		return 0, nil
+	}
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var deleted int64
+	for _, runID := range runIDs {
+		for _, table := range runTables {
+			query := `DELETE FROM ` + table + ` WHERE run_id = ?`
+			if table == "step_timings" {
+				query = `DELETE FROM step_timings WHERE COALESCE(run_id, '') = ?`
+			}
+			res, err := tx.Exec(query, runID)
+			if err != nil {
+				return 0, fmt.Errorf("failed to prune %s of run %s: %w", table, runID, err)
+			}
+			if table == "step_timings" {
+				n, _ := res.RowsAffected()
+				deleted += n
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to prune runs: %w", err)
+	}
+
+	if _, err := v.db.Exec(fmt.Sprintf(`VACUUM %q`, v.naming.schemaName())); err != nil {
+		return deleted, fmt.Errorf("failed to vacuum: %w", err)
+	}
+	v.logger.Debug("pruned runs", "runs", len(runIDs), "step_rows", deleted)
+	return deleted, nil
+}
+
+// prunedRuns returns the IDs of the runs outside the retention, "" for the
+// steps written before runs were tracked.
+func (v *VectorClockAgent) prunedRuns() ([]string, error) {
+	keep, cutoff := -1, ""
+	if v.retention.runs > 0 {
+		keep = v.retention.runs
+	}
+	if v.retention.days > 0 {
+		cutoff = time.Now().UTC().AddDate(0, 0, -v.retention.days).Format(sqliteTimeLayout)
+	}
+	rows, err := v.db.Query(`
+		SELECT run_id FROM (
+			SELECT run_id, at, ROW_NUMBER() OVER (ORDER BY at DESC, seq DESC) AS position
+			FROM (
+				SELECT run_id, started_at AS at, rowid AS seq FROM runs
+				UNION ALL
+				SELECT COALESCE(run_id, ''), MAX(created_at), 0 FROM step_timings
+				WHERE run_id IS NULL OR run_id NOT IN (SELECT run_id FROM runs)
+				GROUP BY COALESCE(run_id, '')
+			)
+		)
+		WHERE (? > 0 AND position > ?) OR at < ?
+	`, keep, keep, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select runs to prune: %w", err)
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	return runIDs, rows.Err()
+}
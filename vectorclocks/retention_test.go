@@ -0,0 +1,135 @@
+package vectorclocks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+// fakeClock advances by tick, or a millisecond when tick is zero, on every
+// reading.
+type fakeClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	tick time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tick > 0 {
+		c.now = c.now.Add(c.tick)
+	} else {
+		c.now = c.now.Add(time.Millisecond)
+	}
+	return c.now
+}
+
+func TestPruneDeletesEveryTableOfARun(t *testing.T) {
+	_, dbPath := newTestAgent(t)
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 4; i++ {
+		steps := 0
+		a, err := NewVectorClockAgent(dbPath,
+			WithVerbosity(VerbositySilent),
+			WithClock(&fakeClock{now: start.Add(time.Duration(i) * time.Minute)}),
+			WithIDGenerator(func(string, string) string {
+				steps++
+				return fmt.Sprintf("run%d-step%d", i, steps)
+			}),
+			WithCapturedOutput(),
+			WithFeatureFlags(map[string]string{"checkout": "on"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		status := runFeature(t, a, 1, `Feature: retention
+  Scenario: one
+    Given a step using a database
+`, func(ctx *godog.ScenarioContext) {
+			a.Step(ctx, `^a step using a database$`, func(ctx context.Context) error {
+				Uses(ctx, "db")
+				_, end := a.Span(ctx, "query")
+				end()
+				return nil
+			})
+		})
+		if status != 0 {
+			t.Fatalf("run %d: suite status = %d, want 0", i, status)
+		}
+		if err := a.Close(); err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+	}
+
+	a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent), KeepRuns(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	latest, err := a.LatestRunID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	for _, table := range runTables {
+		var runs, rows int
+		var runID string
+		err := a.db.QueryRow(`SELECT COUNT(DISTINCT run_id), COUNT(*), COALESCE(MAX(run_id), '') FROM `+table).Scan(&runs, &rows, &runID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rows == 0 {
+			t.Errorf("%s: no rows recorded", table)
+		}
+		if runs != 1 || runID != latest {
+			t.Errorf("%s: kept %d runs (%s), want only %s", table, runs, runID, latest)
+		}
+	}
+}
+
+func TestDownsampleKeepsNanoseconds(t *testing.T) {
+	a, _ := newTestAgent(t, DownsampleAfter(1))
+	defer a.Close()
+	start := time.Now().AddDate(0, 0, -3)
+	var batch []stepRecord
+	for i, d := range []time.Duration{300 * time.Microsecond, 700 * time.Microsecond} {
+		batch = append(batch, stepRecord{
+			stepID:       fmt.Sprintf("s%d", i),
+			runID:        "r1",
+			scenarioName: "quick",
+			stepText:     "a quick step",
+			phase:        phasePrimary,
+			duration:     d,
+			startedAt:    start,
+			endedAt:      start.Add(d),
+		})
+	}
+	if err := a.writeBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+	day := start.UTC().Format("2006-01-02")
+	if _, err := a.db.Exec(`UPDATE step_timings SET created_at = ?`, day+" 12:00:00"); err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted, err := a.Downsample(); err != nil || deleted != 2 {
+		t.Fatalf("Downsample = %d, %v; want 2 rows", deleted, err)
+	}
+	days, err := a.DailyAggregates(start.AddDate(0, 0, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(days) != 1 {
+		t.Fatalf("got %d daily aggregates, want 1", len(days))
+	}
+	got := days[0]
+	if got.Count != 2 || got.Mean != 500*time.Microsecond || got.Min != 300*time.Microsecond || got.Max != 700*time.Microsecond {
+		t.Errorf("daily aggregate = %+v, want 2 steps of 300µs to 700µs", got)
+	}
+}
@@ -0,0 +1,314 @@
+package vectorclocks
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+const (
+	phasePrimary = "primary"
+	phaseRetry   = "retry"
+)
+
+// primaryPhase is a SQL condition selecting only rows of the primary phase,
+// so that statistics are never skewed by re-runs of failed scenarios.
+const primaryPhase = `COALESCE(phase, 'primary') = 'primary'`
+
+// WithRetries makes RunSuite re-run the scenarios that failed, up to n
+// times. Re-runs are stored in the "retry" phase and excluded from
+// statistics.
+func WithRetries(n int) Option {
+	return func(v *VectorClockAgent) {
+		v.retries = n
+	}
+}
+
+// attemptNumber is 1 while the suite runs for the first time and n+1 during
+// the n-th retry.
+func (v *VectorClockAgent) attemptNumber() int {
+	if n := int(v.attempt.Load()); n > 1 {
+		return n
+	}
+	return 1
+}
+
+func (v *VectorClockAgent) phase() string {
+	if v.benchmarking.Load() {
+		return phaseBenchmark
+	}
+	if v.retrying.Load() {
+		return phaseRetry
+	}
+	return phasePrimary
+}
+
+// failedScenario identifies a scenario to re-run. line is the line of the
+// scenario, or of the Examples row for a row of an outline, so that the
+// rows of one outline are told apart, and scenarioLine the line of the
+// scenario or outline itself, by which godog selects it; both are 0 when
+// the feature file cannot be read.
+type failedScenario struct {
+	featureURI   string
+	name         string
+	line         int
+	scenarioLine int
+}
+
+// retryKey identifies the scenario of info among the failures.
+func (info scenarioInfo) retryKey() failedScenario {
+	line := info.line
+	if info.example.line > 0 {
+		line = info.example.line
+	}
+	return failedScenario{featureURI: info.featureURI, name: info.name, line: line, scenarioLine: info.line}
+}
+
+// noteFailure queues a failed scenario for the next retry. During a retry
+// only the scenarios being retried are queued again: the others ran because
+// godog re-runs whole outlines, or whole features when a scenario's line is
+// unknown, and their failures were never counted.
+func (v *VectorClockAgent) noteFailure(f failedScenario) {
+	v.failuresMu.Lock()
+	defer v.failuresMu.Unlock()
+	if v.retrying.Load() && !v.retried[f] {
+		return
+	}
+	if v.failures == nil {
+		v.failures = make(map[failedScenario]bool)
+	}
+	v.failures[f] = true
+}
+
+// takeFailures returns the queued failures and makes them the scenarios
+// the next retry is about.
+func (v *VectorClockAgent) takeFailures() []failedScenario {
+	v.failuresMu.Lock()
+	defer v.failuresMu.Unlock()
+	failed := make([]failedScenario, 0, len(v.failures))
+	for f := range v.failures {
+		failed = append(failed, f)
+	}
+	v.retried, v.failures = v.failures, nil
+	sort.Slice(failed, func(i, j int) bool {
+		if failed[i].featureURI != failed[j].featureURI {
+			return failed[i].featureURI < failed[j].featureURI
+		}
+		if failed[i].name != failed[j].name {
+			return failed[i].name < failed[j].name
+		}
+		return failed[i].line < failed[j].line
+	})
+	return failed
+}
+
+// RunSuite runs suite and, when WithRetries is set, re-runs the scenarios
+// that failed until they pass or the retries are used up. With
+// WithBenchmarks the @benchmark scenarios are then repeated. It returns the
+// godog status of the last attempt, RegressionExitCode when the suite
+// passed but WithRegressionGate or WithBaselineFile found timing
+// regressions, GateErrorExitCode when it passed but the regression gate
+// could not be checked, or BudgetExitCode when it passed but exceeded a
+// failing WithTagBudgets budget. Notifiers are sent the final status.
+func (v *VectorClockAgent) RunSuite(suite godog.TestSuite) int {
+	v.concurrency = 1
+	if suite.Options != nil && suite.Options.Concurrency > 1 {
+		v.concurrency = suite.Options.Concurrency
+	}
+	v.strict = suite.Options != nil && suite.Options.Strict
+	if v.output != nil {
+		suite = v.output.capture(suite)
+	}
+	if v.eta != nil {
+		var paths []string
+		if suite.Options != nil {
+			paths = suite.Options.Paths
+		}
+		v.startETA(paths)
+	}
+	status := suite.Run()
+
+	for attempt := 1; attempt <= v.retries && status != 0; attempt++ {
+		failed := v.takeFailures()
+		if len(failed) == 0 {
+			break
+		}
+		v.retrying.Store(true)
+		v.attempt.Store(int32(attempt + 1))
+		v.logger.Info("retrying failed scenarios", "scenarios", len(failed), "attempt", attempt, "of", v.retries)
+
+		var opts godog.Options
+		if suite.Options != nil {
+			opts = *suite.Options
+		}
+		opts.Paths = retryPaths(failed)
+		retry := suite
+		retry.Options = &opts
+		status = retry.Run()
+	}
+
+	if v.benchmark != nil {
+		v.runBenchmarks(suite)
+		v.reportBenchmarks()
+	}
+
+	if v.gate != nil {
+		status = v.applyGate(status)
+	}
+	if v.baselineFile != "" {
+		status = v.applyBaselineFile(status)
+	}
+	if v.budgets != nil {
+		status = v.applyBudgets(status)
+	}
+	if len(v.notifiers) > 0 {
+		v.notify(status)
+	}
+	return status
+}
+
+// retryPaths turns failed scenarios into godog paths. A scenario is
+// addressed as "file:line" by the line it was run from, or else by the
+// first scenario of its name in the feature file; when neither is known
+// the whole feature is re-run. godog selects an outline only as a whole, so
+// a failed Examples row re-runs the outline it belongs to.
+func retryPaths(failed []failedScenario) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, f := range failed {
+		p := f.featureURI
+		if f.scenarioLine > 0 {
+			p = fmt.Sprintf("%s:%d", f.featureURI, f.scenarioLine)
+		} else if line, ok := scenarioLine(f.featureURI, f.name); ok {
+			p = fmt.Sprintf("%s:%d", f.featureURI, line)
+		}
+		if !seen[p] {
+			seen[p] = true
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+var scenarioKeywords = []string{"Scenario Outline:", "Scenario Template:", "Scenario:", "Example:"}
+
+// scenarioLine finds the line declaring the scenario called name.
+func scenarioLine(featureURI, name string) (int, bool) {
+	f, err := os.Open(featureURI)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		for _, kw := range scenarioKeywords {
+			if strings.HasPrefix(text, kw) && strings.TrimSpace(text[len(kw):]) == name {
+				return line, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// scenarioRetried updates the summary counters for a re-run scenario: a
+// retried scenario that passes no longer counts as failed. Scenarios that
+// only ran alongside it leave the counters alone.
+func (v *VectorClockAgent) scenarioRetried(f failedScenario, failed bool) {
+	if failed {
+		return
+	}
+	v.failuresMu.Lock()
+	retried := v.retried[f]
+	delete(v.retried, f)
+	v.failuresMu.Unlock()
+	if retried {
+		atomic.AddUint64(&v.failed, ^uint64(0))
+	}
+}
+
+// RetriedStep is a step that ran more than once in a run because its
+// scenario was retried.
+type RetriedStep struct {
+	Key StepKey
+	// First is the duration and status of the first attempt.
+	First       time.Duration
+	FirstStatus string
+	// Retries are the durations of the later attempts in order; Attempts
+	// counts all of them including the first.
+	Retries    []time.Duration
+	Attempts   int
+	LastStatus string
+}
+
+// RetriedSteps returns the steps of runID that were retried, with their
+// first attempt reported apart from the retries.
+func (v *VectorClockAgent) RetriedSteps(runID string) ([]RetriedStep, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT scenario_name, step_text, COALESCE(attempt, 1), COALESCE(status, ''), `+durationNs+`
+		FROM step_timings
+		WHERE run_id = ? AND (scenario_name, step_text) IN (
+			SELECT scenario_name, step_text FROM step_timings WHERE run_id = ? AND attempt > 1 AND phase = 'retry'
+		) AND COALESCE(phase, 'primary') != 'benchmark'
+		ORDER BY scenario_name, step_text, attempt, id
+	`, runID, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load retried steps of run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var steps []RetriedStep
+	for rows.Next() {
+		var key StepKey
+		var attempt int
+		var status string
+		var durationNs int64
+		if err := rows.Scan(&key.Scenario, &key.Step, &attempt, &status, &durationNs); err != nil {
+			return nil, err
+		}
+		d := time.Duration(durationNs)
+		if len(steps) == 0 || steps[len(steps)-1].Key != key {
+			steps = append(steps, RetriedStep{Key: key})
+		}
+		s := &steps[len(steps)-1]
+		if attempt <= 1 && s.Attempts == 0 {
+			s.First, s.FirstStatus = d, status
+		} else {
+			s.Retries = append(s.Retries, d)
+		}
+		s.Attempts++
+		s.LastStatus = status
+	}
+	return steps, rows.Err()
+}
+
+// WriteRetriedSteps prints steps one per line: the first attempt, then the
+// retries.
+func WriteRetriedSteps(w io.Writer, steps []RetriedStep) error {
+	if len(steps) == 0 {
+		_, err := fmt.Fprintln(w, "no retried steps")
+		return err
+	}
+	for _, s := range steps {
+		retries := make([]string, len(s.Retries))
+		for i, d := range s.Retries {
+			retries[i] = d.Round(time.Millisecond).String()
+		}
+		_, err := fmt.Fprintf(w, "%s: first %s (%s), retries %s -> %s\n",
+			s.Key, s.First.Round(time.Millisecond), s.FirstStatus, strings.Join(retries, ", "), s.LastStatus)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
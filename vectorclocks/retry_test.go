@@ -0,0 +1,145 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cucumber/godog"
+)
+
+// runRetried runs feature from a file, so that retries can address its
+// scenarios, and fails the steps "<name> fails <n> times" n times per
+// scenario name before they pass.
+func runRetried(t *testing.T, a *VectorClockAgent, feature string) int {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "retry.feature")
+	if err := os.WriteFile(path, []byte(feature), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	runs := make(map[string]int)
+	return a.RunSuite(godog.TestSuite{
+		Name: t.Name(),
+		ScenarioInitializer: func(ctx *godog.ScenarioContext) {
+			a.InitializeScenario(ctx)
+			ctx.Step(`^(.+) fails (\d+) times?$`, func(name string, n int) error {
+				mu.Lock()
+				defer mu.Unlock()
+				runs[name]++
+				if runs[name] <= n {
+					return fmt.Errorf("%s failed on run %d", name, runs[name])
+				}
+				return nil
+			})
+			ctx.Step(`^it passes$`, func() error { return nil })
+		},
+		Options: &godog.Options{
+			Format: "progress",
+			Output: io.Discard,
+			Strict: true,
+			Paths:  []string{path},
+		},
+	})
+}
+
+func TestRetryCountsOnlyRetriedScenarios(t *testing.T) {
+	tests := []struct {
+		name       string
+		retries    int
+		feature    string
+		wantStatus bool
+		wantFailed uint64
+	}{
+		{
+			name:    "passing sibling in a re-run feature",
+			retries: 1,
+			// The outline's row names cannot be found in the file, so
+			// the whole feature is re-run.
+			feature: `Feature: retries
+  Scenario: sibling
+    Given it passes
+
+  Scenario Outline: row <n>
+    Given row <n> fails <times> times
+
+    Examples:
+      | n | times |
+      | 1 | 1     |
+      | 2 | 0     |
+`,
+			wantStatus: true,
+			wantFailed: 0,
+		},
+		{
+			name:    "scenario still failing after retries",
+			retries: 2,
+			feature: `Feature: retries
+  Scenario: sibling
+    Given it passes
+
+  Scenario: broken
+    Given broken fails 5 times
+`,
+			wantFailed: 1,
+		},
+		{
+			name:    "one row of an outline recovers",
+			retries: 1,
+			feature: `Feature: retries
+  Scenario Outline: outline
+    Given <name> fails <times> times
+
+    Examples:
+      | name   | times |
+      | flaky  | 1     |
+      | broken | 5     |
+      | fine   | 0     |
+`,
+			wantFailed: 1,
+		},
+		{
+			name:    "scenarios sharing a name",
+			retries: 1,
+			feature: `Feature: retries
+  Scenario: same
+    Given it passes
+
+  Scenario: same
+    Given broken fails 5 times
+`,
+			wantFailed: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := newTestAgent(t, WithRetries(tt.retries))
+			defer a.Close()
+
+			status := runRetried(t, a, tt.feature)
+			if passed := status == 0; passed != tt.wantStatus {
+				t.Errorf("suite status = %d, want passed = %t", status, tt.wantStatus)
+			}
+			if failed := atomic.LoadUint64(&a.failed); failed != tt.wantFailed {
+				t.Errorf("failed = %d, want %d; %s", failed, tt.wantFailed, a.Summary())
+			}
+		})
+	}
+}
+
+func TestRetryKeyTellsExampleRowsApart(t *testing.T) {
+	outline := scenarioInfo{featureURI: "f.feature", name: "outline", line: 2}
+	row1, row2 := outline, outline
+	row1.example.line, row2.example.line = 7, 8
+	if row1.retryKey() == row2.retryKey() {
+		t.Errorf("rows 7 and 8 share the key %+v", row1.retryKey())
+	}
+	if got := outline.retryKey().line; got != 2 {
+		t.Errorf("scenario key line = %d, want 2", got)
+	}
+}
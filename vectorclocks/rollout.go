@@ -0,0 +1,161 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RolloutPolicy controls how NextConcurrency raises godog concurrency across
+// scheduled runs.
+type RolloutPolicy struct {
+	// Start is the first concurrency tried. Serial execution is assumed
+	// safe.
+	Start int
+	// Max is the highest concurrency tried.
+	Max int
+	// Step is how much the concurrency grows after a level proves safe.
+	Step int
+	// RunsPerLevel is how many runs a level must complete before it is
+	// judged.
+	RunsPerLevel int
+	// MaxFailureIncrease is how much higher the scenario failure rate may
+	// be than at the last safe level, e.g. 0.02 for two percentage points.
+	MaxFailureIncrease float64
+}
+
+// DefaultRolloutPolicy steps from 2 up to 8 one scenario at a time, judging
+// each level after 5 runs.
+var DefaultRolloutPolicy = RolloutPolicy{Start: 2, Max: 8, Step: 1, RunsPerLevel: 5, MaxFailureIncrease: 0.02}
+
+// Rollout is the persisted state of a progressive concurrency rollout.
+type Rollout struct {
+	// Level is the concurrency runs should currently use.
+	Level int
+	// MaxSafe is the highest concurrency that did not raise the failure
+	// rate.
+	MaxSafe int
+	// Ceiling is the lowest concurrency that raised the failure rate, or
+	// zero if none has yet.
+	Ceiling int
+	// Since is when Level was chosen.
+	Since time.Time
+}
+
+// NextConcurrency returns the concurrency the next run should use. Once the
+// current level has completed policy.RunsPerLevel runs it compares their
+// scenario failure rate with the runs at the last safe level: if it did not
+// rise by more than policy.MaxFailureIncrease the level becomes the new safe
+// maximum and the next level is tried, otherwise the rollout backs off to
+// the safe maximum and never tries the failing level again. The runs must
+// be started through RunSuite so that their concurrency is recorded.
+func (v *VectorClockAgent) NextConcurrency(policy RolloutPolicy) (int, error) {
+	v.sync()
+
+	state, err := v.Rollout()
+	if errors.Is(err, sql.ErrNoRows) {
+		state = Rollout{Level: policy.Start, MaxSafe: 1, Since: time.Now()}
+		if state.Level < 1 {
+			state.Level = 1
+		}
+		return state.Level, v.saveRollout(state)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var runs int
+	err = v.db.QueryRow(`
+		SELECT COUNT(*) FROM runs WHERE concurrency = ? AND started_at >= ?
+	`, state.Level, state.Since.UTC().Format(sqliteTimeLayout)).Scan(&runs)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rollout runs: %w", err)
+	}
+	if runs < policy.RunsPerLevel || state.Level == state.MaxSafe {
+		return state.Level, nil
+	}
+
+	current, err := v.failureRate(state.Level, state.Since)
+	if err != nil {
+		return 0, err
+	}
+	safe, err := v.failureRate(state.MaxSafe, time.Time{})
+	if err != nil {
+		return 0, err
+	}
+
+	if current <= safe+policy.MaxFailureIncrease {
+		state.MaxSafe = state.Level
+		next := state.Level + policy.Step
+		if next > policy.Max {
+			next = policy.Max
+		}
+		if state.Ceiling > 0 && next >= state.Ceiling {
+			next = state.Ceiling - 1
+		}
+		if next > state.Level {
+			state.Level = next
+		}
+		v.logger.Info(fmt.Sprintf("concurrency %d is safe (%.1f%% failures vs %.1f%%), next level %d",
+			state.MaxSafe, current*100, safe*100, state.Level))
+	} else {
+		state.Ceiling = state.Level
+		state.Level = state.MaxSafe
+		v.logger.Warn(fmt.Sprintf("concurrency %d raised failures to %.1f%% (from %.1f%%), backing off to %d",
+			state.Ceiling, current*100, safe*100, state.Level))
+	}
+	state.Since = time.Now()
+	return state.Level, v.saveRollout(state)
+}
+
+// Rollout returns the persisted rollout state. It returns sql.ErrNoRows
+// before the first call to NextConcurrency.
+func (v *VectorClockAgent) Rollout() (Rollout, error) {
+	var r Rollout
+	var ceiling sql.NullInt64
+	var since timestamp
+	err := v.db.QueryRow(`
+		SELECT level, max_safe, ceiling, level_since FROM concurrency_rollout WHERE id = 1
+	`).Scan(&r.Level, &r.MaxSafe, &ceiling, &since)
+	if err != nil {
+		return r, err
+	}
+	r.Ceiling = int(ceiling.Int64)
+	r.Since = since.Time
+	return r, nil
+}
+
+func (v *VectorClockAgent) saveRollout(r Rollout) error {
+	var ceiling sql.NullInt64
+	if r.Ceiling > 0 {
+		ceiling = sql.NullInt64{Int64: int64(r.Ceiling), Valid: true}
+	}
+	_, err := v.db.Exec(`
+		INSERT OR REPLACE INTO concurrency_rollout (id, level, max_safe, ceiling, level_since, updated_at)
+		VALUES (1, ?, ?, ?, ?, CURRENT_TIMESTAMP)
+	`, r.Level, r.MaxSafe, ceiling, r.Since.UTC().Format(sqliteTimeLayout))
+	if err != nil {
+		return fmt.Errorf("failed to save rollout state: %w", err)
+	}
+	return nil
+}
+
+// failureRate is the share of primary-phase scenario executions that failed
+// in runs at concurrency started at or after since.
+func (v *VectorClockAgent) failureRate(concurrency int, since time.Time) (float64, error) {
+	var total, failed int
+	err := v.db.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(failed), 0) FROM (
+			SELECT MAX(COALESCE(s.status, '') = 'failed') AS failed
+			FROM step_timings s
+			JOIN runs r ON r.run_id = s.run_id
+			WHERE r.concurrency = ? AND r.started_at >= ? AND `+primaryPhase+`
+			GROUP BY s.run_id, s.scenario_name
+		)
+	`, concurrency, since.UTC().Format(sqliteTimeLayout)).Scan(&total, &failed)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute failure rate: %w", err)
+	}
+	return rate(failed, total), nil
+}
@@ -0,0 +1,30 @@
+package vectorclocks
+
+import "time"
+
+// RoundingPolicy coarsens durations before they are persisted. Rounded
+// values make the database compress better and keep run-to-run diffs from
+// being dominated by scheduler jitter.
+type RoundingPolicy struct {
+	// Round is the bucket width durations are rounded to; zero disables
+	// rounding.
+	Round time.Duration
+	// ExactAbove keeps durations at or above this value exact, so slow
+	// outliers remain precise; zero rounds everything.
+	ExactAbove time.Duration
+}
+
+func (p RoundingPolicy) apply(d time.Duration) time.Duration {
+	if p.Round <= 0 || (p.ExactAbove > 0 && d >= p.ExactAbove) {
+		return d
+	}
+	return d.Round(p.Round)
+}
+
+// WithRounding sets the rounding policy applied when steps are written.
+// In-memory durations are never rounded.
+func WithRounding(policy RoundingPolicy) Option {
+	return func(v *VectorClockAgent) {
+		v.rounding = policy
+	}
+}
@@ -0,0 +1,201 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Verdicts of ParallelSafety.
+const (
+	ParallelSafe    = "parallel-safe"
+	ParallelUnsafe  = "unsafe"
+	ParallelUnknown = "unknown"
+)
+
+const (
+	// minParallelRuns is how many parallel executions a scenario needs
+	// before it can be declared parallel-safe.
+	minParallelRuns = 5
+	// unsafeMargin is how much higher the parallel failure rate must be
+	// than the serial one to call a scenario unsafe.
+	unsafeMargin = 0.05
+)
+
+// ScenarioSafety is the parallel-safety verdict of one scenario with the
+// evidence it is based on.
+type ScenarioSafety struct {
+	Scenario         string
+	Verdict          string
+	Reason           string
+	SerialRuns       int
+	SerialFailures   int
+	ParallelRuns     int
+	ParallelFailures int
+}
+
+// ParallelSafety classifies every scenario as parallel-safe, unsafe or
+// unknown from the recorded history and stores the verdicts in the
+// parallel_safety table. A scenario is unsafe when it failed in a run where
+// it used a resource concurrently with another scenario, or when it fails
+// noticeably more often with concurrency than without. It is parallel-safe
+// once it has run in parallel often enough without either. Only runs
+// started through RunSuite know their concurrency and are considered.
+func (v *VectorClockAgent) ParallelSafety() ([]ScenarioSafety, error) {
+	v.sync()
+
+	contended, err := v.contendedExecutions()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := v.db.Query(`
+		SELECT s.run_id, s.scenario_name, MAX(COALESCE(s.status, '') = 'failed'), r.concurrency
+		FROM step_timings s
+		JOIN runs r ON r.run_id = s.run_id
+		WHERE r.concurrency IS NOT NULL AND ` + primaryPhase + `
+		GROUP BY s.run_id, s.scenario_name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scenario executions: %w", err)
+	}
+	defer rows.Close()
+
+	type evidence struct {
+		ScenarioSafety
+		contended, contendedFailures int
+	}
+	byScenario := make(map[string]*evidence)
+	for rows.Next() {
+		var runID, scenario string
+		var failed bool
+		var concurrency int
+		if err := rows.Scan(&runID, &scenario, &failed, &concurrency); err != nil {
+			return nil, err
+		}
+		e, ok := byScenario[scenario]
+		if !ok {
+			e = &evidence{ScenarioSafety: ScenarioSafety{Scenario: scenario}}
+			byScenario[scenario] = e
+		}
+		if concurrency <= 1 {
+			e.SerialRuns++
+			if failed {
+				e.SerialFailures++
+			}
+			continue
+		}
+		e.ParallelRuns++
+		if failed {
+			e.ParallelFailures++
+		}
+		if contended[[2]string{runID, scenario}] {
+			e.contended++
+			if failed {
+				e.contendedFailures++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	verdicts := make([]ScenarioSafety, 0, len(byScenario))
+	for _, e := range byScenario {
+		s := e.ScenarioSafety
+		serialRate := rate(s.SerialFailures, s.SerialRuns)
+		parallelRate := rate(s.ParallelFailures, s.ParallelRuns)
+		switch {
+		case e.contendedFailures > 0:
+			s.Verdict = ParallelUnsafe
+			s.Reason = fmt.Sprintf("failed %d times while sharing a resource with a concurrent scenario", e.contendedFailures)
+		case s.ParallelFailures > 0 && parallelRate > serialRate+unsafeMargin:
+			s.Verdict = ParallelUnsafe
+			s.Reason = fmt.Sprintf("fails %.0f%% of parallel runs vs %.0f%% of serial runs", parallelRate*100, serialRate*100)
+		case s.ParallelRuns < minParallelRuns:
+			s.Verdict = ParallelUnknown
+			s.Reason = fmt.Sprintf("only %d of %d parallel runs needed", s.ParallelRuns, minParallelRuns)
+		case e.contended > 0:
+			s.Verdict = ParallelUnknown
+			s.Reason = fmt.Sprintf("shared a resource with a concurrent scenario in %d runs", e.contended)
+		default:
+			s.Verdict = ParallelSafe
+			s.Reason = fmt.Sprintf("%d parallel runs without concurrency-related failures", s.ParallelRuns)
+		}
+		verdicts = append(verdicts, s)
+	}
+	sort.Slice(verdicts, func(i, j int) bool { return verdicts[i].Scenario < verdicts[j].Scenario })
+
+	if err := v.storeSafety(verdicts); err != nil {
+		return nil, err
+	}
+	return verdicts, nil
+}
+
+// contendedExecutions returns the (run, scenario) pairs that used a resource
+// at the same time as another scenario.
+func (v *VectorClockAgent) contendedExecutions() (map[[2]string]bool, error) {
+	rows, err := v.db.Query(`
+		SELECT DISTINCT a.run_id, a.scenario_name
+		FROM step_resources a
+		JOIN step_resources b
+			ON a.run_id = b.run_id AND a.resource = b.resource AND a.scenario_name != b.scenario_name
+			AND a.started_at < b.ended_at AND b.started_at < a.ended_at
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect resource conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	contended := make(map[[2]string]bool)
+	for rows.Next() {
+		var key [2]string
+		if err := rows.Scan(&key[0], &key[1]); err != nil {
+			return nil, err
+		}
+		contended[key] = true
+	}
+	return contended, rows.Err()
+}
+
+func (v *VectorClockAgent) storeSafety(verdicts []ScenarioSafety) error {
+	if v.readOnly {
+		return nil
+	}
+	tx, err := v.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, s := range verdicts {
+		_, err := tx.Exec(`
+			INSERT OR REPLACE INTO parallel_safety
+				(scenario_name, verdict, reason, serial_runs, serial_failures, parallel_runs, parallel_failures)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, s.Scenario, s.Verdict, s.Reason, s.SerialRuns, s.SerialFailures, s.ParallelRuns, s.ParallelFailures)
+		if err != nil {
+			return fmt.Errorf("failed to store parallel-safety verdict: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// WriteSafety prints verdicts one per line.
+func WriteSafety(w io.Writer, verdicts []ScenarioSafety) error {
+	for _, s := range verdicts {
+		_, err := fmt.Fprintf(w, "%-13s %s (serial %d/%d failed, parallel %d/%d failed): %s\n",
+			s.Verdict, s.Scenario, s.SerialFailures, s.SerialRuns, s.ParallelFailures, s.ParallelRuns, s.Reason)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
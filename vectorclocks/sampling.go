@@ -0,0 +1,155 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SampledScenario is one scenario picked for a sampling profile. FeatureURI
+// and Line locate it; Line is 0 when it is unknown.
+type SampledScenario struct {
+	Name       string
+	FeatureURI string
+	Line       int
+	Expected   time.Duration
+	Steps      int
+}
+
+// Path returns the godog path running the scenario: "file:line", or the
+// whole feature file when its line is unknown.
+func (s SampledScenario) Path() string {
+	if s.Line > 0 {
+		return fmt.Sprintf("%s:%d", s.FeatureURI, s.Line)
+	}
+	return s.FeatureURI
+}
+
+// SampleProfile is a fast subset of scenarios that still exercises most of
+// the step definitions seen in historical runs.
+type SampleProfile struct {
+	Scenarios      []SampledScenario
+	Expected       time.Duration
+	Coverage       float64
+	TotalScenarios int
+}
+
+// Paths returns the godog paths running exactly the sampled scenarios, for
+// --godog.paths or "run --paths".
+func (p SampleProfile) Paths() []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, s := range p.Scenarios {
+		if path := s.Path(); !seen[path] {
+			seen[path] = true
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// SampleProfile greedily picks the scenarios that add the most unseen step
+// texts per second of historical runtime, until coverage (0..1) of all known
+// step texts is reached or nothing more fits in budget. Scenarios are told
+// apart by feature file and line, so scenarios sharing a name are sampled
+// separately; steps recorded without a feature file cannot be run by path
+// and are left out.
+func (v *VectorClockAgent) SampleProfile(budget time.Duration, coverage float64) (SampleProfile, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`SELECT COALESCE(run_id, ''), feature_uri, COALESCE(scenario_line, 0), scenario_name, step_text, ` + durationNs + `
+		FROM step_timings WHERE ` + primaryPhase + ` AND COALESCE(feature_uri, '') != ''`)
+	if err != nil {
+		return SampleProfile{}, fmt.Errorf("failed to load history: %w", err)
+	}
+	defer rows.Close()
+
+	type history struct {
+		scenario SampledScenario
+		steps    map[string]bool
+		total    time.Duration
+		runs     map[string]bool
+	}
+	scenarios := make(map[string]*history)
+	allSteps := make(map[string]bool)
+	for rows.Next() {
+		var runID, featureURI, scenarioName, stepText string
+		var line int
+		var durationNs int64
+		if err := rows.Scan(&runID, &featureURI, &line, &scenarioName, &stepText, &durationNs); err != nil {
+			return SampleProfile{}, err
+		}
+		key := fmt.Sprintf("%s:%d:%s", featureURI, line, scenarioName)
+		h, ok := scenarios[key]
+		if !ok {
+			h = &history{
+				scenario: SampledScenario{Name: scenarioName, FeatureURI: featureURI, Line: line},
+				steps:    map[string]bool{},
+				runs:     map[string]bool{},
+			}
+			if line == 0 {
+				if l, ok := scenarioLine(featureURI, scenarioName); ok {
+					h.scenario.Line = l
+				}
+			}
+			scenarios[key] = h
+		}
+		h.steps[stepText] = true
+		h.total += time.Duration(durationNs)
+		h.runs[runID] = true
+		allSteps[stepText] = true
+	}
+	if err := rows.Err(); err != nil {
+		return SampleProfile{}, err
+	}
+
+	profile := SampleProfile{TotalScenarios: len(scenarios)}
+	if len(allSteps) == 0 {
+		return profile, nil
+	}
+
+	keys := make([]string, 0, len(scenarios))
+	for key := range scenarios {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	covered := make(map[string]bool)
+	picked := make(map[string]bool)
+	for float64(len(covered))/float64(len(allSteps)) < coverage {
+		best, bestScore := "", 0.0
+		for _, key := range keys {
+			h := scenarios[key]
+			expected := h.total / time.Duration(len(h.runs))
+			if picked[key] || profile.Expected+expected > budget {
+				continue
+			}
+			fresh := 0
+			for step := range h.steps {
+				if !covered[step] {
+					fresh++
+				}
+			}
+			score := float64(fresh) / (expected.Seconds() + 0.001)
+			if fresh > 0 && score > bestScore {
+				best, bestScore = key, score
+			}
+		}
+		if best == "" {
+			break
+		}
+
+		h := scenarios[best]
+		expected := h.total / time.Duration(len(h.runs))
+		picked[best] = true
+		for step := range h.steps {
+			covered[step] = true
+		}
+		profile.Expected += expected
+		s := h.scenario
+		s.Expected, s.Steps = expected, len(h.steps)
+		profile.Scenarios = append(profile.Scenarios, s)
+	}
+	profile.Coverage = float64(len(covered)) / float64(len(allSteps))
+	return profile, nil
+}
@@ -0,0 +1,79 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSampleProfile(t *testing.T) {
+	a, _ := newTestAgent(t)
+	defer a.Close()
+
+	refund := filepath.Join(t.TempDir(), "refund.feature")
+	if err := os.WriteFile(refund, []byte("Feature: Refunds\n  Scenario: Refund\n    Given a refund\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now().Add(-time.Hour)
+	var batch []stepRecord
+	add := func(featureURI string, line int, scenario, text string, ms int) {
+		d := time.Duration(ms) * time.Millisecond
+		batch = append(batch, stepRecord{
+			stepID:       fmt.Sprintf("s%d", len(batch)),
+			runID:        "r1",
+			featureURI:   featureURI,
+			scenarioLine: line,
+			scenarioName: scenario,
+			stepText:     text,
+			phase:        phasePrimary,
+			duration:     d,
+			startedAt:    start,
+			endedAt:      start.Add(d),
+		})
+	}
+	add("features/a.feature", 3, "Checkout", "a cart", 50)
+	add("features/a.feature", 3, "Checkout", "I check out", 50)
+	add("features/b.feature", 7, "Checkout", "a saved card", 40)
+	add("features/a.feature", 10, "Pay (card|cash)?", "I pay", 100)
+	add(refund, 0, "Refund", "a refund", 200)
+	add("", 0, "Unlocated", "an old step", 1)
+	if err := a.writeBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		budget time.Duration
+		want   []string
+	}{
+		{"everything", time.Hour, []string{"features/b.feature:7", "features/a.feature:3", "features/a.feature:10", refund + ":2"}},
+		{"budget", 150 * time.Millisecond, []string{"features/b.feature:7", "features/a.feature:3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, err := a.SampleProfile(tt.budget, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if profile.TotalScenarios != 4 {
+				t.Errorf("TotalScenarios = %d, want 4", profile.TotalScenarios)
+			}
+			if got := profile.Paths(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Paths = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSampledScenarioPath(t *testing.T) {
+	if got := (SampledScenario{FeatureURI: "features/a.feature", Line: 4}).Path(); got != "features/a.feature:4" {
+		t.Errorf("Path = %q", got)
+	}
+	if got := (SampledScenario{FeatureURI: "features/a.feature"}).Path(); got != "features/a.feature" {
+		t.Errorf("Path without a line = %q", got)
+	}
+}
@@ -0,0 +1,248 @@
+package vectorclocks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// DefaultServeRuns is the number of recent runs the dashboard covers.
+const DefaultServeRuns = 30
+
+// Handler serves a read-only HTML dashboard over the database: the recent
+// runs at /, the per-scenario and per-step breakdown of a run at
+// /runs/{id}, and the duration of a scenario or step across the recent runs
+// at /trend?scenario=...&step=... (step empty for the whole scenario), and
+// the stored scenario stability scores as JSON at /stability?sort=... (see
+// SortStability; least stable first by default). It
+// covers the last runs runs, DefaultServeRuns when runs is not positive.
+// Pages use templates/serve.html.tmpl.
+func (v *VectorClockAgent) Handler(runs int) http.Handler {
+	if runs <= 0 {
+		runs = DefaultServeRuns
+	}
+	d := &dashboard{agent: v, runs: runs}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", d.recentRuns)
+	mux.HandleFunc("GET /runs/{id}", d.run)
+	mux.HandleFunc("GET /trend", d.trend)
+	mux.HandleFunc("GET /stability", d.stability)
+	return mux
+}
+
+type dashboard struct {
+	agent *VectorClockAgent
+	runs  int
+}
+
+// render executes the named page, buffering it so a failing page returns
+// a clean error response.
+func (d *dashboard) render(w http.ResponseWriter, page string, data interface{}) {
+	assets := d.agent.assets
+	if assets == nil {
+		assets = embeddedAssets
+	}
+	tmpl, err := loadTemplate(assets, "templates/serve.html.tmpl", serveFuncs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&buf, page, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	buf.WriteTo(w)
+}
+
+var serveFuncs = template.FuncMap{
+	"round": func(d time.Duration) time.Duration { return d.Round(time.Millisecond) },
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04 MST")
+	},
+	"percent": func(part, whole time.Duration) string {
+		if whole <= 0 {
+			return ""
+		}
+		return fmt.Sprintf("%.1f%%", 100*float64(part)/float64(whole))
+	},
+}
+
+// dashboardRun is a row of the recent runs page.
+type dashboardRun struct {
+	RunTimings
+	StartedAt time.Time
+}
+
+func (d *dashboard) recentRuns(w http.ResponseWriter, r *http.Request) {
+	runIDs, err := d.agent.RecentRuns(d.runs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	starts, err := d.agent.runStarts(runIDs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rows := make([]dashboardRun, 0, len(runIDs))
+	for _, runID := range runIDs {
+		rt, err := d.agent.RunTimings(runID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		rows = append(rows, dashboardRun{RunTimings: rt, StartedAt: starts[runID]})
+	}
+	d.render(w, "runs", rows)
+}
+
+// dashboardScenario is a scenario of a run with its steps, slowest first.
+type dashboardScenario struct {
+	Name     string
+	Duration time.Duration
+	Steps    []Delta
+}
+
+func (d *dashboard) run(w http.ResponseWriter, r *http.Request) {
+	rt, err := d.agent.RunTimings(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(rt.Scenarios) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	scenarios := make(map[string]*dashboardScenario, len(rt.Scenarios))
+	for name, duration := range rt.Scenarios {
+		scenarios[name] = &dashboardScenario{Name: name, Duration: duration}
+	}
+	for key, duration := range rt.Steps {
+		if s, ok := scenarios[key.Scenario]; ok {
+			s.Steps = append(s.Steps, Delta{Name: key.Step, Head: duration})
+		}
+	}
+	list := make([]dashboardScenario, 0, len(scenarios))
+	for _, s := range scenarios {
+		sortDeltasByHead(s.Steps)
+		list = append(list, *s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Duration != list[j].Duration {
+			return list[i].Duration > list[j].Duration
+		}
+		return list[i].Name < list[j].Name
+	})
+	d.render(w, "run", struct {
+		RunTimings
+		Scenarios []dashboardScenario
+	}{rt, list})
+}
+
+// dashboardPoint is the duration of a scenario or step in one run.
+type dashboardPoint struct {
+	RunID    string
+	Duration time.Duration
+	// Height is the bar height in percent of the slowest run.
+	Height float64
+}
+
+func (d *dashboard) trend(w http.ResponseWriter, r *http.Request) {
+	key := StepKey{Scenario: r.URL.Query().Get("scenario"), Step: r.URL.Query().Get("step")}
+	if key.Scenario == "" {
+		http.Error(w, "missing scenario parameter", http.StatusBadRequest)
+		return
+	}
+	runIDs, err := d.agent.RecentRuns(d.runs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var points []dashboardPoint
+	var fit []point
+	var slowest time.Duration
+	// RecentRuns is newest first; the chart reads oldest to newest.
+	for i := len(runIDs) - 1; i >= 0; i-- {
+		rt, err := d.agent.RunTimings(runIDs[i])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		duration, ok := rt.Scenarios[key.Scenario]
+		if key.Step != "" {
+			duration, ok = rt.Steps[key]
+		}
+		if !ok {
+			continue
+		}
+		fit = append(fit, point{float64(len(points)), duration})
+		points = append(points, dashboardPoint{RunID: runIDs[i], Duration: duration})
+		slowest = max(slowest, duration)
+	}
+	for i := range points {
+		if slowest > 0 {
+			points[i].Height = 100 * float64(points[i].Duration) / float64(slowest)
+		}
+	}
+
+	data := struct {
+		Key    StepKey
+		Points []dashboardPoint
+		Trend  *Trend
+	}{Key: key, Points: points}
+	if len(fit) >= minTrendPoints {
+		t := fitTrend(fit)
+		data.Trend = &t
+	}
+	d.render(w, "trend", data)
+}
+
+func (d *dashboard) stability(w http.ResponseWriter, r *http.Request) {
+	scores, err := d.agent.Stability()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if by := r.URL.Query().Get("sort"); by != "" && !SortStability(scores, by) {
+		http.Error(w, fmt.Sprintf("unknown sort %q", by), http.StatusBadRequest)
+		return
+	}
+	if scores == nil {
+		scores = []ScenarioStability{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(scores)
+}
+
+// runStarts returns when each of runIDs started.
+func (v *VectorClockAgent) runStarts(runIDs []string) (map[string]time.Time, error) {
+	starts := make(map[string]time.Time, len(runIDs))
+	for _, runID := range runIDs {
+		var startedAt timestamp
+		if err := v.db.QueryRow(`SELECT started_at FROM runs WHERE run_id = ?`, runID).Scan(&startedAt); err != nil {
+			return nil, fmt.Errorf("failed to load start of run %s: %w", runID, err)
+		}
+		starts[runID] = startedAt.Time
+	}
+	return starts, nil
+}
+
+func sortDeltasByHead(deltas []Delta) {
+	sort.Slice(deltas, func(i, j int) bool {
+		if deltas[i].Head != deltas[j].Head {
+			return deltas[i].Head > deltas[j].Head
+		}
+		return deltas[i].Name < deltas[j].Name
+	})
+}
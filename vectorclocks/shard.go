@@ -0,0 +1,221 @@
+package vectorclocks
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// shardInfo identifies which slice of a sharded suite this process runs.
+type shardInfo struct {
+	index int
+	total int
+}
+
+// WithShard declares that this process runs shard index (0-based) of total.
+// Baselines for a sharded run only come from earlier runs of the same shard
+// with the same scenario composition.
+func WithShard(index, total int) Option {
+	return func(v *VectorClockAgent) {
+		v.shard = shardInfo{index: index, total: total}
+	}
+}
+
+func (v *VectorClockAgent) sharded() bool {
+	return v.shard.total > 1
+}
+
+// noteScenario remembers a scenario that ran, for the composition hash.
+func (v *VectorClockAgent) noteScenario(featureURI, name string) {
+	v.compositionMu.Lock()
+	defer v.compositionMu.Unlock()
+	if v.composition == nil {
+		v.composition = make(map[string]bool)
+	}
+	v.composition[featureURI+"\x00"+name] = true
+}
+
+// CompositionHash identifies the set of scenarios that ran so far. Two shards
+// with the same index but different scenarios have different hashes, which
+// keeps their timings from being compared.
+func (v *VectorClockAgent) CompositionHash() string {
+	v.compositionMu.Lock()
+	keys := make([]string, 0, len(v.composition))
+	for k := range v.composition {
+		keys = append(keys, k)
+	}
+	v.compositionMu.Unlock()
+
+	sort.Strings(keys)
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// runRecord is the runs row of one run.
+type runRecord struct {
+	RunID                string            `json:"run_id"`
+	StartedAt            time.Time         `json:"started_at"`
+	FinishedAt           time.Time         `json:"finished_at"`
+	ShardIndex           *int              `json:"shard_index,omitempty"`
+	ShardTotal           *int              `json:"shard_total,omitempty"`
+	CompositionHash      string            `json:"composition_hash"`
+	FirstFailureMs       *int64            `json:"first_failure_ms,omitempty"`
+	FirstFailurePosition *int              `json:"first_failure_position,omitempty"`
+	Concurrency          *int              `json:"concurrency,omitempty"`
+	Metadata             RunMetadata       `json:"metadata"`
+	Flags                map[string]string `json:"flags,omitempty"`
+	Labels               map[string]string `json:"labels,omitempty"`
+	Suite                string            `json:"suite,omitempty"`
+	Interrupted          string            `json:"interrupted,omitempty"`
+	Definitions          []string          `json:"step_definitions,omitempty"`
+}
+
+// recordRun stores the runs row of the current run, or ships it to the
+// collector. Runs that recorded no scenario (report-only invocations) are
+// not stored.
+func (v *VectorClockAgent) recordRun() error {
+	v.compositionMu.Lock()
+	empty := len(v.composition) == 0
+	v.compositionMu.Unlock()
+	if empty {
+		return nil
+	}
+
+	r := runRecord{
+		RunID:           v.runID,
+		StartedAt:       v.startedAt,
+		FinishedAt:      v.now(),
+		CompositionHash: v.CompositionHash(),
+		Metadata:        v.metadata,
+		Flags:           v.flags,
+		Labels:          v.Labels(),
+		Suite:           v.suite,
+		Interrupted:     v.interruption(),
+		Definitions:     v.StepDefinitions(),
+	}
+	if v.sharded() {
+		r.ShardIndex, r.ShardTotal = &v.shard.index, &v.shard.total
+	}
+	if f := v.firstFailure; f != nil {
+		ms := f.after.Milliseconds()
+		r.FirstFailureMs, r.FirstFailurePosition = &ms, &f.position
+	}
+	if v.concurrency > 0 {
+		r.Concurrency = &v.concurrency
+	}
+	if v.collector != nil {
+		return v.collector.sendRun(r)
+	}
+	return v.insertRun(r)
+}
+
+func (v *VectorClockAgent) insertRun(r runRecord) error {
+	md := r.Metadata
+	labels, err := encodeAnnotations(r.Labels)
+	if err != nil {
+		return err
+	}
+	_, err = v.db.Exec(`
+		INSERT OR REPLACE INTO runs (run_id, started_at, finished_at, shard_index, shard_total, composition_hash,
+			first_failure_ms, first_failure_position,
+			ci_provider, pipeline_url, job_url, artifacts_url, pr_number, actor, branch, commit_sha, concurrency,
+			environment, labels, suite, interrupted)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, r.RunID, formatPrecise(r.StartedAt), formatPrecise(r.FinishedAt),
+		r.ShardIndex, r.ShardTotal, r.CompositionHash, r.FirstFailureMs, r.FirstFailurePosition,
+		nullString(md.Provider), nullString(md.PipelineURL), nullString(md.JobURL), nullString(md.ArtifactsURL),
+		nullString(md.PRNumber), nullString(md.Actor), nullString(md.Branch), nullString(md.Commit), r.Concurrency,
+		nullString(md.Environment), labels, nullString(r.Suite), nullString(r.Interrupted))
+	if err != nil {
+		return fmt.Errorf("failed to record run %s: %w", r.RunID, err)
+	}
+	if err := v.insertFlags(r.RunID, r.Flags); err != nil {
+		return err
+	}
+	return v.insertDefinitions(r.RunID, r.Definitions)
+}
+
+// BaselineRuns returns up to n of the most recent earlier runs that are
+// comparable with the current one: uninterrupted runs of the same suite
+// (see WithSuite) with the same shard index, shard count and composition
+// hash for a sharded run, and unsharded runs otherwise.
+func (v *VectorClockAgent) BaselineRuns(n int) ([]string, error) {
+	return v.baselineRunsIn(v.db, n)
+}
+
+// baselineRunsIn is BaselineRuns over the runs recorded in db.
+func (v *VectorClockAgent) baselineRunsIn(db *namedDB, n int) ([]string, error) {
+	query := `
+		SELECT run_id FROM runs
+		WHERE run_id != ? AND COALESCE(suite, '') = ? AND interrupted IS NULL AND shard_total IS NULL
+		ORDER BY started_at DESC, rowid DESC
+		LIMIT ?
+	`
+	args := []interface{}{v.runID, v.suite, n}
+	if v.sharded() {
+		query = `
+			SELECT run_id FROM runs
+			WHERE run_id != ? AND COALESCE(suite, '') = ? AND interrupted IS NULL AND shard_index = ? AND shard_total = ? AND composition_hash = ?
+			ORDER BY started_at DESC, rowid DESC
+			LIMIT ?
+		`
+		args = []interface{}{v.runID, v.suite, v.shard.index, v.shard.total, v.CompositionHash(), n}
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load baseline runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	return runIDs, rows.Err()
+}
+
+// RecentRuns returns the IDs of the n most recently started runs, newest
+// first.
+func (v *VectorClockAgent) RecentRuns(n int) ([]string, error) {
+	rows, err := v.db.Query(`SELECT run_id FROM runs ORDER BY started_at DESC, rowid DESC LIMIT ?`, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	return runIDs, rows.Err()
+}
+
+// LatestRunID returns the most recently started recorded run.
+func (v *VectorClockAgent) LatestRunID() (string, error) {
+	runs, err := v.RecentRuns(1)
+	if err != nil {
+		return "", err
+	}
+	if len(runs) == 0 {
+		return "", fmt.Errorf("no runs recorded")
+	}
+	return runs[0], nil
+}
+
+// nullString stores empty strings as NULL.
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,82 @@
+package vectorclocks
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+func TestRecentRunsWithinOneSecond(t *testing.T) {
+	_, dbPath := newTestAgent(t)
+	second := time.Now().Add(-time.Hour).Truncate(time.Second)
+	// Runs in the order they are recorded; "d" starts exactly when "b"
+	// does and is recorded after it.
+	runs := []struct {
+		id    string
+		start time.Duration
+	}{
+		{"a", 100 * time.Millisecond},
+		{"b", 300 * time.Millisecond},
+		{"c", 200 * time.Millisecond},
+		{"d", 300 * time.Millisecond},
+	}
+	labels := make(map[string]string)
+	for _, r := range runs {
+		a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent), WithClock(&fakeClock{now: second.Add(r.start)}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		labels[a.RunID()] = r.id
+		status := runFeature(t, a, 1, `Feature: ordering
+  Scenario: one
+    Given a step
+`, func(ctx *godog.ScenarioContext) {
+			ctx.Step(`^a step$`, func(context.Context) error { return nil })
+		})
+		if status != 0 {
+			t.Fatalf("run %s: suite status = %d, want 0", r.id, status)
+		}
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	recent, err := a.RecentRuns(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, id := range recent {
+		order = append(order, labels[id])
+	}
+	if want := []string{"d", "b", "c", "a"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("RecentRuns = %v, want %v", order, want)
+	}
+	suites, err := a.Suites()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suites) != 1 || labels[suites[0].LatestRun] != "d" || suites[0].Wall <= 0 {
+		t.Errorf("Suites = %+v, want latest run d with a wall time", suites)
+	}
+}
+
+func TestRunIDsOfOneSecondDiffer(t *testing.T) {
+	start := time.Now().Truncate(time.Second)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := newRunID(start)
+		if seen[id] {
+			t.Fatalf("run ID %s generated twice", id)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,114 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Granularities accepted by PlanShards.
+const (
+	ShardByFeature  = "feature"
+	ShardByScenario = "scenario"
+)
+
+// ShardPlan splits a suite into shards of about the same duration.
+type ShardPlan struct {
+	Shards []PlannedShard `json:"shards"`
+	// Unknown counts the scenarios that never ran before; each is
+	// estimated at the mean of the others.
+	Unknown int `json:"unknown"`
+}
+
+// PlannedShard is one group of a ShardPlan.
+type PlannedShard struct {
+	// Paths are feature files, or "file:line" scenarios, to pass to godog
+	// as Options.Paths or --godog.paths.
+	Paths     []string      `json:"paths"`
+	Scenarios int           `json:"scenarios"`
+	Estimate  time.Duration `json:"estimate_ns"`
+}
+
+// shardUnit is a feature file or scenario placed on a shard as a whole.
+type shardUnit struct {
+	path      string
+	scenarios int
+	estimate  time.Duration
+}
+
+// PlanShards splits the scenarios in paths into n shards with about equal
+// predicted durations, from each scenario's mean over recent runs as
+// PredictSuite estimates it. by is ShardByFeature to keep feature files
+// whole, which keeps their Background and hooks on one shard, or
+// ShardByScenario for finer and so better balanced shards. Each unit goes
+// to the shard with the least work so far, longest units first.
+func (v *VectorClockAgent) PlanShards(paths []string, n int, by string) (ShardPlan, error) {
+	if n < 1 {
+		return ShardPlan{}, fmt.Errorf("invalid shard count %d", n)
+	}
+	scheduled, estimates, unknown, err := v.estimateSuite(paths)
+	if err != nil {
+		return ShardPlan{}, err
+	}
+
+	var units []*shardUnit
+	byPath := make(map[string]*shardUnit)
+	for _, s := range scheduled {
+		var path string
+		switch by {
+		case ShardByFeature:
+			path = s.featureURI
+		case ShardByScenario:
+			path = s.featureURI + ":" + strconv.Itoa(s.line)
+		default:
+			return ShardPlan{}, fmt.Errorf("unknown shard granularity %q (want %s or %s)", by, ShardByFeature, ShardByScenario)
+		}
+		u, ok := byPath[path]
+		if !ok {
+			u = &shardUnit{path: path}
+			byPath[path] = u
+			units = append(units, u)
+		}
+		u.scenarios++
+		u.estimate += estimates[s.scenarioRef]
+	}
+	sort.SliceStable(units, func(i, j int) bool { return units[i].estimate > units[j].estimate })
+
+	plan := ShardPlan{Shards: make([]PlannedShard, n), Unknown: unknown}
+	for _, u := range units {
+		next := 0
+		for i, s := range plan.Shards {
+			if s.Estimate < plan.Shards[next].Estimate {
+				next = i
+			}
+		}
+		s := &plan.Shards[next]
+		s.Paths = append(s.Paths, u.path)
+		s.Scenarios += u.scenarios
+		s.Estimate += u.estimate
+	}
+	for _, s := range plan.Shards {
+		sort.Strings(s.Paths)
+	}
+	return plan, nil
+}
+
+// WriteShardPlan prints each shard, by its 0-based index as WithShard
+// takes it, with its predicted duration and its --godog.paths flag.
+func WriteShardPlan(w io.Writer, plan ShardPlan) error {
+	for i, s := range plan.Shards {
+		_, err := fmt.Fprintf(w, "shard %d of %d: %s, %d scenarios\n  --godog.paths=%s\n",
+			i, len(plan.Shards), s.Estimate.Round(time.Second), s.Scenarios, strings.Join(s.Paths, ","))
+		if err != nil {
+			return err
+		}
+	}
+	if plan.Unknown > 0 {
+		_, err := fmt.Fprintf(w, "%d scenarios without history were estimated at the mean\n", plan.Unknown)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package vectorclocks
+
+import (
+	"errors"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// StatusInterrupted marks a step that was still running when the run was
+// interrupted (see Interrupt and WithSignalHandling).
+const StatusInterrupted = "interrupted"
+
+// WithSignalHandling makes the agent shut down cleanly when the process
+// receives one of signals, SIGINT and SIGTERM by default: it calls
+// Interrupt, so the running steps are recorded as StatusInterrupted and
+// every buffered step is written, prints the summary line and then exits
+// with status 128 plus the signal number, as a shell does. A second signal
+// during the shutdown kills the process at once.
+func WithSignalHandling(signals ...os.Signal) Option {
+	return func(v *VectorClockAgent) {
+		if len(signals) == 0 {
+			signals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+		}
+		v.shutdownSignals = signals
+	}
+}
+
+// handleSignals starts waiting for the signals of WithSignalHandling.
+func (v *VectorClockAgent) handleSignals() {
+	v.signals = make(chan os.Signal, 1)
+	signal.Notify(v.signals, v.shutdownSignals...)
+	go func() {
+		sig, ok := <-v.signals
+		if !ok {
+			return
+		}
+		// Restore the default behaviour, so that a second signal is not
+		// swallowed while the agent closes.
+		signal.Stop(v.signals)
+		os.Exit(v.shutdown(sig))
+	}()
+}
+
+// shutdown interrupts the run on sig and returns the status to exit with.
+// It prints the summary line, which the caller's own PrintSummary never
+// gets to once the process exits.
+func (v *VectorClockAgent) shutdown(sig os.Signal) int {
+	v.logger.Warn("interrupted, saving recorded steps", "signal", sig.String())
+	if err := v.Interrupt(sig.String()); errors.Is(err, ErrClosed) {
+		// The suite finished and is closing the agent already.
+		<-v.closeDone
+	} else if err != nil {
+		v.handleError(err)
+	}
+	v.PrintSummary()
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
+
+// stopSignals stops waiting for the signals of WithSignalHandling.
+func (v *VectorClockAgent) stopSignals() {
+	if v.signals != nil {
+		signal.Stop(v.signals)
+		close(v.signals)
+	}
+}
+
+// Interrupt ends the run early, for callers that handle signals or
+// cancellation themselves: the steps still running are recorded as
+// StatusInterrupted with their duration so far, the run is stored as
+// interrupted with reason, which keeps it out of regression baselines,
+// and the agent is closed, writing every buffered step.
+func (v *VectorClockAgent) Interrupt(reason string) error {
+	v.interruptMu.Lock()
+	v.interrupted = reason
+	v.interruptMu.Unlock()
+	return v.Close()
+}
+
+// interruption returns the reason given to Interrupt, or "".
+func (v *VectorClockAgent) interruption() string {
+	v.interruptMu.Lock()
+	defer v.interruptMu.Unlock()
+	return v.interrupted
+}
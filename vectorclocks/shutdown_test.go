@@ -0,0 +1,95 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"io"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/cucumber/godog"
+)
+
+func TestInterruptRecordsRunningSteps(t *testing.T) {
+	a, dbPath := newTestAgent(t, WithVerbosity(VerbositySummary))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan int)
+	go func() {
+		done <- runFeature(t, a, 1, `Feature: shutdown
+  Scenario: long
+    Given a quick step
+    When a step that hangs
+`, func(ctx *godog.ScenarioContext) {
+			ctx.Step(`^a quick step$`, func() error { return nil })
+			ctx.Step(`^a step that hangs$`, func() error {
+				close(started)
+				<-release
+				return nil
+			})
+		})
+	}()
+	<-started
+	// shutdown is what WithSignalHandling runs on SIGTERM before exiting.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	code := a.shutdown(syscall.SIGTERM)
+	os.Stdout = stdout
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	<-done
+	if code != 143 {
+		t.Errorf("exit status = %d, want 143", code)
+	}
+	if !strings.Contains(string(out), "VC_SUMMARY ") {
+		t.Errorf("shutdown printed %q, want the VC_SUMMARY line", out)
+	}
+	if err := a.Interrupt("terminated"); err != ErrClosed {
+		t.Errorf("second Interrupt = %v, want ErrClosed", err)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	statuses := make(map[string]string)
+	rows, err := db.Query(`SELECT step_text, status FROM step_timings WHERE run_id = ?`, a.RunID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var text, status string
+		if err := rows.Scan(&text, &status); err != nil {
+			t.Fatal(err)
+		}
+		statuses[text] = status
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"a quick step": "passed", "a step that hangs": StatusInterrupted}
+	for text, status := range want {
+		if statuses[text] != status {
+			t.Errorf("step %q recorded as %q, want %q", text, statuses[text], status)
+		}
+	}
+
+	var reason sql.NullString
+	if err := db.QueryRow(`SELECT interrupted FROM runs WHERE run_id = ?`, a.RunID()).Scan(&reason); err != nil {
+		t.Fatal(err)
+	}
+	if reason.String != "terminated" {
+		t.Errorf("run interrupted = %q, want terminated", reason.String)
+	}
+}
@@ -0,0 +1,201 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+const untaggedGroup = "(untagged)"
+
+// SLAGroup is the SLA view of one scenario tag over a reporting period.
+// Availability is the share of scenario executions whose steps all passed;
+// P95 is the 95th percentile of scenario durations.
+type SLAGroup struct {
+	Tag          string
+	Executions   int
+	Availability float64
+	P95          time.Duration
+
+	HasPrevious      bool
+	PrevAvailability float64
+	PrevP95          time.Duration
+}
+
+// AvailabilityTrend is an arrow comparing availability with the previous
+// period.
+func (g SLAGroup) AvailabilityTrend() string {
+	if !g.HasPrevious {
+		return "–"
+	}
+	switch delta := g.Availability - g.PrevAvailability; {
+	case delta > 0.01:
+		return "↑"
+	case delta < -0.01:
+		return "↓"
+	}
+	return "→"
+}
+
+// LatencyTrend is an arrow comparing p95 latency with the previous period.
+func (g SLAGroup) LatencyTrend() string {
+	if !g.HasPrevious || g.PrevP95 == 0 {
+		return "–"
+	}
+	switch ratio := float64(g.P95) / float64(g.PrevP95); {
+	case ratio > 1.05:
+		return "↑"
+	case ratio < 0.95:
+		return "↓"
+	}
+	return "→"
+}
+
+// SLAReport groups scenario executions of one period by tag.
+type SLAReport struct {
+	From   time.Time
+	To     time.Time
+	Groups []SLAGroup
+	// Runs are the runs of the period, linked to their CI pipelines.
+	Runs []RunLink
+
+	assets fs.FS
+}
+
+// SLAReport builds the SLA report for the last period, with trends against
+// the period before it.
+func (v *VectorClockAgent) SLAReport(period time.Duration) (SLAReport, error) {
+	v.sync()
+
+	now := time.Now().UTC()
+	from := now.Add(-period)
+	rows, err := v.db.Query(`
+		SELECT COALESCE(run_id, ''), COALESCE(feature_uri, ''), COALESCE(scenario_line, 0), COALESCE(example_line, 0),
+			scenario_name, COALESCE(tags, ''), COALESCE(status, ''), `+durationNs+`, created_at
+		FROM step_timings
+		WHERE created_at >= ? AND `+primaryPhase+`
+	`, now.Add(-2*period).Format(sqliteTimeLayout))
+	if err != nil {
+		return SLAReport{}, fmt.Errorf("failed to load step timings: %w", err)
+	}
+	defer rows.Close()
+
+	type execution struct {
+		tags     []string
+		passed   bool
+		duration time.Duration
+		current  bool
+	}
+	// An execution is one scenario in one run; the feature file and lines
+	// keep apart scenarios sharing a name and the examples of an outline.
+	type executionKey struct {
+		runID, featureURI, scenarioName string
+		scenarioLine, exampleLine       int
+	}
+	executions := make(map[executionKey]*execution)
+	var order []executionKey
+	for rows.Next() {
+		var key executionKey
+		var tags, status string
+		var durationNs int64
+		var createdAt timestamp
+		if err := rows.Scan(&key.runID, &key.featureURI, &key.scenarioLine, &key.exampleLine, &key.scenarioName, &tags, &status, &durationNs, &createdAt); err != nil {
+			return SLAReport{}, err
+		}
+		e, ok := executions[key]
+		if !ok {
+			e = &execution{passed: true}
+			if tags != "" {
+				e.tags = strings.Split(tags, ",")
+			}
+			executions[key] = e
+			order = append(order, key)
+		}
+		if status != "passed" {
+			e.passed = false
+		}
+		e.duration += time.Duration(durationNs)
+		if !createdAt.Before(from) {
+			e.current = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return SLAReport{}, err
+	}
+
+	type window struct {
+		passed    int
+		durations []time.Duration
+	}
+	current := make(map[string]*window)
+	previous := make(map[string]*window)
+	for _, key := range order {
+		e := executions[key]
+		groups := e.tags
+		if len(groups) == 0 {
+			groups = []string{untaggedGroup}
+		}
+		target := previous
+		if e.current {
+			target = current
+		}
+		for _, tag := range groups {
+			w, ok := target[tag]
+			if !ok {
+				w = &window{}
+				target[tag] = w
+			}
+			if e.passed {
+				w.passed++
+			}
+			w.durations = append(w.durations, e.duration)
+		}
+	}
+
+	report := SLAReport{From: from, To: now, assets: v.assets}
+	for tag, w := range current {
+		sortDurations(w.durations)
+		g := SLAGroup{
+			Tag:          tag,
+			Executions:   len(w.durations),
+			Availability: float64(w.passed) / float64(len(w.durations)),
+			P95:          percentile(w.durations, 95),
+		}
+		if p, ok := previous[tag]; ok {
+			sortDurations(p.durations)
+			g.HasPrevious = true
+			g.PrevAvailability = float64(p.passed) / float64(len(p.durations))
+			g.PrevP95 = percentile(p.durations, 95)
+		}
+		report.Groups = append(report.Groups, g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool { return report.Groups[i].Tag < report.Groups[j].Tag })
+	if report.Runs, err = v.runLinks(from); err != nil {
+		return SLAReport{}, err
+	}
+	return report, nil
+}
+
+var slaFuncs = template.FuncMap{
+	"percent": func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+	"round":   func(d time.Duration) time.Duration { return d.Round(time.Millisecond) },
+	"date":    func(t time.Time) string { return t.Format("2006-01-02 15:04 MST") },
+}
+
+// WriteHTML renders the report as a standalone, print-friendly HTML page
+// using templates/sla.html.tmpl.
+func (r SLAReport) WriteHTML(w io.Writer) error {
+	assets := r.assets
+	if assets == nil {
+		assets = embeddedAssets
+	}
+	tmpl, err := loadTemplate(assets, "templates/sla.html.tmpl", slaFuncs)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, r)
+}
@@ -0,0 +1,54 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestSLAReportExecutions(t *testing.T) {
+	a, _ := newTestAgent(t)
+	defer a.Close()
+
+	start := time.Now().Add(-time.Minute)
+	var batch []stepRecord
+	add := func(featureURI string, line, exampleLine int, scenario, status string) {
+		batch = append(batch, stepRecord{
+			stepID:       fmt.Sprintf("s%d", len(batch)),
+			runID:        "r1",
+			featureURI:   featureURI,
+			scenarioLine: line,
+			example:      exampleRow{line: exampleLine},
+			scenarioName: scenario,
+			stepText:     "a step",
+			status:       status,
+			tags:         "@auth",
+			phase:        phasePrimary,
+			duration:     time.Millisecond,
+			startedAt:    start,
+			endedAt:      start.Add(time.Millisecond),
+		})
+	}
+	// Two scenarios called Login in different features, and an outline
+	// with two example rows.
+	add("features/web.feature", 3, 0, "Login", "passed")
+	add("features/web.feature", 3, 0, "Login", "passed")
+	add("features/api.feature", 5, 0, "Login", "failed")
+	add("features/api.feature", 9, 14, "Token", "passed")
+	add("features/api.feature", 9, 15, "Token", "passed")
+	if err := a.writeBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := a.SLAReport(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Groups) != 1 {
+		t.Fatalf("groups = %+v, want one", report.Groups)
+	}
+	g := report.Groups[0]
+	if g.Tag != "@auth" || g.Executions != 4 || g.Availability != 0.75 {
+		t.Errorf("group = %s, %d executions, %.2f available; want @auth, 4, 0.75", g.Tag, g.Executions, g.Availability)
+	}
+}
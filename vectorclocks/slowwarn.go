@@ -0,0 +1,63 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// WithSlowStepWarnings logs a warning as soon as a step has run for limit,
+// while it is still running, so a slow or hanging step is noticed by
+// whoever watches the run rather than only in the report afterwards. The
+// warning names the scenario and step and, when the step ran before, its
+// mean duration over the recorded runs.
+func WithSlowStepWarnings(limit time.Duration) Option {
+	return func(v *VectorClockAgent) {
+		if limit > 0 {
+			v.warnSlow = limit
+		}
+	}
+}
+
+// watchSlowStep arms the slow-step warning of a step that just started,
+// written at line of file. The returned timer is stopped when the step
+// ends; it is nil without WithSlowStepWarnings.
+func (v *VectorClockAgent) watchSlowStep(scenario, step, file string, line int) *time.Timer {
+	if v.warnSlow <= 0 {
+		return nil
+	}
+	return time.AfterFunc(v.warnSlow, func() {
+		attrs := []interface{}{"scenario", scenario, "step", step, "limit", v.warnSlow}
+		if mean, ok := v.historicalMean(scenario, step); ok {
+			attrs = append(attrs, "mean", mean.Round(time.Millisecond))
+		}
+		v.logger.Warn("step running longer than limit", attrs...)
+		if v.annotate != "" {
+			err := WriteCIAnnotations(os.Stdout, v.annotate, []CIAnnotation{{
+				Title:   "Slow step",
+				Message: fmt.Sprintf("%s is still running after %s", StepKey{Scenario: scenario, Step: step}, v.warnSlow),
+				File:    file,
+				Line:    line,
+			}})
+			if err != nil {
+				v.handleError(err)
+			}
+		}
+	})
+}
+
+// historicalMean returns the mean duration of a step over the runs before
+// the current one.
+func (v *VectorClockAgent) historicalMean(scenario, step string) (time.Duration, bool) {
+	var mean sql.NullFloat64
+	err := v.db.QueryRow(`
+		SELECT AVG(`+durationNs+`)
+		FROM step_timings
+		WHERE scenario_name = ? AND step_text = ? AND `+primaryPhase+` AND COALESCE(run_id, '') != ?
+	`, scenario, step, v.runID).Scan(&mean)
+	if err != nil || !mean.Valid {
+		return 0, false
+	}
+	return time.Duration(mean.Float64), true
+}
@@ -0,0 +1,113 @@
+package vectorclocks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// spanRecord is one finished span of a step.
+type spanRecord struct {
+	id        string
+	parentID  string
+	name      string
+	startedAt time.Time
+	duration  time.Duration
+}
+
+// Span starts timing a named phase inside the step running in ctx, such as
+// "api call" or "db assert", and returns the context to pass to nested
+// spans and the function that ends it. Spans are stored with the step, with
+// parent_step_id pointing at it and parent_span_id at the enclosing span.
+// Spans must end before their step does; later ends are ignored. Outside a
+// recorded step Span does nothing.
+//
+//	ctx, end := agent.Span(ctx, "api call")
+//	defer end()
+func (v *VectorClockAgent) Span(ctx context.Context, name string) (context.Context, func()) {
+	info, ok := ctx.Value(stepKey).(*stepInfo)
+	if !ok {
+		return ctx, func() {}
+	}
+	parentID, _ := ctx.Value(spanKey).(string)
+
+	info.mu.Lock()
+	info.spanCount++
+	id := fmt.Sprintf("%s#%d", info.id, info.spanCount)
+	info.mu.Unlock()
+
+	started := v.now()
+	return context.WithValue(ctx, spanKey, id), func() {
+		rec := spanRecord{id: id, parentID: parentID, name: name, startedAt: started, duration: v.since(started)}
+		info.mu.Lock()
+		if !info.ended {
+			info.spans = append(info.spans, rec)
+		}
+		info.mu.Unlock()
+	}
+}
+
+// StepSpan is a persisted span.
+type StepSpan struct {
+	ID         string
+	StepID     string
+	ParentSpan string
+	Name       string
+	StartedAt  time.Time
+	Duration   time.Duration
+}
+
+// Spans returns the spans recorded in runID, grouped by step and in start
+// order within each step.
+func (v *VectorClockAgent) Spans(runID string) ([]StepSpan, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT span_id, parent_step_id, COALESCE(parent_span_id, ''), name, started_at, duration_ns
+		FROM step_spans
+		WHERE run_id = ?
+		ORDER BY parent_step_id, started_at
+	`, runID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load spans of run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	var spans []StepSpan
+	for rows.Next() {
+		var s StepSpan
+		var started timestamp
+		var durationNs int64
+		if err := rows.Scan(&s.ID, &s.StepID, &s.ParentSpan, &s.Name, &started, &durationNs); err != nil {
+			return nil, err
+		}
+		s.StartedAt, s.Duration = started.Time, time.Duration(durationNs)
+		spans = append(spans, s)
+	}
+	return spans, rows.Err()
+}
+
+// WriteSpans prints spans as a tree under each step.
+func WriteSpans(w io.Writer, spans []StepSpan) error {
+	depth := make(map[string]int, len(spans))
+	step := ""
+	for _, s := range spans {
+		if s.StepID != step {
+			step = s.StepID
+			if _, err := fmt.Fprintln(w, step); err != nil {
+				return err
+			}
+		}
+		d := 1
+		if s.ParentSpan != "" {
+			d = depth[s.ParentSpan] + 1
+		}
+		depth[s.ID] = d
+		if _, err := fmt.Fprintf(w, "%s%-*s %10s\n", strings.Repeat("  ", d), 40-2*d, s.Name, s.Duration.Round(time.Microsecond)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package vectorclocks
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Export formats read by CI test splitting services.
+const (
+	// FormatKnapsack is a Knapsack report: a JSON object mapping each
+	// feature file to its duration in seconds.
+	FormatKnapsack = "knapsack"
+	// FormatJUnit is JUnit XML with a testcase per scenario carrying its
+	// feature file, the timing data CircleCI's "tests split
+	// --split-by=timings" reads from stored test results.
+	FormatJUnit = "junit"
+)
+
+// splitDurations sums the first-attempt durations of timings by key and
+// averages the sums over the runs the key appears in, so exporting many
+// runs yields typical durations rather than totals. Timings with an empty
+// key are left out.
+func splitDurations(timings []StepTiming, key func(StepTiming) string) map[string]time.Duration {
+	type runKey struct{ run, key string }
+	sums := make(map[runKey]time.Duration)
+	for _, t := range timings {
+		if t.Phase != "" && t.Phase != phasePrimary {
+			continue
+		}
+		if k := key(t); k != "" {
+			sums[runKey{t.RunID, k}] += t.Duration
+		}
+	}
+	totals := make(map[string]time.Duration)
+	runs := make(map[string]int)
+	for k, d := range sums {
+		totals[k.key] += d
+		runs[k.key]++
+	}
+	for k := range totals {
+		totals[k] /= time.Duration(runs[k])
+	}
+	return totals
+}
+
+// splitSeconds rounds d to milliseconds and returns it in seconds.
+func splitSeconds(d time.Duration) float64 {
+	return math.Round(d.Seconds()*1000) / 1000
+}
+
+// writeKnapsack writes the Knapsack report of timings.
+func writeKnapsack(w io.Writer, timings []StepTiming) error {
+	report := make(map[string]float64)
+	for uri, d := range splitDurations(timings, func(t StepTiming) string { return t.FeatureURI }) {
+		report[uri] = splitSeconds(d)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name  string      `xml:"name,attr"`
+	Tests int         `xml:"tests,attr"`
+	Time  float64     `xml:"time,attr"`
+	Cases []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string  `xml:"name,attr"`
+	Classname string  `xml:"classname,attr"`
+	File      string  `xml:"file,attr"`
+	Time      float64 `xml:"time,attr"`
+}
+
+// writeJUnit writes timings as JUnit XML with a testsuite per feature file.
+func writeJUnit(w io.Writer, timings []StepTiming) error {
+	scenarios := splitDurations(timings, func(t StepTiming) string {
+		if t.FeatureURI == "" {
+			return ""
+		}
+		return t.FeatureURI + "\x00" + t.ScenarioName
+	})
+
+	suites := make(map[string]*junitSuite)
+	for key, d := range scenarios {
+		uri, name, _ := strings.Cut(key, "\x00")
+		suite, ok := suites[uri]
+		if !ok {
+			suite = &junitSuite{Name: uri}
+			suites[uri] = suite
+		}
+		suite.Cases = append(suite.Cases, junitCase{Name: name, Classname: uri, File: uri, Time: splitSeconds(d)})
+		suite.Tests++
+		suite.Time += d.Seconds()
+	}
+	var report junitSuites
+	for _, suite := range suites {
+		suite.Time = math.Round(suite.Time*1000) / 1000
+		sort.Slice(suite.Cases, func(i, j int) bool { return suite.Cases[i].Name < suite.Cases[j].Name })
+		report.Suites = append(report.Suites, *suite)
+	}
+	sort.Slice(report.Suites, func(i, j int) bool { return report.Suites[i].Name < report.Suites[j].Name })
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,263 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultStabilityRuns is the number of recent runs stability scores cover.
+const DefaultStabilityRuns = 30
+
+// Weights of the components of a stability score; they add up to 1.
+const (
+	stabilityPassWeight     = 0.6
+	stabilityDurationWeight = 0.2
+	stabilityRetryWeight    = 0.2
+)
+
+// Orders accepted by SortStability.
+const (
+	StabilityByScore    = "score"
+	StabilityByPassRate = "pass-rate"
+	StabilityByVariance = "variance"
+	StabilityByRetries  = "retries"
+	StabilityByName     = "name"
+)
+
+// ScenarioStability is how dependable a scenario has been over recent runs.
+type ScenarioStability struct {
+	Scenario   string `json:"scenario"`
+	FeatureURI string `json:"feature_uri,omitempty"`
+	Runs       int    `json:"runs"`
+	// PassRate is the fraction of the runs in which the scenario passed
+	// its first attempt, with the most recent runs weighted highest.
+	PassRate float64 `json:"pass_rate"`
+	// CV is the coefficient of variation of the scenario duration.
+	CV float64 `json:"duration_cv"`
+	// RetryRate is the fraction of the runs in which the scenario was
+	// retried.
+	RetryRate float64 `json:"retry_rate"`
+	// Score weighs the pass rate at 60% and the duration variance (CV
+	// capped at 1) and retry usage at 20% each, so 1 is perfectly stable
+	// and 0 is as unstable as it gets.
+	Score     float64   `json:"score"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// UpdateStability scores every scenario of the last n runs and replaces the
+// stored scores with them. Close calls it with DefaultStabilityRuns after a
+// run that executed scenarios. Scenarios that ran in fewer than three runs
+// are not scored.
+func (v *VectorClockAgent) UpdateStability(n int) ([]ScenarioStability, error) {
+	runIDs, err := v.RecentRuns(n)
+	if err != nil {
+		return nil, err
+	}
+	if len(runIDs) == 0 {
+		return nil, nil
+	}
+	v.sync()
+
+	order := make(map[string]int, len(runIDs))
+	args := make([]interface{}, len(runIDs))
+	for i, runID := range runIDs {
+		order[runID] = i
+		args[i] = runID
+	}
+	rows, err := v.db.Query(`
+		SELECT run_id, scenario_name, MAX(COALESCE(feature_uri, '')),
+			MAX(`+primaryPhase+`),
+			SUM(CASE WHEN `+primaryPhase+` THEN `+durationNs+` ELSE 0 END),
+			MAX(`+primaryPhase+` AND COALESCE(status, '') = 'failed'),
+			MAX(`+primaryPhase+` AND COALESCE(status, '') = 'passed'),
+			MAX(COALESCE(phase, 'primary') = 'retry')
+		FROM step_timings
+		WHERE run_id IN (?`+strings.Repeat(", ?", len(runIDs)-1)+`)
+		GROUP BY run_id, scenario_name
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load scenario history: %w", err)
+	}
+	defer rows.Close()
+
+	type sample struct {
+		age                     int
+		duration                time.Duration
+		failed, passed, retried bool
+	}
+	history := make(map[string][]sample)
+	uris := make(map[string]string)
+	for rows.Next() {
+		var runID, scenario, uri string
+		var primary bool
+		var durationNs int64
+		var s sample
+		if err := rows.Scan(&runID, &scenario, &uri, &primary, &durationNs, &s.failed, &s.passed, &s.retried); err != nil {
+			return nil, err
+		}
+		if !primary {
+			continue
+		}
+		s.age = order[runID]
+		s.duration = time.Duration(durationNs)
+		history[scenario] = append(history[scenario], s)
+		if uri != "" {
+			uris[scenario] = uri
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	var scores []ScenarioStability
+	for scenario, samples := range history {
+		if len(samples) < 3 {
+			continue
+		}
+		s := ScenarioStability{Scenario: scenario, FeatureURI: uris[scenario], Runs: len(samples), PassRate: 1, UpdatedAt: now}
+
+		// The newest run weighs len(runIDs), the oldest 1. Skipped or
+		// pending runs say nothing about whether the scenario passes.
+		var sum, sumSq, passed, weights float64
+		var retried int
+		for _, sm := range samples {
+			sum += float64(sm.duration)
+			sumSq += float64(sm.duration) * float64(sm.duration)
+			if sm.retried {
+				retried++
+			}
+			if sm.failed || sm.passed {
+				w := float64(len(runIDs) - sm.age)
+				weights += w
+				if !sm.failed {
+					passed += w
+				}
+			}
+		}
+		if weights > 0 {
+			s.PassRate = passed / weights
+		}
+		if mean := sum / float64(len(samples)); mean > 0 {
+			s.CV = math.Sqrt(math.Max(sumSq/float64(len(samples))-mean*mean, 0)) / mean
+		}
+		s.RetryRate = float64(retried) / float64(len(samples))
+		s.Score = stabilityPassWeight*s.PassRate +
+			stabilityDurationWeight*(1-math.Min(s.CV, 1)) +
+			stabilityRetryWeight*(1-s.RetryRate)
+		scores = append(scores, s)
+	}
+	SortStability(scores, StabilityByScore)
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(`DELETE FROM scenario_stability`); err != nil {
+		return nil, fmt.Errorf("failed to clear stability scores: %w", err)
+	}
+	for _, s := range scores {
+		_, err := tx.Exec(`
+			INSERT INTO scenario_stability (scenario_name, feature_uri, run_count, pass_rate, duration_cv, retry_rate, score, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		`, s.Scenario, s.FeatureURI, s.Runs, s.PassRate, s.CV, s.RetryRate, s.Score, now.Format(sqliteTimeLayout))
+		if err != nil {
+			return nil, fmt.Errorf("failed to store stability of %s: %w", s.Scenario, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to store stability scores: %w", err)
+	}
+	return scores, nil
+}
+
+// updateStability refreshes the stored scores after a run that executed
+// scenarios.
+func (v *VectorClockAgent) updateStability() error {
+	if atomic.LoadUint64(&v.scenarios) == 0 || v.collector != nil {
+		return nil
+	}
+	_, err := v.UpdateStability(DefaultStabilityRuns)
+	return err
+}
+
+// Stability returns the stored stability scores, least stable first.
+func (v *VectorClockAgent) Stability() ([]ScenarioStability, error) {
+	rows, err := v.db.Query(`
+		SELECT scenario_name, COALESCE(feature_uri, ''), run_count, pass_rate, duration_cv, retry_rate, score, updated_at
+		FROM scenario_stability
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stability scores: %w", err)
+	}
+	defer rows.Close()
+
+	var scores []ScenarioStability
+	for rows.Next() {
+		var s ScenarioStability
+		var updatedAt timestamp
+		if err := rows.Scan(&s.Scenario, &s.FeatureURI, &s.Runs, &s.PassRate, &s.CV, &s.RetryRate, &s.Score, &updatedAt); err != nil {
+			return nil, err
+		}
+		s.UpdatedAt = updatedAt.Time
+		scores = append(scores, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	SortStability(scores, StabilityByScore)
+	return scores, nil
+}
+
+// SortStability orders scores so the scenarios most in need of maintenance
+// come first: lowest score or pass rate, highest duration variance or retry
+// rate, or by name. It reports whether by is a known order.
+func SortStability(scores []ScenarioStability, by string) bool {
+	var less func(a, b ScenarioStability) bool
+	switch by {
+	case StabilityByScore:
+		less = func(a, b ScenarioStability) bool { return a.Score < b.Score }
+	case StabilityByPassRate:
+		less = func(a, b ScenarioStability) bool { return a.PassRate < b.PassRate }
+	case StabilityByVariance:
+		less = func(a, b ScenarioStability) bool { return a.CV > b.CV }
+	case StabilityByRetries:
+		less = func(a, b ScenarioStability) bool { return a.RetryRate > b.RetryRate }
+	case StabilityByName:
+		less = func(ScenarioStability, ScenarioStability) bool { return false }
+	default:
+		return false
+	}
+	sort.SliceStable(scores, func(i, j int) bool {
+		if less(scores[i], scores[j]) {
+			return true
+		}
+		if less(scores[j], scores[i]) {
+			return false
+		}
+		return scores[i].Scenario < scores[j].Scenario
+	})
+	return true
+}
+
+// WriteStability prints stability scores one per line.
+func WriteStability(w io.Writer, scores []ScenarioStability) error {
+	for _, s := range scores {
+		name := s.Scenario
+		if s.FeatureURI != "" {
+			name += " (" + s.FeatureURI + ")"
+		}
+		_, err := fmt.Fprintf(w, "%.2f %s: %d runs, passed %.0f%%, cv %.2f, retried %.0f%%\n",
+			s.Score, name, s.Runs, 100*s.PassRate, s.CV, 100*s.RetryRate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
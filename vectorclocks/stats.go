@@ -0,0 +1,203 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// percentile returns the p-th percentile (0-100) of sorted using the
+// nearest-rank method. sorted must be in ascending order.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+func sortDurations(d []time.Duration) {
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+}
+
+// StepStats summarises the duration distribution of one step text across
+// all recorded runs.
+type StepStats struct {
+	Step  string
+	Count int
+	Mean  time.Duration
+	Min   time.Duration
+	Max   time.Duration
+	P50   time.Duration
+	P90   time.Duration
+	P95   time.Duration
+	P99   time.Duration
+}
+
+// StepStats returns the duration distribution of every step text in the
+// primary phase, slowest p95 first. Step texts are compared with runs of
+// whitespace collapsed. With WithApproximateStats the percentiles are
+// estimated from sampled durations.
+func (v *VectorClockAgent) StepStats() ([]StepStats, error) {
+	if v.reservoirSize > 0 {
+		return v.approximateStepStats()
+	}
+	v.sync()
+
+	rows, err := v.db.Query(`SELECT step_text, ` + durationNs + ` FROM step_timings WHERE ` + primaryPhase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load step durations: %w", err)
+	}
+	defer rows.Close()
+
+	samples := make(map[string][]time.Duration)
+	for rows.Next() {
+		var text string
+		var durationNs int64
+		if err := rows.Scan(&text, &durationNs); err != nil {
+			return nil, err
+		}
+		text = normalizeStepText(text)
+		samples[text] = append(samples[text], time.Duration(durationNs))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	stats := make([]StepStats, 0, len(samples))
+	for text, d := range samples {
+		stats = append(stats, newStepStats(text, d))
+	}
+	sortStepStats(stats)
+	return stats, nil
+}
+
+// sortStepStats orders stats slowest p95 first.
+func sortStepStats(stats []StepStats) {
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].P95 != stats[j].P95 {
+			return stats[i].P95 > stats[j].P95
+		}
+		return stats[i].Step < stats[j].Step
+	})
+}
+
+func newStepStats(step string, d []time.Duration) StepStats {
+	sortDurations(d)
+	var sum time.Duration
+	for _, x := range d {
+		sum += x
+	}
+	return StepStats{
+		Step:  step,
+		Count: len(d),
+		Mean:  sum / time.Duration(len(d)),
+		Min:   d[0],
+		Max:   d[len(d)-1],
+		P50:   percentile(d, 50),
+		P90:   percentile(d, 90),
+		P95:   percentile(d, 95),
+		P99:   percentile(d, 99),
+	}
+}
+
+// ScenarioStats summarises how long one scenario took across recorded runs.
+// A scenario's duration in a run is the sum of its steps, as in Top.
+type ScenarioStats struct {
+	Scenario string
+	// Runs is the number of runs that executed the scenario, and Failed
+	// how many of them had a failed step.
+	Runs   int
+	Failed int
+	Mean   time.Duration
+	Min    time.Duration
+	Max    time.Duration
+	P50    time.Duration
+	P90    time.Duration
+	P95    time.Duration
+	P99    time.Duration
+}
+
+// ScenarioStats returns the duration distribution of the scenario named
+// name in the primary phase. Runs is 0 when the scenario was never
+// recorded.
+func (v *VectorClockAgent) ScenarioStats(name string) (ScenarioStats, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT SUM(`+durationNs+`), MAX(COALESCE(status, '') = 'failed')
+		FROM step_timings
+		WHERE scenario_name = ? AND `+primaryPhase+`
+		GROUP BY COALESCE(run_id, '')
+	`, name)
+	if err != nil {
+		return ScenarioStats{}, fmt.Errorf("failed to load durations of scenario %q: %w", name, err)
+	}
+	defer rows.Close()
+
+	s := ScenarioStats{Scenario: name}
+	var d []time.Duration
+	for rows.Next() {
+		var totalNs int64
+		var failed bool
+		if err := rows.Scan(&totalNs, &failed); err != nil {
+			return ScenarioStats{}, err
+		}
+		d = append(d, time.Duration(totalNs))
+		if failed {
+			s.Failed++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return ScenarioStats{}, err
+	}
+	if len(d) == 0 {
+		return s, nil
+	}
+
+	st := newStepStats(name, d)
+	s.Runs = st.Count
+	s.Mean, s.Min, s.Max = st.Mean, st.Min, st.Max
+	s.P50, s.P90, s.P95, s.P99 = st.P50, st.P90, st.P95, st.P99
+	return s, nil
+}
+
+func normalizeStepText(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// WriteStepStats prints stats as a table, one step per row, with durations
+// rounded to the millisecond.
+func WriteStepStats(w io.Writer, stats []StepStats) error {
+	return WriteStepStatsIn(w, stats, "")
+}
+
+// WriteStepStatsIn is WriteStepStats with durations printed in unit. An
+// empty unit rounds to the millisecond.
+func WriteStepStatsIn(w io.Writer, stats []StepStats, unit DisplayUnit) error {
+	if _, err := fmt.Fprintf(w, "%6s %12s %12s %12s %12s %12s %12s %12s  %s\n",
+		"count", "mean", "min", "p50", "p90", "p95", "p99", "max", "step"); err != nil {
+		return err
+	}
+	ms := func(d time.Duration) string { return d.Round(time.Millisecond).String() }
+	if unit != "" {
+		ms = unit.Format
+	}
+	for _, s := range stats {
+		_, err := fmt.Fprintf(w, "%6d %12s %12s %12s %12s %12s %12s %12s  %s\n",
+			s.Count, ms(s.Mean), ms(s.Min), ms(s.P50), ms(s.P90), ms(s.P95), ms(s.P99), ms(s.Max), s.Step)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	ms := func(n ...int) []time.Duration {
+		d := make([]time.Duration, len(n))
+		for i, x := range n {
+			d[i] = time.Duration(x) * time.Millisecond
+		}
+		return d
+	}
+	hundred := make([]int, 100)
+	for i := range hundred {
+		hundred[i] = i + 1
+	}
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"empty", nil, 50, 0},
+		{"single", ms(7), 99, 7 * time.Millisecond},
+		{"p0 is the minimum", ms(1, 2, 3), 0, time.Millisecond},
+		{"p100 is the maximum", ms(1, 2, 3), 100, 3 * time.Millisecond},
+		{"median of odd count", ms(1, 2, 3), 50, 2 * time.Millisecond},
+		{"median of even count takes the lower", ms(1, 2, 3, 4), 50, 2 * time.Millisecond},
+		{"nearest rank rounds up", ms(1, 2, 3, 4), 51, 3 * time.Millisecond},
+		{"p90 of 1..100", ms(hundred...), 90, 90 * time.Millisecond},
+		{"p99 of 1..100", ms(hundred...), 99, 99 * time.Millisecond},
+		{"p99.5 of 1..100", ms(hundred...), 99.5, 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(tt.sorted, tt.p); got != tt.want {
+			t.Errorf("%s: percentile(%v) = %s, want %s", tt.name, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestNewStepStats(t *testing.T) {
+	ms := time.Millisecond
+	got := newStepStats("a step", []time.Duration{40 * ms, 10 * ms, 30 * ms, 20 * ms})
+	want := StepStats{Step: "a step", Count: 4, Mean: 25 * ms, Min: 10 * ms, Max: 40 * ms, P50: 20 * ms, P90: 40 * ms, P95: 40 * ms, P99: 40 * ms}
+	if got != want {
+		t.Errorf("newStepStats = %+v, want %+v", got, want)
+	}
+}
+
+func TestStepStats(t *testing.T) {
+	a, _ := newTestAgent(t)
+	defer a.Close()
+	start := time.Now().Add(-time.Hour)
+	var batch []stepRecord
+	add := func(text, phase string, ms ...int) {
+		for _, n := range ms {
+			d := time.Duration(n) * time.Millisecond
+			batch = append(batch, stepRecord{
+				stepID:    fmt.Sprintf("s%d", len(batch)),
+				runID:     "r1",
+				stepText:  text,
+				phase:     phase,
+				duration:  d,
+				startedAt: start,
+				endedAt:   start.Add(d),
+			})
+		}
+	}
+	add("fast step", phasePrimary, 1, 2, 3)
+	add("slow step", phasePrimary, 100, 200)
+	add("slow  step", phasePrimary, 300)
+	add("slow step", phaseRetry, 10000)
+	if err := a.writeBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := a.StepStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range stats {
+		got = append(got, fmt.Sprintf("%s n=%d p50=%s max=%s", s.Step, s.Count, s.P50, s.Max))
+	}
+	want := []string{
+		"slow step n=3 p50=200ms max=300ms",
+		"fast step n=3 p50=2ms max=3ms",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("StepStats = %q, want %q", got, want)
+	}
+}
+
+func TestApproximateStepStatsKeepNanoseconds(t *testing.T) {
+	a, _ := newTestAgent(t, WithApproximateStats(0))
+	defer a.Close()
+	start := time.Now().Add(-time.Hour)
+	var batch []stepRecord
+	for i, d := range []time.Duration{300 * time.Microsecond, 500 * time.Microsecond, 700 * time.Microsecond} {
+		batch = append(batch, stepRecord{
+			stepID:    fmt.Sprintf("s%d", i),
+			runID:     "r1",
+			stepText:  "a quick step",
+			phase:     phasePrimary,
+			duration:  d,
+			startedAt: start,
+			endedAt:   start.Add(d),
+		})
+	}
+	if err := a.writeBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	want := StepStats{Step: "a quick step", Count: 3, Mean: 500 * time.Microsecond, Min: 300 * time.Microsecond, Max: 700 * time.Microsecond,
+		P50: 500 * time.Microsecond, P90: 700 * time.Microsecond, P95: 700 * time.Microsecond, P99: 700 * time.Microsecond}
+	for _, rebuild := range []bool{false, true} {
+		if rebuild {
+			if err := a.RebuildApproximateStats(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		stats, err := a.approximateStepStats()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats) != 1 || stats[0] != want {
+			t.Errorf("rebuilt %t: approximate stats = %+v, want %+v", rebuild, stats, want)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package vectorclocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// FormatStats selects the StatsBundle of a suite in the export command.
+const FormatStats = "stats"
+
+// statsBundleVersion is bumped when the stats bundle format changes
+// incompatibly.
+const statsBundleVersion = 1
+
+// StatsBundle holds aggregated duration statistics of a suite without the
+// timings they were computed from, so that teams can share what their runs
+// usually take without sharing the database. ImportStats merges bundles
+// into another database's stored baselines.
+type StatsBundle struct {
+	Version   int          `json:"version"`
+	CreatedAt time.Time    `json:"created_at"`
+	Suite     string       `json:"suite"`
+	Runs      int          `json:"runs"`
+	Total     StatsEntry   `json:"total"`
+	Scenarios []StatsEntry `json:"scenarios"`
+	Steps     []StatsEntry `json:"steps"`
+}
+
+// StatsEntry is the duration distribution of the run total, a scenario or
+// a step over the runs of a bundle. Step entries carry both Scenario and
+// Step; scenario entries only Scenario. Durations are in nanoseconds.
+type StatsEntry struct {
+	Scenario string    `json:"scenario,omitempty"`
+	Step     string    `json:"step,omitempty"`
+	Count    int       `json:"count"`
+	MeanNs   int64     `json:"mean_ns"`
+	P50Ns    int64     `json:"p50_ns"`
+	P90Ns    int64     `json:"p90_ns"`
+	P95Ns    int64     `json:"p95_ns"`
+	P99Ns    int64     `json:"p99_ns"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+func (e StatsEntry) name() string {
+	if e.Step == "" {
+		return e.Scenario
+	}
+	return StepKey{Scenario: e.Scenario, Step: e.Step}.String()
+}
+
+// statsSamples collects the durations of one entry and when it last ran.
+type statsSamples struct {
+	durations []time.Duration
+	lastSeen  time.Time
+}
+
+func (s *statsSamples) add(d time.Duration, at time.Time) {
+	s.durations = append(s.durations, d)
+	if at.After(s.lastSeen) {
+		s.lastSeen = at
+	}
+}
+
+func (s *statsSamples) entry(key StepKey) StatsEntry {
+	st := newStepStats(key.Step, s.durations)
+	return StatsEntry{
+		Scenario: key.Scenario,
+		Step:     key.Step,
+		Count:    st.Count,
+		MeanNs:   st.Mean.Nanoseconds(),
+		P50Ns:    st.P50.Nanoseconds(),
+		P90Ns:    st.P90.Nanoseconds(),
+		P95Ns:    st.P95.Nanoseconds(),
+		P99Ns:    st.P99.Nanoseconds(),
+		LastSeen: s.lastSeen,
+	}
+}
+
+// ExportStats aggregates the runs of suite in window, the runs stored
+// baselines are computed from, into a StatsBundle: per run total, scenario
+// and step, the number of runs it appeared in, its mean and percentile
+// durations and when it last ran.
+func (v *VectorClockAgent) ExportStats(suite string, window BaselineWindow) (StatsBundle, error) {
+	v.sync()
+
+	runIDs, err := v.baselineWindowRuns(suite, window)
+	if err != nil {
+		return StatsBundle{}, err
+	}
+	if len(runIDs) == 0 {
+		return StatsBundle{}, fmt.Errorf("no green runs of suite %q to export statistics from", suite)
+	}
+
+	var total statsSamples
+	scenarios := make(map[string]*statsSamples)
+	steps := make(map[StepKey]*statsSamples)
+	for _, runID := range runIDs {
+		rt, err := v.RunTimings(runID)
+		if err != nil {
+			return StatsBundle{}, err
+		}
+		var startedAt timestamp
+		if err := v.db.QueryRow(`SELECT started_at FROM runs WHERE run_id = ?`, runID).Scan(&startedAt); err != nil {
+			return StatsBundle{}, fmt.Errorf("failed to load start of run %s: %w", runID, err)
+		}
+		total.add(rt.Total, startedAt.Time)
+		for name, d := range rt.Scenarios {
+			if scenarios[name] == nil {
+				scenarios[name] = &statsSamples{}
+			}
+			scenarios[name].add(d, startedAt.Time)
+		}
+		for key, d := range rt.Steps {
+			if steps[key] == nil {
+				steps[key] = &statsSamples{}
+			}
+			steps[key].add(d, startedAt.Time)
+		}
+	}
+
+	b := StatsBundle{
+		Version:   statsBundleVersion,
+		CreatedAt: v.now().UTC().Truncate(time.Second),
+		Suite:     suite,
+		Runs:      len(runIDs),
+		Total:     total.entry(StepKey{}),
+	}
+	for name, s := range scenarios {
+		b.Scenarios = append(b.Scenarios, s.entry(StepKey{Scenario: name}))
+	}
+	for key, s := range steps {
+		b.Steps = append(b.Steps, s.entry(key))
+	}
+	sortStatsEntries(b.Scenarios)
+	sortStatsEntries(b.Steps)
+	return b, nil
+}
+
+func sortStatsEntries(entries []StatsEntry) {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name() < entries[j].name() })
+}
+
+// Write encodes b as JSON.
+func (b StatsBundle) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b)
+}
+
+// LoadStatsBundle reads a bundle written by StatsBundle.Write.
+func LoadStatsBundle(path string) (StatsBundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return StatsBundle{}, fmt.Errorf("failed to read stats bundle: %w", err)
+	}
+	var b StatsBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return StatsBundle{}, fmt.Errorf("failed to parse stats bundle %s: %w", path, err)
+	}
+	if b.Version != statsBundleVersion {
+		return StatsBundle{}, fmt.Errorf("stats bundle %s has version %d, want %d", path, b.Version, statsBundleVersion)
+	}
+	return b, nil
+}
+
+// source names the bundle among imported baselines: its suite and when it
+// was created.
+func (b StatsBundle) source() string {
+	return b.Suite + "@" + b.CreatedAt.UTC().Format(time.RFC3339)
+}
+
+// ImportStats stores bundles as imported baselines of suite. They are kept
+// apart from the baselines RecomputeBaselines computes from local runs, so
+// later local runs do not replace them, and StoredBaselineOf combines both.
+// Importing a bundle again replaces its earlier import.
+func (v *VectorClockAgent) ImportStats(suite string, bundles ...StatsBundle) (StoredBaseline, error) {
+	if v.readOnly {
+		return StoredBaseline{}, ErrReadOnly
+	}
+	v.sync()
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return StoredBaseline{}, err
+	}
+	defer tx.Rollback()
+	importedAt := formatPrecise(v.now().UTC())
+	for _, b := range bundles {
+		if _, err := tx.Exec(`DELETE FROM imported_baselines WHERE source = ? AND suite = ?`, b.source(), suite); err != nil {
+			return StoredBaseline{}, fmt.Errorf("failed to replace imported baselines of suite %q: %w", suite, err)
+		}
+		insert := func(kind string, e StatsEntry) error {
+			if e.Count <= 0 {
+				return nil
+			}
+			_, err := tx.Exec(`
+				INSERT INTO imported_baselines (source, suite, kind, scenario_name, step_text, median_ns, run_count, imported_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			`, b.source(), suite, kind, e.Scenario, e.Step, e.P50Ns, e.Count, importedAt)
+			if err != nil {
+				return fmt.Errorf("failed to import statistics into baselines of suite %q: %w", suite, err)
+			}
+			return nil
+		}
+		if err := insert("total", b.Total); err != nil {
+			return StoredBaseline{}, err
+		}
+		for _, e := range b.Scenarios {
+			if err := insert("scenario", e); err != nil {
+				return StoredBaseline{}, err
+			}
+		}
+		for _, e := range b.Steps {
+			if err := insert("step", e); err != nil {
+				return StoredBaseline{}, err
+			}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return StoredBaseline{}, fmt.Errorf("failed to commit baselines of suite %q: %w", suite, err)
+	}
+
+	stored, _, err := v.StoredBaselineOf(suite)
+	return stored, err
+}
+
+// baselineMedian is a stored median and the number of runs it was taken
+// over.
+type baselineMedian struct {
+	median time.Duration
+	runs   int
+}
+
+// pooledMedian combines medians taken over separate sets of runs into the
+// median of all runs, counting each median once per run of its set; the
+// nearest rank is taken as percentile does.
+func pooledMedian(medians []baselineMedian) time.Duration {
+	sort.Slice(medians, func(i, j int) bool { return medians[i].median < medians[j].median })
+	total := 0
+	for _, m := range medians {
+		total += m.runs
+	}
+	rank := (total + 1) / 2
+	for _, m := range medians {
+		if rank <= m.runs {
+			return m.median
+		}
+		rank -= m.runs
+	}
+	return 0
+}
@@ -0,0 +1,96 @@
+package vectorclocks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+func TestPooledMedian(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name    string
+		medians []baselineMedian
+		want    time.Duration
+	}{
+		{"none", nil, 0},
+		{"one set", []baselineMedian{{10 * ms, 5}}, 10 * ms},
+		{"equal sets", []baselineMedian{{30 * ms, 2}, {10 * ms, 2}}, 10 * ms},
+		{"larger set wins", []baselineMedian{{10 * ms, 1}, {50 * ms, 1}, {20 * ms, 5}}, 20 * ms},
+		{"outlier set", []baselineMedian{{10 * ms, 3}, {20 * ms, 3}, {900 * ms, 1}}, 20 * ms},
+	}
+	for _, tt := range tests {
+		if got := pooledMedian(tt.medians); got != tt.want {
+			t.Errorf("%s: pooledMedian = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestImportedBaselinesSurviveLocalRuns(t *testing.T) {
+	run := func(a *VectorClockAgent, d time.Duration) {
+		t.Helper()
+		status := runFeature(t, a, 1, `Feature: stats
+  Scenario: one
+    Given a step
+`, func(ctx *godog.ScenarioContext) {
+			ctx.Step(`^a step$`, func(context.Context) error {
+				time.Sleep(d)
+				return nil
+			})
+		})
+		if status != 0 {
+			t.Fatalf("suite status = %d, want 0", status)
+		}
+	}
+
+	_, remotePath := newTestAgent(t)
+	for i := 0; i < 3; i++ {
+		a, err := NewVectorClockAgent(remotePath, WithVerbosity(VerbositySilent))
+		if err != nil {
+			t.Fatal(err)
+		}
+		run(a, 20*time.Millisecond)
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	remote, err := NewVectorClockAgent(remotePath, WithVerbosity(VerbositySilent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bundle, err := remote.ExportStats("", BaselineWindow{})
+	remote.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, localPath := newTestAgent(t, WithStoredBaselines(BaselineWindow{}))
+	for i := 0; i < 2; i++ {
+		if _, err := local.ImportStats("", bundle); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run(local, 0)
+	if err := local.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := NewVectorClockAgent(localPath, WithVerbosity(VerbositySilent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, ok, err := a.StoredBaselineOf("")
+	if err != nil || !ok {
+		t.Fatalf("StoredBaselineOf = %v, %v", ok, err)
+	}
+	if b.Runs != 4 {
+		t.Errorf("baseline covers %d runs, want 3 imported and 1 local", b.Runs)
+	}
+	key := StepKey{Scenario: "one", Step: "a step"}
+	if d := b.Timings.Steps[key]; d < 20*time.Millisecond {
+		t.Errorf("median of %s = %s, want the imported median of at least 20ms", key, d)
+	}
+}
@@ -0,0 +1,59 @@
+package vectorclocks
+
+import (
+	"math/rand"
+	"time"
+)
+
+// SamplingPolicy configures WithStepSampling.
+type SamplingPolicy struct {
+	// Rate keeps one in Rate step executions; 1 or less keeps them all.
+	Rate int
+	// Slow steps, those taking at least Slow, are always kept; 0 keeps
+	// only failed ones unconditionally.
+	Slow time.Duration
+}
+
+// WithStepSampling records only one in p.Rate step executions, chosen at
+// random, to keep the database small and writes cheap on suites with
+// hundreds of thousands of step executions. Steps that did not pass or
+// skip, and steps slower than p.Slow, are always recorded. Each sampled
+// step stores the rate in sample_rate, and RunTimings weighs it by that
+// rate, so run and scenario totals stay comparable with unsampled runs;
+// per-step statistics are computed from the recorded steps only.
+func WithStepSampling(p SamplingPolicy) Option {
+	return func(v *VectorClockAgent) {
+		if p.Rate > 1 {
+			v.sampling = &p
+		}
+	}
+}
+
+// keep reports whether rec is recorded unconditionally.
+func (p *SamplingPolicy) keep(rec stepRecord) bool {
+	switch rec.status {
+	case "passed", "skipped":
+	default:
+		return true
+	}
+	return p.Slow > 0 && rec.duration >= p.Slow
+}
+
+// sample drops the records not sampled and marks the sampled ones with the
+// rate they stand for.
+func (v *VectorClockAgent) sample(records []stepRecord) []stepRecord {
+	p := v.sampling
+	kept := records[:0:0]
+	for _, rec := range records {
+		switch {
+		case p.keep(rec):
+		case rand.Intn(p.Rate) == 0:
+			rec.sampleRate = p.Rate
+		default:
+			v.unsampled.Add(1)
+			continue
+		}
+		kept = append(kept, rec)
+	}
+	return kept
+}
@@ -0,0 +1,136 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WithSuite names the godog suite the agent records, such as "api", "ui"
+// or "contracts", so several suites can share one database. The name is
+// stored with the run; regression gates and baselines then only compare
+// runs of the same suite, and reports can be narrowed to one suite
+// (TimingFilter.Suite) or grouped by suite (Suites). It defaults to the
+// WithNamespace name.
+func WithSuite(name string) Option {
+	return func(v *VectorClockAgent) {
+		v.suite = name
+	}
+}
+
+// Suite returns the name set with WithSuite.
+func (v *VectorClockAgent) Suite() string {
+	return v.suite
+}
+
+// SuiteRuns returns the IDs of the n most recently started runs of suite,
+// newest first. The empty suite holds the runs recorded without
+// WithSuite.
+func (v *VectorClockAgent) SuiteRuns(suite string, n int) ([]string, error) {
+	rows, err := v.db.Query(`
+		SELECT run_id FROM runs
+		WHERE COALESCE(suite, '') = ?
+		ORDER BY started_at DESC, rowid DESC
+		LIMIT ?
+	`, suite, n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list runs of suite %q: %w", suite, err)
+	}
+	defer rows.Close()
+
+	var runIDs []string
+	for rows.Next() {
+		var runID string
+		if err := rows.Scan(&runID); err != nil {
+			return nil, err
+		}
+		runIDs = append(runIDs, runID)
+	}
+	return runIDs, rows.Err()
+}
+
+// SuiteSummary describes the runs of one suite.
+type SuiteSummary struct {
+	// Suite is "" for runs recorded without WithSuite.
+	Suite string
+	Runs  int
+	// LatestRun is the most recently started run, which took Wall from
+	// start to finish and StepTime summed over its primary-phase steps.
+	LatestRun     string
+	LatestStarted time.Time
+	Wall          time.Duration
+	StepTime      time.Duration
+	Scenarios     int
+}
+
+// Suites summarizes the runs of every suite in the database, by suite
+// name.
+func (v *VectorClockAgent) Suites() ([]SuiteSummary, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT COALESCE(suite, ''), COUNT(*) FROM runs
+		GROUP BY COALESCE(suite, '')
+		ORDER BY COALESCE(suite, '')
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list suites: %w", err)
+	}
+	var suites []SuiteSummary
+	for rows.Next() {
+		var s SuiteSummary
+		if err := rows.Scan(&s.Suite, &s.Runs); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		suites = append(suites, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range suites {
+		s := &suites[i]
+		var started, finished timestamp
+		var stepNs sql.NullInt64
+		err := v.db.QueryRow(`
+			SELECT r.run_id, r.started_at, r.finished_at,
+				(SELECT SUM(`+durationNs+`) FROM step_timings WHERE run_id = r.run_id AND `+primaryPhase+`),
+				(SELECT COUNT(DISTINCT scenario_name) FROM step_timings WHERE run_id = r.run_id AND `+primaryPhase+`)
+			FROM runs r
+			WHERE COALESCE(r.suite, '') = ?
+			ORDER BY r.started_at DESC, r.rowid DESC
+			LIMIT 1
+		`, s.Suite).Scan(&s.LatestRun, &started, &finished, &stepNs, &s.Scenarios)
+		if err != nil {
+			return nil, fmt.Errorf("failed to summarize suite %q: %w", s.Suite, err)
+		}
+		s.LatestStarted = started.Time
+		if !started.IsZero() && !finished.IsZero() {
+			s.Wall = finished.Sub(started.Time)
+		}
+		s.StepTime = time.Duration(stepNs.Int64)
+	}
+	return suites, nil
+}
+
+// WriteSuites prints suites as a table, one suite per row.
+func WriteSuites(w io.Writer, suites []SuiteSummary) error {
+	if _, err := fmt.Fprintf(w, "%-16s %6s %10s %10s %9s  %s\n", "suite", "runs", "wall", "step time", "scenarios", "latest run"); err != nil {
+		return err
+	}
+	for _, s := range suites {
+		name := s.Suite
+		if name == "" {
+			name = "(none)"
+		}
+		_, err := fmt.Fprintf(w, "%-16s %6d %10s %10s %9d  %s\n", name, s.Runs,
+			s.Wall.Round(time.Millisecond), s.StepTime.Round(time.Millisecond), s.Scenarios, s.LatestRun)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
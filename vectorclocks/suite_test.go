@@ -0,0 +1,40 @@
+package vectorclocks
+
+import (
+	"io"
+	"path/filepath"
+	"testing"
+
+	"github.com/cucumber/godog"
+)
+
+// newTestAgent opens an agent on a database in a temporary directory.
+func newTestAgent(t *testing.T, opts ...Option) (*VectorClockAgent, string) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "timings.db")
+	a, err := NewVectorClockAgent(dbPath, append([]Option{WithVerbosity(VerbositySilent)}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewVectorClockAgent: %v", err)
+	}
+	return a, dbPath
+}
+
+// runFeature runs feature through the agent's hooks with the steps
+// registered by steps and returns godog's exit status.
+func runFeature(t *testing.T, a *VectorClockAgent, concurrency int, feature string, steps func(*godog.ScenarioContext)) int {
+	t.Helper()
+	return a.RunSuite(godog.TestSuite{
+		Name: t.Name(),
+		ScenarioInitializer: func(ctx *godog.ScenarioContext) {
+			a.InitializeScenario(ctx)
+			steps(ctx)
+		},
+		Options: &godog.Options{
+			Format:          "progress",
+			Output:          io.Discard,
+			Concurrency:     concurrency,
+			Strict:          true,
+			FeatureContents: []godog.Feature{{Name: "test.feature", Contents: []byte(feature)}},
+		},
+	})
+}
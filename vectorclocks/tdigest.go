@@ -0,0 +1,191 @@
+package vectorclocks
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DefaultCompression is the t-digest compression used for step digests.
+// Higher values keep more centroids and give more accurate quantiles.
+const DefaultCompression = 100
+
+// TDigest is a merging t-digest: a compact sketch of a distribution that
+// answers quantile queries with small relative error at the tails, and that
+// can be merged with other digests.
+type TDigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	count       float64
+	min, max    float64
+}
+
+type centroid struct {
+	mean, weight float64
+}
+
+// NewTDigest returns an empty digest. compression <= 0 means
+// DefaultCompression.
+func NewTDigest(compression float64) *TDigest {
+	if compression <= 0 {
+		compression = DefaultCompression
+	}
+	return &TDigest{compression: compression, min: math.Inf(1), max: math.Inf(-1)}
+}
+
+// Add records one value.
+func (t *TDigest) Add(x float64) {
+	t.add(centroid{mean: x, weight: 1})
+}
+
+func (t *TDigest) add(c centroid) {
+	t.buffer = append(t.buffer, c)
+	t.count += c.weight
+	t.min = math.Min(t.min, c.mean)
+	t.max = math.Max(t.max, c.mean)
+	if len(t.buffer) >= int(5*t.compression) {
+		t.compress()
+	}
+}
+
+// Merge adds every value recorded by other.
+func (t *TDigest) Merge(other *TDigest) {
+	other.compress()
+	for _, c := range other.centroids {
+		t.add(c)
+	}
+	if other.count > 0 {
+		t.min = math.Min(t.min, other.min)
+		t.max = math.Max(t.max, other.max)
+	}
+}
+
+// Count is the number of values recorded.
+func (t *TDigest) Count() float64 {
+	return t.count
+}
+
+// compress merges the buffered values into the centroids. A centroid may
+// grow to 4·n·q·(1-q)/compression, so centroids near the tails stay small.
+func (t *TDigest) compress() {
+	if len(t.buffer) == 0 {
+		return
+	}
+	all := append(t.centroids, t.buffer...)
+	t.buffer = t.buffer[:0]
+	sort.Slice(all, func(i, j int) bool { return all[i].mean < all[j].mean })
+
+	merged := make([]centroid, 0, len(all))
+	cur := all[0]
+	var before float64
+	for _, c := range all[1:] {
+		proposed := cur.weight + c.weight
+		q := (before + proposed/2) / t.count
+		if proposed <= 4*t.count*q*(1-q)/t.compression {
+			cur.mean += (c.mean - cur.mean) * c.weight / proposed
+			cur.weight = proposed
+			continue
+		}
+		merged = append(merged, cur)
+		before += cur.weight
+		cur = c
+	}
+	t.centroids = append(merged, cur)
+}
+
+// Quantile returns the estimated value at quantile q (0-1), or NaN when the
+// digest is empty.
+func (t *TDigest) Quantile(q float64) float64 {
+	t.compress()
+	if len(t.centroids) == 0 {
+		return math.NaN()
+	}
+	if q <= 0 {
+		return t.min
+	}
+	if q >= 1 {
+		return t.max
+	}
+	if len(t.centroids) == 1 {
+		return t.centroids[0].mean
+	}
+
+	index := q * t.count
+	first := t.centroids[0]
+	if index < first.weight/2 {
+		return t.min + (first.mean-t.min)*index/(first.weight/2)
+	}
+	cumulative := 0.0
+	for i := 0; i < len(t.centroids)-1; i++ {
+		a, b := t.centroids[i], t.centroids[i+1]
+		left := cumulative + a.weight/2
+		right := cumulative + a.weight + b.weight/2
+		if index < right {
+			return a.mean + (b.mean-a.mean)*(index-left)/(right-left)
+		}
+		cumulative += a.weight
+	}
+	last := t.centroids[len(t.centroids)-1]
+	tail := index - (t.count - last.weight/2)
+	return last.mean + (t.max-last.mean)*tail/(last.weight/2)
+}
+
+// tdigestVersion is the first byte of the binary encoding.
+const tdigestVersion = 1
+
+// MarshalBinary encodes the digest for storage.
+func (t *TDigest) MarshalBinary() ([]byte, error) {
+	t.compress()
+	var buf bytes.Buffer
+	buf.WriteByte(tdigestVersion)
+	for _, f := range []float64{t.compression, t.min, t.max} {
+		binary.Write(&buf, binary.LittleEndian, f)
+	}
+	var n [binary.MaxVarintLen64]byte
+	buf.Write(n[:binary.PutUvarint(n[:], uint64(len(t.centroids)))])
+	for _, c := range t.centroids {
+		binary.Write(&buf, binary.LittleEndian, c.mean)
+		binary.Write(&buf, binary.LittleEndian, c.weight)
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a digest written by MarshalBinary.
+func (t *TDigest) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	version, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("failed to decode t-digest: %w", err)
+	}
+	if version != tdigestVersion {
+		return fmt.Errorf("unsupported t-digest version %d", version)
+	}
+	*t = TDigest{}
+	for _, f := range []*float64{&t.compression, &t.min, &t.max} {
+		if err := binary.Read(r, binary.LittleEndian, f); err != nil {
+			return fmt.Errorf("failed to decode t-digest: %w", err)
+		}
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("failed to decode t-digest: %w", err)
+	}
+	if n > uint64(r.Len()/16) {
+		return fmt.Errorf("failed to decode t-digest: %d centroids in %d bytes", n, r.Len())
+	}
+	t.centroids = make([]centroid, n)
+	for i := range t.centroids {
+		c := &t.centroids[i]
+		if err := binary.Read(r, binary.LittleEndian, &c.mean); err != nil {
+			return fmt.Errorf("failed to decode t-digest: %w", err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, &c.weight); err != nil {
+			return fmt.Errorf("failed to decode t-digest: %w", err)
+		}
+		t.count += c.weight
+	}
+	return nil
+}
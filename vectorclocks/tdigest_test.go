@@ -0,0 +1,153 @@
+package vectorclocks
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/cucumber/godog"
+)
+
+func TestTDigestQuantile(t *testing.T) {
+	uniform := NewTDigest(0)
+	for _, i := range rand.New(rand.NewSource(1)).Perm(10000) {
+		uniform.Add(float64(i + 1))
+	}
+	single := NewTDigest(0)
+	single.Add(42)
+	skewed := NewTDigest(0)
+	for i := 0; i < 990; i++ {
+		skewed.Add(10)
+	}
+	for i := 0; i < 10; i++ {
+		skewed.Add(1000)
+	}
+
+	tests := []struct {
+		name      string
+		digest    *TDigest
+		q         float64
+		want, tol float64
+	}{
+		{"uniform min", uniform, 0, 1, 0},
+		{"uniform p1", uniform, 0.01, 100, 10},
+		{"uniform p50", uniform, 0.5, 5000, 100},
+		{"uniform p90", uniform, 0.9, 9000, 100},
+		{"uniform p99", uniform, 0.99, 9900, 10},
+		{"uniform max", uniform, 1, 10000, 0},
+		{"single p50", single, 0.5, 42, 0},
+		{"single p99", single, 0.99, 42, 0},
+		{"skewed p50", skewed, 0.5, 10, 0.5},
+		{"skewed p999", skewed, 0.999, 1000, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.digest.Quantile(tt.q); math.Abs(got-tt.want) > tt.tol {
+			t.Errorf("%s: Quantile(%v) = %v, want %v ± %v", tt.name, tt.q, got, tt.want, tt.tol)
+		}
+	}
+	if got := NewTDigest(0).Quantile(0.5); !math.IsNaN(got) {
+		t.Errorf("Quantile of an empty digest = %v, want NaN", got)
+	}
+}
+
+func TestTDigestMerge(t *testing.T) {
+	low, high, all := NewTDigest(0), NewTDigest(0), NewTDigest(0)
+	for i := 1; i <= 5000; i++ {
+		low.Add(float64(i))
+		high.Add(float64(i + 5000))
+	}
+	for i := 1; i <= 10000; i++ {
+		all.Add(float64(i))
+	}
+	low.Merge(high)
+	if low.Count() != 10000 {
+		t.Fatalf("merged Count = %v, want 10000", low.Count())
+	}
+	for _, q := range []float64{0, 0.1, 0.5, 0.9, 0.99, 1} {
+		if got, want := low.Quantile(q), all.Quantile(q); math.Abs(got-want) > 100 {
+			t.Errorf("merged Quantile(%v) = %v, want %v", q, got, want)
+		}
+	}
+	empty := NewTDigest(0)
+	empty.Merge(NewTDigest(0))
+	if empty.Count() != 0 || !math.IsNaN(empty.Quantile(0.5)) {
+		t.Errorf("merging empty digests gave %v values", empty.Count())
+	}
+}
+
+func TestTDigestBinary(t *testing.T) {
+	d := NewTDigest(50)
+	for i := 1; i <= 1000; i++ {
+		d.Add(float64(i))
+	}
+	data, err := d.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded TDigest
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Count() != d.Count() {
+		t.Errorf("decoded Count = %v, want %v", decoded.Count(), d.Count())
+	}
+	for _, q := range []float64{0, 0.5, 0.99, 1} {
+		if got, want := decoded.Quantile(q), d.Quantile(q); got != want {
+			t.Errorf("decoded Quantile(%v) = %v, want %v", q, got, want)
+		}
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"unknown version", append([]byte{tdigestVersion + 1}, data[1:]...)},
+		{"truncated", data[:len(data)-8]},
+	}
+	for _, tt := range tests {
+		if err := new(TDigest).UnmarshalBinary(tt.data); err == nil {
+			t.Errorf("%s: UnmarshalBinary succeeded", tt.name)
+		}
+	}
+}
+
+func TestStepDigestPersisted(t *testing.T) {
+	_, dbPath := newTestAgent(t)
+	for i := 0; i < 2; i++ {
+		a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent))
+		if err != nil {
+			t.Fatal(err)
+		}
+		status := runFeature(t, a, 1, `Feature: digest
+  Scenario: one
+    Given a step
+    And a  step
+`, func(ctx *godog.ScenarioContext) {
+			ctx.Step(`^a\s+step$`, func(context.Context) error { return nil })
+		})
+		if status != 0 {
+			t.Fatalf("suite status = %d, want 0", status)
+		}
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, err := NewVectorClockAgent(dbPath, WithVerbosity(VerbositySilent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	d, err := a.StepDigest("a step")
+	if err != nil || d == nil {
+		t.Fatalf("StepDigest = %v, %v", d, err)
+	}
+	if d.Count() != 4 {
+		t.Errorf("digest holds %v durations, want 4 over the normalized step text", d.Count())
+	}
+	if d, err := a.StepDigest("another step"); err != nil || d != nil {
+		t.Errorf("StepDigest of an unknown step = %v, %v; want nil", d, err)
+	}
+}
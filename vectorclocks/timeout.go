@@ -0,0 +1,132 @@
+package vectorclocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrStepTimeout fails a step that ran past the limit WithStepTimeouts set
+// for it.
+var ErrStepTimeout = errors.New("vectorclocks: step exceeded its timeout")
+
+// StepTimeout limits how long the steps it matches may run.
+type StepTimeout struct {
+	// Tag selects the steps of scenarios with the tag, e.g. "@slow"; empty
+	// matches every scenario.
+	Tag string
+	// Step selects steps by their text; nil matches every step.
+	Step  *regexp.Regexp
+	Limit time.Duration
+}
+
+func (t StepTimeout) matches(tags []string, text string) bool {
+	if t.Step != nil && !t.Step.MatchString(text) {
+		return false
+	}
+	if t.Tag == "" {
+		return true
+	}
+	for _, tag := range tags {
+		if tag == t.Tag {
+			return true
+		}
+	}
+	return false
+}
+
+// WithStepTimeouts gives each step the Limit of the last of timeouts that
+// matches it. The step's context carries the deadline, so a step
+// definition that passes it on to its calls is cancelled when it runs out;
+// a step still running is failed with ErrStepTimeout once it returns, and
+// recorded as StatusTimedOut either way. Go cannot stop a goroutine, so a
+// step that ignores its context runs on to the end. Timeouts need the
+// hooks of InitializeScenario; the formatter cannot set step contexts.
+func WithStepTimeouts(timeouts ...StepTimeout) Option {
+	return func(v *VectorClockAgent) {
+		v.timeouts = append(v.timeouts, timeouts...)
+	}
+}
+
+// ParseStepTimeouts parses a comma-separated list of [selector=]duration,
+// where the selector is a tag or a regular expression between slashes
+// matched against the step text, and a bare duration applies to every
+// step: "30s,@slow=2m,/^I upload/=5m". Later entries win, so the bare
+// default goes first. Expressions cannot contain commas.
+func ParseStepTimeouts(s string) ([]StepTimeout, error) {
+	var timeouts []StepTimeout
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		var t StepTimeout
+		value := field
+		if i := strings.LastIndex(field, "="); i >= 0 {
+			selector := strings.TrimSpace(field[:i])
+			value = field[i+1:]
+			switch {
+			case len(selector) >= 2 && strings.HasPrefix(selector, "/") && strings.HasSuffix(selector, "/"):
+				re, err := regexp.Compile(selector[1 : len(selector)-1])
+				if err != nil {
+					return nil, fmt.Errorf("step timeout %q: %w", field, err)
+				}
+				t.Step = re
+			case selector != "":
+				t.Tag = budgetTag(selector)
+			}
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("step timeout %q: want [@tag=|/regexp/=]duration", field)
+		}
+		t.Limit = d
+		timeouts = append(timeouts, t)
+	}
+	return timeouts, nil
+}
+
+// stepTimeout returns the limit for a step of a scenario with tags, or 0.
+func (v *VectorClockAgent) stepTimeout(tags []string, text string) time.Duration {
+	for i := len(v.timeouts) - 1; i >= 0; i-- {
+		if v.timeouts[i].matches(tags, text) {
+			return v.timeouts[i].Limit
+		}
+	}
+	return 0
+}
+
+// withStepDeadline derives the context of a starting step with its
+// timeout, if any, and keeps the cancel function on info.
+func (v *VectorClockAgent) withStepDeadline(ctx context.Context, info *stepInfo) context.Context {
+	limit := v.stepTimeout(info.scenario.tags, info.text)
+	if limit <= 0 {
+		return ctx
+	}
+	info.parent = ctx
+	ctx, info.cancel = context.WithTimeout(ctx, limit)
+	info.timeout = limit
+	return ctx
+}
+
+// stepValues is the context a step hands on to the next one once its
+// deadline is released: the deadline and cancellation of the context
+// before the step, and the values the step added.
+type stepValues struct {
+	context.Context
+	values context.Context
+}
+
+func (c stepValues) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
+// withoutDeadline returns ctx, the context a step returned, with the
+// deadline and cancellation of parent instead of its own, so that the
+// next step does not start on a cancelled context.
+func withoutDeadline(ctx, parent context.Context) context.Context {
+	return stepValues{Context: parent, values: ctx}
+}
@@ -0,0 +1,111 @@
+package vectorclocks
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cucumber/godog"
+)
+
+func TestStepTimeoutCoversOneStep(t *testing.T) {
+	a, _ := newTestAgent(t, WithStepTimeouts(StepTimeout{Limit: 5 * time.Second}))
+	defer a.Close()
+
+	type counterKey struct{}
+	status := runFeature(t, a, 1, `Feature: timeouts
+  Scenario: three steps
+    Given a first step
+    When a second step
+    Then the second step saw the first
+`, func(ctx *godog.ScenarioContext) {
+		ctx.Step(`^a first step$`, func(ctx context.Context) (context.Context, error) {
+			return context.WithValue(ctx, counterKey{}, 1), ctx.Err()
+		})
+		ctx.Step(`^a second step$`, func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("second step has no deadline")
+			}
+			return ctx.Err()
+		})
+		ctx.Step(`^the second step saw the first$`, func(ctx context.Context) error {
+			if ctx.Value(counterKey{}) != 1 {
+				t.Error("value returned by the first step was lost")
+			}
+			return ctx.Err()
+		})
+	})
+	if status != 0 {
+		t.Fatalf("suite status = %d, want 0", status)
+	}
+
+	timings, err := a.Timings(TimingFilter{RunID: a.RunID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timings) != 3 {
+		t.Fatalf("recorded %d steps, want 3", len(timings))
+	}
+	for _, s := range timings {
+		if s.Status != "passed" {
+			t.Errorf("step %q recorded as %q, want passed", s.StepText, s.Status)
+		}
+	}
+}
+
+func TestStepTimeoutRecordsOverrun(t *testing.T) {
+	tests := []struct {
+		name string
+		step func(ctx context.Context) error
+	}{
+		{"step ignores its context", func(ctx context.Context) error {
+			time.Sleep(100 * time.Millisecond)
+			return nil
+		}},
+		{"step returns its own error", func(ctx context.Context) error {
+			<-ctx.Done()
+			return errors.New("backend call failed")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := newTestAgent(t, WithStepTimeouts(StepTimeout{Limit: 50 * time.Millisecond}))
+			defer a.Close()
+
+			var mu sync.Mutex
+			var scenarioErr error
+			status := runFeature(t, a, 1, `Feature: timeouts
+  Scenario: slow
+    Given a slow backend
+`, func(ctx *godog.ScenarioContext) {
+				ctx.Step(`^a slow backend$`, tt.step)
+				ctx.After(func(ctx context.Context, _ *godog.Scenario, err error) (context.Context, error) {
+					mu.Lock()
+					scenarioErr = err
+					mu.Unlock()
+					return ctx, nil
+				})
+			})
+			if status == 0 {
+				t.Error("suite passed, want the timed out step to fail it")
+			}
+			mu.Lock()
+			err := scenarioErr
+			mu.Unlock()
+			if err == nil || !strings.Contains(err.Error(), ErrStepTimeout.Error()) {
+				t.Errorf("scenario error = %v, want %v", err, ErrStepTimeout)
+			}
+
+			timings, err := a.Timings(TimingFilter{RunID: a.RunID()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(timings) != 1 || timings[0].Status != StatusTimedOut {
+				t.Fatalf("Timings = %+v, want one %s step", timings, StatusTimedOut)
+			}
+		})
+	}
+}
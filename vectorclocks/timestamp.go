@@ -0,0 +1,42 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"time"
+)
+
+// sqliteTimeLayout matches the format of SQLite's CURRENT_TIMESTAMP.
+const sqliteTimeLayout = "2006-01-02 15:04:05"
+
+// timestamp scans a DATETIME column. The sqlite3 driver returns such
+// columns as time.Time, but values computed in SQL (MIN, MAX, ...) come
+// back as text.
+type timestamp struct {
+	time.Time
+}
+
+func (t *timestamp) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		t.Time = time.Time{}
+	case time.Time:
+		t.Time = v.UTC()
+	case string:
+		return t.parse(v)
+	case []byte:
+		return t.parse(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into timestamp", src)
+	}
+	return nil
+}
+
+func (t *timestamp) parse(s string) error {
+	for _, layout := range []string{sqliteTimeLayout, time.RFC3339Nano, "2006-01-02 15:04:05.999999999-07:00"} {
+		if parsed, err := time.Parse(layout, s); err == nil {
+			t.Time = parsed.UTC()
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot parse timestamp %q", s)
+}
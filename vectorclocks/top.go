@@ -0,0 +1,86 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Hotspot is the duration of a step or scenario aggregated across runs.
+type Hotspot struct {
+	Name  string
+	Count int
+	Mean  time.Duration
+	Max   time.Duration
+}
+
+// Top returns the n slowest steps and scenarios by mean duration across all
+// recorded runs. A scenario's duration is the sum of its steps in one run.
+func (v *VectorClockAgent) Top(n int) (steps, scenarios []Hotspot, err error) {
+	v.sync()
+
+	steps, err = v.hotspots(`
+		SELECT scenario_name || ' / ' || step_text, COUNT(*), AVG(`+durationNs+`), MAX(`+durationNs+`)
+		FROM step_timings
+		WHERE `+primaryPhase+`
+		GROUP BY scenario_name, step_text
+		ORDER BY AVG(`+durationNs+`) DESC
+		LIMIT ?
+	`, n)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load slowest steps: %w", err)
+	}
+	scenarios, err = v.hotspots(`
+		SELECT scenario_name, COUNT(*), AVG(total_ns), MAX(total_ns)
+		FROM (
+			SELECT scenario_name, SUM(`+durationNs+`) AS total_ns
+			FROM step_timings
+			WHERE `+primaryPhase+`
+			GROUP BY COALESCE(run_id, ''), scenario_name
+		)
+		GROUP BY scenario_name
+		ORDER BY AVG(total_ns) DESC
+		LIMIT ?
+	`, n)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load slowest scenarios: %w", err)
+	}
+	return steps, scenarios, nil
+}
+
+func (v *VectorClockAgent) hotspots(query string, n int) ([]Hotspot, error) {
+	rows, err := v.db.Query(query, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hotspots []Hotspot
+	for rows.Next() {
+		var h Hotspot
+		var meanNs float64
+		var maxNs int64
+		if err := rows.Scan(&h.Name, &h.Count, &meanNs, &maxNs); err != nil {
+			return nil, err
+		}
+		h.Mean = time.Duration(meanNs)
+		h.Max = time.Duration(maxNs)
+		hotspots = append(hotspots, h)
+	}
+	return hotspots, rows.Err()
+}
+
+// WriteHotspots prints hotspots under title, slowest first.
+func WriteHotspots(w io.Writer, title string, hotspots []Hotspot) error {
+	if _, err := fmt.Fprintf(w, "%s\n%10s %10s %6s  %s\n", title, "mean", "max", "runs", "name"); err != nil {
+		return err
+	}
+	for _, h := range hotspots {
+		_, err := fmt.Fprintf(w, "%10s %10s %6d  %s\n",
+			h.Mean.Round(time.Millisecond), h.Max.Round(time.Millisecond), h.Count, h.Name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
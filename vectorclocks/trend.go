@@ -0,0 +1,140 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+)
+
+// minTrendPoints is the fewest runs a step or scenario needs before a trend
+// is fitted.
+const minTrendPoints = 3
+
+// minTrendCorrelation is the correlation between run order and duration
+// required before growth counts as a trend rather than noise.
+const minTrendCorrelation = 0.5
+
+// Trend is a least-squares line fitted through the durations of a step or
+// scenario over recent runs.
+type Trend struct {
+	Name string
+	// Points is the number of runs that contained the item.
+	Points int
+	Mean   time.Duration
+	// Slope is the fitted change per run.
+	Slope time.Duration
+	// Growth is the fitted change across the window in percent of Mean.
+	Growth float64
+	// Correlation is Pearson's r between run order and duration.
+	Correlation float64
+	// Rising is set when Growth exceeds the threshold and Correlation is
+	// strong enough to rule out noise.
+	Rising bool
+}
+
+// Trends fits a trend through every scenario and step over the last n runs.
+// Items that grew by more than threshold percent across the window are
+// flagged as rising. Both slices are sorted by growth, largest first.
+func (v *VectorClockAgent) Trends(n int, threshold float64) (scenarios, steps []Trend, err error) {
+	runIDs, err := v.RecentRuns(n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scenarioSeries := make(map[string][]point)
+	stepSeries := make(map[string][]point)
+	// RecentRuns is newest first; x counts runs oldest first.
+	for i := len(runIDs) - 1; i >= 0; i-- {
+		x := float64(len(runIDs) - 1 - i)
+		rt, err := v.RunTimings(runIDs[i])
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, d := range rt.Scenarios {
+			scenarioSeries[name] = append(scenarioSeries[name], point{x, d})
+		}
+		for key, d := range rt.Steps {
+			stepSeries[key.String()] = append(stepSeries[key.String()], point{x, d})
+		}
+	}
+	return fitTrends(scenarioSeries, threshold), fitTrends(stepSeries, threshold), nil
+}
+
+type point struct {
+	x float64
+	d time.Duration
+}
+
+func fitTrends(series map[string][]point, threshold float64) []Trend {
+	var trends []Trend
+	for name, points := range series {
+		if len(points) < minTrendPoints {
+			continue
+		}
+		t := fitTrend(points)
+		t.Name = name
+		t.Rising = t.Growth > threshold && t.Correlation >= minTrendCorrelation
+		trends = append(trends, t)
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Growth != trends[j].Growth {
+			return trends[i].Growth > trends[j].Growth
+		}
+		return trends[i].Name < trends[j].Name
+	})
+	return trends
+}
+
+func fitTrend(points []point) Trend {
+	n := float64(len(points))
+	var sumX, sumY float64
+	for _, p := range points {
+		sumX += p.x
+		sumY += float64(p.d)
+	}
+	meanX, meanY := sumX/n, sumY/n
+
+	var sxy, sxx, syy float64
+	for _, p := range points {
+		dx, dy := p.x-meanX, float64(p.d)-meanY
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+
+	t := Trend{Points: len(points), Mean: time.Duration(meanY)}
+	if sxx == 0 {
+		return t
+	}
+	slope := sxy / sxx
+	t.Slope = time.Duration(slope)
+	if meanY > 0 {
+		span := points[len(points)-1].x - points[0].x
+		t.Growth = slope * span / meanY * 100
+	}
+	if syy > 0 {
+		t.Correlation = sxy / math.Sqrt(sxx*syy)
+	}
+	return t
+}
+
+// WriteTrends prints trends under title. Rising trends are marked with "!!".
+func WriteTrends(w io.Writer, title string, trends []Trend) error {
+	if _, err := fmt.Fprintln(w, title); err != nil {
+		return err
+	}
+	for _, t := range trends {
+		marker := "  "
+		if t.Rising {
+			marker = "!!"
+		}
+		_, err := fmt.Fprintf(w, "%s %s: mean %s, %s/run over %d runs (%+.1f%%, r=%.2f)\n", marker, t.Name,
+			t.Mean.Round(time.Millisecond), formatChange(t.Slope), t.Points, t.Growth, t.Correlation)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
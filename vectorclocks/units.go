@@ -0,0 +1,59 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// durationNs is the exact duration of a step_timings row in nanoseconds.
+// Rows written before duration_ns existed only have millisecond precision.
+const durationNs = `COALESCE(duration_ns, duration_ms * 1000000)`
+
+// DisplayUnit is the unit durations are printed in.
+type DisplayUnit string
+
+// Display units accepted by WithDisplayUnit. UnitAuto picks a unit per
+// value, like time.Duration.String.
+const (
+	UnitAuto         DisplayUnit = "auto"
+	UnitNanoseconds  DisplayUnit = "ns"
+	UnitMicroseconds DisplayUnit = "µs"
+	UnitMilliseconds DisplayUnit = "ms"
+	UnitSeconds      DisplayUnit = "s"
+)
+
+// ParseDisplayUnit parses "auto", "ns", "us" (or "µs"), "ms" or "s".
+func ParseDisplayUnit(s string) (DisplayUnit, error) {
+	switch u := DisplayUnit(strings.ToLower(s)); u {
+	case "us":
+		return UnitMicroseconds, nil
+	case UnitAuto, UnitNanoseconds, UnitMicroseconds, UnitMilliseconds, UnitSeconds:
+		return u, nil
+	}
+	return "", fmt.Errorf("unknown display unit %q (want auto, ns, us, ms or s)", s)
+}
+
+// WithDisplayUnit sets the unit Report prints durations in. By default
+// they are printed in whole milliseconds.
+func WithDisplayUnit(unit DisplayUnit) Option {
+	return func(v *VectorClockAgent) {
+		v.unit = unit
+	}
+}
+
+// Format prints d in the unit, with three decimals for units coarser than
+// a nanosecond.
+func (u DisplayUnit) Format(d time.Duration) string {
+	switch u {
+	case UnitNanoseconds:
+		return fmt.Sprintf("%d ns", d.Nanoseconds())
+	case UnitMicroseconds:
+		return fmt.Sprintf("%.3f µs", float64(d)/float64(time.Microsecond))
+	case UnitMilliseconds:
+		return fmt.Sprintf("%.3f ms", float64(d)/float64(time.Millisecond))
+	case UnitSeconds:
+		return fmt.Sprintf("%.3f s", d.Seconds())
+	}
+	return d.String()
+}
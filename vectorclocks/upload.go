@@ -0,0 +1,135 @@
+package vectorclocks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FormatSQLite uploads a snapshot of the whole database.
+const FormatSQLite = "sqlite"
+
+// DefaultUploadPath is the object name uploads use without UploadPolicy.Path.
+const DefaultUploadPath = "vectorclocks/{date}/{run_id}.{ext}"
+
+// UploadPolicy configures WithUpload.
+type UploadPolicy struct {
+	// URL is the bucket or directory to upload to; see OpenObjectStore.
+	URL string
+	// Path names the uploaded object. {run_id}, {date} (the day the run
+	// started, YYYY-MM-DD) and {ext} (the file extension of Format) are
+	// replaced; empty uses DefaultUploadPath.
+	Path string
+	// Format is FormatSQLite for the whole database, or an export format
+	// such as FormatNDJSON for the steps of the run; empty uses
+	// FormatSQLite.
+	Format string
+}
+
+// WithUpload makes Close upload the database, or an export of the run, to
+// object storage once the run is stored and finalised, so that timings
+// survive ephemeral CI runners. Upload errors go to the error handler and
+// do not change the suite status.
+func WithUpload(p UploadPolicy) Option {
+	return func(v *VectorClockAgent) {
+		if p.URL != "" {
+			v.upload = &p
+		}
+	}
+}
+
+// uploadName expands the path template of p for the run.
+func (p UploadPolicy) uploadName(v *VectorClockAgent) string {
+	path := p.Path
+	if path == "" {
+		path = DefaultUploadPath
+	}
+	ext := p.Format
+	switch p.Format {
+	case "", FormatSQLite:
+		ext = "db"
+	case FormatKnapsack:
+		ext = "json"
+	case FormatJUnit:
+		ext = "xml"
+	case FormatOpenMetrics:
+		ext = "prom"
+	}
+	return strings.NewReplacer(
+		"{run_id}", v.runID,
+		"{date}", v.startedAt.UTC().Format("2006-01-02"),
+		"{ext}", ext,
+	).Replace(path)
+}
+
+// uploadRun uploads the run as configured by WithUpload. Close calls it
+// after the run is stored and finalised; an agent that ran no scenarios
+// uploads nothing.
+func (v *VectorClockAgent) uploadRun() error {
+	v.compositionMu.Lock()
+	empty := len(v.composition) == 0
+	v.compositionMu.Unlock()
+	if empty {
+		return nil
+	}
+	store, err := OpenObjectStore(v.upload.URL)
+	if err != nil {
+		return err
+	}
+
+	var body io.Reader
+	switch v.upload.Format {
+	case "", FormatSQLite:
+		f, cleanup, err := v.snapshot()
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		body = f
+	default:
+		timings, err := v.Timings(TimingFilter{RunID: v.runID})
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := WriteExport(&buf, v.upload.Format, timings); err != nil {
+			return err
+		}
+		body = &buf
+	}
+
+	location, err := store.Put(context.Background(), v.upload.uploadName(v), body)
+	if err != nil {
+		return err
+	}
+	v.logger.Info("uploaded run", "run_id", v.runID, "location", location)
+	return nil
+}
+
+// snapshot writes a consistent copy of the database to a temporary file
+// and opens it, so that the upload streams it rather than holding a
+// second copy of the database in memory. cleanup closes and removes it.
+func (v *VectorClockAgent) snapshot() (f *os.File, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "vectorclocks-upload-")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path := filepath.Join(dir, "snapshot.db")
+	if _, err := v.db.Exec(fmt.Sprintf(`VACUUM %q INTO ?`, v.naming.schemaName()), path); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("failed to snapshot database: %w", err)
+	}
+	if f, err = os.Open(path); err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	return f, func() {
+		f.Close()
+		os.RemoveAll(dir)
+	}, nil
+}
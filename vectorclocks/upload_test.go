@@ -0,0 +1,73 @@
+package vectorclocks
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cucumber/godog"
+)
+
+func TestUploadAfterClose(t *testing.T) {
+	_, dbPath := newTestAgent(t)
+	dir := t.TempDir()
+	var a *VectorClockAgent
+	// Stability scores scenarios that ran in three runs.
+	for i := 0; i < 3; i++ {
+		var err error
+		a, err = NewVectorClockAgent(dbPath,
+			WithVerbosity(VerbositySilent),
+			WithUpload(UploadPolicy{URL: dir, Path: "{run_id}.{ext}"}),
+			WithStoredBaselines(BaselineWindow{}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		status := runFeature(t, a, 1, `Feature: upload
+  Scenario: one
+    Given a step
+`, func(ctx *godog.ScenarioContext) {
+			ctx.Step(`^a step$`, func(context.Context) error { return nil })
+		})
+		if status != 0 {
+			t.Fatalf("suite status = %d, want 0", status)
+		}
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	uploaded, err := NewVectorClockAgent(filepath.Join(dir, a.RunID()+".db"), WithVerbosity(VerbositySilent))
+	if err != nil {
+		t.Fatalf("opening the upload: %v", err)
+	}
+	defer uploaded.Close()
+	for _, table := range []string{"runs", "step_timings", "step_digest", "scenario_stability", "baselines"} {
+		var n int
+		if err := uploaded.db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			t.Errorf("uploaded database has no rows in %s", table)
+		}
+	}
+}
+
+func TestSnapshotStreamsFromATemporaryFile(t *testing.T) {
+	a, _ := newTestAgent(t)
+	defer a.Close()
+
+	f, cleanup, err := a.snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(f, header); err != nil || string(header) != "SQLite format 3\x00" {
+		t.Errorf("snapshot starts with %q, %v; want an SQLite database", header, err)
+	}
+	cleanup()
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Errorf("snapshot %s still exists after cleanup: %v", f.Name(), err)
+	}
+}
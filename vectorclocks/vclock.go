@@ -0,0 +1,230 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// VectorClockHeader is the HTTP header conventionally carrying a
+// VectorClock between test runners and the system under test.
+const VectorClockHeader = "X-Vector-Clock"
+
+// VectorClock maps process IDs to the number of events each process had
+// seen when an event happened. Unlike wall clocks, comparing two clocks
+// tells reliably whether one event happened before another, even across
+// machines whose clocks disagree.
+type VectorClock map[string]uint64
+
+// Causality is how two events relate, as told by their vector clocks.
+type Causality int
+
+const (
+	// Concurrent events happened without either seeing the other.
+	Concurrent Causality = iota
+	// HappenedBefore events were seen by the event compared with.
+	HappenedBefore
+	// HappenedAfter events saw the event compared with.
+	HappenedAfter
+	// Identical clocks belong to the same event.
+	Identical
+)
+
+// Compare tells how the event of c relates to the event of other:
+// HappenedBefore, HappenedAfter, Identical or Concurrent.
+func (c VectorClock) Compare(other VectorClock) Causality {
+	less, greater := false, false
+	for p, n := range c {
+		if n < other[p] {
+			less = true
+		} else if n > other[p] {
+			greater = true
+		}
+	}
+	for p, n := range other {
+		if _, ok := c[p]; !ok && n > 0 {
+			less = true
+		}
+	}
+	switch {
+	case less && greater:
+		return Concurrent
+	case less:
+		return HappenedBefore
+	case greater:
+		return HappenedAfter
+	default:
+		return Identical
+	}
+}
+
+// Merge returns the component-wise maximum of c and other, the clock of an
+// event that has seen both.
+func (c VectorClock) Merge(other VectorClock) VectorClock {
+	merged := make(VectorClock, len(c)+len(other))
+	for p, n := range c {
+		merged[p] = n
+	}
+	for p, n := range other {
+		if n > merged[p] {
+			merged[p] = n
+		}
+	}
+	return merged
+}
+
+// String encodes c as comma-separated "process=count" pairs in process
+// order, the form stored in the database and sent in VectorClockHeader.
+func (c VectorClock) String() string {
+	processes := make([]string, 0, len(c))
+	for p := range c {
+		processes = append(processes, p)
+	}
+	sort.Strings(processes)
+	pairs := make([]string, 0, len(c))
+	for _, p := range processes {
+		pairs = append(pairs, p+"="+strconv.FormatUint(c[p], 10))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// ParseVectorClock decodes a clock encoded by VectorClock.String. An empty
+// string is an empty clock.
+func ParseVectorClock(s string) (VectorClock, error) {
+	c := make(VectorClock)
+	if strings.TrimSpace(s) == "" {
+		return c, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		p, n, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || p == "" {
+			return nil, fmt.Errorf("invalid vector clock component %q (want process=count)", pair)
+		}
+		count, err := strconv.ParseUint(n, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector clock component %q: %w", pair, err)
+		}
+		c[p] = count
+	}
+	return c, nil
+}
+
+// WithProcessID names the agent's component of the vector clocks it
+// stamps on steps. It defaults to the host name and process ID, which is
+// unique among runners writing events for the same system under test;
+// set it when runners need stable names, e.g. "runner-1".
+func WithProcessID(id string) Option {
+	return func(v *VectorClockAgent) {
+		v.process = id
+	}
+}
+
+func defaultProcessID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "localhost"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// ProcessID returns the agent's component of its vector clocks.
+func (v *VectorClockAgent) ProcessID() string {
+	return v.process
+}
+
+// Tick counts an event of the agent's process, such as a request sent to
+// the system under test, and returns the clock to send along with it, e.g.
+// in VectorClockHeader. Every recorded step ticks the clock as well.
+func (v *VectorClockAgent) Tick() VectorClock {
+	v.clockMu.Lock()
+	defer v.clockMu.Unlock()
+	return v.tickLocked()
+}
+
+// Observe merges a clock received from another process, such as one
+// returned by the system under test or another runner, into the agent's,
+// so that the steps recorded afterwards are ordered after the events it
+// has seen.
+func (v *VectorClockAgent) Observe(c VectorClock) {
+	v.clockMu.Lock()
+	defer v.clockMu.Unlock()
+	v.clock = v.clock.Merge(c)
+	v.tickLocked()
+}
+
+func (v *VectorClockAgent) tickLocked() VectorClock {
+	if v.clock == nil {
+		v.clock = make(VectorClock)
+	}
+	v.clock[v.process]++
+	return v.clock.Merge(nil)
+}
+
+// CausalOrder sorts timings, typically merged from several runners with
+// merge, so that every step comes after the steps that happened before it
+// according to their vector clocks. Steps whose order the clocks leave
+// open keep their wall-clock order, as do steps recorded without clocks.
+func CausalOrder(timings []StepTiming) []StepTiming {
+	// The steps of each process form a chain ordered by the process's own
+	// component; a chain's head is ready once no other chain's head
+	// happened before it.
+	chains := make(map[string][]StepTiming)
+	var processes []string
+	for _, t := range timings {
+		if _, ok := chains[t.Process]; !ok {
+			processes = append(processes, t.Process)
+		}
+		chains[t.Process] = append(chains[t.Process], t)
+	}
+	sort.Strings(processes)
+	for _, p := range processes {
+		chain := chains[p]
+		sort.SliceStable(chain, func(i, j int) bool {
+			if a, b := chain[i].Clock[p], chain[j].Clock[p]; a != b {
+				return a < b
+			}
+			return stepStart(chain[i]).Before(stepStart(chain[j]))
+		})
+	}
+
+	ready := func(t StepTiming) bool {
+		for _, q := range processes {
+			if q == t.Process || len(chains[q]) == 0 {
+				continue
+			}
+			if head := chains[q][0]; len(head.Clock) > 0 && head.Clock[q] <= t.Clock[q] {
+				return false
+			}
+		}
+		return true
+	}
+	// earliest picks the chain whose head started first among those
+	// passing ok, or -1.
+	earliest := func(ok func(StepTiming) bool) int {
+		next := -1
+		for i, p := range processes {
+			if len(chains[p]) == 0 || !ok(chains[p][0]) {
+				continue
+			}
+			if next < 0 || stepStart(chains[p][0]).Before(stepStart(chains[processes[next]][0])) {
+				next = i
+			}
+		}
+		return next
+	}
+	ordered := make([]StepTiming, 0, len(timings))
+	for len(ordered) < len(timings) {
+		next := earliest(ready)
+		if next < 0 {
+			// Clocks from different databases can contradict each other;
+			// fall back to wall-clock order rather than stall.
+			next = earliest(func(StepTiming) bool { return true })
+		}
+		p := processes[next]
+		ordered = append(ordered, chains[p][0])
+		chains[p] = chains[p][1:]
+	}
+	return ordered
+}
@@ -0,0 +1,152 @@
+package vectorclocks
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVectorClockCompare(t *testing.T) {
+	tests := []struct {
+		name        string
+		a, b        VectorClock
+		want, wantR Causality
+	}{
+		{"empty", VectorClock{}, nil, Identical, Identical},
+		{"identical", VectorClock{"p": 1, "q": 2}, VectorClock{"p": 1, "q": 2}, Identical, Identical},
+		{"zero component", VectorClock{"p": 1, "q": 0}, VectorClock{"p": 1}, Identical, Identical},
+		{"before", VectorClock{"p": 1}, VectorClock{"p": 2}, HappenedBefore, HappenedAfter},
+		{"before with new process", VectorClock{"p": 1}, VectorClock{"p": 1, "q": 1}, HappenedBefore, HappenedAfter},
+		{"concurrent", VectorClock{"p": 2, "q": 1}, VectorClock{"p": 1, "q": 2}, Concurrent, Concurrent},
+		{"concurrent disjoint", VectorClock{"p": 1}, VectorClock{"q": 1}, Concurrent, Concurrent},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Compare(tt.b); got != tt.want {
+			t.Errorf("%s: Compare = %v, want %v", tt.name, got, tt.want)
+		}
+		if got := tt.b.Compare(tt.a); got != tt.wantR {
+			t.Errorf("%s: reversed Compare = %v, want %v", tt.name, got, tt.wantR)
+		}
+	}
+}
+
+func TestVectorClockMerge(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b VectorClock
+		want VectorClock
+	}{
+		{"nil", nil, nil, VectorClock{}},
+		{"one side", VectorClock{"p": 3}, nil, VectorClock{"p": 3}},
+		{"maximum", VectorClock{"p": 3, "q": 1}, VectorClock{"p": 1, "q": 4, "r": 2}, VectorClock{"p": 3, "q": 4, "r": 2}},
+	}
+	for _, tt := range tests {
+		before := tt.a.String()
+		got := tt.a.Merge(tt.b)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Merge = %v, want %v", tt.name, got, tt.want)
+		}
+		if tt.a.String() != before {
+			t.Errorf("%s: Merge changed its receiver to %v", tt.name, tt.a)
+		}
+		if c := got.Compare(tt.a); c != HappenedAfter && c != Identical {
+			t.Errorf("%s: merged clock is %v the receiver", tt.name, c)
+		}
+	}
+}
+
+func TestParseVectorClock(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    VectorClock
+		wantErr bool
+	}{
+		{"", VectorClock{}, false},
+		{"  ", VectorClock{}, false},
+		{"p=1", VectorClock{"p": 1}, false},
+		{"runner-1=3, sut=12", VectorClock{"runner-1": 3, "sut": 12}, false},
+		{"p", nil, true},
+		{"=1", nil, true},
+		{"p=-1", nil, true},
+		{"p=1,,q=2", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseVectorClock(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseVectorClock(%q) error = %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseVectorClock(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	c := VectorClock{"sut": 12, "runner-1": 3}
+	if s := c.String(); s != "runner-1=3,sut=12" {
+		t.Errorf("String = %q, want processes in order", s)
+	}
+	if parsed, err := ParseVectorClock(c.String()); err != nil || !reflect.DeepEqual(parsed, c) {
+		t.Errorf("ParseVectorClock(String()) = %v, %v; want %v", parsed, err, c)
+	}
+}
+
+func TestTickAndObserve(t *testing.T) {
+	a, _ := newTestAgent(t, WithProcessID("runner"))
+	defer a.Close()
+	first := a.Tick()
+	if !reflect.DeepEqual(first, VectorClock{"runner": 1}) {
+		t.Fatalf("first Tick = %v", first)
+	}
+	a.Observe(VectorClock{"sut": 5, "runner": 1})
+	second := a.Tick()
+	if want := (VectorClock{"runner": 3, "sut": 5}); !reflect.DeepEqual(second, want) {
+		t.Errorf("Tick after Observe = %v, want %v", second, want)
+	}
+	if first.Compare(second) != HappenedBefore {
+		t.Errorf("first tick is %v the second", first.Compare(second))
+	}
+	second["runner"] = 100
+	if c := a.Tick(); c["runner"] != 4 {
+		t.Errorf("Tick returned the agent's own clock: %v", c)
+	}
+}
+
+func TestCausalOrder(t *testing.T) {
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	at := func(text, process string, clock VectorClock, offset time.Duration) StepTiming {
+		return StepTiming{StepText: text, Process: process, Clock: clock, StartedAt: start.Add(offset)}
+	}
+	tests := []struct {
+		name    string
+		timings []StepTiming
+		want    []string
+	}{
+		{"wall clock without clocks", []StepTiming{
+			at("b", "", nil, 2*time.Second),
+			at("a", "", nil, time.Second),
+		}, []string{"a", "b"}},
+		{"clock order over skewed wall clocks", []StepTiming{
+			// q's clock runs a minute early, yet its step saw p's request.
+			at("q handles request", "q", VectorClock{"p": 1, "q": 1}, -time.Minute),
+			at("p sends request", "p", VectorClock{"p": 1}, 0),
+			at("p reads reply", "p", VectorClock{"p": 2, "q": 1}, time.Second),
+		}, []string{"p sends request", "q handles request", "p reads reply"}},
+		{"concurrent steps keep wall clock order", []StepTiming{
+			at("q", "q", VectorClock{"q": 1}, 2*time.Second),
+			at("p", "p", VectorClock{"p": 1}, time.Second),
+		}, []string{"p", "q"}},
+		{"contradicting clocks do not stall", []StepTiming{
+			at("p", "p", VectorClock{"p": 1, "q": 2}, 0),
+			at("q", "q", VectorClock{"p": 2, "q": 1}, time.Second),
+		}, []string{"p", "q"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, s := range CausalOrder(tt.timings) {
+			got = append(got, s.StepText)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: CausalOrder = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package vectorclocks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrWaitTimeout is returned by WaitFor when the condition did not hold in
+// time.
+var ErrWaitTimeout = errors.New("vectorclocks: condition not met in time")
+
+// Used by WaitFor for steps without history.
+var (
+	DefaultWaitTimeout  = 30 * time.Second
+	DefaultWaitInterval = 100 * time.Millisecond
+)
+
+// Bounds on the interval and timeout WaitFor derives from history.
+const (
+	minWaitInterval = 10 * time.Millisecond
+	maxWaitInterval = time.Second
+	minWaitTimeout  = time.Second
+)
+
+// WaitFor polls cond until it reports true, returns an error, or the
+// timeout passes. For a step with history (see History) the timeout is
+// three times its p95 and cond is polled every tenth of its median, so fast
+// steps are checked often and slow ones are given room; otherwise
+// DefaultWaitTimeout and DefaultWaitInterval apply. A deadline on ctx that
+// comes sooner wins.
+func WaitFor(ctx context.Context, cond func() (bool, error)) error {
+	timeout, interval := waitBudget(ctx)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	started := time.Now()
+	for {
+		done, err := cond()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w after %s", ErrWaitTimeout, time.Since(started).Round(time.Millisecond))
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitBudget derives the timeout and polling interval of WaitFor from the
+// history of the step running in ctx.
+func waitBudget(ctx context.Context) (timeout, interval time.Duration) {
+	h, ok := History(ctx)
+	if !ok {
+		return DefaultWaitTimeout, DefaultWaitInterval
+	}
+	return max(3*h.P95, minWaitTimeout), min(max(h.Median/10, minWaitInterval), maxWaitInterval)
+}
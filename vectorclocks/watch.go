@@ -0,0 +1,38 @@
+package vectorclocks
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Watch calls changed once and then again whenever new step rows appear in
+// the database, checking every interval, until ctx is done. Rows written
+// by other processes, such as a suite running against the same file, are
+// picked up too. It returns nil when ctx is cancelled.
+func (v *VectorClockAgent) Watch(ctx context.Context, interval time.Duration, changed func() error) error {
+	last := int64(-1)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		var latest int64
+		if err := v.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM step_timings`).Scan(&latest); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to poll step timings: %w", err)
+		}
+		if latest != last {
+			last = latest
+			if err := changed(); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package vectorclocks
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"sort"
+	"time"
+)
+
+// WaterfallStep is one step of a scenario placed on the scenario's
+// timeline.
+type WaterfallStep struct {
+	Step string
+	// Offset is when the step started relative to the scenario's first
+	// step.
+	Offset   time.Duration
+	Duration time.Duration
+}
+
+// Waterfall is the step timeline of every scenario of a run.
+type Waterfall struct {
+	RunID     string
+	Scenarios map[string][]WaterfallStep
+
+	assets fs.FS
+}
+
+// Waterfall loads the first-attempt step timeline of every scenario of
+// runID. Steps recorded before start times were tracked are laid end to
+// end.
+func (v *VectorClockAgent) Waterfall(runID string) (Waterfall, error) {
+	v.sync()
+
+	rows, err := v.db.Query(`
+		SELECT scenario_name, step_text, `+durationNs+`, started_at
+		FROM step_timings
+		WHERE run_id = ? AND `+primaryPhase+`
+		ORDER BY scenario_name, COALESCE(started_at, created_at), id
+	`, runID)
+	if err != nil {
+		return Waterfall{}, fmt.Errorf("failed to load waterfall of run %s: %w", runID, err)
+	}
+	defer rows.Close()
+
+	wf := Waterfall{RunID: runID, Scenarios: make(map[string][]WaterfallStep), assets: v.assets}
+	starts := make(map[string]time.Time)
+	for rows.Next() {
+		var scenario string
+		var s WaterfallStep
+		var durationNs int64
+		var startedAt timestamp
+		if err := rows.Scan(&scenario, &s.Step, &durationNs, &startedAt); err != nil {
+			return Waterfall{}, err
+		}
+		s.Duration = time.Duration(durationNs)
+		steps := wf.Scenarios[scenario]
+		switch {
+		case startedAt.IsZero() && len(steps) > 0:
+			last := steps[len(steps)-1]
+			s.Offset = last.Offset + last.Duration
+		case startedAt.IsZero():
+		case len(steps) == 0:
+			starts[scenario] = startedAt.Time
+		default:
+			s.Offset = startedAt.Sub(starts[scenario])
+		}
+		wf.Scenarios[scenario] = append(steps, s)
+	}
+	return wf, rows.Err()
+}
+
+// WaterfallBar is a step drawn in a waterfall comparison. Left and Width
+// are percentages of the scenario row.
+type WaterfallBar struct {
+	WaterfallStep
+	Left, Width float64
+	// Change is "grew", "shrank", "same", "new" or "removed", comparing
+	// the step with the same step of the other run.
+	Change string
+	Delta  Delta
+}
+
+// WaterfallRow aligns the base and head timelines of one scenario on a
+// shared scale.
+type WaterfallRow struct {
+	Scenario   string
+	Base, Head []WaterfallBar
+	Scale      time.Duration
+	Total      Delta
+}
+
+// WaterfallComparison lays two runs side by side, scenario by scenario.
+type WaterfallComparison struct {
+	Base, Head string
+	Threshold  float64
+	Rows       []WaterfallRow
+
+	assets fs.FS
+}
+
+// CompareWaterfalls aligns the scenarios of base and head. Steps more than
+// threshold percent slower are marked as grown, those more than threshold
+// percent faster as shrunk. Steps are matched by text and position, so a
+// step repeated within a scenario is compared with its own repetition.
+func CompareWaterfalls(base, head Waterfall, threshold float64) WaterfallComparison {
+	c := WaterfallComparison{Base: base.RunID, Head: head.RunID, Threshold: threshold, assets: head.assets}
+
+	names := make(map[string]bool)
+	for name := range base.Scenarios {
+		names[name] = true
+	}
+	for name := range head.Scenarios {
+		names[name] = true
+	}
+	for name := range names {
+		c.Rows = append(c.Rows, waterfallRow(name, base.Scenarios[name], head.Scenarios[name], threshold))
+	}
+	sort.Slice(c.Rows, func(i, j int) bool {
+		if a, b := c.Rows[i].Total.Change(), c.Rows[j].Total.Change(); a != b {
+			return a > b
+		}
+		return c.Rows[i].Scenario < c.Rows[j].Scenario
+	})
+	return c
+}
+
+func waterfallRow(scenario string, base, head []WaterfallStep, threshold float64) WaterfallRow {
+	row := WaterfallRow{Scenario: scenario}
+	end := func(steps []WaterfallStep) time.Duration {
+		var d time.Duration
+		for _, s := range steps {
+			d = max(d, s.Offset+s.Duration)
+		}
+		return d
+	}
+	row.Scale = max(end(base), end(head))
+	row.Total = newDelta(scenario, end(base), end(head), threshold)
+
+	// occurrence numbers repeated step texts so each is matched with the
+	// same repetition in the other run.
+	type occurrence struct {
+		step string
+		n    int
+	}
+	index := func(steps []WaterfallStep) map[occurrence]WaterfallStep {
+		seen := make(map[string]int)
+		m := make(map[occurrence]WaterfallStep, len(steps))
+		for _, s := range steps {
+			seen[s.Step]++
+			m[occurrence{s.Step, seen[s.Step]}] = s
+		}
+		return m
+	}
+	baseIndex, headIndex := index(base), index(head)
+
+	bars := func(steps []WaterfallStep, other map[occurrence]WaterfallStep, isHead bool) []WaterfallBar {
+		seen := make(map[string]int)
+		out := make([]WaterfallBar, 0, len(steps))
+		for _, s := range steps {
+			seen[s.Step]++
+			match, ok := other[occurrence{s.Step, seen[s.Step]}]
+			bar := WaterfallBar{WaterfallStep: s}
+			if row.Scale > 0 {
+				bar.Left = 100 * float64(s.Offset) / float64(row.Scale)
+				bar.Width = 100 * float64(s.Duration) / float64(row.Scale)
+			}
+			switch {
+			case !ok && isHead:
+				bar.Change = "new"
+			case !ok:
+				bar.Change = "removed"
+			default:
+				b, h := match.Duration, s.Duration
+				if !isHead {
+					b, h = h, b
+				}
+				bar.Delta = newDelta(s.Step, b, h, threshold)
+				bar.Change = "same"
+				if p := bar.Delta.Percent(); p > threshold {
+					bar.Change = "grew"
+				} else if p < -threshold {
+					bar.Change = "shrank"
+				}
+			}
+			out = append(out, bar)
+		}
+		return out
+	}
+	row.Base = bars(base, headIndex, false)
+	row.Head = bars(head, baseIndex, true)
+	return row
+}
+
+var waterfallFuncs = template.FuncMap{
+	"round":  func(d time.Duration) time.Duration { return d.Round(time.Millisecond) },
+	"change": deltaChange,
+}
+
+// WriteHTML renders the comparison as a standalone HTML page using
+// templates/waterfall.html.tmpl.
+func (c WaterfallComparison) WriteHTML(w io.Writer) error {
+	assets := c.assets
+	if assets == nil {
+		assets = embeddedAssets
+	}
+	tmpl, err := loadTemplate(assets, "templates/waterfall.html.tmpl", waterfallFuncs)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, c)
+}
@@ -0,0 +1,249 @@
+package vectorclocks
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// writeBatchSize is the number of step records after which the writer
+	// commits. Records of one scenario are never split across transactions.
+	writeBatchSize = 256
+	// writeInterval bounds how long a record waits in a partial batch.
+	writeInterval = 500 * time.Millisecond
+)
+
+// stepRecord is one finished step waiting to be persisted.
+type stepRecord struct {
+	stepID       string
+	scenarioName string
+	stepText     string
+	duration     time.Duration
+	runID        string
+	status       string
+	tags         string
+	featureURI   string
+	phase        string
+	attempt      int
+	startedAt    time.Time
+	endedAt      time.Time
+	resources    []string
+	spans        []spanRecord
+	annotations  map[string]string
+	// injected is the fault latency removed from duration.
+	injected time.Duration
+	// sampleRate is N when the step was kept as one in N by step
+	// sampling, and 0 when it was recorded unconditionally.
+	sampleRate int
+	// memory is the allocation delta recorded WithMemoryTracking.
+	memory *MemoryDelta
+	// example is the Examples row of an outline scenario.
+	example exampleRow
+	// origin is OriginBackground or OriginScenario, or "" when unknown.
+	origin string
+	// scenarioLine and stepLine locate the step in its feature file; 0
+	// when unknown.
+	scenarioLine int
+	stepLine     int
+	// process and clock are the recording agent's ProcessID and its
+	// vector clock when the step ended, encoded by VectorClock.String.
+	process string
+	clock   string
+	// gap is the time between the end of the scenario's previous step, or
+	// the scenario's start, and the start of this step: hook and framework
+	// time no step accounts for. Zero outside a scenario.
+	gap time.Duration
+}
+
+// startWriter launches the background goroutine that persists records sent
+// on v.writes.
+func (v *VectorClockAgent) startWriter() {
+	v.writes = make(chan []stepRecord, writeBatchSize)
+	v.flushes = make(chan chan error)
+	v.writerDone = make(chan struct{})
+	go v.writeLoop()
+}
+
+func (v *VectorClockAgent) writeLoop() {
+	defer close(v.writerDone)
+
+	ticker := time.NewTicker(writeInterval)
+	defer ticker.Stop()
+
+	// errs collects write failures until the next Flush or Close reports
+	// them.
+	var errs []error
+	batch := make([]stepRecord, 0, writeBatchSize)
+	commit := func() {
+		if len(batch) > 0 {
+			if err := v.writeBatch(batch); err != nil {
+				errs = append(errs, err)
+			}
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case recs, ok := <-v.writes:
+			if !ok {
+				commit()
+				v.writeErr = errors.Join(errs...)
+				return
+			}
+			batch = append(batch, recs...)
+			if len(batch) >= writeBatchSize {
+				commit()
+			}
+		case <-ticker.C:
+			commit()
+		case ack := <-v.flushes:
+			for drained := false; !drained; {
+				select {
+				case recs := <-v.writes:
+					batch = append(batch, recs...)
+				default:
+					drained = true
+				}
+			}
+			commit()
+			ack <- errors.Join(errs...)
+			errs = nil
+		}
+	}
+}
+
+// writeBatch inserts records in a single transaction, or ships them to the
+// collector.
+func (v *VectorClockAgent) writeBatch(batch []stepRecord) error {
+	if v.collector != nil {
+		return v.collector.sendSteps(batch)
+	}
+
+	tx, err := v.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction for %d steps: %w", len(batch), err)
+	}
+
+	stmt := tx.Stmt(v.insertStmt)
+	defer stmt.Close()
+	resourceStmt := tx.Stmt(v.resourceStmt)
+	defer resourceStmt.Close()
+
+	var errs []error
+	for _, rec := range batch {
+		duration := v.rounding.apply(rec.duration)
+		durationMs := duration.Milliseconds()
+		annotations, err := encodeAnnotations(rec.annotations)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("step '%s': %w", rec.stepID, err))
+		}
+		allocBytes, mallocs, gcCycles := rec.memory.columns()
+		stepID, err := v.insertStep(tx, stmt, rec.runID, []interface{}{rec.stepID, rec.scenarioName, rec.stepText, durationMs, duration.Nanoseconds(), rec.runID, rec.status, rec.tags, rec.featureURI, rec.phase, rec.attempt,
+			formatPrecise(rec.startedAt), formatPrecise(rec.endedAt), annotations,
+			sql.NullInt64{Int64: rec.injected.Nanoseconds(), Valid: rec.injected > 0},
+			sql.NullInt64{Int64: int64(rec.sampleRate), Valid: rec.sampleRate > 0},
+			allocBytes, mallocs, gcCycles,
+			sql.NullInt64{Int64: int64(rec.example.line), Valid: rec.example.line > 0}, nullString(rec.example.values), nullString(rec.origin),
+			sql.NullInt64{Int64: int64(rec.scenarioLine), Valid: rec.scenarioLine > 0},
+			sql.NullInt64{Int64: int64(rec.stepLine), Valid: rec.stepLine > 0}, rec.hash(),
+			nullString(rec.process), nullString(rec.clock),
+			sql.NullInt64{Int64: rec.gap.Nanoseconds(), Valid: rec.gap > 0}})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to save step '%s' to DB: %w", rec.stepID, err))
+			continue
+		}
+		if stepID == "" {
+			// Already stored by this run.
+			continue
+		}
+		if v.reservoirSize > 0 && rec.phase == phasePrimary {
+			if err := v.sampleStep(tx, rec.stepText, duration); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		for _, s := range rec.spans {
+			_, err := tx.Exec(`
+				INSERT OR IGNORE INTO step_spans (span_id, run_id, parent_step_id, parent_span_id, name, started_at, ended_at, duration_ns)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			`, s.id, rec.runID, stepID, nullString(s.parentID), s.name,
+				formatPrecise(s.startedAt), formatPrecise(s.startedAt.Add(s.duration)), s.duration.Nanoseconds())
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to save span %s of step '%s': %w", s.name, rec.stepID, err))
+			}
+		}
+		for _, resource := range rec.resources {
+			_, err := resourceStmt.Exec(rec.runID, stepID, rec.scenarioName, resource, formatPrecise(rec.startedAt), formatPrecise(rec.endedAt))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to save resource %s of step '%s': %w", resource, rec.stepID, err))
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit %d steps: %w", len(batch), err)
+	}
+	v.logger.Debug("wrote steps", "steps", len(batch))
+	return errors.Join(errs...)
+}
+
+// enqueue hands records to the writer, which commits them in one
+// transaction.
+func (v *VectorClockAgent) enqueue(records []stepRecord) error {
+	if v.recordFilter != nil {
+		records = v.filterRecords(records)
+	}
+	if v.sampling != nil {
+		records = v.sample(records)
+	}
+	return v.submit(records, true)
+}
+
+// submit hands records to the writer as they are. Tracked records are
+// also kept for the reconciliation in Close, which only concerns the
+// steps this agent recorded itself.
+func (v *VectorClockAgent) submit(records []stepRecord, track bool) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	if v.readOnly {
+		return fmt.Errorf("%w, dropping %d steps", ErrReadOnly, len(records))
+	}
+	v.closeMu.RLock()
+	defer v.closeMu.RUnlock()
+	if v.closed {
+		return fmt.Errorf("%w, dropping %d steps", ErrClosed, len(records))
+	}
+	if track {
+		for _, rec := range records {
+			v.durations.Store(rec.stepID, rec)
+		}
+	}
+	v.writes <- records
+	return nil
+}
+
+// Flush blocks until every step recorded so far has been written and
+// returns the write errors that occurred since the previous Flush.
+func (v *VectorClockAgent) Flush() error {
+	v.closeMu.RLock()
+	defer v.closeMu.RUnlock()
+	if v.closed {
+		return ErrClosed
+	}
+
+	ack := make(chan error)
+	v.flushes <- ack
+	return <-ack
+}
+
+// sync flushes before a read and hands write errors to the error handler,
+// since they concern the recording rather than the query being run.
+func (v *VectorClockAgent) sync() {
+	if err := v.Flush(); err != nil && !errors.Is(err, ErrClosed) {
+		v.handleError(err)
+	}
+}